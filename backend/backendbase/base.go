@@ -19,7 +19,7 @@ type Backend interface {
 
 	Clear(pts [4]Vec)
 	Fill(style *FillStyle, pts []Vec, tf Mat, canOverlap bool)
-	DrawImage(dimg Image, sx, sy, sw, sh float64, pts [4]Vec, alpha float64)
+	DrawImage(style *FillStyle, dimg Image, sx, sy, sw, sh float64, pts [4]Vec)
 	FillImageMask(style *FillStyle, mask *image.Alpha, pts [4]Vec) // pts must have four points
 
 	ClearClip()
@@ -32,10 +32,83 @@ type Backend interface {
 	AsImage() Image // can return nil if not supported
 }
 
+// PathBackend can optionally be implemented by a backend
+// to receive the paths as they were built on the canvas,
+// instead of triangles. This is useful for backends that
+// produce vector graphics. The canvas uses it for filling
+// and stroking paths, everything else including shadows
+// still uses the Backend functions
+type PathBackend interface {
+	FillPath(style *FillStyle, path *Path, tf Mat)
+	StrokePath(style *FillStyle, path *Path, line *LineStyle, tf Mat)
+}
+
+// Path is a path with all curves already converted to
+// lines. The points have to be transformed with the matrix
+// passed along with the path
+type Path struct {
+	SubPaths []SubPath
+	FillRule FillRule
+
+	// Triangles returns the triangles that the canvas would
+	// otherwise have passed to Fill, with the transformation
+	// already applied. It can only be called during the
+	// FillPath or StrokePath call
+	Triangles func() []Vec
+}
+
+// SubPath is a list of points connected by lines
+type SubPath struct {
+	Points []Vec
+	Closed bool
+}
+
+// FillRule determines which parts of a path are inside
+type FillRule uint8
+
+// Fill rule constants
+const (
+	NonZero FillRule = iota
+	EvenOdd
+)
+
+// LineStyle is the line width and other details on how to
+// stroke a path. The sizes are in the coordinate space of
+// the path points
+type LineStyle struct {
+	Width      float64
+	Join       LineJoin
+	Cap        LineCap
+	MiterLimit float64
+	Dash       []float64
+	DashOffset float64
+}
+
+// LineJoin is the shape of the corners of a stroked path
+type LineJoin uint8
+
+// Line join constants
+const (
+	MiterJoin LineJoin = iota
+	BevelJoin
+	RoundJoin
+)
+
+// LineCap is the shape of the ends of a stroked path
+type LineCap uint8
+
+// Line cap constants
+const (
+	ButtCap LineCap = iota
+	SquareCap
+	RoundCap
+)
+
 // FillStyle is the color and other details on how to fill
 type FillStyle struct {
 	Color          color.RGBA
 	Blur           float64
+	Composite      CompositeOperation
 	LinearGradient LinearGradient
 	RadialGradient RadialGradient
 	Gradient       struct {
@@ -45,6 +118,166 @@ type FillStyle struct {
 		RadTo   float64
 	}
 	ImagePattern ImagePattern
+
+	// ImageSmoothing is the filter used for images and
+	// image patterns
+	ImageSmoothing ImageSmoothing
+
+	// Filter is applied to the output of the draw operation
+	// before it is composited onto the target
+	Filter Filter
+}
+
+// CompositeOperation determines how the drawn pixels are
+// combined with the pixels already in the target
+type CompositeOperation uint8
+
+// Composite operation constants. See
+// https://www.w3.org/TR/compositing-1/ for the definitions
+const (
+	SourceOver CompositeOperation = iota
+	SourceIn
+	SourceOut
+	SourceAtop
+	DestinationOver
+	DestinationIn
+	DestinationOut
+	DestinationAtop
+	Lighter
+	Copy
+	Xor
+	Multiply
+	Screen
+	Overlay
+	Darken
+	Lighten
+	ColorDodge
+	ColorBurn
+	HardLight
+	SoftLight
+	Difference
+	Exclusion
+	Hue
+	Saturation
+	Color
+	Luminosity
+)
+
+// ImageSmoothing determines how images and image patterns
+// are filtered when they are scaled. The zero value is the
+// default of the canvas
+type ImageSmoothing uint8
+
+// Image smoothing constants. SmoothingDisabled uses the
+// nearest pixel, the other constants are the qualities for
+// enabled image smoothing
+const (
+	SmoothingLow ImageSmoothing = iota
+	SmoothingMedium
+	SmoothingHigh
+	SmoothingDisabled
+)
+
+// Filter is a list of filter functions that are applied
+// one after the other
+type Filter []FilterFunc
+
+// FilterFunc is a single filter function. Amount is the
+// argument of the function, in pixels for blur, in degrees
+// for hue-rotate and as a ratio for the others. The offset
+// and color are only used by drop-shadow, where Amount is
+// the blur radius
+type FilterFunc struct {
+	Type             FilterType
+	Amount           float64
+	OffsetX, OffsetY float64
+	Color            color.RGBA
+}
+
+// FilterType is the kind of a filter function
+type FilterType uint8
+
+// Filter function constants. See
+// https://www.w3.org/TR/filter-effects-1/#filter-functions
+// for the definitions
+const (
+	FilterBlur FilterType = iota
+	FilterBrightness
+	FilterContrast
+	FilterGrayscale
+	FilterHueRotate
+	FilterInvert
+	FilterOpacity
+	FilterSaturate
+	FilterSepia
+	FilterDropShadow
+)
+
+// ColorMatrix returns the matrix for the filter functions
+// that only change colors. The matrix is in row major order
+// and has four rows of five values, where the fifth column
+// is added as an offset. It operates on non-premultiplied
+// colors with values between 0 and 1. The second return
+// value is false for blur and drop-shadow
+func (f FilterFunc) ColorMatrix() ([20]float64, bool) {
+	a := f.Amount
+	switch f.Type {
+	case FilterBrightness:
+		return [20]float64{
+			a, 0, 0, 0, 0,
+			0, a, 0, 0, 0,
+			0, 0, a, 0, 0,
+			0, 0, 0, 1, 0}, true
+	case FilterContrast:
+		o := 0.5 - 0.5*a
+		return [20]float64{
+			a, 0, 0, 0, o,
+			0, a, 0, 0, o,
+			0, 0, a, 0, o,
+			0, 0, 0, 1, 0}, true
+	case FilterGrayscale:
+		a = 1 - math.Min(a, 1)
+		return [20]float64{
+			0.2126 + 0.7874*a, 0.7152 - 0.7152*a, 0.0722 - 0.0722*a, 0, 0,
+			0.2126 - 0.2126*a, 0.7152 + 0.2848*a, 0.0722 - 0.0722*a, 0, 0,
+			0.2126 - 0.2126*a, 0.7152 - 0.7152*a, 0.0722 + 0.9278*a, 0, 0,
+			0, 0, 0, 1, 0}, true
+	case FilterHueRotate:
+		sn, cs := math.Sincos(a * math.Pi / 180)
+		return [20]float64{
+			0.213 + cs*0.787 - sn*0.213, 0.715 - cs*0.715 - sn*0.715, 0.072 - cs*0.072 + sn*0.928, 0, 0,
+			0.213 - cs*0.213 + sn*0.143, 0.715 + cs*0.285 + sn*0.140, 0.072 - cs*0.072 - sn*0.283, 0, 0,
+			0.213 - cs*0.213 - sn*0.787, 0.715 - cs*0.715 + sn*0.715, 0.072 + cs*0.928 + sn*0.072, 0, 0,
+			0, 0, 0, 1, 0}, true
+	case FilterInvert:
+		a = math.Min(a, 1)
+		return [20]float64{
+			1 - 2*a, 0, 0, 0, a,
+			0, 1 - 2*a, 0, 0, a,
+			0, 0, 1 - 2*a, 0, a,
+			0, 0, 0, 1, 0}, true
+	case FilterOpacity:
+		a = math.Min(a, 1)
+		return [20]float64{
+			1, 0, 0, 0, 0,
+			0, 1, 0, 0, 0,
+			0, 0, 1, 0, 0,
+			0, 0, 0, a, 0}, true
+	case FilterSaturate:
+		return [20]float64{
+			0.213 + 0.787*a, 0.715 - 0.715*a, 0.072 - 0.072*a, 0, 0,
+			0.213 - 0.213*a, 0.715 + 0.285*a, 0.072 - 0.072*a, 0, 0,
+			0.213 - 0.213*a, 0.715 - 0.715*a, 0.072 + 0.928*a, 0, 0,
+			0, 0, 0, 1, 0}, true
+	case FilterSepia:
+		a = 1 - math.Min(a, 1)
+		return [20]float64{
+			0.393 + 0.607*a, 0.769 - 0.769*a, 0.189 - 0.189*a, 0, 0,
+			0.349 - 0.349*a, 0.686 + 0.314*a, 0.168 - 0.168*a, 0, 0,
+			0.272 - 0.272*a, 0.534 - 0.534*a, 0.131 + 0.869*a, 0, 0,
+			0, 0, 0, 1, 0}, true
+	}
+	return [20]float64{}, false
 }
 
 type Gradient []GradientStop
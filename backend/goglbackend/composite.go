@@ -0,0 +1,96 @@
+package goglbackend
+
+import (
+	"unsafe"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/goglbackend/gl"
+)
+
+// activateLayer redirects rendering to an offscreen buffer
+// if the style requires a blur, a filter or a composite
+// operation other than source-over
+func (b *GoGLBackend) activateLayer(style *backendbase.FillStyle) {
+	if style.Blur <= 0 && len(style.Filter) == 0 && style.Composite == backendbase.SourceOver {
+		return
+	}
+
+	b.offscr1.alpha = true
+	b.enableTextureRenderTarget(&b.offscr1)
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+
+	if style.Composite != backendbase.SourceOver || len(style.Filter) > 0 {
+		// keep the layer premultiplied so that it can be
+		// composited and filtered correctly
+		gl.BlendFuncSeparate(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA, gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	}
+}
+
+// drawLayer draws the offscreen buffer that was activated
+// with activateLayer onto the target
+func (b *GoGLBackend) drawLayer(style *backendbase.FillStyle, min, max backendbase.Vec) {
+	if style.Blur > 0 {
+		b.drawBlurred(style.Blur, min, max, style.Composite)
+	} else if len(style.Filter) > 0 {
+		b.drawFiltered(style.Filter, style.Composite)
+	} else if style.Composite != backendbase.SourceOver {
+		b.drawComposite(style.Composite)
+	}
+}
+
+// drawComposite combines the contents of offscr1 with the
+// target using the given operation. Since the operations
+// can also affect areas that offscr1 doesn't cover, the
+// entire target within the clip area is drawn
+func (b *GoGLBackend) drawComposite(op backendbase.CompositeOperation) {
+	compositeOp := int(op)
+
+	b.offscr2.alpha = true
+	b.enableTextureRenderTarget(&b.offscr2)
+	b.disableTextureRenderTarget()
+
+	gl.ActiveTexture(gl.TEXTURE1)
+	gl.BindTexture(gl.TEXTURE_2D, b.offscr2.tex)
+	gl.CopyTexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, int32(b.x), int32(b.y), int32(b.w), int32(b.h))
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.shadowBuf)
+	data := [16]float32{
+		0, 0,
+		0, float32(b.fh),
+		float32(b.fw), float32(b.fh),
+		float32(b.fw), 0,
+		0, 1,
+		0, 0,
+		1, 0,
+		1, 1,
+	}
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, unsafe.Pointer(&data[0]), gl.STREAM_DRAW)
+
+	gl.UseProgram(b.shd.ID)
+	gl.Uniform1i(b.shd.Image, 0)
+	gl.Uniform1i(b.shd.CompositeDest, 1)
+	gl.Uniform1i(b.shd.CompositeOp, int32(compositeOp))
+	gl.Uniform2f(b.shd.CanvasSize, float32(b.fw), float32(b.fh))
+	gl.UniformMatrix3fv(b.shd.Matrix, 1, false, &mat3identity[0])
+	gl.Uniform1i(b.shd.UseAlphaTex, 0)
+	gl.Uniform1i(b.shd.Func, shdFuncComposite)
+
+	gl.Disable(gl.BLEND)
+	gl.StencilFunc(gl.EQUAL, 0, 0xFF)
+
+	gl.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, nil)
+	gl.VertexAttribPointer(b.shd.TexCoord, 2, gl.FLOAT, false, 0, gl.PtrOffset(8*4))
+	gl.EnableVertexAttribArray(b.shd.Vertex)
+	gl.EnableVertexAttribArray(b.shd.TexCoord)
+	gl.DrawArrays(gl.TRIANGLE_FAN, 0, 4)
+	gl.DisableVertexAttribArray(b.shd.Vertex)
+	gl.DisableVertexAttribArray(b.shd.TexCoord)
+
+	gl.StencilFunc(gl.ALWAYS, 0, 0xFF)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+}
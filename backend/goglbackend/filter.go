@@ -0,0 +1,177 @@
+package goglbackend
+
+import (
+	"unsafe"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/goglbackend/gl"
+)
+
+var identityColorMatrix = [20]float64{
+	1, 0, 0, 0, 0,
+	0, 1, 0, 0, 0,
+	0, 0, 1, 0, 0,
+	0, 0, 0, 1, 0,
+}
+
+// drawFiltered applies the filter functions one after the
+// other to the contents of offscr1 and then draws the
+// result onto the target using the given operation
+func (b *GoGLBackend) drawFiltered(filter backendbase.Filter, op backendbase.CompositeOperation) {
+	b.offscr2.alpha = true
+	b.offscr3.alpha = true
+
+	gl.UseProgram(b.shd.ID)
+	gl.Uniform1i(b.shd.Image, 0)
+	gl.Uniform2f(b.shd.CanvasSize, float32(b.fw), float32(b.fh))
+	gl.UniformMatrix3fv(b.shd.Matrix, 1, false, &mat3identity[0])
+	gl.Uniform1i(b.shd.UseAlphaTex, 0)
+
+	gl.Disable(gl.BLEND)
+	gl.StencilFunc(gl.ALWAYS, 0, 0xFF)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.ClearColor(0, 0, 0, 0)
+
+	for _, f := range filter {
+		if m, ok := f.ColorMatrix(); ok {
+			b.enableTextureRenderTarget(&b.offscr2)
+			gl.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+			b.colorMatrixPass(m, 0, 0)
+			b.offscr1, b.offscr2 = b.offscr2, b.offscr1
+			continue
+		}
+		switch f.Type {
+		case backendbase.FilterBlur:
+			if f.Amount > 0 {
+				b.blurOffscreen(&b.offscr1, &b.offscr2, f.Amount*2)
+			}
+		case backendbase.FilterDropShadow:
+			// draw the alpha channel in the shadow color into
+			// offscr3, blur it, and then draw the image over it
+			c := f.Color
+			shadow := [20]float64{
+				0, 0, 0, 0, float64(c.R) / 255,
+				0, 0, 0, 0, float64(c.G) / 255,
+				0, 0, 0, 0, float64(c.B) / 255,
+				0, 0, 0, float64(c.A) / 255, 0,
+			}
+			b.enableTextureRenderTarget(&b.offscr3)
+			gl.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+			b.colorMatrixPass(shadow, f.OffsetX, f.OffsetY)
+			if f.Amount > 0 {
+				b.blurOffscreen(&b.offscr3, &b.offscr2, f.Amount*2)
+			}
+			b.enableTextureRenderTarget(&b.offscr3)
+			gl.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+			gl.Enable(gl.BLEND)
+			gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+			b.colorMatrixPass(identityColorMatrix, 0, 0)
+			gl.Disable(gl.BLEND)
+			b.offscr1, b.offscr3 = b.offscr3, b.offscr1
+		}
+	}
+
+	gl.Enable(gl.BLEND)
+	if op == backendbase.SourceOver {
+		b.disableTextureRenderTarget()
+		gl.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+		gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+		gl.StencilFunc(gl.EQUAL, 0, 0xFF)
+		b.colorMatrixPass(identityColorMatrix, 0, 0)
+		gl.StencilFunc(gl.ALWAYS, 0, 0xFF)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	} else {
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		b.drawComposite(op)
+	}
+}
+
+// colorMatrixPass draws the bound texture over the entire
+// render target with the color matrix applied. The matrix
+// is in the row major form of backendbase.FilterFunc.
+// The image is moved by the offset in pixels
+func (b *GoGLBackend) colorMatrixPass(m [20]float64, dx, dy float64) {
+	tx := float32(dx / b.fw)
+	ty := float32(dy / b.fh)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.shadowBuf)
+	data := [16]float32{
+		0, 0,
+		0, float32(b.fh),
+		float32(b.fw), float32(b.fh),
+		float32(b.fw), 0,
+		-tx, 1 + ty,
+		-tx, ty,
+		1 - tx, ty,
+		1 - tx, 1 + ty,
+	}
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, unsafe.Pointer(&data[0]), gl.STREAM_DRAW)
+
+	var m4 [16]float32
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			m4[col*4+row] = float32(m[row*5+col])
+		}
+	}
+
+	gl.Uniform1i(b.shd.Func, shdFuncColorMatrix)
+	gl.UniformMatrix4fv(b.shd.ColorMatrix, 1, false, &m4[0])
+	gl.Uniform4f(b.shd.ColorOffset, float32(m[4]), float32(m[9]), float32(m[14]), float32(m[19]))
+
+	gl.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, nil)
+	gl.VertexAttribPointer(b.shd.TexCoord, 2, gl.FLOAT, false, 0, gl.PtrOffset(8*4))
+	gl.EnableVertexAttribArray(b.shd.Vertex)
+	gl.EnableVertexAttribArray(b.shd.TexCoord)
+	gl.DrawArrays(gl.TRIANGLE_FAN, 0, 4)
+	gl.DisableVertexAttribArray(b.shd.Vertex)
+	gl.DisableVertexAttribArray(b.shd.TexCoord)
+}
+
+// blurOffscreen blurs the contents of the offscreen buffer
+// with the same box blur passes as drawBlurred, using tmp
+// for the intermediate results
+func (b *GoGLBackend) blurOffscreen(img, tmp *offscreenBuffer, size float64) {
+	sizea, sizeb, sizec := boxSizes(size)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.shadowBuf)
+	data := [16]float32{
+		0, 0,
+		0, float32(b.fh),
+		float32(b.fw), float32(b.fh),
+		float32(b.fw), 0,
+		0, 1,
+		0, 0,
+		1, 0,
+		1, 1,
+	}
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, unsafe.Pointer(&data[0]), gl.STREAM_DRAW)
+
+	gl.Uniform1i(b.shd.Func, shdFuncBoxBlur)
+
+	gl.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, nil)
+	gl.VertexAttribPointer(b.shd.TexCoord, 2, gl.FLOAT, false, 0, gl.PtrOffset(8*4))
+	gl.EnableVertexAttribArray(b.shd.Vertex)
+	gl.EnableVertexAttribArray(b.shd.TexCoord)
+
+	b.enableTextureRenderTarget(tmp)
+	gl.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.box3(sizea, 0, false)
+	b.enableTextureRenderTarget(img)
+	gl.BindTexture(gl.TEXTURE_2D, tmp.tex)
+	b.box3(sizeb, -0.5, false)
+	b.enableTextureRenderTarget(tmp)
+	gl.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.box3(sizec, 0, false)
+	b.enableTextureRenderTarget(img)
+	gl.BindTexture(gl.TEXTURE_2D, tmp.tex)
+	b.box3(sizea, 0, true)
+	b.enableTextureRenderTarget(tmp)
+	gl.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.box3(sizeb, -0.5, true)
+	b.enableTextureRenderTarget(img)
+	gl.BindTexture(gl.TEXTURE_2D, tmp.tex)
+	b.box3(sizec, 0, true)
+
+	gl.DisableVertexAttribArray(b.shd.Vertex)
+	gl.DisableVertexAttribArray(b.shd.TexCoord)
+}
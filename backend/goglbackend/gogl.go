@@ -22,6 +22,7 @@ type GLContext struct {
 
 	offscr1 offscreenBuffer
 	offscr2 offscreenBuffer
+	offscr3 offscreenBuffer
 
 	imageBufTex uint32
 	imageBuf    []byte
@@ -303,6 +304,7 @@ func (b *GoGLBackend) useShader(style *backendbase.FillStyle, tf [9]float32, use
 		img := ipd.Image.(*Image)
 		gl.ActiveTexture(gl.TEXTURE0)
 		gl.BindTexture(gl.TEXTURE_2D, img.tex)
+		b.setImageFilter(img, style.ImageSmoothing)
 		gl.Uniform2f(b.shd.ImageSize, float32(img.w), float32(img.h))
 		gl.Uniform1i(b.shd.Image, 0)
 		var f32mat [9]float32
@@ -15,6 +15,11 @@ type Image struct {
 	w, h int
 	tex  uint32
 	flip bool
+
+	// mipmaps is set for loaded images, and smoothing is
+	// the filter that the texture is currently set up for
+	mipmaps   bool
+	smoothing backendbase.ImageSmoothing
 }
 
 func (b *GoGLBackend) LoadImage(src image.Image) (backendbase.Image, error) {
@@ -62,9 +67,9 @@ func loadImage(src image.Image, tex uint32) (*Image, error) {
 }
 
 func loadImageRGBA(src *image.RGBA, tex uint32) (*Image, error) {
-	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy()}
+	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy(), mipmaps: true}
 
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
@@ -93,8 +98,8 @@ func loadImageRGBA(src *image.RGBA, tex uint32) (*Image, error) {
 }
 
 func loadImageConverted(src image.Image, tex uint32) (*Image, error) {
-	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy()}
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy(), mipmaps: true}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
@@ -120,6 +125,31 @@ func loadImageConverted(src image.Image, tex uint32) (*Image, error) {
 	return img, nil
 }
 
+// setImageFilter sets the filtering of the image texture,
+// which must already be bound, for the image smoothing
+// setting. SmoothingLow uses the closest mipmap level,
+// while SmoothingMedium and SmoothingHigh blend the two
+// levels around the size. Textures only support nearest and
+// linear filtering, so SmoothingHigh is the same as
+// SmoothingMedium. Images of offscreen canvases have no
+// mipmaps and always use nearest filtering
+func (b *GoGLBackend) setImageFilter(img *Image, smoothing backendbase.ImageSmoothing) {
+	if !img.mipmaps || smoothing == img.smoothing {
+		return
+	}
+	if smoothing == backendbase.SmoothingDisabled {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	} else if smoothing == backendbase.SmoothingLow {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	} else {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	}
+	img.smoothing = smoothing
+}
+
 // Width returns the width of the image
 func (img *Image) Width() int { return img.w }
 
@@ -152,9 +182,11 @@ func (img *Image) Replace(src image.Image) error {
 	return nil
 }
 
-func (b *GoGLBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec, alpha float64) {
+func (b *GoGLBackend) DrawImage(style *backendbase.FillStyle, dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec) {
 	b.activate()
 
+	b.activateLayer(style)
+
 	img := dimg.(*Image)
 
 	sx /= float64(img.w)
@@ -186,12 +218,13 @@ func (b *GoGLBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float64,
 
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.setImageFilter(img, style.ImageSmoothing)
 
 	gl.UseProgram(b.shd.ID)
 	gl.Uniform1i(b.shd.Image, 0)
 	gl.Uniform2f(b.shd.CanvasSize, float32(b.fw), float32(b.fh))
 	gl.UniformMatrix3fv(b.shd.Matrix, 1, false, &mat3identity[0])
-	gl.Uniform1f(b.shd.GlobalAlpha, float32(alpha))
+	gl.Uniform1f(b.shd.GlobalAlpha, float32(style.Color.A)/255)
 	gl.Uniform1i(b.shd.UseAlphaTex, 0)
 	gl.Uniform1i(b.shd.Func, shdFuncImage)
 	gl.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, nil)
@@ -203,6 +236,9 @@ func (b *GoGLBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float64,
 	gl.DisableVertexAttribArray(b.shd.TexCoord)
 
 	gl.StencilFunc(gl.ALWAYS, 0, 0xFF)
+
+	min, max := extent(pts[:])
+	b.drawLayer(style, min, max)
 }
 
 type ImagePattern struct {
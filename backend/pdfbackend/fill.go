@@ -0,0 +1,286 @@
+package pdfbackend
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+func (b *PDFBackend) Fill(style *backendbase.FillStyle, pts []backendbase.Vec, tf backendbase.Mat, canOverlap bool) {
+	b.raster.Fill(b.rasterStyle(style), pts, tf, canOverlap)
+
+	if style.Blur > 0 || len(style.Filter) > 0 {
+		b.drawRasterized(style, func(sb *softwarebackend.SoftwareBackend, stl *backendbase.FillStyle) {
+			sb.Fill(stl, pts, tf, canOverlap)
+		})
+		return
+	}
+
+	if tf != backendbase.MatIdentity {
+		tfpts := make([]backendbase.Vec, len(pts))
+		for i, pt := range pts {
+			tfpts[i] = pt.MulMat(tf)
+		}
+		pts = tfpts
+	}
+
+	d := trianglesPath(pts)
+	if d == "" {
+		return
+	}
+	b.paintPath(style, d, "f", "", 0)
+}
+
+func (b *PDFBackend) FillImageMask(style *backendbase.FillStyle, mask *image.Alpha, pts [4]backendbase.Vec) {
+	b.raster.FillImageMask(b.rasterStyle(style), mask, pts)
+
+	if style.Blur > 0 || len(style.Filter) > 0 {
+		b.drawRasterized(style, func(sb *softwarebackend.SoftwareBackend, stl *backendbase.FillStyle) {
+			if stl.Blur == 0 {
+				sb.FillImageMask(stl, mask, pts)
+				return
+			}
+			// the software backend doesn't blur image masks,
+			// so the mask is painted with the style first and
+			// the result is then filled as an image pattern
+			paint := *stl
+			paint.Blur = 0
+			paint.Filter = nil
+			sb.FillImageMask(&paint, mask, pts)
+			painted := image.NewRGBA(sb.Image.Rect)
+			copy(painted.Pix, sb.Image.Pix)
+			for i := range sb.Image.Pix {
+				sb.Image.Pix[i] = 0
+			}
+			img, err := sb.LoadImage(painted)
+			if err != nil {
+				return
+			}
+			defer img.Delete()
+			ip := sb.LoadImagePattern(backendbase.ImagePatternData{
+				Image:     img,
+				Transform: [9]float64{1, 0, 0, 0, 1, 0, 0, 0, 1},
+				Repeat:    backendbase.NoRepeat,
+			})
+			defer ip.Delete()
+			stl.LinearGradient = nil
+			stl.RadialGradient = nil
+			stl.ImagePattern = ip
+			stl.ImageSmoothing = backendbase.SmoothingDisabled
+			quad := rectQuad(0, 0, float64(b.w), float64(b.h))
+			sb.Fill(stl, quad[:], backendbase.MatIdentity, false)
+		})
+		return
+	}
+
+	// the mask is used as a luminosity soft mask, so the
+	// alpha values are converted to a grayscale image
+	bounds := mask.Bounds()
+	gray := &image.Gray{Pix: mask.Pix, Stride: mask.Stride, Rect: bounds}
+	maskName := "M0"
+	form := b.newObj()
+	b.writeObj(form, fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 %d %d] /Group << /S /Transparency /CS /DeviceGray >> /Resources << /XObject << /%s %d 0 R >> >>",
+		b.w, b.h, maskName, b.imageObj(gray)),
+		[]byte(fmt.Sprintf("q\n%s cm\n/%s Do\nQ\n", matrix(imageMatrix(pts)), maskName)))
+
+	b.paintPath(style, polygonPath(pts[:]), "f", "", form)
+}
+
+// rasterStyle returns a copy of the style that refers to
+// the gradients and patterns of the software backend
+func (b *PDFBackend) rasterStyle(style *backendbase.FillStyle) *backendbase.FillStyle {
+	stl := *style
+	if lg, ok := style.LinearGradient.(*LinearGradient); ok {
+		stl.LinearGradient = lg.raster
+	}
+	if rg, ok := style.RadialGradient.(*RadialGradient); ok {
+		stl.RadialGradient = rg.raster
+	}
+	if ip, ok := style.ImagePattern.(*ImagePattern); ok {
+		stl.ImagePattern = ip.raster
+	}
+	return &stl
+}
+
+// paintPath paints the path d with the style. op is the
+// path painting operator, which decides whether the path
+// is filled or stroked, and prefix is written right before
+// the path, for example to set the line style. If smask is
+// not zero, it is the object number of a form that is used
+// as a soft mask
+func (b *PDFBackend) paintPath(style *backendbase.FillStyle, d, op, prefix string, smask int) {
+	if style.Color.A == 0 {
+		return
+	}
+
+	b.content.WriteString("q\n")
+
+	var pat int
+	var clip string
+	if lg, ok := style.LinearGradient.(*LinearGradient); ok {
+		coords := fmt.Sprintf("[%s %s %s %s]",
+			num(style.Gradient.X0), num(style.Gradient.Y0),
+			num(style.Gradient.X1), num(style.Gradient.Y1))
+		var alphaMask int
+		pat, alphaMask = b.shadingPattern(&lg.gradient, 2, coords)
+		if smask == 0 {
+			smask = alphaMask
+		}
+	} else if rg, ok := style.RadialGradient.(*RadialGradient); ok {
+		coords := fmt.Sprintf("[%s %s %s %s %s %s]",
+			num(style.Gradient.X0), num(style.Gradient.Y0), num(style.Gradient.RadFrom),
+			num(style.Gradient.X1), num(style.Gradient.Y1), num(style.Gradient.RadTo))
+		var alphaMask int
+		pat, alphaMask = b.shadingPattern(&rg.gradient, 3, coords)
+		if smask == 0 {
+			smask = alphaMask
+		}
+	} else if ip, ok := style.ImagePattern.(*ImagePattern); ok {
+		pat, clip = b.pattern(ip)
+	}
+
+	b.setExtGState(style, smask)
+	if clip != "" {
+		fmt.Fprintf(&b.content, "%sW n\n", clip)
+	}
+	b.content.WriteString(prefix)
+
+	stroke := op == "S"
+	if pat != 0 {
+		if stroke {
+			fmt.Fprintf(&b.content, "/Pattern CS /%s SCN\n", b.usePattern(pat))
+		} else {
+			fmt.Fprintf(&b.content, "/Pattern cs /%s scn\n", b.usePattern(pat))
+		}
+	} else {
+		c := style.Color
+		colorOp := "rg"
+		if stroke {
+			colorOp = "RG"
+		}
+		fmt.Fprintf(&b.content, "%s %s %s %s\n", num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255), colorOp)
+	}
+	fmt.Fprintf(&b.content, "%s%s\nQ\n", d, op)
+}
+
+// setExtGState sets the alpha, blend mode and soft mask
+// for the following drawing operations
+func (b *PDFBackend) setExtGState(style *backendbase.FillStyle, smask int) {
+	key := extGStateKey{alpha: style.Color.A, blend: blendMode(style.Composite)}
+	if key.alpha == 255 && key.blend == "" && smask == 0 {
+		return
+	}
+
+	obj, ok := b.extGStates[key]
+	if !ok || smask != 0 {
+		dict := fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s", num(float64(key.alpha)/255), num(float64(key.alpha)/255))
+		if key.blend != "" {
+			dict += " /BM /" + key.blend
+		}
+		if smask != 0 {
+			dict += fmt.Sprintf(" /SMask << /Type /Mask /S /Luminosity /G %d 0 R >>", smask)
+		}
+		dict += " >>"
+		obj = b.newObj()
+		b.writeObj(obj, dict, nil)
+		if smask == 0 {
+			b.extGStates[key] = obj
+		}
+	}
+
+	name := fmt.Sprintf("GS%d", obj)
+	b.res.extGStates[name] = obj
+	fmt.Fprintf(&b.content, "/%s gs\n", name)
+}
+
+func (b *PDFBackend) useXObject(obj int) string {
+	name := fmt.Sprintf("Im%d", obj)
+	b.res.xObjects[name] = obj
+	return name
+}
+
+func (b *PDFBackend) usePattern(obj int) string {
+	name := fmt.Sprintf("P%d", obj)
+	b.res.patterns[name] = obj
+	return name
+}
+
+// drawRasterized renders the fill with a software backend
+// and embeds the result as an image. It is used for blurs
+// and filters, which PDF doesn't have
+func (b *PDFBackend) drawRasterized(style *backendbase.FillStyle, fn func(sb *softwarebackend.SoftwareBackend, stl *backendbase.FillStyle)) {
+	if b.blur == nil {
+		b.blur = softwarebackend.New(b.w, b.h)
+	} else {
+		pix := b.blur.Image.Pix
+		for i := range pix {
+			pix[i] = 0
+		}
+	}
+
+	stl := b.rasterStyle(style)
+	stl.Composite = backendbase.SourceOver
+	fn(b.blur, stl)
+
+	bounds := image.Rectangle{}
+	img := b.blur.Image
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if img.Pix[y*img.Stride+x*4+3] != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if bounds.Empty() {
+		return
+	}
+
+	pts := rectQuad(float64(bounds.Min.X), float64(bounds.Min.Y), float64(bounds.Dx()), float64(bounds.Dy()))
+	name := b.useXObject(b.imageObj(img.SubImage(bounds)))
+
+	b.content.WriteString("q\n")
+	b.setExtGState(&backendbase.FillStyle{Color: color.RGBA{A: 255}, Composite: style.Composite}, 0)
+	fmt.Fprintf(&b.content, "%s cm\n/%s Do\nQ\n", matrix(imageMatrix(pts)), name)
+}
+
+// blendMode returns the PDF blend mode name for the
+// operation. The Porter-Duff operations are not supported
+// by PDF and are drawn like source-over
+func blendMode(op backendbase.CompositeOperation) string {
+	switch op {
+	case backendbase.Multiply:
+		return "Multiply"
+	case backendbase.Screen:
+		return "Screen"
+	case backendbase.Overlay:
+		return "Overlay"
+	case backendbase.Darken:
+		return "Darken"
+	case backendbase.Lighten:
+		return "Lighten"
+	case backendbase.ColorDodge:
+		return "ColorDodge"
+	case backendbase.ColorBurn:
+		return "ColorBurn"
+	case backendbase.HardLight:
+		return "HardLight"
+	case backendbase.SoftLight:
+		return "SoftLight"
+	case backendbase.Difference:
+		return "Difference"
+	case backendbase.Exclusion:
+		return "Exclusion"
+	case backendbase.Hue:
+		return "Hue"
+	case backendbase.Saturation:
+		return "Saturation"
+	case backendbase.Color:
+		return "Color"
+	case backendbase.Luminosity:
+		return "Luminosity"
+	}
+	return ""
+}
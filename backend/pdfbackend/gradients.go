@@ -0,0 +1,131 @@
+package pdfbackend
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// LinearGradient is a linear gradient for the PDF backend
+type LinearGradient struct {
+	gradient
+}
+
+// RadialGradient is a radial gradient for the PDF backend
+type RadialGradient struct {
+	gradient
+}
+
+// gradient holds the gradient stops. They are written as
+// PDF functions once and then used by the shadings with
+// the actual coordinates
+type gradient struct {
+	data    backendbase.Gradient
+	fn      int
+	alphaFn int
+	raster  interface {
+		Delete()
+		Replace(data backendbase.Gradient)
+	}
+}
+
+func (b *PDFBackend) LoadLinearGradient(data backendbase.Gradient) backendbase.LinearGradient {
+	return &LinearGradient{gradient{data: data, raster: b.raster.LoadLinearGradient(data)}}
+}
+
+func (b *PDFBackend) LoadRadialGradient(data backendbase.Gradient) backendbase.RadialGradient {
+	return &RadialGradient{gradient{data: data, raster: b.raster.LoadRadialGradient(data)}}
+}
+
+func (g *gradient) Delete() {
+	g.raster.Delete()
+}
+
+func (g *gradient) Replace(data backendbase.Gradient) {
+	g.data = data
+	g.fn = 0
+	g.alphaFn = 0
+	g.raster.Replace(data)
+}
+
+// shadingPattern writes a shading of the given type with
+// the coordinates and returns the object number of a
+// pattern that paints it. If any of the gradient stops are
+// transparent, the object number of a form that can be used
+// as a soft mask is returned as well
+func (b *PDFBackend) shadingPattern(g *gradient, shadingType int, coords string) (pat, smask int) {
+	if g.fn == 0 {
+		g.fn = b.gradientFunction(g.data, false)
+		for _, stop := range g.data {
+			if stop.Color.A < 255 {
+				g.alphaFn = b.gradientFunction(g.data, true)
+				break
+			}
+		}
+	}
+
+	sh := b.newObj()
+	b.writeObj(sh, fmt.Sprintf("<< /ShadingType %d /ColorSpace /DeviceRGB /Coords %s /Function %d 0 R /Extend [true true] >>",
+		shadingType, coords, g.fn), nil)
+
+	// like image patterns, the pattern matrix maps to the
+	// unflipped default coordinate space of the page
+	pat = b.newObj()
+	b.writeObj(pat, fmt.Sprintf("<< /Type /Pattern /PatternType 2 /Shading %d 0 R /Matrix [1 0 0 -1 0 %d] >>", sh, b.h), nil)
+
+	if g.alphaFn == 0 {
+		return pat, 0
+	}
+
+	alphaSh := b.newObj()
+	b.writeObj(alphaSh, fmt.Sprintf("<< /ShadingType %d /ColorSpace /DeviceGray /Coords %s /Function %d 0 R /Extend [true true] >>",
+		shadingType, coords, g.alphaFn), nil)
+	smask = b.newObj()
+	b.writeObj(smask, fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 %d %d] /Group << /S /Transparency /CS /DeviceGray >> /Resources << /Shading << /Sh0 %d 0 R >> >>",
+		b.w, b.h, alphaSh), []byte("/Sh0 sh\n"))
+	return pat, smask
+}
+
+// gradientFunction writes a stitching function that
+// interpolates between the gradient stops, either the
+// colors or only the alpha values
+func (b *PDFBackend) gradientFunction(data backendbase.Gradient, alpha bool) int {
+	stops := make(backendbase.Gradient, 0, len(data)+2)
+	if len(data) == 0 {
+		stops = append(stops, backendbase.GradientStop{})
+	} else if data[0].Pos > 0 {
+		stops = append(stops, backendbase.GradientStop{Pos: 0, Color: data[0].Color})
+	}
+	stops = append(stops, data...)
+	last := stops[len(stops)-1]
+	if last.Pos < 1 {
+		stops = append(stops, backendbase.GradientStop{Pos: 1, Color: last.Color})
+	}
+
+	values := func(stop backendbase.GradientStop) string {
+		if alpha {
+			return num(float64(stop.Color.A) / 255)
+		}
+		return fmt.Sprintf("%s %s %s", num(float64(stop.Color.R)/255), num(float64(stop.Color.G)/255), num(float64(stop.Color.B)/255))
+	}
+
+	var fns, bounds, encode bytes.Buffer
+	for i := 1; i < len(stops); i++ {
+		fn := b.newObj()
+		b.writeObj(fn, fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%s] /C1 [%s] /N 1 >>", values(stops[i-1]), values(stops[i])), nil)
+		if i > 1 {
+			fns.WriteByte(' ')
+			bounds.WriteString(num(stops[i-1].Pos))
+			bounds.WriteByte(' ')
+			encode.WriteByte(' ')
+		}
+		fmt.Fprintf(&fns, "%d 0 R", fn)
+		encode.WriteString("0 1")
+	}
+
+	obj := b.newObj()
+	b.writeObj(obj, fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		fns.String(), bytes.TrimSpace(bounds.Bytes()), encode.String()), nil)
+	return obj
+}
@@ -0,0 +1,210 @@
+package pdfbackend
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// Image is an image for the PDF backend. It is embedded
+// into the file as an image XObject the first time it is
+// used and then shared by all pages
+type Image struct {
+	b      *PDFBackend
+	src    image.Image
+	w, h   int
+	obj    int
+	raster backendbase.Image
+}
+
+func (b *PDFBackend) LoadImage(src image.Image) (backendbase.Image, error) {
+	raster, err := b.raster.LoadImage(src)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	return &Image{b: b, src: src, w: bounds.Dx(), h: bounds.Dy(), raster: raster}, nil
+}
+
+// Width returns the width of the image
+func (img *Image) Width() int { return img.w }
+
+// Height returns the height of the image
+func (img *Image) Height() int { return img.h }
+
+// Size returns the width and height of the image
+func (img *Image) Size() (int, int) { return img.w, img.h }
+
+// Delete frees the resources of the image. Images that
+// were already used stay in the file
+func (img *Image) Delete() {
+	img.raster.Delete()
+}
+
+// Replace replaces the image with the new one
+func (img *Image) Replace(src image.Image) error {
+	err := img.raster.Replace(src)
+	if err != nil {
+		return err
+	}
+	bounds := src.Bounds()
+	img.src = src
+	img.w, img.h = bounds.Dx(), bounds.Dy()
+	img.obj = 0
+	return nil
+}
+
+// ref returns the object number of the image XObject
+func (img *Image) ref() int {
+	if img.obj == 0 {
+		img.obj = img.b.imageObj(img.src)
+	}
+	return img.obj
+}
+
+// imageObj writes the image as an image XObject and returns
+// the object number. Grayscale images are written in the
+// gray color space, anything else as RGB with the alpha
+// values as a soft mask
+func (b *PDFBackend) imageObj(img image.Image) int {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if gray, ok := img.(*image.Gray); ok {
+		data := make([]byte, 0, w*h)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			off := gray.PixOffset(bounds.Min.X, y)
+			data = append(data, gray.Pix[off:off+w]...)
+		}
+		obj := b.newObj()
+		b.writeObj(obj, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", w, h), data)
+		return obj
+	}
+
+	rgb := make([]byte, 0, w*h*3)
+	alpha := make([]byte, 0, w*h)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A < 255 {
+				opaque = false
+			}
+		}
+	}
+
+	var smask string
+	if !opaque {
+		maskObj := b.newObj()
+		b.writeObj(maskObj, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", w, h), alpha)
+		smask = fmt.Sprintf(" /SMask %d 0 R", maskObj)
+	}
+	obj := b.newObj()
+	b.writeObj(obj, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8%s", w, h, smask), rgb)
+	return obj
+}
+
+func (b *PDFBackend) DrawImage(style *backendbase.FillStyle, dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec) {
+	img := dimg.(*Image)
+
+	b.raster.DrawImage(b.rasterStyle(style), img.raster, sx, sy, sw, sh, pts)
+
+	if style.Color.A == 0 {
+		return
+	}
+
+	if len(style.Filter) > 0 {
+		b.drawRasterized(style, func(sb *softwarebackend.SoftwareBackend, stl *backendbase.FillStyle) {
+			sb.DrawImage(stl, img.raster, sx, sy, sw, sh, pts)
+		})
+		return
+	}
+
+	// map the whole image, of which the source rectangle
+	// is then clipped out
+	m := backendbase.MatTranslate(backendbase.Vec{-sx, -sy}).Mul(quadMatrix(pts, sw, sh))
+	fw, fh := float64(img.w), float64(img.h)
+	var full [4]backendbase.Vec
+	for i, pt := range rectQuad(0, 0, fw, fh) {
+		full[i] = pt.MulMat(m)
+	}
+
+	b.content.WriteString("q\n")
+	b.setExtGState(style, 0)
+	if sx > 0 || sy > 0 || sx+sw < fw || sy+sh < fh {
+		fmt.Fprintf(&b.content, "%sW n\n", polygonPath(pts[:]))
+	}
+	fmt.Fprintf(&b.content, "%s cm\n/%s Do\nQ\n", matrix(imageMatrix(full)), b.useXObject(img.ref()))
+}
+
+// ImagePattern is an image pattern for the PDF backend
+type ImagePattern struct {
+	data   backendbase.ImagePatternData
+	raster backendbase.ImagePattern
+}
+
+func (b *PDFBackend) LoadImagePattern(data backendbase.ImagePatternData) backendbase.ImagePattern {
+	return &ImagePattern{
+		data:   data,
+		raster: b.raster.LoadImagePattern(rasterPatternData(data)),
+	}
+}
+
+// Delete frees the resources of the image pattern
+func (ip *ImagePattern) Delete() {
+	ip.raster.Delete()
+}
+
+// Replace replaces the image pattern data
+func (ip *ImagePattern) Replace(data backendbase.ImagePatternData) {
+	ip.data = data
+	ip.raster.Replace(rasterPatternData(data))
+}
+
+func rasterPatternData(data backendbase.ImagePatternData) backendbase.ImagePatternData {
+	if img, ok := data.Image.(*Image); ok {
+		data.Image = img.raster
+	}
+	return data
+}
+
+// pattern writes a tiling pattern for the image pattern and
+// returns its object number. PDF patterns always repeat in
+// both directions, so for the other repeat modes a clip
+// path is returned that limits the pattern
+func (b *PDFBackend) pattern(ip *ImagePattern) (obj int, clip string) {
+	img := ip.data.Image.(*Image)
+
+	t := ip.data.Transform
+	m := backendbase.Mat{t[0], t[3], t[1], t[4], t[2], t[5]}.Invert()
+
+	// the pattern matrix maps to the default coordinate
+	// space of the page, which is not flipped
+	flip := backendbase.Mat{1, 0, 0, -1, 0, float64(b.h)}
+	obj = b.newObj()
+	b.writeObj(obj, fmt.Sprintf("/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 %d %d] /XStep %d /YStep %d /Matrix [%s] /Resources << /XObject << /Im0 %d 0 R >> >>",
+		img.w, img.h, img.w, img.h, matrix(m.Mul(flip)), img.ref()),
+		[]byte(fmt.Sprintf("q\n%d 0 0 -%d 0 %d cm\n/Im0 Do\nQ\n", img.w, img.h, img.h)))
+
+	if ip.data.Repeat != backendbase.Repeat {
+		const far = 1e7
+		x, y, w, h := 0.0, 0.0, float64(img.w), float64(img.h)
+		if ip.data.Repeat == backendbase.RepeatX {
+			x, w = -far, 2*far
+		} else if ip.data.Repeat == backendbase.RepeatY {
+			y, h = -far, 2*far
+		}
+		var quad [4]backendbase.Vec
+		for i, pt := range rectQuad(x, y, w, h) {
+			quad[i] = pt.MulMat(m)
+		}
+		clip = polygonPath(quad[:])
+	}
+
+	return obj, clip
+}
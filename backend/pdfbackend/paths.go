@@ -0,0 +1,111 @@
+package pdfbackend
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// FillPath fills the path with the PDF fill operators
+func (b *PDFBackend) FillPath(style *backendbase.FillStyle, path *backendbase.Path, tf backendbase.Mat) {
+	tris := path.Triangles()
+	b.raster.Fill(b.rasterStyle(style), tris, backendbase.MatIdentity, false)
+
+	if style.Blur > 0 || len(style.Filter) > 0 {
+		b.drawRasterized(style, func(sb *softwarebackend.SoftwareBackend, stl *backendbase.FillStyle) {
+			sb.Fill(stl, tris, backendbase.MatIdentity, false)
+		})
+		return
+	}
+
+	d := pathData(path.SubPaths, tf, true)
+	if d == "" {
+		return
+	}
+	op := "f"
+	if path.FillRule == backendbase.EvenOdd {
+		op = "f*"
+	}
+	b.paintPath(style, d, op, "", 0)
+}
+
+// StrokePath strokes the path with the PDF stroke operator.
+// The transformation is applied to the graphics state so
+// that the line width is transformed as well
+func (b *PDFBackend) StrokePath(style *backendbase.FillStyle, path *backendbase.Path, line *backendbase.LineStyle, tf backendbase.Mat) {
+	tris := path.Triangles()
+	b.raster.Fill(b.rasterStyle(style), tris, backendbase.MatIdentity, true)
+
+	if style.Blur > 0 || len(style.Filter) > 0 {
+		b.drawRasterized(style, func(sb *softwarebackend.SoftwareBackend, stl *backendbase.FillStyle) {
+			sb.Fill(stl, tris, backendbase.MatIdentity, true)
+		})
+		return
+	}
+
+	if tf[0]*tf[3]-tf[1]*tf[2] == 0 {
+		return
+	}
+	d := pathData(path.SubPaths, backendbase.MatIdentity, false)
+	if d == "" {
+		return
+	}
+
+	var prefix bytes.Buffer
+	if tf != backendbase.MatIdentity {
+		fmt.Fprintf(&prefix, "%s cm\n", matrix(tf))
+	}
+	fmt.Fprintf(&prefix, "%s w\n", num(line.Width))
+	switch line.Join {
+	case backendbase.MiterJoin:
+		fmt.Fprintf(&prefix, "0 j\n%s M\n", num(math.Max(line.MiterLimit, 1)))
+	case backendbase.RoundJoin:
+		prefix.WriteString("1 j\n")
+	case backendbase.BevelJoin:
+		prefix.WriteString("2 j\n")
+	}
+	switch line.Cap {
+	case backendbase.ButtCap:
+		prefix.WriteString("0 J\n")
+	case backendbase.RoundCap:
+		prefix.WriteString("1 J\n")
+	case backendbase.SquareCap:
+		prefix.WriteString("2 J\n")
+	}
+	if len(line.Dash) > 0 {
+		prefix.WriteByte('[')
+		for i, v := range line.Dash {
+			if i > 0 {
+				prefix.WriteByte(' ')
+			}
+			prefix.WriteString(num(v))
+		}
+		fmt.Fprintf(&prefix, "] %s d\n", num(line.DashOffset))
+	}
+
+	b.paintPath(style, d, "S", prefix.String(), 0)
+}
+
+// pathData returns the path construction operators for the
+// subpaths with the matrix applied. If close is true, all
+// subpaths are closed
+func pathData(subPaths []backendbase.SubPath, m backendbase.Mat, close bool) string {
+	var buf bytes.Buffer
+	for _, sp := range subPaths {
+		for i, pt := range sp.Points {
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			pt = pt.MulMat(m)
+			fmt.Fprintf(&buf, "%s %s %s\n", num(pt[0]), num(pt[1]), op)
+		}
+		if close || sp.Closed {
+			buf.WriteString("h\n")
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,411 @@
+package pdfbackend
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// PDFBackend is a canvas backend that writes a PDF file.
+// Every canvas pixel is one PDF point. Call NewPage to
+// start a new page and Close to finish the file. Everything
+// on the current page is also rendered with a software
+// backend so that GetImageData keeps working
+type PDFBackend struct {
+	w, h int
+
+	out     *bufio.Writer
+	offset  int64
+	objects []int64
+	pages   []int
+	err     error
+	closed  bool
+
+	content bytes.Buffer
+	res     resources
+
+	clipped   bool
+	clipPaths bytes.Buffer
+
+	extGStates map[extGStateKey]int
+
+	raster *softwarebackend.SoftwareBackend
+	blur   *softwarebackend.SoftwareBackend
+}
+
+// resources holds the names of the resources used on the
+// current page, mapped to their object numbers
+type resources struct {
+	extGStates map[string]int
+	xObjects   map[string]int
+	patterns   map[string]int
+}
+
+type extGStateKey struct {
+	alpha uint8
+	blend string
+}
+
+const (
+	catalogObj = 1
+	pagesObj   = 2
+)
+
+var errClosed = errors.New("PDF backend has already been closed")
+
+// New returns a new PDF backend that writes to the given
+// writer. w and h are the size of the pages
+func New(out io.Writer, w, h int) *PDFBackend {
+	b := &PDFBackend{
+		w:          w,
+		h:          h,
+		out:        bufio.NewWriter(out),
+		objects:    make([]int64, 3),
+		extGStates: make(map[extGStateKey]int),
+		raster:     softwarebackend.New(w, h),
+	}
+	b.write([]byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"))
+	b.startPage()
+	return b
+}
+
+// Size returns the size of the pages
+func (b *PDFBackend) Size() (int, int) {
+	return b.w, b.h
+}
+
+// NewPage finishes the current page and starts a new empty
+// one
+func (b *PDFBackend) NewPage() error {
+	if b.closed {
+		return errClosed
+	}
+	b.finishPage()
+	b.startPage()
+	b.raster.PutImageData(image.NewRGBA(image.Rect(0, 0, b.w, b.h)), 0, 0)
+	return b.err
+}
+
+// Close finishes the current page and writes the remaining
+// parts of the PDF file. It does not close the underlying
+// writer
+func (b *PDFBackend) Close() error {
+	if b.closed {
+		return errClosed
+	}
+	b.finishPage()
+	b.closed = true
+
+	var kids bytes.Buffer
+	for i, page := range b.pages {
+		if i > 0 {
+			kids.WriteByte(' ')
+		}
+		fmt.Fprintf(&kids, "%d 0 R", page)
+	}
+	b.writeObj(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(b.pages)), nil)
+	b.writeObj(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj), nil)
+
+	xref := b.offset
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(b.objects))
+	for _, offset := range b.objects[1:] {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(b.objects), catalogObj, xref)
+	b.write(buf.Bytes())
+
+	if b.err == nil {
+		b.err = b.out.Flush()
+	}
+	return b.err
+}
+
+// startPage starts a new page. The clip of the canvas
+// still applies to the new page
+func (b *PDFBackend) startPage() {
+	b.resetPage()
+	if b.clipPaths.Len() > 0 {
+		b.restoreClip()
+	}
+}
+
+// resetPage removes everything from the current page
+func (b *PDFBackend) resetPage() {
+	b.content.Reset()
+	b.res = resources{
+		extGStates: make(map[string]int),
+		xObjects:   make(map[string]int),
+		patterns:   make(map[string]int),
+	}
+	b.clipped = false
+	// flip the coordinate system so that the origin is at
+	// the top left like on the canvas
+	fmt.Fprintf(&b.content, "1 0 0 -1 0 %d cm\n", b.h)
+}
+
+func (b *PDFBackend) finishPage() {
+	if b.clipped {
+		b.content.WriteString("Q\n")
+		b.clipped = false
+	}
+
+	contents := b.newObj()
+	b.writeObj(contents, "", b.content.Bytes())
+
+	var res bytes.Buffer
+	res.WriteString("<<")
+	writeResourceDict(&res, "ExtGState", b.res.extGStates)
+	writeResourceDict(&res, "XObject", b.res.xObjects)
+	writeResourceDict(&res, "Pattern", b.res.patterns)
+	res.WriteString(" >>")
+
+	page := b.newObj()
+	b.writeObj(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources %s /Contents %d 0 R >>",
+		pagesObj, b.w, b.h, res.String(), contents), nil)
+	b.pages = append(b.pages, page)
+}
+
+func writeResourceDict(buf *bytes.Buffer, key string, m map[string]int) {
+	if len(m) == 0 {
+		return
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(buf, " /%s <<", key)
+	for _, name := range names {
+		fmt.Fprintf(buf, " /%s %d 0 R", name, m[name])
+	}
+	buf.WriteString(" >>")
+}
+
+func (b *PDFBackend) write(p []byte) {
+	if b.err != nil {
+		return
+	}
+	n, err := b.out.Write(p)
+	b.offset += int64(n)
+	b.err = err
+}
+
+// newObj reserves an object number
+func (b *PDFBackend) newObj() int {
+	b.objects = append(b.objects, 0)
+	return len(b.objects) - 1
+}
+
+// writeObj writes the object with the given number. If
+// stream is not nil, it is compressed and written as the
+// stream of the object, and dict may contain additional
+// entries for the stream dictionary
+func (b *PDFBackend) writeObj(num int, dict string, stream []byte) {
+	b.objects[num] = b.offset
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d 0 obj\n", num)
+	if stream == nil {
+		buf.WriteString(dict)
+		buf.WriteString("\nendobj\n")
+		b.write(buf.Bytes())
+		return
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(stream)
+	zw.Close()
+
+	fmt.Fprintf(&buf, "<< %s /Filter /FlateDecode /Length %d >>\nstream\n", dict, compressed.Len())
+	buf.Write(compressed.Bytes())
+	buf.WriteString("\nendstream\nendobj\n")
+	b.write(buf.Bytes())
+}
+
+// GetImageData returns the pixels of the current page as
+// rendered by the internal software backend
+func (b *PDFBackend) GetImageData(x, y, w, h int) *image.RGBA {
+	return b.raster.GetImageData(x, y, w, h)
+}
+
+func (b *PDFBackend) PutImageData(img *image.RGBA, x, y int) {
+	b.raster.PutImageData(img, x, y)
+
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	pts := rectQuad(float64(x), float64(y), w, h)
+	name := b.useXObject(b.imageObj(img))
+
+	if b.clipped {
+		b.content.WriteString("Q\n")
+		b.clipped = false
+		defer b.restoreClip()
+	}
+	b.clearQuad(pts)
+	fmt.Fprintf(&b.content, "q\n%s cm\n/%s Do\nQ\n", matrix(imageMatrix(pts)), name)
+}
+
+func (b *PDFBackend) CanUseAsImage(b2 backendbase.Backend) bool {
+	return false
+}
+
+func (b *PDFBackend) AsImage() backendbase.Image {
+	return nil
+}
+
+// Clear clears the area. Since PDF pages have no
+// transparency, the area is filled with white, unless the
+// entire page is cleared
+func (b *PDFBackend) Clear(pts [4]backendbase.Vec) {
+	b.raster.Clear(pts)
+	b.clearQuad(pts)
+}
+
+func (b *PDFBackend) clearQuad(pts [4]backendbase.Vec) {
+	if !b.clipped && b.coversAll(pts) {
+		b.resetPage()
+		return
+	}
+	fmt.Fprintf(&b.content, "q\n1 1 1 rg\n%sf\nQ\n", polygonPath(pts[:]))
+}
+
+// coversAll returns true if the quad is an axis aligned
+// rectangle that covers the entire page
+func (b *PDFBackend) coversAll(pts [4]backendbase.Vec) bool {
+	aligned := pts[0][0] == pts[1][0] && pts[2][0] == pts[3][0] && pts[0][1] == pts[3][1] && pts[1][1] == pts[2][1]
+	if !aligned {
+		aligned = pts[0][0] == pts[3][0] && pts[1][0] == pts[2][0] && pts[0][1] == pts[1][1] && pts[2][1] == pts[3][1]
+	}
+	if !aligned {
+		return false
+	}
+	minX, maxX := math.Min(pts[0][0], pts[2][0]), math.Max(pts[0][0], pts[2][0])
+	minY, maxY := math.Min(pts[0][1], pts[2][1]), math.Max(pts[0][1], pts[2][1])
+	return minX <= 0 && minY <= 0 && maxX >= float64(b.w) && maxY >= float64(b.h)
+}
+
+// ClearClip removes the clipping. Clipping in PDF can only
+// be undone by restoring the graphics state, so the clip
+// paths are kept in a saved state that is restored here
+func (b *PDFBackend) ClearClip() {
+	b.raster.ClearClip()
+	if b.clipped {
+		b.content.WriteString("Q\n")
+		b.clipped = false
+	}
+	b.clipPaths.Reset()
+}
+
+func (b *PDFBackend) Clip(pts []backendbase.Vec) {
+	b.raster.Clip(pts)
+	if !b.clipped {
+		b.content.WriteString("q\n")
+		b.clipped = true
+	}
+	d := trianglesPath(pts)
+	if d == "" {
+		d = "0 0 m h\n"
+	}
+	fmt.Fprintf(&b.clipPaths, "%sW n\n", d)
+	fmt.Fprintf(&b.content, "%sW n\n", d)
+}
+
+// restoreClip reapplies the clip paths after the clip had
+// to be removed temporarily
+func (b *PDFBackend) restoreClip() {
+	b.content.WriteString("q\n")
+	b.clipped = true
+	b.content.WriteString(b.clipPaths.String())
+}
+
+func rectQuad(x, y, w, h float64) [4]backendbase.Vec {
+	return [4]backendbase.Vec{{x, y}, {x, y + h}, {x + w, y + h}, {x + w, y}}
+}
+
+// num formats a number with at most four decimals, since
+// PDF does not allow exponents
+func num(v float64) string {
+	v = math.Round(v*10000) / 10000
+	if v == 0 {
+		v = 0 // avoid negative zero
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func matrix(m backendbase.Mat) string {
+	return fmt.Sprintf("%s %s %s %s %s %s", num(m[0]), num(m[1]), num(m[2]), num(m[3]), num(m[4]), num(m[5]))
+}
+
+func polygonPath(pts []backendbase.Vec) string {
+	var buf bytes.Buffer
+	for i, pt := range pts {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&buf, "%s %s %s\n", num(pt[0]), num(pt[1]), op)
+	}
+	buf.WriteString("h\n")
+	return buf.String()
+}
+
+// trianglesPath merges the triangles into a single path.
+// Four points are treated as a quad like in the other
+// backends. All triangles are written with the same
+// orientation so that the nonzero fill rule fills the
+// union of the triangles
+func trianglesPath(pts []backendbase.Vec) string {
+	if len(pts) == 4 {
+		return polygonPath(pts)
+	}
+
+	var buf bytes.Buffer
+	for i := 3; i <= len(pts); i += 3 {
+		p0, p1, p2 := pts[i-3], pts[i-2], pts[i-1]
+		cross := (p1[0]-p0[0])*(p2[1]-p0[1]) - (p1[1]-p0[1])*(p2[0]-p0[0])
+		if cross == 0 {
+			continue
+		} else if cross < 0 {
+			p1, p2 = p2, p1
+		}
+		fmt.Fprintf(&buf, "%s %s m %s %s l %s %s l h\n",
+			num(p0[0]), num(p0[1]),
+			num(p1[0]), num(p1[1]),
+			num(p2[0]), num(p2[1]))
+	}
+	return buf.String()
+}
+
+// quadMatrix returns the transformation that maps the
+// rectangle 0,0,w,h onto the quad, where the first point
+// is the top left, the second the bottom left and the
+// fourth the top right corner
+func quadMatrix(pts [4]backendbase.Vec, w, h float64) backendbase.Mat {
+	return backendbase.Mat{
+		(pts[3][0] - pts[0][0]) / w, (pts[3][1] - pts[0][1]) / w,
+		(pts[1][0] - pts[0][0]) / h, (pts[1][1] - pts[0][1]) / h,
+		pts[0][0], pts[0][1],
+	}
+}
+
+// imageMatrix returns the transformation that maps the PDF
+// image space, which is the unit square with the first row
+// of the image at the top, onto the quad
+func imageMatrix(pts [4]backendbase.Vec) backendbase.Mat {
+	return backendbase.Mat{
+		pts[2][0] - pts[1][0], pts[2][1] - pts[1][1],
+		pts[0][0] - pts[1][0], pts[0][1] - pts[1][1],
+		pts[1][0], pts[1][1],
+	}
+}
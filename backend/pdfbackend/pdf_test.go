@@ -0,0 +1,315 @@
+package pdfbackend_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/pdfbackend"
+)
+
+type pdfObject struct {
+	dict   string
+	stream []byte
+}
+
+var (
+	startxrefRe = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	refRe       = regexp.MustCompile(`(\d+) 0 R`)
+	lengthRe    = regexp.MustCompile(`/Length (\d+)`)
+)
+
+// parsePDF reads the objects of the file through its xref
+// table and returns them along with the root object number
+func parsePDF(t *testing.T, data []byte) (map[int]pdfObject, int) {
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatal("missing PDF header")
+	}
+	m := startxrefRe.FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("no xref table at offset %d", xref)
+	}
+
+	lines := strings.Split(string(data[xref:]), "\n")
+	count, err := strconv.Atoi(strings.Fields(lines[1])[1])
+	if err != nil {
+		t.Fatalf("invalid xref subsection header %q", lines[1])
+	}
+	if lines[2] != "0000000000 65535 f " {
+		t.Fatalf("invalid first xref entry %q", lines[2])
+	}
+	if lines[count+2] != "trailer" {
+		t.Fatalf("expected trailer after %d entries, got %q", count, lines[count+2])
+	}
+	m = regexp.MustCompile(`/Size (\d+) /Root (\d+) 0 R`).FindSubmatch([]byte(lines[count+3]))
+	if m == nil || string(m[1]) != strconv.Itoa(count) {
+		t.Fatalf("invalid trailer %q", lines[count+3])
+	}
+	root, _ := strconv.Atoi(string(m[2]))
+
+	objects := make(map[int]pdfObject)
+	for num := 1; num < count; num++ {
+		entry := lines[num+2]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("invalid xref entry %q", entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		header := fmt.Sprintf("%d 0 obj\n", num)
+		if !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Fatalf("object %d is not at offset %d", num, offset)
+		}
+		rest := data[offset+len(header):]
+
+		var obj pdfObject
+		if idx := bytes.Index(rest, []byte(">>\nstream\n")); idx >= 0 && idx < bytes.Index(rest, []byte("endobj")) {
+			obj.dict = string(rest[:idx+2])
+			l := lengthRe.FindStringSubmatch(obj.dict)
+			length, _ := strconv.Atoi(l[1])
+			compressed := rest[idx+10 : idx+10+length]
+			if !bytes.HasPrefix(rest[idx+10+length:], []byte("\nendstream\nendobj\n")) {
+				t.Fatalf("stream of object %d has the wrong length", num)
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatalf("failed to decompress object %d: %v", num, err)
+			}
+			obj.stream, err = ioutil.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("failed to decompress object %d: %v", num, err)
+			}
+		} else {
+			end := bytes.Index(rest, []byte("\nendobj\n"))
+			if end < 0 {
+				t.Fatalf("object %d has no end", num)
+			}
+			obj.dict = string(rest[:end])
+		}
+		objects[num] = obj
+	}
+	return objects, root
+}
+
+func ref(t *testing.T, dict, key string) int {
+	m := regexp.MustCompile("/" + key + ` (\d+) 0 R`).FindStringSubmatch(dict)
+	if m == nil {
+		t.Fatalf("no %s reference in %q", key, dict)
+	}
+	num, _ := strconv.Atoi(m[1])
+	return num
+}
+
+// pageContents returns the decompressed content streams
+// of all pages
+func pageContents(t *testing.T, data []byte) []string {
+	objects, root := parsePDF(t, data)
+	pages := objects[ref(t, objects[root].dict, "Pages")]
+	if !strings.Contains(pages.dict, "/Type /Pages") {
+		t.Fatalf("invalid pages object %q", pages.dict)
+	}
+	kids := regexp.MustCompile(`/Kids \[([^\]]*)\]`).FindStringSubmatch(pages.dict)
+	var contents []string
+	for _, m := range refRe.FindAllStringSubmatch(kids[1], -1) {
+		num, _ := strconv.Atoi(m[1])
+		page := objects[num]
+		if !strings.Contains(page.dict, "/Type /Page ") || !strings.Contains(page.dict, "/MediaBox [0 0 100 50]") {
+			t.Fatalf("invalid page object %q", page.dict)
+		}
+		contents = append(contents, string(objects[ref(t, page.dict, "Contents")].stream))
+	}
+	if !strings.Contains(pages.dict, fmt.Sprintf("/Count %d", len(contents))) {
+		t.Fatalf("page count doesn't match the kids in %q", pages.dict)
+	}
+	return contents
+}
+
+func TestPages(t *testing.T) {
+	var buf bytes.Buffer
+	backend := pdfbackend.New(&buf, 100, 50)
+	cv := canvas.New(backend)
+	colors := []string{"#F00", "#0F0", "#00F"}
+	for i, c := range colors {
+		if i > 0 {
+			err := backend.NewPage()
+			if err != nil {
+				t.Fatalf("failed to start page %d: %v", i, err)
+			}
+		}
+		cv.SetFillStyle(c)
+		cv.FillRect(10, 10, 20, 20)
+	}
+	err := backend.Close()
+	if err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	contents := pageContents(t, buf.Bytes())
+	if len(contents) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(contents))
+	}
+	for i, expected := range []string{"1 0 0 rg", "0 1 0 rg", "0 0 1 rg"} {
+		if !strings.HasPrefix(contents[i], "1 0 0 -1 0 50 cm\n") {
+			t.Errorf("page %d doesn't flip the coordinate system", i)
+		}
+		if strings.Count(contents[i], " rg\n") != 1 || !strings.Contains(contents[i], expected+"\n10 10 m\n10 30 l\n30 30 l\n30 10 l\nh\nf\n") {
+			t.Errorf("unexpected content of page %d:\n%s", i, contents[i])
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	backend := pdfbackend.New(ioutil.Discard, 100, 50)
+	err := backend.Close()
+	if err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if backend.NewPage() == nil {
+		t.Error("NewPage after Close didn't return an error")
+	}
+	if backend.Close() == nil {
+		t.Error("Close after Close didn't return an error")
+	}
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestWriteError(t *testing.T) {
+	backend := pdfbackend.New(failingWriter{}, 100, 50)
+	cv := canvas.New(backend)
+	cv.SetFillStyle("#F00")
+	cv.FillRect(10, 10, 20, 20)
+	err := backend.Close()
+	if err != errWrite {
+		t.Errorf("expected the write error from Close, got %v", err)
+	}
+
+	// the error stays once the buffer had to be flushed
+	backend = pdfbackend.New(failingWriter{}, 100, 50)
+	cv = canvas.New(backend)
+	err = nil
+	for i := 0; i < 1000 && err == nil; i++ {
+		cv.FillRect(float64(i%80), 10, 20, 20)
+		err = backend.NewPage()
+	}
+	if err != errWrite {
+		t.Errorf("expected the write error from NewPage, got %v", err)
+	}
+}
+
+func TestCompositeFallback(t *testing.T) {
+	var buf bytes.Buffer
+	backend := pdfbackend.New(&buf, 100, 50)
+	cv := canvas.New(backend)
+	cv.SetFillStyle("#F00")
+	cv.FillRect(0, 0, 50, 50)
+	cv.SetGlobalCompositeOperation(canvas.CompositeXor)
+	cv.FillRect(25, 0, 50, 50)
+	backend.NewPage()
+	cv.SetGlobalCompositeOperation(canvas.CompositeMultiply)
+	cv.FillRect(25, 0, 50, 50)
+	backend.Close()
+
+	contents := pageContents(t, buf.Bytes())
+	if len(contents) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(contents))
+	}
+
+	// xor is drawn like source-over, without a graphics state
+	if strings.Contains(contents[0], " gs\n") || strings.Count(contents[0], "1 0 0 rg\n") != 2 {
+		t.Errorf("xor wasn't drawn like source-over:\n%s", contents[0])
+	}
+
+	objects, _ := parsePDF(t, buf.Bytes())
+	m := regexp.MustCompile(`/GS(\d+) gs\n`).FindStringSubmatch(contents[1])
+	if m == nil {
+		t.Fatalf("multiply doesn't set a graphics state:\n%s", contents[1])
+	}
+	num, _ := strconv.Atoi(m[1])
+	if gs := objects[num].dict; !strings.Contains(gs, "/Type /ExtGState") || !strings.Contains(gs, "/BM /Multiply") {
+		t.Errorf("unexpected graphics state %q", gs)
+	}
+}
+
+func TestRasterizedImageMask(t *testing.T) {
+	// the mask has a gap in the middle
+	mask := image.NewAlpha(image.Rect(0, 0, 80, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 80; x++ {
+			if x < 20 || x >= 60 {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		blur   float64
+		filter backendbase.Filter
+	}{
+		{"blur", 2, nil},
+		{"filter", 0, backendbase.Filter{{Type: backendbase.FilterBlur, Amount: 2}}},
+	} {
+		var buf bytes.Buffer
+		backend := pdfbackend.New(&buf, 100, 50)
+		style := backendbase.FillStyle{
+			Color:  color.RGBA{A: 255},
+			Blur:   tc.blur,
+			Filter: tc.filter,
+			LinearGradient: backend.LoadLinearGradient(backendbase.Gradient{
+				{Pos: 0, Color: color.RGBA{R: 255, A: 255}},
+				{Pos: 1, Color: color.RGBA{B: 255, A: 255}},
+			}),
+		}
+		style.Gradient.X0, style.Gradient.X1 = 10, 90
+		backend.FillImageMask(&style, mask, [4]backendbase.Vec{{10, 10}, {10, 40}, {90, 40}, {90, 10}})
+		backend.Close()
+
+		objects, _ := parsePDF(t, buf.Bytes())
+		var img pdfObject
+		for _, obj := range objects {
+			if strings.Contains(obj.dict, "/ColorSpace /DeviceRGB") {
+				img = obj
+			}
+		}
+		m := regexp.MustCompile(`/Width (\d+) /Height (\d+)`).FindStringSubmatch(img.dict)
+		if m == nil {
+			t.Fatalf("%s: no rasterized image", tc.name)
+		}
+		w, _ := strconv.Atoi(m[1])
+		h, _ := strconv.Atoi(m[2])
+		alpha := objects[ref(t, img.dict, "SMask")].stream
+
+		// along the middle row the mask is painted with the
+		// gradient, red on the left and blue on the right, with
+		// the gap left empty
+		row := h / 2 * w
+		left, right := (row+w/8)*3, (row+w-1-w/8)*3
+		if r, b := img.stream[left], img.stream[left+2]; r < 200 || b > 50 {
+			t.Errorf("%s: the left side is %d,%d instead of red", tc.name, r, b)
+		}
+		if r, b := img.stream[right], img.stream[right+2]; b < 200 || r > 50 {
+			t.Errorf("%s: the right side is %d,%d instead of blue", tc.name, r, b)
+		}
+		if a := alpha[row+w/2]; a > 5 {
+			t.Errorf("%s: the gap in the mask has the alpha %d", tc.name, a)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package recordingbackend
+
+import (
+	"encoding/gob"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// DisplayList is a list of recorded backend calls. It only
+// refers to resources that were loaded within the list, so
+// it can be encoded, sent somewhere else and replayed on
+// any backend
+type DisplayList struct {
+	Width, Height int
+	Commands      []Command
+}
+
+// Op is the type of a recorded command
+type Op uint8
+
+// Op constants
+const (
+	OpLoadImage Op = iota
+	OpReplaceImage
+	OpDeleteImage
+	OpLoadImagePattern
+	OpReplaceImagePattern
+	OpDeleteImagePattern
+	OpLoadLinearGradient
+	OpLoadRadialGradient
+	OpReplaceGradient
+	OpDeleteGradient
+	OpClear
+	OpFill
+	OpFillPath
+	OpStrokePath
+	OpDrawImage
+	OpFillImageMask
+	OpClearClip
+	OpClip
+	OpPutImageData
+)
+
+// Command is a single recorded backend call. Only the
+// fields that are relevant for the Op are set
+type Command struct {
+	Op Op
+
+	// ID is the resource that is loaded, replaced or
+	// deleted, or the image for OpDrawImage
+	ID int
+
+	Style      Style
+	Points     []backendbase.Vec
+	Transform  backendbase.Mat
+	CanOverlap bool
+
+	// Source is the x, y, width and height of the source
+	// rectangle for OpDrawImage
+	Source [4]float64
+
+	Image    *image.RGBA
+	Mask     *image.Alpha
+	X, Y     int
+	Gradient backendbase.Gradient
+	Pattern  PatternData
+	Path     Path
+	Line     backendbase.LineStyle
+}
+
+// Style is the serializable version of the fill style,
+// with gradients and patterns referred to by their IDs
+type Style struct {
+	Color          color.RGBA
+	Blur           float64
+	Composite      backendbase.CompositeOperation
+	LinearGradient int
+	RadialGradient int
+	Gradient       struct {
+		X0, Y0  float64
+		X1, Y1  float64
+		RadFrom float64
+		RadTo   float64
+	}
+	ImagePattern   int
+	ImageSmoothing backendbase.ImageSmoothing
+	Filter         backendbase.Filter
+}
+
+// PatternData is the serializable version of the image
+// pattern data, with the image referred to by its ID
+type PatternData struct {
+	Image     int
+	Transform [9]float64
+	Repeat    backendbase.ImagePatternRepeat
+}
+
+// Path is a recorded path. The triangles are recorded as
+// well so that the path can be replayed on backends that
+// don't implement backendbase.PathBackend
+type Path struct {
+	SubPaths  []backendbase.SubPath
+	FillRule  backendbase.FillRule
+	Triangles []backendbase.Vec
+}
+
+// Encode writes the display list in the gob format
+func (dl *DisplayList) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(dl)
+}
+
+// Decode reads a display list that was written with Encode
+func Decode(r io.Reader) (*DisplayList, error) {
+	var dl DisplayList
+	err := gob.NewDecoder(r).Decode(&dl)
+	if err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// Replay executes the recorded commands on the given
+// backend. Resources that are still loaded at the end of
+// the list are not deleted, but can no longer be used
+func (dl *DisplayList) Replay(dst backendbase.Backend) error {
+	r := replayer{
+		dst:      dst,
+		images:   make(map[int]backendbase.Image),
+		patterns: make(map[int]backendbase.ImagePattern),
+		linear:   make(map[int]backendbase.LinearGradient),
+		radial:   make(map[int]backendbase.RadialGradient),
+	}
+	r.pathDst, _ = dst.(backendbase.PathBackend)
+
+	for i := range dl.Commands {
+		err := r.run(&dl.Commands[i])
+		if err != nil {
+			return fmt.Errorf("command %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+type replayer struct {
+	dst      backendbase.Backend
+	pathDst  backendbase.PathBackend
+	images   map[int]backendbase.Image
+	patterns map[int]backendbase.ImagePattern
+	linear   map[int]backendbase.LinearGradient
+	radial   map[int]backendbase.RadialGradient
+}
+
+func (r *replayer) run(c *Command) error {
+	switch c.Op {
+	case OpLoadImage:
+		img, err := r.dst.LoadImage(c.Image)
+		if err != nil {
+			return err
+		}
+		r.images[c.ID] = img
+	case OpReplaceImage:
+		img, ok := r.images[c.ID]
+		if !ok {
+			return fmt.Errorf("unknown image %d", c.ID)
+		}
+		return img.Replace(c.Image)
+	case OpDeleteImage:
+		if img, ok := r.images[c.ID]; ok {
+			img.Delete()
+			delete(r.images, c.ID)
+		}
+	case OpLoadImagePattern:
+		data, err := r.patternData(&c.Pattern)
+		if err != nil {
+			return err
+		}
+		r.patterns[c.ID] = r.dst.LoadImagePattern(data)
+	case OpReplaceImagePattern:
+		ip, ok := r.patterns[c.ID]
+		if !ok {
+			return fmt.Errorf("unknown image pattern %d", c.ID)
+		}
+		data, err := r.patternData(&c.Pattern)
+		if err != nil {
+			return err
+		}
+		ip.Replace(data)
+	case OpDeleteImagePattern:
+		if ip, ok := r.patterns[c.ID]; ok {
+			ip.Delete()
+			delete(r.patterns, c.ID)
+		}
+	case OpLoadLinearGradient:
+		r.linear[c.ID] = r.dst.LoadLinearGradient(c.Gradient)
+	case OpLoadRadialGradient:
+		r.radial[c.ID] = r.dst.LoadRadialGradient(c.Gradient)
+	case OpReplaceGradient:
+		if lg, ok := r.linear[c.ID]; ok {
+			lg.Replace(c.Gradient)
+		} else if rg, ok := r.radial[c.ID]; ok {
+			rg.Replace(c.Gradient)
+		} else {
+			return fmt.Errorf("unknown gradient %d", c.ID)
+		}
+	case OpDeleteGradient:
+		if lg, ok := r.linear[c.ID]; ok {
+			lg.Delete()
+			delete(r.linear, c.ID)
+		} else if rg, ok := r.radial[c.ID]; ok {
+			rg.Delete()
+			delete(r.radial, c.ID)
+		}
+	case OpClear:
+		var pts [4]backendbase.Vec
+		copy(pts[:], c.Points)
+		r.dst.Clear(pts)
+	case OpFill:
+		style, err := r.style(&c.Style)
+		if err != nil {
+			return err
+		}
+		r.dst.Fill(&style, c.Points, c.Transform, c.CanOverlap)
+	case OpFillPath, OpStrokePath:
+		style, err := r.style(&c.Style)
+		if err != nil {
+			return err
+		}
+		if r.pathDst == nil {
+			r.dst.Fill(&style, c.Path.Triangles, backendbase.MatIdentity, c.Op == OpStrokePath)
+			return nil
+		}
+		path := backendbase.Path{
+			SubPaths:  c.Path.SubPaths,
+			FillRule:  c.Path.FillRule,
+			Triangles: func() []backendbase.Vec { return c.Path.Triangles },
+		}
+		if c.Op == OpFillPath {
+			r.pathDst.FillPath(&style, &path, c.Transform)
+		} else {
+			r.pathDst.StrokePath(&style, &path, &c.Line, c.Transform)
+		}
+	case OpDrawImage:
+		img, ok := r.images[c.ID]
+		if !ok {
+			return fmt.Errorf("unknown image %d", c.ID)
+		}
+		style, err := r.style(&c.Style)
+		if err != nil {
+			return err
+		}
+		var pts [4]backendbase.Vec
+		copy(pts[:], c.Points)
+		r.dst.DrawImage(&style, img, c.Source[0], c.Source[1], c.Source[2], c.Source[3], pts)
+	case OpFillImageMask:
+		style, err := r.style(&c.Style)
+		if err != nil {
+			return err
+		}
+		var pts [4]backendbase.Vec
+		copy(pts[:], c.Points)
+		r.dst.FillImageMask(&style, c.Mask, pts)
+	case OpClearClip:
+		r.dst.ClearClip()
+	case OpClip:
+		r.dst.Clip(c.Points)
+	case OpPutImageData:
+		r.dst.PutImageData(c.Image, c.X, c.Y)
+	default:
+		return fmt.Errorf("invalid op %d", c.Op)
+	}
+	return nil
+}
+
+func (r *replayer) style(s *Style) (backendbase.FillStyle, error) {
+	style := backendbase.FillStyle{
+		Color:          s.Color,
+		Blur:           s.Blur,
+		Composite:      s.Composite,
+		Gradient:       s.Gradient,
+		ImageSmoothing: s.ImageSmoothing,
+		Filter:         s.Filter,
+	}
+	if s.LinearGradient != 0 {
+		lg, ok := r.linear[s.LinearGradient]
+		if !ok {
+			return style, fmt.Errorf("unknown gradient %d", s.LinearGradient)
+		}
+		style.LinearGradient = lg
+	}
+	if s.RadialGradient != 0 {
+		rg, ok := r.radial[s.RadialGradient]
+		if !ok {
+			return style, fmt.Errorf("unknown gradient %d", s.RadialGradient)
+		}
+		style.RadialGradient = rg
+	}
+	if s.ImagePattern != 0 {
+		ip, ok := r.patterns[s.ImagePattern]
+		if !ok {
+			return style, fmt.Errorf("unknown image pattern %d", s.ImagePattern)
+		}
+		style.ImagePattern = ip
+	}
+	return style, nil
+}
+
+func (r *replayer) patternData(pd *PatternData) (backendbase.ImagePatternData, error) {
+	img, ok := r.images[pd.Image]
+	if !ok {
+		return backendbase.ImagePatternData{}, fmt.Errorf("unknown image %d", pd.Image)
+	}
+	return backendbase.ImagePatternData{
+		Image:     img,
+		Transform: pd.Transform,
+		Repeat:    pd.Repeat,
+	}, nil
+}
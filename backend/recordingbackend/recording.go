@@ -0,0 +1,261 @@
+package recordingbackend
+
+import (
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// RecordingBackend is a canvas backend that records all
+// drawing calls in a display list, which can later be
+// replayed on another backend. Everything is also rendered
+// with a software backend so that GetImageData keeps
+// working
+type RecordingBackend struct {
+	w, h int
+
+	list   DisplayList
+	nextID int
+
+	// resources holds the current load command of every
+	// resource that hasn't been deleted, and clip the clip
+	// commands since the last ClearClip, so that a new
+	// display list can start with them
+	resources map[int]*Command
+	clip      []Command
+
+	raster *softwarebackend.SoftwareBackend
+}
+
+// New returns a new recording backend with the given size
+func New(w, h int) *RecordingBackend {
+	return &RecordingBackend{
+		w:         w,
+		h:         h,
+		list:      DisplayList{Width: w, Height: h},
+		resources: make(map[int]*Command),
+		raster:    softwarebackend.New(w, h),
+	}
+}
+
+// Size returns the size of the recorded canvas
+func (b *RecordingBackend) Size() (int, int) {
+	return b.w, b.h
+}
+
+// DisplayList returns the commands recorded since the
+// backend was created or since the last call to Reset
+func (b *RecordingBackend) DisplayList() *DisplayList {
+	return &b.list
+}
+
+// Reset starts a new display list, for example for the
+// next frame. So that it can be replayed on its own, the
+// new list starts with the resources that are still
+// loaded and the current clip
+func (b *RecordingBackend) Reset() {
+	b.list = DisplayList{Width: b.w, Height: b.h}
+
+	ids := make([]int, 0, len(b.resources))
+	for id := range b.resources {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		b.record(*b.resources[id])
+	}
+	for _, c := range b.clip {
+		b.record(c)
+	}
+}
+
+func (b *RecordingBackend) record(c Command) {
+	b.list.Commands = append(b.list.Commands, c)
+}
+
+// load records the command that loads a resource with a
+// new ID and returns the ID
+func (b *RecordingBackend) load(c Command) int {
+	b.nextID++
+	c.ID = b.nextID
+	b.resources[c.ID] = &c
+	b.record(c)
+	return c.ID
+}
+
+// replace records the command that changes a resource and
+// keeps it as the new load command of the resource
+func (b *RecordingBackend) replace(id int, c Command) {
+	load := b.resources[id]
+	if load == nil {
+		return
+	}
+	c.ID = id
+	b.record(c)
+	loadOp := load.Op
+	*load = c
+	load.Op = loadOp
+}
+
+func (b *RecordingBackend) unload(id int, op Op) {
+	if b.resources[id] == nil {
+		return
+	}
+	delete(b.resources, id)
+	b.record(Command{Op: op, ID: id})
+}
+
+func (b *RecordingBackend) GetImageData(x, y, w, h int) *image.RGBA {
+	return b.raster.GetImageData(x, y, w, h)
+}
+
+func (b *RecordingBackend) PutImageData(img *image.RGBA, x, y int) {
+	b.raster.PutImageData(img, x, y)
+	b.record(Command{Op: OpPutImageData, Image: copyRGBA(img), X: x, Y: y})
+}
+
+func (b *RecordingBackend) CanUseAsImage(b2 backendbase.Backend) bool {
+	return false
+}
+
+func (b *RecordingBackend) AsImage() backendbase.Image {
+	return nil
+}
+
+func (b *RecordingBackend) Clear(pts [4]backendbase.Vec) {
+	b.raster.Clear(pts)
+	b.record(Command{Op: OpClear, Points: pts[:]})
+}
+
+func (b *RecordingBackend) Fill(style *backendbase.FillStyle, pts []backendbase.Vec, tf backendbase.Mat, canOverlap bool) {
+	b.raster.Fill(b.rasterStyle(style), pts, tf, canOverlap)
+	b.record(Command{
+		Op:         OpFill,
+		Style:      recordStyle(style),
+		Points:     copyPoints(pts),
+		Transform:  tf,
+		CanOverlap: canOverlap,
+	})
+}
+
+func (b *RecordingBackend) FillPath(style *backendbase.FillStyle, path *backendbase.Path, tf backendbase.Mat) {
+	tris := path.Triangles()
+	b.raster.Fill(b.rasterStyle(style), tris, backendbase.MatIdentity, false)
+	b.record(Command{
+		Op:        OpFillPath,
+		Style:     recordStyle(style),
+		Path:      recordPath(path, tris),
+		Transform: tf,
+	})
+}
+
+func (b *RecordingBackend) StrokePath(style *backendbase.FillStyle, path *backendbase.Path, line *backendbase.LineStyle, tf backendbase.Mat) {
+	tris := path.Triangles()
+	b.raster.Fill(b.rasterStyle(style), tris, backendbase.MatIdentity, true)
+	c := Command{
+		Op:        OpStrokePath,
+		Style:     recordStyle(style),
+		Path:      recordPath(path, tris),
+		Line:      *line,
+		Transform: tf,
+	}
+	c.Line.Dash = copyFloats(line.Dash)
+	b.record(c)
+}
+
+func (b *RecordingBackend) FillImageMask(style *backendbase.FillStyle, mask *image.Alpha, pts [4]backendbase.Vec) {
+	b.raster.FillImageMask(b.rasterStyle(style), mask, pts)
+	mcopy := image.NewAlpha(image.Rect(0, 0, mask.Rect.Dx(), mask.Rect.Dy()))
+	draw.Draw(mcopy, mcopy.Rect, mask, mask.Rect.Min, draw.Src)
+	b.record(Command{
+		Op:     OpFillImageMask,
+		Style:  recordStyle(style),
+		Mask:   mcopy,
+		Points: pts[:],
+	})
+}
+
+func (b *RecordingBackend) ClearClip() {
+	b.raster.ClearClip()
+	b.clip = b.clip[:0]
+	b.record(Command{Op: OpClearClip})
+}
+
+func (b *RecordingBackend) Clip(pts []backendbase.Vec) {
+	b.raster.Clip(pts)
+	c := Command{Op: OpClip, Points: copyPoints(pts)}
+	b.clip = append(b.clip, c)
+	b.record(c)
+}
+
+// rasterStyle returns a copy of the style that refers to
+// the gradients and patterns of the software backend
+func (b *RecordingBackend) rasterStyle(style *backendbase.FillStyle) *backendbase.FillStyle {
+	stl := *style
+	if lg, ok := style.LinearGradient.(*LinearGradient); ok {
+		stl.LinearGradient = lg.raster
+	}
+	if rg, ok := style.RadialGradient.(*RadialGradient); ok {
+		stl.RadialGradient = rg.raster
+	}
+	if ip, ok := style.ImagePattern.(*ImagePattern); ok {
+		stl.ImagePattern = ip.raster
+	}
+	return &stl
+}
+
+func recordStyle(style *backendbase.FillStyle) Style {
+	s := Style{
+		Color:          style.Color,
+		Blur:           style.Blur,
+		Composite:      style.Composite,
+		Gradient:       style.Gradient,
+		ImageSmoothing: style.ImageSmoothing,
+		Filter:         style.Filter,
+	}
+	if lg, ok := style.LinearGradient.(*LinearGradient); ok {
+		s.LinearGradient = lg.id
+	}
+	if rg, ok := style.RadialGradient.(*RadialGradient); ok {
+		s.RadialGradient = rg.id
+	}
+	if ip, ok := style.ImagePattern.(*ImagePattern); ok {
+		s.ImagePattern = ip.id
+	}
+	return s
+}
+
+func recordPath(path *backendbase.Path, tris []backendbase.Vec) Path {
+	p := Path{
+		SubPaths:  make([]backendbase.SubPath, len(path.SubPaths)),
+		FillRule:  path.FillRule,
+		Triangles: copyPoints(tris),
+	}
+	for i, sp := range path.SubPaths {
+		p.SubPaths[i] = backendbase.SubPath{Points: copyPoints(sp.Points), Closed: sp.Closed}
+	}
+	return p
+}
+
+func copyPoints(pts []backendbase.Vec) []backendbase.Vec {
+	return append([]backendbase.Vec(nil), pts...)
+}
+
+func copyFloats(v []float64) []float64 {
+	if v == nil {
+		return nil
+	}
+	return append([]float64(nil), v...)
+}
+
+// copyRGBA returns a copy of the image with the bounds
+// moved to the origin
+func copyRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Rect, src, bounds.Min, draw.Src)
+	return img
+}
@@ -0,0 +1,153 @@
+package recordingbackend_test
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/recordingbackend"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+func drawScene(cv *canvas.Canvas) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	cv.SetFillStyle("#333")
+	cv.FillRect(0, 0, 100, 100)
+
+	lg := cv.CreateLinearGradient(0, 0, 100, 0)
+	lg.AddColorStop(0, "#F00")
+	lg.AddColorStop(1, "#00F8")
+	cv.SetFillStyle(lg)
+	cv.BeginPath()
+	cv.Arc(30, 30, 20, 0, math.Pi*2, false)
+	cv.Fill()
+
+	cv.SetStrokeStyle("#0F0")
+	cv.SetLineWidth(3)
+	cv.SetLineDash([]float64{5, 3})
+	cv.BeginPath()
+	cv.MoveTo(10, 90)
+	cv.BezierCurveTo(30, 50, 70, 100, 90, 60)
+	cv.Stroke()
+
+	cv.Save()
+	cv.BeginPath()
+	cv.Rect(50, 10, 40, 40)
+	cv.Clip()
+	cv.SetFillStyle(cv.CreatePattern(img, canvas.Repeat))
+	cv.FillRect(40, 0, 60, 60)
+	cv.Restore()
+
+	cv.SetShadowColor("#0008")
+	cv.SetShadowBlur(4)
+	cv.SetShadowOffset(2, 2)
+	cv.DrawImage(img, 20, 55, 24, 24)
+	cv.SetShadowColor("#0000")
+
+	cv.SetGlobalCompositeOperation(canvas.CompositeMultiply)
+	cv.SetFillStyle("#FF0")
+	cv.FillRect(10, 40, 80, 20)
+	cv.SetGlobalCompositeOperation(canvas.CompositeSourceOver)
+
+	cv.SetFont("../../testdata/Roboto-Light.ttf", 16)
+	cv.SetFillStyle("#FFF")
+	cv.FillText("Replay", 40, 95)
+}
+
+func TestReplay(t *testing.T) {
+	direct := softwarebackend.New(100, 100)
+	drawScene(canvas.New(direct))
+
+	recording := recordingbackend.New(100, 100)
+	drawScene(canvas.New(recording))
+
+	var buf bytes.Buffer
+	err := recording.DisplayList().Encode(&buf)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	dl, err := recordingbackend.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(dl.Commands) != len(recording.DisplayList().Commands) {
+		t.Fatalf("decoded %d commands, expected %d", len(dl.Commands), len(recording.DisplayList().Commands))
+	}
+	replayed := softwarebackend.New(100, 100)
+	err = dl.Replay(replayed)
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+
+	compare(t, "replay", replayed.GetImageData(0, 0, 100, 100), direct.GetImageData(0, 0, 100, 100))
+	compare(t, "recording", recording.GetImageData(0, 0, 100, 100), direct.GetImageData(0, 0, 100, 100))
+}
+
+func TestReset(t *testing.T) {
+	recording := recordingbackend.New(100, 100)
+	cv := canvas.New(recording)
+	drawScene(cv)
+
+	// the second frame uses the clip and a pattern image from
+	// the first one
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	pattern := cv.CreatePattern(img, canvas.Repeat)
+	cv.BeginPath()
+	cv.Rect(10, 10, 50, 50)
+	cv.Clip()
+	recording.Reset()
+	replayed := softwarebackend.New(100, 100)
+	cv2 := canvas.New(replayed)
+	cv2.PutImageData(recording.GetImageData(0, 0, 100, 100), 0, 0)
+
+	cv.SetFillStyle(pattern)
+	cv.FillRect(0, 0, 100, 100)
+
+	var buf bytes.Buffer
+	recording.DisplayList().Encode(&buf)
+	dl, err := recordingbackend.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	err = dl.Replay(replayed)
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	compare(t, "reset", replayed.GetImageData(0, 0, 100, 100), recording.GetImageData(0, 0, 100, 100))
+}
+
+func TestReplayUnknownResource(t *testing.T) {
+	dl := recordingbackend.DisplayList{
+		Width:  100,
+		Height: 100,
+		Commands: []recordingbackend.Command{
+			{Op: recordingbackend.OpFill, Style: recordingbackend.Style{LinearGradient: 5}, Points: []backendbase.Vec{{0, 0}, {10, 0}, {0, 10}}},
+		},
+	}
+	if dl.Replay(softwarebackend.New(100, 100)) == nil {
+		t.Error("replaying a fill with an unknown gradient didn't fail")
+	}
+}
+
+func compare(t *testing.T, name string, a, b *image.RGBA) {
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if ca, cb := a.RGBAAt(x, y), b.RGBAAt(x, y); ca != cb {
+				t.Fatalf("%s: pixel %d,%d is %v instead of %v", name, x, y, ca, cb)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package recordingbackend
+
+import (
+	"image"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// Image is an image for the recording backend
+type Image struct {
+	b      *RecordingBackend
+	id     int
+	w, h   int
+	raster backendbase.Image
+}
+
+// LoadImage converts the image to RGBA before recording it.
+// The converted image is also used for the software
+// backend, so that replaying the display list gives the
+// same result
+func (b *RecordingBackend) LoadImage(src image.Image) (backendbase.Image, error) {
+	rgba := copyRGBA(src)
+	raster, err := b.raster.LoadImage(rgba)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{b: b, w: rgba.Rect.Dx(), h: rgba.Rect.Dy(), raster: raster}
+	img.id = b.load(Command{Op: OpLoadImage, Image: rgba})
+	return img, nil
+}
+
+// Width returns the width of the image
+func (img *Image) Width() int { return img.w }
+
+// Height returns the height of the image
+func (img *Image) Height() int { return img.h }
+
+// Size returns the width and height of the image
+func (img *Image) Size() (int, int) { return img.w, img.h }
+
+// Delete deletes the image
+func (img *Image) Delete() {
+	img.raster.Delete()
+	img.b.unload(img.id, OpDeleteImage)
+}
+
+// Replace replaces the image with the new one
+func (img *Image) Replace(src image.Image) error {
+	rgba := copyRGBA(src)
+	err := img.raster.Replace(rgba)
+	if err != nil {
+		return err
+	}
+	img.w, img.h = rgba.Rect.Dx(), rgba.Rect.Dy()
+	img.b.replace(img.id, Command{Op: OpReplaceImage, Image: rgba})
+	return nil
+}
+
+func (b *RecordingBackend) DrawImage(style *backendbase.FillStyle, dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec) {
+	img := dimg.(*Image)
+	b.raster.DrawImage(b.rasterStyle(style), img.raster, sx, sy, sw, sh, pts)
+	b.record(Command{
+		Op:     OpDrawImage,
+		ID:     img.id,
+		Style:  recordStyle(style),
+		Source: [4]float64{sx, sy, sw, sh},
+		Points: pts[:],
+	})
+}
+
+// ImagePattern is an image pattern for the recording
+// backend
+type ImagePattern struct {
+	b      *RecordingBackend
+	id     int
+	raster backendbase.ImagePattern
+}
+
+func (b *RecordingBackend) LoadImagePattern(data backendbase.ImagePatternData) backendbase.ImagePattern {
+	raster, pd := rasterPatternData(data)
+	ip := &ImagePattern{b: b, raster: b.raster.LoadImagePattern(raster)}
+	ip.id = b.load(Command{Op: OpLoadImagePattern, Pattern: pd})
+	return ip
+}
+
+// Delete deletes the image pattern
+func (ip *ImagePattern) Delete() {
+	ip.raster.Delete()
+	ip.b.unload(ip.id, OpDeleteImagePattern)
+}
+
+// Replace replaces the image pattern data
+func (ip *ImagePattern) Replace(data backendbase.ImagePatternData) {
+	raster, pd := rasterPatternData(data)
+	ip.raster.Replace(raster)
+	ip.b.replace(ip.id, Command{Op: OpReplaceImagePattern, Pattern: pd})
+}
+
+// rasterPatternData returns the pattern data for the
+// software backend and the data to record
+func rasterPatternData(data backendbase.ImagePatternData) (backendbase.ImagePatternData, PatternData) {
+	pd := PatternData{Transform: data.Transform, Repeat: data.Repeat}
+	if img, ok := data.Image.(*Image); ok {
+		data.Image = img.raster
+		pd.Image = img.id
+	}
+	return data, pd
+}
+
+// LinearGradient is a linear gradient for the recording
+// backend
+type LinearGradient struct {
+	gradient
+}
+
+// RadialGradient is a radial gradient for the recording
+// backend
+type RadialGradient struct {
+	gradient
+}
+
+type gradient struct {
+	b      *RecordingBackend
+	id     int
+	raster interface {
+		Delete()
+		Replace(data backendbase.Gradient)
+	}
+}
+
+func (b *RecordingBackend) LoadLinearGradient(data backendbase.Gradient) backendbase.LinearGradient {
+	lg := &LinearGradient{gradient{b: b, raster: b.raster.LoadLinearGradient(data)}}
+	lg.id = b.load(Command{Op: OpLoadLinearGradient, Gradient: copyGradient(data)})
+	return lg
+}
+
+func (b *RecordingBackend) LoadRadialGradient(data backendbase.Gradient) backendbase.RadialGradient {
+	rg := &RadialGradient{gradient{b: b, raster: b.raster.LoadRadialGradient(data)}}
+	rg.id = b.load(Command{Op: OpLoadRadialGradient, Gradient: copyGradient(data)})
+	return rg
+}
+
+func (g *gradient) Delete() {
+	g.raster.Delete()
+	g.b.unload(g.id, OpDeleteGradient)
+}
+
+func (g *gradient) Replace(data backendbase.Gradient) {
+	g.raster.Replace(data)
+	g.b.replace(g.id, Command{Op: OpReplaceGradient, Gradient: copyGradient(data)})
+}
+
+func copyGradient(data backendbase.Gradient) backendbase.Gradient {
+	return append(backendbase.Gradient(nil), data...)
+}
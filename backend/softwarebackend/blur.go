@@ -3,22 +3,20 @@ package softwarebackend
 import (
 	"image"
 	"image/color"
-	"image/draw"
 	"math"
-)
 
-func (b *SoftwareBackend) activateBlurTarget() {
-	b.blurSwap = b.Image
-	b.Image = image.NewRGBA(b.Image.Rect)
-}
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
 
-func (b *SoftwareBackend) drawBlurred(size float64) {
-	blurred := box3(b.Image, size)
-	b.Image = b.blurSwap
-	draw.Draw(b.Image, b.Image.Rect, blurred, image.ZP, draw.Over)
+func (b *SoftwareBackend) drawBlurred(size float64, op backendbase.CompositeOperation) {
+	blurred := box3(b.Image, size, b.Workers)
+	b.Image = b.layerSwap
+	b.clip = b.clipSwap
+	b.premulLayer = false
+	b.composite(blurred, op)
 }
 
-func box3(img *image.RGBA, size float64) *image.RGBA {
+func box3(img *image.RGBA, size float64, workers int) *image.RGBA {
 	size *= 1 - 1/(size+1) // this just seems to improve the accuracy
 
 	fsize := math.Floor(size)
@@ -31,21 +29,32 @@ func box3(img *image.RGBA, size float64) *image.RGBA {
 	if size-fsize > 0.666666666 {
 		sizec++
 	}
-	img = box3x(img, sizea)
-	img = box3x(img, sizeb)
-	img = box3x(img, sizec)
-	img = box3y(img, sizea)
-	img = box3y(img, sizeb)
-	img = box3y(img, sizec)
+	img = box3x(img, sizea, workers)
+	img = box3x(img, sizeb, workers)
+	img = box3x(img, sizec, workers)
+	img = box3y(img, sizea, workers)
+	img = box3y(img, sizeb, workers)
+	img = box3y(img, sizec, workers)
 	return img
 }
 
-func box3x(img *image.RGBA, size int) *image.RGBA {
+// box3x blurs the rows of the image. The rows are
+// independent of each other, so they are split among the
+// workers
+func box3x(img *image.RGBA, size int, workers int) *image.RGBA {
 	bounds := img.Bounds()
 	result := image.NewRGBA(bounds)
-	w, h := bounds.Dx(), bounds.Dy()
+	parallel(bounds.Dy(), workers, func(y0, y1 int) {
+		box3xRows(img, result, size, y0, y1)
+	})
+
+	return result
+}
 
-	for y := 0; y < h; y++ {
+func box3xRows(img, result *image.RGBA, size int, y0, y1 int) {
+	w := img.Bounds().Dx()
+
+	for y := y0; y < y1; y++ {
 		if size >= w {
 			var r, g, b, a float64
 			for x := 0; x < w; x++ {
@@ -115,16 +124,26 @@ func box3x(img *image.RGBA, size int) *image.RGBA {
 			}
 		}
 	}
-
-	return result
 }
 
-func box3y(img *image.RGBA, size int) *image.RGBA {
+// box3y blurs the columns of the image. Like with box3x,
+// the columns are split among the workers
+func box3y(img *image.RGBA, size int, workers int) *image.RGBA {
 	bounds := img.Bounds()
 	result := image.NewRGBA(bounds)
-	w, h := bounds.Dx(), bounds.Dy()
+	w := bounds.Dx()
+
+	parallel(w, workers, func(x0, x1 int) {
+		box3yColumns(img, result, size, x0, x1)
+	})
+
+	return result
+}
+
+func box3yColumns(img, result *image.RGBA, size int, x0, x1 int) {
+	h := img.Bounds().Dy()
 
-	for x := 0; x < w; x++ {
+	for x := x0; x < x1; x++ {
 		if size >= h {
 			var r, g, b, a float64
 			for y := 0; y < h; y++ {
@@ -194,6 +213,4 @@ func box3y(img *image.RGBA, size int) *image.RGBA {
 			}
 		}
 	}
-
-	return result
 }
@@ -0,0 +1,102 @@
+package softwarebackend_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// roundedRect returns the triangles of a rounded rectangle
+// as a fan around its center
+func roundedRect(x0, y0, x1, y1, r float64) []backendbase.Vec {
+	var outline []backendbase.Vec
+	corners := [4]backendbase.Vec{{x1 - r, y0 + r}, {x1 - r, y1 - r}, {x0 + r, y1 - r}, {x0 + r, y0 + r}}
+	for i, c := range corners {
+		for j := 0; j <= 8; j++ {
+			a := (float64(i-1) + float64(j)/8) * math.Pi / 2
+			outline = append(outline, backendbase.Vec{c[0] + math.Cos(a)*r, c[1] + math.Sin(a)*r})
+		}
+	}
+	center := backendbase.Vec{(x0 + x1) / 2, (y0 + y1) / 2}
+	var tris []backendbase.Vec
+	for i, pt := range outline {
+		tris = append(tris, center, pt, outline[(i+1)%len(outline)])
+	}
+	return tris
+}
+
+var avatar = roundedRect(10.5, 15.25, 89.75, 84.5, 23.3)
+
+var fullQuad = [4]backendbase.Vec{{0, 0}, {100, 0}, {100, 100}, {0, 100}}
+
+func TestClipFill(t *testing.T) {
+	backend := newBlack()
+	backend.AnalyticAA = true
+	backend.Clip(avatar)
+	backend.Fill(white, fullImage[:], backendbase.MatIdentity, false)
+	checkCoverage(t, "fill", avatar, backend.Image)
+
+	fractional := 0
+	for i := 0; i < len(backend.Image.Pix); i += 4 {
+		if v := backend.Image.Pix[i]; v > 0 && v < 255 {
+			fractional++
+		}
+	}
+	if fractional < 100 {
+		t.Errorf("only %d pixels on the clip edge are anti-aliased", fractional)
+	}
+}
+
+func TestClipDrawImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for _, msaa := range []int{0, 4} {
+		backend := newBlack()
+		backend.AnalyticAA = msaa == 0
+		backend.MSAA = msaa
+		bimg, err := backend.LoadImage(img)
+		if err != nil {
+			t.Fatalf("failed to load image: %v", err)
+		}
+		backend.Clip(avatar)
+		backend.DrawImage(white, bimg, 0, 0, 10, 10, fullQuad)
+		checkCoverage(t, "image", avatar, backend.Image)
+	}
+}
+
+func TestClipFillImageMask(t *testing.T) {
+	mask := image.NewAlpha(image.Rect(0, 0, 100, 100))
+	for i := range mask.Pix {
+		mask.Pix[i] = 255
+	}
+	backend := newBlack()
+	backend.AnalyticAA = true
+	backend.Clip(avatar)
+	backend.FillImageMask(white, mask, fullQuad)
+	checkCoverage(t, "mask", avatar, backend.Image)
+}
+
+func TestClipClear(t *testing.T) {
+	backend := softwarebackend.New(100, 100)
+	for i := range backend.Image.Pix {
+		backend.Image.Pix[i] = 255
+	}
+	backend.AnalyticAA = true
+	backend.Clip(avatar)
+	backend.Clear(fullQuad)
+
+	// the cleared area is the inverse of the clip
+	inv := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			inv.SetRGBA(x, y, color.RGBA{R: 255 - backend.Image.RGBAAt(x, y).A})
+		}
+	}
+	checkCoverage(t, "clear", avatar, inv)
+}
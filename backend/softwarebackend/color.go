@@ -15,7 +15,20 @@ func toRGBA(src color.Color) color.RGBA {
 	}
 }
 
+// mix blends src over dest like the GL backends do, with
+// the alpha blended the same way as the color channels
 func mix(src, dest color.Color) color.RGBA {
+	return mixAlpha(src, dest, false)
+}
+
+// mixPremul blends src over dest with the alpha blended
+// separately, so that a layer that is drawn to starting
+// out transparent ends up premultiplied
+func mixPremul(src, dest color.Color) color.RGBA {
+	return mixAlpha(src, dest, true)
+}
+
+func mixAlpha(src, dest color.Color, separate bool) color.RGBA {
 	ir1, ig1, ib1, ia1 := src.RGBA()
 	r1 := float64(ir1) / 65535.0
 	g1 := float64(ig1) / 65535.0
@@ -32,6 +45,9 @@ func mix(src, dest color.Color) color.RGBA {
 	g := (g1-g2)*a1 + g2
 	b := (b1-b2)*a1 + b2
 	a := math.Max((a1-a2)*a1+a2, a2)
+	if separate {
+		a = a1 + a2*(1-a1)
+	}
 
 	return color.RGBA{
 		R: uint8(math.Round(r * 255.0)),
@@ -41,18 +57,44 @@ func mix(src, dest color.Color) color.RGBA {
 	}
 }
 
-func alphaColor(col color.Color, alpha color.Alpha) color.RGBA {
-	ir, ig, ib, _ := col.RGBA()
-	a2 := float64(alpha.A) / 255.0
-	r := float64(ir) * a2 / 65535.0
-	g := float64(ig) * a2 / 65535.0
-	b := float64(ib) * a2 / 65535.0
-	return color.RGBA{
-		R: uint8(r * 255.0),
-		G: uint8(g * 255.0),
-		B: uint8(b * 255.0),
-		A: 255,
+// scaleAlpha multiplies all channels of the premultiplied
+// color with the alpha, so that it stays premultiplied
+func scaleAlpha(col color.RGBA, alpha uint8) color.RGBA {
+	if alpha == 255 {
+		return col
+	}
+	a := int(alpha)
+	col.R = uint8((int(col.R)*a + 127) / 255)
+	col.G = uint8((int(col.G)*a + 127) / 255)
+	col.B = uint8((int(col.B)*a + 127) / 255)
+	col.A = uint8((int(col.A)*a + 127) / 255)
+	return col
+}
+
+// unpremultiply converts the premultiplied color to the
+// straight alpha color that mix expects
+func unpremultiply(col color.RGBA) color.RGBA {
+	if col.A == 255 || col.A == 0 {
+		return col
+	}
+	a := int(col.A)
+	div := func(v uint8) uint8 {
+		if int(v) >= a {
+			return 255
+		}
+		return uint8((int(v)*255 + a/2) / a)
+	}
+	col.R, col.G, col.B = div(col.R), div(col.G), div(col.B)
+	return col
+}
+
+// clipAlpha multiplies the alpha of the color with the
+// value of the clip mask or image mask
+func clipAlpha(col color.RGBA, clip color.Alpha) color.RGBA {
+	if clip.A < 255 {
+		col.A = uint8((int(col.A)*int(clip.A) + 127) / 255)
 	}
+	return col
 }
 
 func lerp(col1, col2 color.Color, ratio float64) color.RGBA {
@@ -0,0 +1,292 @@
+package softwarebackend
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// activateLayer redirects drawing to a new transparent
+// layer. The clip mask is only applied when the layer is
+// composited, so that partially clipped pixels are not
+// faded twice and blurred shadows are clipped after blurring.
+// If premul is set, the alpha is blended separately so that
+// the layer can be composited and filtered correctly, like
+// the GL backends do
+func (b *SoftwareBackend) activateLayer(premul bool) {
+	b.premulLayer = premul
+	b.layerSwap = b.Image
+	// the layer image is kept and cleared for the next
+	// layer instead of allocating a new one every time
+	if b.layer == nil {
+		b.layer = image.NewRGBA(b.Image.Rect)
+	} else {
+		for i := range b.layer.Pix {
+			b.layer.Pix[i] = 0
+		}
+	}
+	b.Image = b.layer
+	if b.noClip == nil {
+		b.noClip = image.NewAlpha(b.clip.Rect)
+		for i := range b.noClip.Pix {
+			b.noClip.Pix[i] = 255
+		}
+	}
+	b.clipSwap = b.clip
+	b.clip = b.noClip
+}
+
+// drawLayer applies the filter to the layer and then
+// composites it onto the previous image
+func (b *SoftwareBackend) drawLayer(op backendbase.CompositeOperation, filter backendbase.Filter) {
+	layer := b.Image
+	b.Image = b.layerSwap
+	b.clip = b.clipSwap
+	b.premulLayer = false
+	if len(filter) > 0 {
+		layer = applyFilter(layer, filter, b.Workers)
+	}
+	b.composite(layer, op)
+}
+
+// mix blends the color onto the target image pixel
+func (b *SoftwareBackend) mix(x, y int, col color.RGBA) {
+	if b.premulLayer {
+		b.Image.SetRGBA(x, y, mixPremul(col, b.Image.RGBAAt(x, y)))
+	} else {
+		b.Image.SetRGBA(x, y, mix(col, b.Image.RGBAAt(x, y)))
+	}
+}
+
+// usesLayer returns whether drawing with the style has to
+// go through a layer
+func usesLayer(style *backendbase.FillStyle) bool {
+	return style.Composite != backendbase.SourceOver || len(style.Filter) > 0
+}
+
+// composite draws the premultiplied src image onto the target
+// image using the given operation and the clip mask. Anything
+// but source-over affects the entire clip area, not just where
+// src has any coverage
+func (b *SoftwareBackend) composite(src *image.RGBA, op backendbase.CompositeOperation) {
+	if op == backendbase.SourceOver {
+		if b.clip.Opaque() {
+			// drawing without a mask rounds slightly
+			// differently, this keeps shadows unchanged
+			draw.Draw(b.Image, b.Image.Rect, src, image.ZP, draw.Over)
+			return
+		}
+		draw.DrawMask(b.Image, b.Image.Rect, src, image.ZP, b.clip, image.ZP, draw.Over)
+		return
+	}
+
+	parallel(b.h, b.Workers, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < b.w; x++ {
+				clip := b.clip.AlphaAt(x, y)
+				if clip.A == 0 {
+					continue
+				}
+				dst := b.Image.RGBAAt(x, y)
+				col := compositePixel(op, src.RGBAAt(x, y), dst)
+				if clip.A < 255 {
+					col = lerp(col, dst, float64(clip.A)/255)
+				}
+				b.Image.SetRGBA(x, y, col)
+			}
+		}
+	})
+}
+
+// compositePixel combines the two premultiplied colors
+// according to the operation and returns the premultiplied
+// result
+func compositePixel(op backendbase.CompositeOperation, src, dst color.RGBA) color.RGBA {
+	sc := [3]float64{float64(src.R) / 255, float64(src.G) / 255, float64(src.B) / 255}
+	sa := float64(src.A) / 255
+	dc := [3]float64{float64(dst.R) / 255, float64(dst.G) / 255, float64(dst.B) / 255}
+	da := float64(dst.A) / 255
+
+	var rc [3]float64
+	var ra float64
+
+	var fa, fb float64
+	porterDuff := true
+	switch op {
+	case backendbase.SourceOver:
+		fa, fb = 1, 1-sa
+	case backendbase.SourceIn:
+		fa, fb = da, 0
+	case backendbase.SourceOut:
+		fa, fb = 1-da, 0
+	case backendbase.SourceAtop:
+		fa, fb = da, 1-sa
+	case backendbase.DestinationOver:
+		fa, fb = 1-da, 1
+	case backendbase.DestinationIn:
+		fa, fb = 0, sa
+	case backendbase.DestinationOut:
+		fa, fb = 0, 1-sa
+	case backendbase.DestinationAtop:
+		fa, fb = 1-da, sa
+	case backendbase.Lighter:
+		fa, fb = 1, 1
+	case backendbase.Copy:
+		fa, fb = 1, 0
+	case backendbase.Xor:
+		fa, fb = 1-da, 1-sa
+	default:
+		porterDuff = false
+	}
+
+	if porterDuff {
+		for i := range rc {
+			rc[i] = sc[i]*fa + dc[i]*fb
+		}
+		ra = sa*fa + da*fb
+	} else {
+		// unpremultiplied colors for the blend functions
+		var cs, cb [3]float64
+		for i := range cs {
+			if sa > 0 {
+				cs[i] = sc[i] / sa
+			}
+			if da > 0 {
+				cb[i] = dc[i] / da
+			}
+		}
+		blended := blendColors(op, cb, cs)
+		for i := range rc {
+			rc[i] = sc[i]*(1-da) + dc[i]*(1-sa) + sa*da*blended[i]
+		}
+		ra = sa + da*(1-sa)
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round(clamp01(rc[0]) * 255)),
+		G: uint8(math.Round(clamp01(rc[1]) * 255)),
+		B: uint8(math.Round(clamp01(rc[2]) * 255)),
+		A: uint8(math.Round(clamp01(ra) * 255)),
+	}
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// blendColors applies the blend mode to the unpremultiplied
+// backdrop color cb and source color cs
+func blendColors(op backendbase.CompositeOperation, cb, cs [3]float64) [3]float64 {
+	switch op {
+	case backendbase.Hue:
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case backendbase.Saturation:
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case backendbase.Color:
+		return setLum(cs, lum(cb))
+	case backendbase.Luminosity:
+		return setLum(cb, lum(cs))
+	}
+
+	var result [3]float64
+	for i := range result {
+		result[i] = blendChannel(op, cb[i], cs[i])
+	}
+	return result
+}
+
+func blendChannel(op backendbase.CompositeOperation, cb, cs float64) float64 {
+	switch op {
+	case backendbase.Multiply:
+		return cb * cs
+	case backendbase.Screen:
+		return cb + cs - cb*cs
+	case backendbase.Overlay:
+		return blendChannel(backendbase.HardLight, cs, cb)
+	case backendbase.Darken:
+		return math.Min(cb, cs)
+	case backendbase.Lighten:
+		return math.Max(cb, cs)
+	case backendbase.ColorDodge:
+		if cb == 0 {
+			return 0
+		} else if cs >= 1 {
+			return 1
+		}
+		return math.Min(1, cb/(1-cs))
+	case backendbase.ColorBurn:
+		if cb >= 1 {
+			return 1
+		} else if cs == 0 {
+			return 0
+		}
+		return 1 - math.Min(1, (1-cb)/cs)
+	case backendbase.HardLight:
+		if cs <= 0.5 {
+			return cb * 2 * cs
+		}
+		cs = 2*cs - 1
+		return cb + cs - cb*cs
+	case backendbase.SoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		var d float64
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		} else {
+			d = math.Sqrt(cb)
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case backendbase.Difference:
+		return math.Abs(cb - cs)
+	case backendbase.Exclusion:
+		return cb + cs - 2*cb*cs
+	}
+	return cs
+}
+
+func lum(c [3]float64) float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+func clipColor(c [3]float64) [3]float64 {
+	l := lum(c)
+	n := math.Min(math.Min(c[0], c[1]), c[2])
+	x := math.Max(math.Max(c[0], c[1]), c[2])
+	for i := range c {
+		if n < 0 {
+			c[i] = l + (c[i]-l)*l/(l-n)
+		}
+		if x > 1 {
+			c[i] = l + (c[i]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
+
+func setLum(c [3]float64, l float64) [3]float64 {
+	d := l - lum(c)
+	return clipColor([3]float64{c[0] + d, c[1] + d, c[2] + d})
+}
+
+func sat(c [3]float64) float64 {
+	return math.Max(math.Max(c[0], c[1]), c[2]) - math.Min(math.Min(c[0], c[1]), c[2])
+}
+
+func setSat(c [3]float64, s float64) [3]float64 {
+	n := math.Min(math.Min(c[0], c[1]), c[2])
+	x := math.Max(math.Max(c[0], c[1]), c[2])
+	if x <= n {
+		return [3]float64{}
+	}
+	return [3]float64{(c[0] - n) * s / (x - n), (c[1] - n) * s / (x - n), (c[2] - n) * s / (x - n)}
+}
@@ -0,0 +1,277 @@
+package softwarebackend
+
+import (
+	"image/color"
+	"math"
+	"math/bits"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// coverageRows is the number of rows that are rasterized
+// at once with analytic anti-aliasing
+const coverageRows = 32
+
+// unionSamples is the number of samples per pixel in each
+// direction that triangles which can overlap are combined
+// with
+const unionSamples = 8
+
+// coverage accumulates the signed area that the edges of a
+// shape cover in each cell, like font rasterizers do. The
+// running sum of the cells along a row is then the exact
+// area of each pixel that is covered by the shape.
+//
+// Triangles that can overlap would be counted more than once
+// that way, so they are instead combined as the union of
+// their samples, with one bit per sample in masks
+type coverage struct {
+	cells  []float64
+	masks  []uint64
+	stride int
+	w      int
+	y0, y1 int
+}
+
+func newCoverage(w int) *coverage {
+	stride := w + 2
+	return &coverage{
+		cells:  make([]float64, stride*coverageRows),
+		stride: stride,
+		w:      w,
+	}
+}
+
+// getCoverage returns a coverage buffer for the width of the
+// image. The buffers are pooled, so that the workers reuse
+// them instead of allocating new ones for every tile
+func (b *SoftwareBackend) getCoverage() *coverage {
+	if c, ok := b.coverages.Get().(*coverage); ok && c.w == b.w {
+		return c
+	}
+	return newCoverage(b.w)
+}
+
+// reset clears the cells, or the sample masks for a union,
+// and sets the range of rows that are rasterized next
+func (c *coverage) reset(y0, y1 int, union bool) {
+	c.y0, c.y1 = y0, y1
+	if union {
+		if c.masks == nil {
+			c.masks = make([]uint64, c.w*coverageRows)
+		}
+		masks := c.masks[:(y1-y0)*c.w]
+		for i := range masks {
+			masks[i] = 0
+		}
+		return
+	}
+	cells := c.cells[:(y1-y0)*c.stride]
+	for i := range cells {
+		cells[i] = 0
+	}
+}
+
+// addTriangles adds the area of the triangles. They are
+// all added with the same orientation, so that the areas
+// of triangles that share an edge add up without seams
+func (c *coverage) addTriangles(pts []backendbase.Vec) {
+	iterateTriangles(pts, func(tri []backendbase.Vec) {
+		a, b, cc := tri[0], tri[1], tri[2]
+		if (b[0]-a[0])*(cc[1]-a[1])-(b[1]-a[1])*(cc[0]-a[0]) < 0 {
+			b, cc = cc, b
+		}
+		c.addLine(a, b)
+		c.addLine(b, cc)
+		c.addLine(cc, a)
+	})
+}
+
+// addSamples sets the bits of the samples that are inside
+// the triangle. A sample is at the center of its part of the
+// pixel and is inside if it is left of the right edge and
+// not left of the left edge, so triangles that share an edge
+// don't both cover a sample
+func (c *coverage) addSamples(tri []backendbase.Vec) {
+	const n = unionSamples
+	minY := math.Min(math.Min(tri[0][1], tri[1][1]), tri[2][1])
+	maxY := math.Max(math.Max(tri[0][1], tri[1][1]), tri[2][1])
+	if math.IsNaN(minY) || math.IsNaN(maxY) {
+		return
+	}
+	sy0 := int(math.Max(math.Ceil(minY*n-0.5), float64(c.y0*n)))
+	sy1 := int(math.Min(math.Ceil(maxY*n-0.5), float64(c.y1*n)))
+	for sy := sy0; sy < sy1; sy++ {
+		l, r, out := triangleLR(tri, (float64(sy)+0.5)/n)
+		if out || math.IsNaN(l) || math.IsNaN(r) {
+			continue
+		}
+		sx0 := int(math.Max(math.Ceil(l*n-0.5), 0))
+		sx1 := int(math.Min(math.Ceil(r*n-0.5), float64(c.w*n)))
+		row := c.masks[(sy/n-c.y0)*c.w:]
+		shift := uint(sy%n) * n
+		for sx := sx0; sx < sx1; {
+			bit := sx % n
+			count := n - bit
+			if sx1-sx < count {
+				count = sx1 - sx
+			}
+			row[sx/n] |= (1<<uint(count) - 1) << uint(bit) << shift
+			sx += count
+		}
+	}
+}
+
+func (c *coverage) addLine(p0, p1 backendbase.Vec) {
+	if p0[1] == p1[1] || math.IsNaN(p0[1]) || math.IsNaN(p1[1]) {
+		return
+	}
+	dir := 1.0
+	if p0[1] > p1[1] {
+		p0, p1 = p1, p0
+		dir = -1
+	}
+	if p1[1] <= float64(c.y0) || p0[1] >= float64(c.y1) {
+		return
+	}
+
+	dxdy := (p1[0] - p0[0]) / (p1[1] - p0[1])
+	ystart := int(math.Max(math.Floor(p0[1]), float64(c.y0)))
+	yend := int(math.Min(math.Ceil(p1[1]), float64(c.y1)))
+	for y := ystart; y < yend; y++ {
+		fy := float64(y)
+		top := math.Max(fy, p0[1])
+		bottom := math.Min(fy+1, p1[1])
+		xa := p0[0] + (top-p0[1])*dxdy
+		xb := p0[0] + (bottom-p0[1])*dxdy
+		row := c.cells[(y-c.y0)*c.stride : (y-c.y0+1)*c.stride]
+		c.addSegment(row, xa, xb, (bottom-top)*dir)
+	}
+}
+
+// addSegment adds a part of an edge within a single row,
+// where d is the signed height of the part. Anything left
+// of the image is moved to the left border, since it covers
+// the whole row from there on
+func (c *coverage) addSegment(row []float64, x0, x1, d float64) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	fw := float64(c.w)
+	if x1 <= 0 {
+		x0, x1 = 0, 0
+	} else if x0 >= fw {
+		x0, x1 = fw, fw
+	} else if x0 < 0 {
+		t := -x0 / (x1 - x0)
+		c.addSegment(row, 0, 0, d*t)
+		c.addSegment(row, 0, x1, d*(1-t))
+		return
+	} else if x1 > fw {
+		t := (x1 - fw) / (x1 - x0)
+		c.addSegment(row, x0, fw, d*(1-t))
+		c.addSegment(row, fw, fw, d*t)
+		return
+	}
+
+	x0f := math.Floor(x0)
+	x0i := int(x0f)
+	x1c := math.Ceil(x1)
+	x1i := int(x1c)
+	if x1i <= x0i+1 {
+		xm := 0.5*(x0+x1) - x0f
+		row[x0i] += d - d*xm
+		row[x0i+1] += d * xm
+		return
+	}
+
+	s := 1 / (x1 - x0)
+	x0r := x0 - x0f
+	a0 := 0.5 * s * (1 - x0r) * (1 - x0r)
+	x1r := x1 - x1c + 1
+	am := 0.5 * s * x1r * x1r
+	row[x0i] += d * a0
+	if x1i == x0i+2 {
+		row[x0i+1] += d * (1 - a0 - am)
+	} else {
+		a1 := s * (1.5 - x0r)
+		row[x0i+1] += d * (a1 - a0)
+		for xi := x0i + 2; xi < x1i-1; xi++ {
+			row[xi] += d * s
+		}
+		a2 := a1 + float64(x1i-x0i-3)*s
+		row[x1i-1] += d * (1 - a2 - am)
+	}
+	row[x1i] += d * am
+}
+
+// iterateCoverage rasterizes the triangles with analytic
+// anti-aliasing and calls fn for every pixel in the given
+// rows that is at least partially covered. If the triangles
+// can overlap, the coverage is that of their union
+func (b *SoftwareBackend) iterateCoverage(pts []backendbase.Vec, y0, y1 int, canOverlap bool, fn func(x, y int, cov float64)) {
+	if len(pts) == 0 {
+		return
+	}
+	minX, maxX := pts[0][0], pts[0][0]
+	for _, pt := range pts[1:] {
+		minX = math.Min(minX, pt[0])
+		maxX = math.Max(maxX, pt[0])
+	}
+	x0, x1 := 0, b.w
+	if !math.IsNaN(minX) && !math.IsNaN(maxX) {
+		x0 = int(math.Max(math.Floor(minX), 0))
+		x1 = int(math.Min(math.Ceil(maxX)+1, float64(b.w)))
+	}
+
+	c := b.getCoverage()
+	defer b.coverages.Put(c)
+	for cy0 := y0; cy0 < y1; cy0 += coverageRows {
+		cy1 := cy0 + coverageRows
+		if cy1 > y1 {
+			cy1 = y1
+		}
+		c.reset(cy0, cy1, canOverlap)
+
+		if canOverlap {
+			iterateTriangles(pts, c.addSamples)
+			for y := cy0; y < cy1; y++ {
+				row := c.masks[(y-cy0)*c.w:]
+				for x := x0; x < x1; x++ {
+					if row[x] != 0 {
+						fn(x, y, float64(bits.OnesCount64(row[x]))/(unionSamples*unionSamples))
+					}
+				}
+			}
+			continue
+		}
+
+		c.addTriangles(pts)
+		for y := cy0; y < cy1; y++ {
+			row := c.cells[(y-cy0)*c.stride:]
+			var acc float64
+			for x := x0; x < x1; x++ {
+				acc += row[x]
+				cov := math.Min(math.Abs(acc), 1)
+				if cov < 0.5/255 {
+					continue
+				}
+				fn(x, y, cov)
+			}
+		}
+	}
+}
+
+func (b *SoftwareBackend) fillTrianglesCoverage(pts []backendbase.Vec, y0, y1 int, canOverlap bool, fn func(x, y float64) color.RGBA) {
+	b.iterateCoverage(pts, y0, y1, canOverlap, func(x, y int, cov float64) {
+		clip := b.clip.AlphaAt(x, y)
+		if clip.A == 0 {
+			return
+		}
+		col := clipAlpha(fn(float64(x), float64(y)), clip)
+		col.A = uint8(math.Round(float64(col.A) * cov))
+		if col.A > 0 {
+			b.mix(x, y, col)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package softwarebackend_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+var coverageTriangles = []backendbase.Vec{
+	// shallow edges
+	{10.3, 20.7}, {90.2, 25.1}, {40.6, 80.9},
+	// partially outside of the image
+	{-20.5, 5.2}, {60.3, -10.1}, {30.7, 15.4},
+	// two triangles sharing an edge, in different orientations
+	{60.25, 60.5}, {95.75, 62.1}, {70.4, 97.3},
+	{60.25, 60.5}, {70.4, 97.3}, {50.1, 90.8},
+}
+
+var fullImage = [6]backendbase.Vec{{0, 0}, {100, 0}, {100, 100}, {0, 0}, {100, 100}, {0, 100}}
+
+var white = &backendbase.FillStyle{Color: color.RGBA{R: 255, G: 255, B: 255, A: 255}}
+
+func newBlack() *softwarebackend.SoftwareBackend {
+	backend := softwarebackend.New(100, 100)
+	for i := 3; i < len(backend.Image.Pix); i += 4 {
+		backend.Image.Pix[i] = 255
+	}
+	return backend
+}
+
+// clipPolygon clips the polygon to the half plane in which
+// inside returns true, with cross returning the point where
+// an edge crosses the border
+func clipPolygon(poly []backendbase.Vec, inside func(v backendbase.Vec) bool, cross func(a, b backendbase.Vec) backendbase.Vec) []backendbase.Vec {
+	var result []backendbase.Vec
+	for i, cur := range poly {
+		prev := poly[(i+len(poly)-1)%len(poly)]
+		if inside(cur) {
+			if !inside(prev) {
+				result = append(result, cross(prev, cur))
+			}
+			result = append(result, cur)
+		} else if inside(prev) {
+			result = append(result, cross(prev, cur))
+		}
+	}
+	return result
+}
+
+// pixelArea returns the exact area of the pixel at x, y that
+// is covered by the triangle
+func pixelArea(tri []backendbase.Vec, x, y int) float64 {
+	poly := append([]backendbase.Vec{}, tri...)
+	for _, edge := range []struct {
+		axis  int
+		value float64
+		less  bool
+	}{{0, float64(x), false}, {0, float64(x + 1), true}, {1, float64(y), false}, {1, float64(y + 1), true}} {
+		edge := edge
+		poly = clipPolygon(poly, func(v backendbase.Vec) bool {
+			return (v[edge.axis] <= edge.value) == edge.less
+		}, func(a, b backendbase.Vec) backendbase.Vec {
+			t := (edge.value - a[edge.axis]) / (b[edge.axis] - a[edge.axis])
+			return backendbase.Vec{a[0] + (b[0]-a[0])*t, a[1] + (b[1]-a[1])*t}
+		})
+		if len(poly) < 3 {
+			return 0
+		}
+	}
+	var area float64
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	return math.Abs(area) / 2
+}
+
+// exactArea returns the area of the pixel that is covered
+// by the triangles, which must not overlap
+func exactArea(tris []backendbase.Vec, x, y int) float64 {
+	var area float64
+	for i := 0; i < len(tris); i += 3 {
+		area += pixelArea(tris[i:i+3], x, y)
+	}
+	return math.Min(area, 1)
+}
+
+func checkCoverage(t *testing.T, name string, tris []backendbase.Vec, img *image.RGBA) {
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			expected := exactArea(tris, x, y)
+			if got := float64(img.RGBAAt(x, y).R) / 255; math.Abs(got-expected) > 1.0/255 {
+				t.Fatalf("%s: pixel %d,%d has coverage %g instead of %g", name, x, y, got, expected)
+			}
+		}
+	}
+}
+
+func TestCoverageFill(t *testing.T) {
+	backend := newBlack()
+	backend.AnalyticAA = true
+	backend.Fill(white, coverageTriangles, backendbase.MatIdentity, false)
+	checkCoverage(t, "fill", coverageTriangles, backend.Image)
+}
+
+func TestCoverageClip(t *testing.T) {
+	backend := newBlack()
+	backend.AnalyticAA = true
+	backend.Clip(coverageTriangles)
+	backend.Fill(white, fullImage[:], backendbase.MatIdentity, false)
+	checkCoverage(t, "clip", coverageTriangles, backend.Image)
+}
+
+func TestCoveragePrecedence(t *testing.T) {
+	render := func(analytic bool, msaa int) *image.RGBA {
+		backend := newBlack()
+		backend.AnalyticAA = analytic
+		backend.MSAA = msaa
+		backend.Fill(white, coverageTriangles, backendbase.MatIdentity, false)
+		return backend.Image
+	}
+	analytic, both, msaa := render(true, 0), render(true, 4), render(false, 4)
+
+	differs := false
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if c1, c2 := analytic.RGBAAt(x, y), both.RGBAAt(x, y); c1 != c2 {
+				t.Fatalf("pixel %d,%d is %v with analytic AA and %v with MSAA as well", x, y, c1, c2)
+			}
+			if analytic.RGBAAt(x, y) != msaa.RGBAAt(x, y) {
+				differs = true
+			}
+		}
+	}
+	if !differs {
+		t.Error("MSAA renders the same as analytic AA")
+	}
+}
+
+func TestCoverageOverlap(t *testing.T) {
+	// every triangle is drawn twice, which must not make the
+	// edges any brighter, and the triangles sharing an edge
+	// must not leave a seam. The union is made from samples,
+	// so it is only close to the exact area
+	tris := append(append([]backendbase.Vec{}, coverageTriangles...), coverageTriangles...)
+	backend := newBlack()
+	backend.AnalyticAA = true
+	backend.Fill(white, tris, backendbase.MatIdentity, true)
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			expected := exactArea(coverageTriangles, x, y)
+			if got := float64(backend.Image.RGBAAt(x, y).R) / 255; math.Abs(got-expected) > 0.1 {
+				t.Fatalf("pixel %d,%d has coverage %g instead of %g", x, y, got, expected)
+			}
+		}
+	}
+}
@@ -10,11 +10,15 @@ import (
 
 func (b *SoftwareBackend) Clear(pts [4]backendbase.Vec) {
 	iterateTriangles(pts[:], func(tri []backendbase.Vec) {
-		b.fillTriangleNoAA(tri, func(x, y int) {
-			if b.clip.AlphaAt(x, y).A == 0 {
+		b.fillTriangleNoAA(tri, 0, b.h, func(x, y int) {
+			clip := b.clip.AlphaAt(x, y)
+			if clip.A == 0 {
+				return
+			} else if clip.A == 255 {
+				b.Image.SetRGBA(x, y, color.RGBA{})
 				return
 			}
-			b.Image.SetRGBA(x, y, color.RGBA{})
+			b.Image.SetRGBA(x, y, lerp(color.RGBA{}, b.Image.RGBAAt(x, y), float64(clip.A)/255))
 		})
 	})
 }
@@ -36,17 +40,26 @@ func (b *SoftwareBackend) Fill(style *backendbase.FillStyle, pts []backendbase.V
 	}
 
 	if style.Blur > 0 {
-		b.activateBlurTarget()
-		b.fillTriangles(pts, ffn)
-		b.drawBlurred(style.Blur)
+		b.activateLayer(usesLayer(style))
+		b.fillTriangles(pts, canOverlap, ffn)
+		b.drawBlurred(style.Blur, style.Composite)
+	} else if usesLayer(style) {
+		b.activateLayer(true)
+		b.fillTriangles(pts, canOverlap, ffn)
+		b.drawLayer(style.Composite, style.Filter)
 	} else {
-		b.fillTriangles(pts, ffn)
+		b.fillTriangles(pts, canOverlap, ffn)
 	}
 }
 
 func (b *SoftwareBackend) FillImageMask(style *backendbase.FillStyle, mask *image.Alpha, pts [4]backendbase.Vec) {
 	ffn := fillFunc(style)
 
+	if usesLayer(style) {
+		b.activateLayer(true)
+		defer b.drawLayer(style.Composite, style.Filter)
+	}
+
 	mw := float64(mask.Bounds().Dx())
 	mh := float64(mask.Bounds().Dy())
 	b.fillQuad(pts, func(x, y, sx2, sy2 float64) color.RGBA {
@@ -57,7 +70,7 @@ func (b *SoftwareBackend) FillImageMask(style *backendbase.FillStyle, mask *imag
 			return color.RGBA{}
 		}
 		col := ffn(x, y)
-		return alphaColor(col, a)
+		return clipAlpha(col, a)
 	})
 }
 
@@ -99,11 +112,25 @@ func fillFunc(style *backendbase.FillStyle) func(x, y float64) color.RGBA {
 	} else if ip := style.ImagePattern; ip != nil {
 		ip := ip.(*ImagePattern)
 		img := ip.data.Image.(*Image)
-		mip := img.mips[0] // todo select the right mip size
 		w, h := img.Size()
 		fw, fh := float64(w), float64(h)
 		rx := ip.data.Repeat == backendbase.Repeat || ip.data.Repeat == backendbase.RepeatX
 		ry := ip.data.Repeat == backendbase.Repeat || ip.data.Repeat == backendbase.RepeatY
+		wx, wy := wrapFunc(clampWrap), wrapFunc(clampWrap)
+		if rx {
+			wx = repeatWrap
+		}
+		if ry {
+			wy = repeatWrap
+		}
+
+		// the transform maps canvas pixels to image pixels,
+		// so its determinant is the number of image pixels
+		// per canvas pixel
+		t := ip.data.Transform
+		scale := math.Abs(t[0]*t[4] - t[1]*t[3])
+		sample := img.sampler(style.ImageSmoothing, float64(w*h)/scale)
+
 		return func(x, y float64) color.RGBA {
 			pos := backendbase.Vec{x, y}
 			tfptx := pos[0]*ip.data.Transform[0] + pos[1]*ip.data.Transform[1] + ip.data.Transform[2]
@@ -116,16 +143,7 @@ func fillFunc(style *backendbase.FillStyle) func(x, y float64) color.RGBA {
 				return color.RGBA{}
 			}
 
-			mx := int(math.Floor(tfptx)) % w
-			if mx < 0 {
-				mx += w
-			}
-			my := int(math.Floor(tfpty)) % h
-			if my < 0 {
-				my += h
-			}
-
-			return toRGBA(mip.At(mx, my))
+			return sample(tfptx, tfpty, wx, wy)
 		}
 	}
 	return func(x, y float64) color.RGBA {
@@ -150,17 +168,26 @@ func (b *SoftwareBackend) ClearClip() {
 func (b *SoftwareBackend) Clip(pts []backendbase.Vec) {
 	b.clearStencil()
 
-	iterateTriangles(pts[:], func(tri []backendbase.Vec) {
-		b.fillTriangleNoAA(tri, func(x, y int) {
-			b.stencil.SetAlpha(x, y, color.Alpha{A: 255})
+	// with any kind of anti-aliasing the clip mask is
+	// rasterized with analytic coverage, since the
+	// combined mask can have fractional values anyway
+	if b.AnalyticAA || b.MSAA > 0 {
+		b.parallelRows(pts, func(y0, y1 int) {
+			b.iterateCoverage(pts, y0, y1, false, func(x, y int, cov float64) {
+				b.stencil.SetAlpha(x, y, color.Alpha{A: uint8(math.Round(cov * 255))})
+			})
 		})
-	})
+	} else {
+		iterateTriangles(pts[:], func(tri []backendbase.Vec) {
+			b.fillTriangleNoAA(tri, 0, b.h, func(x, y int) {
+				b.stencil.SetAlpha(x, y, color.Alpha{A: 255})
+			})
+		})
+	}
 
 	p := b.clip.Pix
 	p2 := b.stencil.Pix
 	for i := range p {
-		if p2[i] == 0 {
-			p[i] = 0
-		}
+		p[i] = uint8((int(p[i])*int(p2[i]) + 127) / 255)
 	}
 }
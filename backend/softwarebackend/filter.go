@@ -0,0 +1,108 @@
+package softwarebackend
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// applyFilter applies the filter functions one after the
+// other to the premultiplied image and returns the result.
+// The image may be modified
+func applyFilter(img *image.RGBA, filter backendbase.Filter, workers int) *image.RGBA {
+	for _, f := range filter {
+		if m, ok := f.ColorMatrix(); ok {
+			colorMatrix(img, m, workers)
+			continue
+		}
+		switch f.Type {
+		case backendbase.FilterBlur:
+			if f.Amount > 0 {
+				img = box3(img, f.Amount*2, workers)
+			}
+		case backendbase.FilterDropShadow:
+			img = dropShadow(img, f, workers)
+		}
+	}
+	return img
+}
+
+// colorMatrix multiplies the colors of the image with the
+// matrix. The matrix works on non-premultiplied colors, so
+// the colors are converted before and after
+func colorMatrix(img *image.RGBA, m [20]float64, workers int) {
+	bounds := img.Bounds()
+	parallel(bounds.Dy(), workers, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			off := img.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+			row := img.Pix[off : off+bounds.Dx()*4]
+			for i := 0; i < len(row); i += 4 {
+				if row[i+3] == 0 {
+					continue
+				}
+				a := float64(row[i+3]) / 255
+				c := [4]float64{
+					float64(row[i]) / 255 / a,
+					float64(row[i+1]) / 255 / a,
+					float64(row[i+2]) / 255 / a,
+					a,
+				}
+				var r [4]float64
+				for j := 0; j < 4; j++ {
+					v := m[j*5]*c[0] + m[j*5+1]*c[1] + m[j*5+2]*c[2] + m[j*5+3]*c[3] + m[j*5+4]
+					r[j] = math.Max(0, math.Min(1, v))
+				}
+				row[i] = uint8(math.Round(r[0] * r[3] * 255))
+				row[i+1] = uint8(math.Round(r[1] * r[3] * 255))
+				row[i+2] = uint8(math.Round(r[2] * r[3] * 255))
+				row[i+3] = uint8(math.Round(r[3] * 255))
+			}
+		}
+	})
+}
+
+// dropShadow draws the image over a blurred and offset copy
+// of its alpha channel in the shadow color
+func dropShadow(img *image.RGBA, f backendbase.FilterFunc, workers int) *image.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dx, dy := int(math.Round(f.OffsetX)), int(math.Round(f.OffsetY))
+
+	ca := int(f.Color.A)
+	cr := int(f.Color.R) * ca
+	cg := int(f.Color.G) * ca
+	cb := int(f.Color.B) * ca
+
+	shadow := image.NewRGBA(bounds)
+	parallel(h, workers, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			sy := y - dy
+			if sy < 0 || sy >= h {
+				continue
+			}
+			for x := 0; x < w; x++ {
+				sx := x - dx
+				if sx < 0 || sx >= w {
+					continue
+				}
+				a := int(img.Pix[img.PixOffset(bounds.Min.X+sx, bounds.Min.Y+sy)+3])
+				if a == 0 {
+					continue
+				}
+				off := shadow.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+				shadow.Pix[off] = uint8((cr*a + 255*255/2) / (255 * 255))
+				shadow.Pix[off+1] = uint8((cg*a + 255*255/2) / (255 * 255))
+				shadow.Pix[off+2] = uint8((cb*a + 255*255/2) / (255 * 255))
+				shadow.Pix[off+3] = uint8((ca*a + 127) / 255)
+			}
+		}
+	})
+
+	if f.Amount > 0 {
+		shadow = box3(shadow, f.Amount*2, workers)
+	}
+	draw.Draw(shadow, bounds, img, bounds.Min, draw.Over)
+	return shadow
+}
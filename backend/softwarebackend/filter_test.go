@@ -0,0 +1,84 @@
+package softwarebackend_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+func renderFilter(filter string, fn func(cv *canvas.Canvas)) *softwarebackend.SoftwareBackend {
+	backend := softwarebackend.New(100, 100)
+	cv := canvas.New(backend)
+	cv.SetFillStyle("#FFF")
+	cv.FillRect(0, 0, 100, 100)
+	cv.SetFilter(filter)
+	fn(cv)
+	return backend
+}
+
+func near(c1, c2 color.RGBA) bool {
+	d := func(a, b uint8) bool { return int(a)+2 >= int(b) && int(b)+2 >= int(a) }
+	return d(c1.R, c2.R) && d(c1.G, c2.G) && d(c1.B, c2.B) && d(c1.A, c2.A)
+}
+
+func TestFilterGrayscale(t *testing.T) {
+	backend := renderFilter("grayscale(100%)", func(cv *canvas.Canvas) {
+		cv.SetFillStyle("#F00")
+		cv.FillRect(20, 20, 60, 60)
+	})
+	if c := backend.Image.RGBAAt(50, 50); !near(c, color.RGBA{R: 54, G: 54, B: 54, A: 255}) {
+		t.Errorf("grayscale red is %v", c)
+	}
+	if c := backend.Image.RGBAAt(10, 10); c != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("background was changed to %v", c)
+	}
+}
+
+func TestFilterBlur(t *testing.T) {
+	backend := renderFilter("blur(4px)", func(cv *canvas.Canvas) {
+		cv.SetFillStyle("#000")
+		cv.FillRect(30, 30, 40, 40)
+	})
+	if c := backend.Image.RGBAAt(50, 50); c != (color.RGBA{A: 255}) {
+		t.Errorf("center of the blurred rect is %v", c)
+	}
+	if c := backend.Image.RGBAAt(2, 50); c != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("blur reaches too far, pixel is %v", c)
+	}
+	// the edge is smooth and darkens towards the inside
+	prev := uint8(255)
+	for x := 18; x <= 42; x++ {
+		c := backend.Image.RGBAAt(x, 50)
+		if c.R > prev {
+			t.Fatalf("blurred edge is not monotonic at %d", x)
+		}
+		prev = c.R
+	}
+	if c := backend.Image.RGBAAt(30, 50); c.R < 64 || c.R > 192 {
+		t.Errorf("blurred edge is %v", c)
+	}
+}
+
+func TestFilterDropShadow(t *testing.T) {
+	backend := renderFilter("drop-shadow(10px 5px #00F)", func(cv *canvas.Canvas) {
+		cv.SetFillStyle("#F00")
+		cv.FillRect(20, 20, 40, 40)
+	})
+	for _, c := range []struct {
+		x, y int
+		col  color.RGBA
+	}{
+		{40, 40, color.RGBA{R: 255, A: 255}},
+		{65, 40, color.RGBA{B: 255, A: 255}},
+		{40, 62, color.RGBA{B: 255, A: 255}},
+		{25, 22, color.RGBA{R: 255, A: 255}},
+		{75, 40, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{15, 40, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+	} {
+		if col := backend.Image.RGBAAt(c.x, c.y); col != c.col {
+			t.Errorf("pixel %d,%d is %v instead of %v", c.x, c.y, col, c.col)
+		}
+	}
+}
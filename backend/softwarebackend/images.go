@@ -3,7 +3,6 @@ package softwarebackend
 import (
 	"image"
 	"image/color"
-	"math"
 
 	"github.com/tfriedel6/canvas/backend/backendbase"
 )
@@ -43,55 +42,24 @@ func halveImage(img image.Image) (*image.RGBA, int, int) {
 	return rimg, w, h
 }
 
-func (b *SoftwareBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec, alpha float64) {
+func (b *SoftwareBackend) DrawImage(style *backendbase.FillStyle, dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec) {
 	simg := dimg.(*Image)
 	if simg.deleted {
 		return
 	}
 
-	bounds := simg.mips[0].Bounds()
-	w, h := bounds.Dx(), bounds.Dy()
-
-	factor := float64(w*h) / (sw * sh)
-	area := quadArea(pts) * factor
-	mip := simg.mips[0]
-	closest := math.MaxFloat64
-	mipW, mipH := w, h
-	for _, img := range simg.mips {
-		bounds := img.Bounds()
-		w, h := bounds.Dx(), bounds.Dy()
-		dist := math.Abs(float64(w*h) - area)
-		if dist < closest {
-			closest = dist
-			mip = img
-			mipW = w
-			mipH = h
-		}
+	if usesLayer(style) {
+		b.activateLayer(true)
+		defer b.drawLayer(style.Composite, style.Filter)
 	}
 
-	mipScaleX := float64(mipW) / float64(w)
-	mipScaleY := float64(mipH) / float64(h)
-	sx *= mipScaleX
-	sy *= mipScaleY
-	sw *= mipScaleX
-	sh *= mipScaleY
+	w, h := simg.Size()
+	factor := float64(w*h) / (sw * sh)
+	sample := simg.sampler(style.ImageSmoothing, quadArea(pts)*factor)
 
 	b.fillQuad(pts, func(x, y, tx, ty float64) color.RGBA {
-		imgx := sx + sw*tx
-		imgy := sy + sh*ty
-		imgxf := math.Floor(imgx)
-		imgyf := math.Floor(imgy)
-		return toRGBA(mip.At(int(imgxf), int(imgyf)))
-
-		// rx := imgx - imgxf
-		// ry := imgy - imgyf
-		// ca := mip.At(int(imgxf), int(imgyf))
-		// cb := mip.At(int(imgxf+1), int(imgyf))
-		// cc := mip.At(int(imgxf), int(imgyf+1))
-		// cd := mip.At(int(imgxf+1), int(imgyf+1))
-		// ctop := lerp(ca, cb, rx)
-		// cbtm := lerp(cc, cd, rx)
-		// b.Image.Set(x, y, lerp(ctop, cbtm, ry))
+		col := sample(sx+sw*tx, sy+sh*ty, clampWrap, clampWrap)
+		return unpremultiply(scaleAlpha(col, style.Color.A))
 	})
 }
 
@@ -0,0 +1,223 @@
+package softwarebackend
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// wrapFunc maps a pixel coordinate to one within the size
+type wrapFunc func(v, size int) int
+
+func clampWrap(v, size int) int {
+	if v < 0 {
+		return 0
+	} else if v >= size {
+		return size - 1
+	}
+	return v
+}
+
+func repeatWrap(v, size int) int {
+	v %= size
+	if v < 0 {
+		v += size
+	}
+	return v
+}
+
+// sampleFunc returns the color of the image at the position
+// in pixels, where whole numbers are on the edges of the
+// pixels
+type sampleFunc func(img image.Image, x, y float64, wx, wy wrapFunc) color.RGBA
+
+// sampler returns a function that samples the image at
+// positions in pixels of the full size image, for the image
+// smoothing setting and the number of pixels that the whole
+// image is drawn to. SmoothingLow uses the mipmap level
+// closest in size, while SmoothingMedium and SmoothingHigh
+// blend the two levels around the size, with SmoothingHigh
+// also interpolating bicubic instead of bilinear
+func (img *Image) sampler(smoothing backendbase.ImageSmoothing, area float64) func(x, y float64, wx, wy wrapFunc) color.RGBA {
+	switch smoothing {
+	case backendbase.SmoothingDisabled:
+		mip := img.mips[0]
+		return func(x, y float64, wx, wy wrapFunc) color.RGBA {
+			return sampleNearest(mip, x, y, wx, wy)
+		}
+	case backendbase.SmoothingLow:
+		mip, scaleX, scaleY := img.mip(area)
+		return func(x, y float64, wx, wy wrapFunc) color.RGBA {
+			return sampleBilinear(mip, x*scaleX, y*scaleY, wx, wy)
+		}
+	}
+
+	sample := sampleFunc(sampleBilinear)
+	if smoothing == backendbase.SmoothingHigh {
+		sample = sampleBicubic
+	}
+	mip0, scaleX0, scaleY0, mip1, scaleX1, scaleY1, t := img.mipPair(area)
+	if t == 0 {
+		return func(x, y float64, wx, wy wrapFunc) color.RGBA {
+			return sample(mip0, x*scaleX0, y*scaleY0, wx, wy)
+		}
+	}
+	return func(x, y float64, wx, wy wrapFunc) color.RGBA {
+		c0 := sample(mip0, x*scaleX0, y*scaleY0, wx, wy)
+		c1 := sample(mip1, x*scaleX1, y*scaleY1, wx, wy)
+		return lerp(c1, c0, t)
+	}
+}
+
+func sampleNearest(img image.Image, x, y float64, wx, wy wrapFunc) color.RGBA {
+	bounds := img.Bounds()
+	ix := wx(int(math.Floor(x)), bounds.Dx())
+	iy := wy(int(math.Floor(y)), bounds.Dy())
+	return toRGBA(img.At(bounds.Min.X+ix, bounds.Min.Y+iy))
+}
+
+func sampleBilinear(img image.Image, x, y float64, wx, wy wrapFunc) color.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	x -= 0.5
+	y -= 0.5
+	fx, fy := math.Floor(x), math.Floor(y)
+	rx, ry := x-fx, y-fy
+	x0, y0 := int(fx), int(fy)
+
+	var sum [4]float64
+	for j := 0; j < 2; j++ {
+		wy1 := 1 - ry
+		if j == 1 {
+			wy1 = ry
+		}
+		sy := bounds.Min.Y + wy(y0+j, h)
+		for i := 0; i < 2; i++ {
+			wx1 := 1 - rx
+			if i == 1 {
+				wx1 = rx
+			}
+			r, g, b, a := img.At(bounds.Min.X+wx(x0+i, w), sy).RGBA()
+			f := wx1 * wy1
+			sum[0] += float64(r) * f
+			sum[1] += float64(g) * f
+			sum[2] += float64(b) * f
+			sum[3] += float64(a) * f
+		}
+	}
+	return sumColor(sum)
+}
+
+// sampleBicubic interpolates the 4x4 pixels around the
+// position with Catmull-Rom splines
+func sampleBicubic(img image.Image, x, y float64, wx, wy wrapFunc) color.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	x -= 0.5
+	y -= 0.5
+	fx, fy := math.Floor(x), math.Floor(y)
+	x0, y0 := int(fx)-1, int(fy)-1
+	kx := catmullRom(x - fx)
+	ky := catmullRom(y - fy)
+
+	var sum [4]float64
+	for j := 0; j < 4; j++ {
+		sy := bounds.Min.Y + wy(y0+j, h)
+		for i := 0; i < 4; i++ {
+			r, g, b, a := img.At(bounds.Min.X+wx(x0+i, w), sy).RGBA()
+			f := kx[i] * ky[j]
+			sum[0] += float64(r) * f
+			sum[1] += float64(g) * f
+			sum[2] += float64(b) * f
+			sum[3] += float64(a) * f
+		}
+	}
+
+	// the spline can overshoot, and since the colors are
+	// premultiplied they must not exceed the alpha value
+	sum[3] = math.Max(0, math.Min(sum[3], 65535))
+	for i := 0; i < 3; i++ {
+		sum[i] = math.Max(0, math.Min(sum[i], sum[3]))
+	}
+	return sumColor(sum)
+}
+
+// catmullRom returns the weights of the four pixels for the
+// fractional position t between the second and third pixel
+func catmullRom(t float64) [4]float64 {
+	t2 := t * t
+	t3 := t2 * t
+	return [4]float64{
+		0.5 * (-t3 + 2*t2 - t),
+		0.5 * (3*t3 - 5*t2 + 2),
+		0.5 * (-3*t3 + 4*t2 + t),
+		0.5 * (t3 - t2),
+	}
+}
+
+func sumColor(sum [4]float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(math.Round(sum[0] / 257)),
+		G: uint8(math.Round(sum[1] / 257)),
+		B: uint8(math.Round(sum[2] / 257)),
+		A: uint8(math.Round(sum[3] / 257)),
+	}
+}
+
+// mip returns the mipmap level with the size closest to the
+// given area in pixels, along with its scale compared to
+// the full size image
+func (img *Image) mip(area float64) (mip image.Image, scaleX, scaleY float64) {
+	bounds := img.mips[0].Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	mip = img.mips[0]
+	closest := math.MaxFloat64
+	mipW, mipH := w, h
+	for _, m := range img.mips {
+		bounds := m.Bounds()
+		mw, mh := bounds.Dx(), bounds.Dy()
+		dist := math.Abs(float64(mw*mh) - area)
+		if dist < closest {
+			closest = dist
+			mip = m
+			mipW = mw
+			mipH = mh
+		}
+	}
+
+	return mip, float64(mipW) / float64(w), float64(mipH) / float64(h)
+}
+
+// mipPair returns the two mipmap levels that are larger and
+// smaller than the given area in pixels along with their
+// scales, and how far the area is from the larger level
+// towards the smaller one
+func (img *Image) mipPair(area float64) (mip0 image.Image, scaleX0, scaleY0 float64, mip1 image.Image, scaleX1, scaleY1, t float64) {
+	bounds := img.mips[0].Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	scale := func(m image.Image) (float64, float64) {
+		b := m.Bounds()
+		return float64(b.Dx()) / float64(w), float64(b.Dy()) / float64(h)
+	}
+
+	// every level has a quarter of the pixels of the one
+	// before it
+	lod := 0.5 * math.Log2(float64(w*h)/area)
+	if !(lod > 0) {
+		scaleX0, scaleY0 = scale(img.mips[0])
+		return img.mips[0], scaleX0, scaleY0, img.mips[0], scaleX0, scaleY0, 0
+	}
+	last := len(img.mips) - 1
+	if lod >= float64(last) {
+		scaleX0, scaleY0 = scale(img.mips[last])
+		return img.mips[last], scaleX0, scaleY0, img.mips[last], scaleX0, scaleY0, 0
+	}
+	level := int(lod)
+	mip0, mip1 = img.mips[level], img.mips[level+1]
+	scaleX0, scaleY0 = scale(mip0)
+	scaleX1, scaleY1 = scale(mip1)
+	return mip0, scaleX0, scaleY0, mip1, scaleX1, scaleY1, lod - float64(level)
+}
@@ -0,0 +1,118 @@
+package softwarebackend_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// grayRow returns an opaque image with a single row of the
+// given gray values
+func grayRow(values ...uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(values), 1))
+	for x, v := range values {
+		img.SetRGBA(x, 0, color.RGBA{R: v, G: v, B: v, A: 255})
+	}
+	return img
+}
+
+// drawSampled draws the image into the rectangle with the
+// image smoothing setting and returns the gray values of
+// the first row
+func drawSampled(t *testing.T, img image.Image, smoothing backendbase.ImageSmoothing, x, w, h float64) []uint8 {
+	t.Helper()
+	backend := softwarebackend.New(16, 16)
+	bimg, err := backend.LoadImage(img)
+	if err != nil {
+		t.Fatalf("failed to load image: %v", err)
+	}
+	style := &backendbase.FillStyle{Color: color.RGBA{A: 255}, ImageSmoothing: smoothing}
+	bounds := img.Bounds()
+	backend.DrawImage(style, bimg, 0, 0, float64(bounds.Dx()), float64(bounds.Dy()),
+		[4]backendbase.Vec{{x, 0}, {x, h}, {x + w, h}, {x + w, 0}})
+	row := make([]uint8, 16)
+	for i := range row {
+		c := backend.Image.RGBAAt(i, 0)
+		if c.R != c.G || c.R != c.B {
+			t.Fatalf("pixel %d is %v instead of gray", i, c)
+		}
+		row[i] = c.R
+	}
+	return row
+}
+
+func checkRow(t *testing.T, name string, row []uint8, expected ...uint8) {
+	t.Helper()
+	for i, v := range expected {
+		if row[i] != v {
+			t.Errorf("%s: got %v instead of %v", name, row[:len(expected)], expected)
+			return
+		}
+	}
+}
+
+func TestSampleNearest(t *testing.T) {
+	// at twice the size every pixel is repeated
+	row := drawSampled(t, grayRow(10, 200, 90, 255), backendbase.SmoothingDisabled, 0, 8, 2)
+	checkRow(t, "nearest", row, 10, 10, 200, 200, 90, 90, 255, 255)
+}
+
+func TestSampleBilinear(t *testing.T) {
+	// moved by half a pixel, every pixel is in the middle of
+	// two pixels of the image
+	row := drawSampled(t, grayRow(0, 100, 200, 250), backendbase.SmoothingLow, 0.5, 4, 1)
+	checkRow(t, "half pixel", row[1:], 50, 150, 225)
+
+	// at twice the size the pixels are a quarter of the way
+	// between the pixels of the image, and the edges are
+	// clamped
+	row = drawSampled(t, grayRow(0, 255), backendbase.SmoothingLow, 0, 4, 1)
+	checkRow(t, "twice the size", row, 0, 64, 191, 255)
+}
+
+func TestSampleBicubic(t *testing.T) {
+	// the spline overshoots next to an edge
+	row := drawSampled(t, grayRow(64, 64, 192, 192), backendbase.SmoothingHigh, 0.5, 4, 1)
+	checkRow(t, "soft edge", row[1:], 56, 128, 200)
+
+	// and on a hard edge it is clamped to the valid range
+	row = drawSampled(t, grayRow(0, 0, 255, 255), backendbase.SmoothingHigh, 0.5, 4, 1)
+	checkRow(t, "hard edge", row[1:], 0, 128, 255)
+}
+
+func TestSampleMipmaps(t *testing.T) {
+	// one white pixel in every 4x4 block averages to 63 in
+	// the half size mipmap level and to 15 in the quarter
+	// size one, where all the pixels are the same
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 2; y < 16; y += 4 {
+		for x := 2; x < 16; x += 4 {
+			img.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	for _, smoothing := range []backendbase.ImageSmoothing{backendbase.SmoothingLow, backendbase.SmoothingMedium, backendbase.SmoothingHigh} {
+		row := drawSampled(t, img, smoothing, 0, 4, 4)
+		checkRow(t, "quarter size", row, 15, 15, 15, 15)
+	}
+	row := drawSampled(t, img, backendbase.SmoothingDisabled, 0, 4, 4)
+	checkRow(t, "quarter size without smoothing", row, 255, 255, 255, 255)
+
+	// between the levels the closest one is used, unless the
+	// two levels are blended, which brings in the pattern of
+	// the half size level
+	low := drawSampled(t, img, backendbase.SmoothingLow, 0, 6, 6)
+	checkRow(t, "closest level", low, 15, 15, 15, 15, 15, 15)
+	medium := drawSampled(t, img, backendbase.SmoothingMedium, 0, 6, 6)
+	blended := false
+	for _, v := range medium[:6] {
+		if v != 15 {
+			blended = true
+		}
+	}
+	if !blended {
+		t.Errorf("blended levels: got %v", medium[:6])
+	}
+}
@@ -3,6 +3,7 @@ package softwarebackend
 import (
 	"image"
 	"image/draw"
+	"sync"
 
 	"github.com/tfriedel6/canvas/backend/backendbase"
 )
@@ -12,11 +13,29 @@ type SoftwareBackend struct {
 
 	MSAA int
 
-	blurSwap *image.RGBA
+	// AnalyticAA enables anti-aliasing of fills by
+	// calculating the exact area of each pixel that is
+	// covered. It is faster than MSAA and takes precedence
+	// over it
+	AnalyticAA bool
+
+	// Workers is the number of goroutines that are used for
+	// drawing. The image is split into tiles that are drawn
+	// concurrently, with the same result as drawing them one
+	// after another
+	Workers int
+
+	layer       *image.RGBA
+	layerSwap   *image.RGBA
+	premulLayer bool
+	clipSwap    *image.Alpha
+	noClip      *image.Alpha
 
 	clip    *image.Alpha
 	stencil *image.Alpha
 	w, h    int
+
+	coverages sync.Pool
 }
 
 func New(w, h int) *SoftwareBackend {
@@ -30,6 +49,8 @@ func (b *SoftwareBackend) SetSize(w, h int) {
 	b.Image = image.NewRGBA(image.Rect(0, 0, w, h))
 	b.clip = image.NewAlpha(image.Rect(0, 0, w, h))
 	b.stencil = image.NewAlpha(image.Rect(0, 0, w, h))
+	b.layer = nil
+	b.noClip = nil
 	b.ClearClip()
 }
 
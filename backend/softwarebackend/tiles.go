@@ -0,0 +1,85 @@
+package softwarebackend
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// minTileSize is the minimum number of rows or columns that
+// are processed by a worker at once
+const minTileSize = 16
+
+// parallel splits the range from 0 to n into tiles and calls
+// fn for each of them, using up to workers goroutines. As
+// long as fn only writes to its own tile, the result is the
+// same as with a single call for the whole range
+func parallel(n, workers int, fn func(start, end int)) {
+	if workers <= 1 || n <= minTileSize {
+		fn(0, n)
+		return
+	}
+
+	size := (n + workers*4 - 1) / (workers * 4)
+	if size < minTileSize {
+		size = minTileSize
+	}
+	count := (n + size - 1) / size
+	if workers > count {
+		workers = count
+	}
+
+	var next int32
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				tile := int(atomic.AddInt32(&next, 1)) - 1
+				if tile >= count {
+					return
+				}
+				start := tile * size
+				end := start + size
+				if end > n {
+					end = n
+				}
+				fn(start, end)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// parallelRows calls fn for tiles of rows that together
+// cover all the rows that the points can touch. Every pixel
+// is drawn by only one tile, so the tiles can be drawn
+// concurrently without changing the result
+func (b *SoftwareBackend) parallelRows(pts []backendbase.Vec, fn func(y0, y1 int)) {
+	if len(pts) == 0 {
+		fn(0, b.h)
+		return
+	}
+
+	minY, maxY := pts[0][1], pts[0][1]
+	for _, pt := range pts[1:] {
+		minY = math.Min(minY, pt[1])
+		maxY = math.Max(maxY, pt[1])
+	}
+	if math.IsNaN(minY) || math.IsNaN(maxY) {
+		fn(0, b.h)
+		return
+	}
+	y0 := int(math.Max(math.Floor(minY), 0))
+	y1 := int(math.Min(math.Ceil(maxY)+1, float64(b.h)))
+	if y1 <= y0 {
+		return
+	}
+
+	parallel(y1-y0, b.Workers, func(start, end int) {
+		fn(y0+start, y0+end)
+	})
+}
@@ -48,18 +48,18 @@ func triangleLR(tri []backendbase.Vec, y float64) (l, r float64, outside bool) {
 	return
 }
 
-func (b *SoftwareBackend) fillTriangleNoAA(tri []backendbase.Vec, fn func(x, y int)) {
+func (b *SoftwareBackend) fillTriangleNoAA(tri []backendbase.Vec, y0, y1 int, fn func(x, y int)) {
 	minY := int(math.Floor(math.Min(math.Min(tri[0][1], tri[1][1]), tri[2][1])))
 	maxY := int(math.Ceil(math.Max(math.Max(tri[0][1], tri[1][1]), tri[2][1])))
-	if minY < 0 {
-		minY = 0
-	} else if minY >= b.h {
+	if minY < y0 {
+		minY = y0
+	} else if minY >= y1 {
 		return
 	}
-	if maxY < 0 {
+	if maxY < y0 {
 		return
-	} else if maxY >= b.h {
-		maxY = b.h - 1
+	} else if maxY >= y1 {
+		maxY = y1 - 1
 	}
 	for y := minY; y <= maxY; y++ {
 		l, r, out := triangleLR(tri, float64(y)+0.5)
@@ -96,20 +96,20 @@ type msaaPixel struct {
 	tx, ty float64
 }
 
-func (b *SoftwareBackend) fillTriangleMSAA(tri []backendbase.Vec, msaaLevel int, msaaPixels []msaaPixel, fn func(x, y int)) []msaaPixel {
+func (b *SoftwareBackend) fillTriangleMSAA(tri []backendbase.Vec, y0, y1 int, msaaLevel int, msaaPixels []msaaPixel, fn func(x, y int)) []msaaPixel {
 	msaaStep := 1.0 / float64(msaaLevel+1)
 
 	minY := int(math.Floor(math.Min(math.Min(tri[0][1], tri[1][1]), tri[2][1])))
 	maxY := int(math.Ceil(math.Max(math.Max(tri[0][1], tri[1][1]), tri[2][1])))
-	if minY < 0 {
-		minY = 0
-	} else if minY >= b.h {
+	if minY < y0 {
+		minY = y0
+	} else if minY >= y1 {
 		return msaaPixels
 	}
-	if maxY < 0 {
+	if maxY < y0 {
 		return msaaPixels
-	} else if maxY >= b.h {
-		maxY = b.h - 1
+	} else if maxY >= y1 {
+		maxY = y1 - 1
 	}
 
 	for y := minY; y <= maxY; y++ {
@@ -202,18 +202,18 @@ func quadArea(quad [4]backendbase.Vec) float64 {
 	return math.Abs(leftv[0]*topv[1] - leftv[1]*topv[0])
 }
 
-func (b *SoftwareBackend) fillQuadNoAA(quad [4]backendbase.Vec, fn func(x, y int, tx, ty float64)) {
+func (b *SoftwareBackend) fillQuadNoAA(quad [4]backendbase.Vec, y0, y1 int, fn func(x, y int, tx, ty float64)) {
 	minY := int(math.Floor(math.Min(math.Min(quad[0][1], quad[1][1]), math.Min(quad[2][1], quad[3][1]))))
 	maxY := int(math.Ceil(math.Max(math.Max(quad[0][1], quad[1][1]), math.Max(quad[2][1], quad[3][1]))))
-	if minY < 0 {
-		minY = 0
-	} else if minY >= b.h {
+	if minY < y0 {
+		minY = y0
+	} else if minY >= y1 {
 		return
 	}
-	if maxY < 0 {
+	if maxY < y0 {
 		return
-	} else if maxY >= b.h {
-		maxY = b.h - 1
+	} else if maxY >= y1 {
+		maxY = y1 - 1
 	}
 
 	leftv := backendbase.Vec{quad[1][0] - quad[0][0], quad[1][1] - quad[0][1]}
@@ -272,20 +272,20 @@ func (b *SoftwareBackend) fillQuadNoAA(quad [4]backendbase.Vec, fn func(x, y int
 	}
 }
 
-func (b *SoftwareBackend) fillQuadMSAA(quad [4]backendbase.Vec, msaaLevel int, msaaPixels []msaaPixel, fn func(x, y int, tx, ty float64)) []msaaPixel {
+func (b *SoftwareBackend) fillQuadMSAA(quad [4]backendbase.Vec, y0, y1 int, msaaLevel int, msaaPixels []msaaPixel, fn func(x, y int, tx, ty float64)) []msaaPixel {
 	msaaStep := 1.0 / float64(msaaLevel+1)
 
 	minY := int(math.Floor(math.Min(math.Min(quad[0][1], quad[1][1]), math.Min(quad[2][1], quad[3][1]))))
 	maxY := int(math.Ceil(math.Max(math.Max(quad[0][1], quad[1][1]), math.Max(quad[2][1], quad[3][1]))))
-	if minY < 0 {
-		minY = 0
-	} else if minY >= b.h {
+	if minY < y0 {
+		minY = y0
+	} else if minY >= y1 {
 		return msaaPixels
 	}
-	if maxY < 0 {
+	if maxY < y0 {
 		return msaaPixels
-	} else if maxY >= b.h {
-		maxY = b.h - 1
+	} else if maxY >= y1 {
+		maxY = y1 - 1
 	}
 
 	leftv := backendbase.Vec{quad[1][0] - quad[0][0], quad[1][1] - quad[0][1]}
@@ -405,21 +405,28 @@ func (b *SoftwareBackend) fillQuadMSAA(quad [4]backendbase.Vec, msaaLevel int, m
 func (b *SoftwareBackend) fillQuad(pts [4]backendbase.Vec, fn func(x, y, tx, ty float64) color.RGBA) {
 	b.clearStencil()
 
+	b.parallelRows(pts[:], func(y0, y1 int) {
+		b.fillQuadRows(pts, y0, y1, fn)
+	})
+}
+
+func (b *SoftwareBackend) fillQuadRows(pts [4]backendbase.Vec, y0, y1 int, fn func(x, y, tx, ty float64) color.RGBA) {
 	if b.MSAA > 0 {
 		var msaaPixelBuf [500]msaaPixel
 		msaaPixels := msaaPixelBuf[:0]
 
-		msaaPixels = b.fillQuadMSAA(pts, b.MSAA, msaaPixels, func(x, y int, tx, ty float64) {
-			if b.clip.AlphaAt(x, y).A == 0 {
+		msaaPixels = b.fillQuadMSAA(pts, y0, y1, b.MSAA, msaaPixels, func(x, y int, tx, ty float64) {
+			clip := b.clip.AlphaAt(x, y)
+			if clip.A == 0 {
 				return
 			}
 			if b.stencil.AlphaAt(x, y).A > 0 {
 				return
 			}
 			b.stencil.SetAlpha(x, y, color.Alpha{A: 255})
-			col := fn(float64(x)+0.5, float64(y)+0.5, tx, ty)
+			col := clipAlpha(fn(float64(x)+0.5, float64(y)+0.5, tx, ty), clip)
 			if col.A > 0 {
-				b.Image.SetRGBA(x, y, mix(col, b.Image.RGBAAt(x, y)))
+				b.mix(x, y, col)
 			}
 		})
 
@@ -452,21 +459,22 @@ func (b *SoftwareBackend) fillQuad(pts [4]backendbase.Vec, fn func(x, y, tx, ty
 				B: uint8(mb / samples),
 				A: uint8(ma / samples),
 			}
-			b.Image.SetRGBA(px.ix, px.iy, mix(combined, b.Image.RGBAAt(px.ix, px.iy)))
+			b.mix(px.ix, px.iy, clipAlpha(combined, b.clip.AlphaAt(px.ix, px.iy)))
 		}
 
 	} else {
-		b.fillQuadNoAA(pts, func(x, y int, tx, ty float64) {
-			if b.clip.AlphaAt(x, y).A == 0 {
+		b.fillQuadNoAA(pts, y0, y1, func(x, y int, tx, ty float64) {
+			clip := b.clip.AlphaAt(x, y)
+			if clip.A == 0 {
 				return
 			}
 			if b.stencil.AlphaAt(x, y).A > 0 {
 				return
 			}
 			b.stencil.SetAlpha(x, y, color.Alpha{A: 255})
-			col := fn(float64(x)+0.5, float64(y)+0.5, tx, ty)
+			col := clipAlpha(fn(float64(x)+0.5, float64(y)+0.5, tx, ty), clip)
 			if col.A > 0 {
-				b.Image.SetRGBA(x, y, mix(col, b.Image.RGBAAt(x, y)))
+				b.mix(x, y, col)
 			}
 		})
 	}
@@ -489,40 +497,42 @@ func iterateTriangles(pts []backendbase.Vec, fn func(tri []backendbase.Vec)) {
 	}
 }
 
-func (b *SoftwareBackend) fillTrianglesNoAA(pts []backendbase.Vec, fn func(x, y float64) color.RGBA) {
+func (b *SoftwareBackend) fillTrianglesNoAA(pts []backendbase.Vec, y0, y1 int, fn func(x, y float64) color.RGBA) {
 	iterateTriangles(pts[:], func(tri []backendbase.Vec) {
-		b.fillTriangleNoAA(tri, func(x, y int) {
-			if b.clip.AlphaAt(x, y).A == 0 {
+		b.fillTriangleNoAA(tri, y0, y1, func(x, y int) {
+			clip := b.clip.AlphaAt(x, y)
+			if clip.A == 0 {
 				return
 			}
 			if b.stencil.AlphaAt(x, y).A > 0 {
 				return
 			}
 			b.stencil.SetAlpha(x, y, color.Alpha{A: 255})
-			col := fn(float64(x), float64(y))
+			col := clipAlpha(fn(float64(x), float64(y)), clip)
 			if col.A > 0 {
-				b.Image.SetRGBA(x, y, mix(col, b.Image.RGBAAt(x, y)))
+				b.mix(x, y, col)
 			}
 		})
 	})
 }
 
-func (b *SoftwareBackend) fillTrianglesMSAA(pts []backendbase.Vec, msaaLevel int, fn func(x, y float64) color.RGBA) {
+func (b *SoftwareBackend) fillTrianglesMSAA(pts []backendbase.Vec, y0, y1 int, msaaLevel int, fn func(x, y float64) color.RGBA) {
 	var msaaPixelBuf [500]msaaPixel
 	msaaPixels := msaaPixelBuf[:0]
 
 	iterateTriangles(pts[:], func(tri []backendbase.Vec) {
-		msaaPixels = b.fillTriangleMSAA(tri, msaaLevel, msaaPixels, func(x, y int) {
-			if b.clip.AlphaAt(x, y).A == 0 {
+		msaaPixels = b.fillTriangleMSAA(tri, y0, y1, msaaLevel, msaaPixels, func(x, y int) {
+			clip := b.clip.AlphaAt(x, y)
+			if clip.A == 0 {
 				return
 			}
 			if b.stencil.AlphaAt(x, y).A > 0 {
 				return
 			}
 			b.stencil.SetAlpha(x, y, color.Alpha{A: 255})
-			col := fn(float64(x), float64(y))
+			col := clipAlpha(fn(float64(x), float64(y)), clip)
 			if col.A > 0 {
-				b.Image.SetRGBA(x, y, mix(col, b.Image.RGBAAt(x, y)))
+				b.mix(x, y, col)
 			}
 		})
 	})
@@ -556,16 +566,25 @@ func (b *SoftwareBackend) fillTrianglesMSAA(pts []backendbase.Vec, msaaLevel int
 			B: uint8(mb / samples),
 			A: uint8(ma / samples),
 		}
-		b.Image.SetRGBA(px.ix, px.iy, mix(combined, b.Image.RGBAAt(px.ix, px.iy)))
+		b.mix(px.ix, px.iy, clipAlpha(combined, b.clip.AlphaAt(px.ix, px.iy)))
 	}
 }
 
-func (b *SoftwareBackend) fillTriangles(pts []backendbase.Vec, fn func(x, y float64) color.RGBA) {
+func (b *SoftwareBackend) fillTriangles(pts []backendbase.Vec, canOverlap bool, fn func(x, y float64) color.RGBA) {
+	if b.AnalyticAA {
+		b.parallelRows(pts, func(y0, y1 int) {
+			b.fillTrianglesCoverage(pts, y0, y1, canOverlap, fn)
+		})
+		return
+	}
+
 	b.clearStencil()
 
-	if b.MSAA > 0 {
-		b.fillTrianglesMSAA(pts, b.MSAA, fn)
-	} else {
-		b.fillTrianglesNoAA(pts, fn)
-	}
+	b.parallelRows(pts, func(y0, y1 int) {
+		if b.MSAA > 0 {
+			b.fillTrianglesMSAA(pts, y0, y1, b.MSAA, fn)
+		} else {
+			b.fillTrianglesNoAA(pts, y0, y1, fn)
+		}
+	})
 }
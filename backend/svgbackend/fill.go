@@ -0,0 +1,285 @@
+package svgbackend
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+func (b *SVGBackend) Fill(style *backendbase.FillStyle, pts []backendbase.Vec, tf backendbase.Mat, canOverlap bool) {
+	b.raster.Fill(b.rasterStyle(style), pts, tf, canOverlap)
+
+	if tf != backendbase.MatIdentity {
+		tfpts := make([]backendbase.Vec, len(pts))
+		for i, pt := range pts {
+			tfpts[i] = pt.MulMat(tf)
+		}
+		pts = tfpts
+	}
+
+	d := trianglesPath(pts)
+	if d == "" {
+		return
+	}
+	b.draw(style, false, backendbase.MatIdentity, func(paint string) string {
+		return fmt.Sprintf("<path d=\"%s\"%s/>", d, paint)
+	})
+}
+
+func (b *SVGBackend) FillImageMask(style *backendbase.FillStyle, mask *image.Alpha, pts [4]backendbase.Vec) {
+	b.raster.FillImageMask(b.rasterStyle(style), mask, pts)
+
+	// SVG masks use the luminance, so the alpha values
+	// are converted to a grayscale image
+	bounds := mask.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			gray.SetGray(x, y, color.Gray{Y: mask.AlphaAt(bounds.Min.X+x, bounds.Min.Y+y).A})
+		}
+	}
+
+	mw, mh := float64(bounds.Dx()), float64(bounds.Dy())
+	id := b.id("m")
+	fmt.Fprintf(&b.defs, "<mask id=\"%s\" maskUnits=\"userSpaceOnUse\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\">", id, b.w, b.h)
+	fmt.Fprintf(&b.defs, "<image width=\"%d\" height=\"%d\"%s xlink:href=\"%s\"/>", bounds.Dx(), bounds.Dy(), matrixAttr("transform", quadMatrix(pts, mw, mh)), pngDataURI(gray))
+	b.defs.WriteString("</mask>\n")
+
+	d := polygonPath(pts[:])
+	b.draw(style, false, backendbase.MatIdentity, func(paint string) string {
+		return fmt.Sprintf("<path d=\"%s\"%s mask=\"url(#%s)\"/>", d, paint, id)
+	})
+}
+
+// rasterStyle returns a copy of the style that refers to
+// the gradients and patterns of the software backend
+func (b *SVGBackend) rasterStyle(style *backendbase.FillStyle) *backendbase.FillStyle {
+	stl := *style
+	if lg, ok := style.LinearGradient.(*LinearGradient); ok {
+		stl.LinearGradient = lg.raster
+	}
+	if rg, ok := style.RadialGradient.(*RadialGradient); ok {
+		stl.RadialGradient = rg.raster
+	}
+	if ip, ok := style.ImagePattern.(*ImagePattern); ok {
+		stl.ImagePattern = ip.raster
+	}
+	return &stl
+}
+
+// paint returns the fill attributes for the style, or the
+// stroke attributes if stroke is true. tf is the transform
+// of the element, which the gradients and patterns have to
+// be adjusted for. If the fill also has to be clipped, for
+// example for image patterns that don't repeat, the clip
+// path id is returned as well
+func (b *SVGBackend) paint(style *backendbase.FillStyle, stroke bool, tf backendbase.Mat) (attrs, clip string) {
+	var gradientTransform string
+	if tf != backendbase.MatIdentity {
+		gradientTransform = matrixAttr("gradientTransform", tf.Invert())
+	}
+
+	var fill string
+	if lg, ok := style.LinearGradient.(*LinearGradient); ok {
+		id := b.id("g")
+		fmt.Fprintf(&b.defs, "<linearGradient id=\"%s\" xlink:href=\"#%s\" gradientUnits=\"userSpaceOnUse\" x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\"%s/>\n",
+			id, b.gradientStops(&lg.gradient),
+			num(style.Gradient.X0), num(style.Gradient.Y0),
+			num(style.Gradient.X1), num(style.Gradient.Y1), gradientTransform)
+		fill = "url(#" + id + ")"
+	} else if rg, ok := style.RadialGradient.(*RadialGradient); ok {
+		id := b.id("g")
+		fmt.Fprintf(&b.defs, "<radialGradient id=\"%s\" xlink:href=\"#%s\" gradientUnits=\"userSpaceOnUse\" fx=\"%s\" fy=\"%s\" fr=\"%s\" cx=\"%s\" cy=\"%s\" r=\"%s\"%s/>\n",
+			id, b.gradientStops(&rg.gradient),
+			num(style.Gradient.X0), num(style.Gradient.Y0), num(style.Gradient.RadFrom),
+			num(style.Gradient.X1), num(style.Gradient.Y1), num(style.Gradient.RadTo), gradientTransform)
+		fill = "url(#" + id + ")"
+	} else if ip, ok := style.ImagePattern.(*ImagePattern); ok {
+		fill, clip = b.pattern(ip, tf, style.ImageSmoothing)
+	} else {
+		fill = colorString(style.Color)
+	}
+
+	name := "fill"
+	if stroke {
+		name = "stroke"
+		attrs = " fill=\"none\""
+	}
+	attrs += fmt.Sprintf(" %s=\"%s\"", name, fill)
+	if style.Color.A < 255 {
+		attrs += fmt.Sprintf(" %s-opacity=\"%s\"", name, num(float64(style.Color.A)/255))
+	}
+	return attrs, clip
+}
+
+// draw adds the element returned by fn to the document.
+// The clip, blur and composite operation are applied with
+// wrapping group elements where necessary. stroke and tf
+// are passed on to paint
+func (b *SVGBackend) draw(style *backendbase.FillStyle, stroke bool, tf backendbase.Mat, fn func(paint string) string) {
+	op := style.Composite
+	if style.Color.A == 0 && (op == backendbase.SourceOver || op == backendbase.DestinationOver) {
+		return
+	}
+
+	paint, paintClip := b.paint(style, stroke, tf)
+	elem := fn(paint)
+	if paintClip != "" {
+		elem = fmt.Sprintf("<g clip-path=\"url(#%s)\">%s</g>", paintClip, elem)
+	}
+	if style.Blur > 0 {
+		elem = fmt.Sprintf("<g filter=\"url(#%s)\">%s</g>", b.blurFilter(style.Blur), elem)
+	}
+	if len(style.Filter) > 0 {
+		elem = fmt.Sprintf("<g filter=\"url(#%s)\">%s</g>", b.filter(style.Filter), elem)
+	}
+
+	switch op {
+	case backendbase.DestinationOver:
+		var body bytes.Buffer
+		body.WriteString(b.clipped(elem))
+		body.WriteByte('\n')
+		body.Write(b.body.Bytes())
+		b.body = body
+		return
+	case backendbase.Copy:
+		b.clearQuad(rectQuad(0, 0, float64(b.w), float64(b.h)), b.clip)
+	case backendbase.DestinationOut, backendbase.DestinationIn:
+		filter := b.colorFilter(op == backendbase.DestinationIn)
+		id := b.id("m")
+		fmt.Fprintf(&b.defs, "<mask id=\"%s\" maskUnits=\"userSpaceOnUse\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\">", id, b.w, b.h)
+		fmt.Fprintf(&b.defs, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>", b.w, b.h)
+		if op == backendbase.DestinationOut {
+			fmt.Fprintf(&b.defs, "<g%s><g filter=\"url(#%s)\">%s</g></g>", clipAttr(b.clip), filter, elem)
+		} else {
+			fmt.Fprintf(&b.defs, "<g%s><rect width=\"%d\" height=\"%d\" fill=\"black\"/><g filter=\"url(#%s)\">%s</g></g>", clipAttr(b.clip), b.w, b.h, filter, elem)
+		}
+		b.defs.WriteString("</mask>\n")
+		b.wrapBody(fmt.Sprintf("<g mask=\"url(#%s)\">\n", id))
+		return
+	}
+
+	if mode := blendMode(op); mode != "" {
+		elem = fmt.Sprintf("<g style=\"mix-blend-mode:%s\">%s</g>", mode, elem)
+	}
+	b.body.WriteString(b.clipped(elem))
+	b.body.WriteByte('\n')
+}
+
+func (b *SVGBackend) clipped(elem string) string {
+	if b.clip == "" {
+		return elem
+	}
+	return fmt.Sprintf("<g clip-path=\"url(#%s)\">%s</g>", b.clip, elem)
+}
+
+func (b *SVGBackend) blurFilter(size float64) string {
+	if id, ok := b.blurFilters[size]; ok {
+		return id
+	}
+	id := b.id("f")
+	fmt.Fprintf(&b.defs, "<filter id=\"%s\" filterUnits=\"userSpaceOnUse\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\"><feGaussianBlur stdDeviation=\"%s\"/></filter>\n",
+		id, b.w, b.h, num(size/2))
+	b.blurFilters[size] = id
+	return id
+}
+
+// filter returns a filter element with a primitive for
+// each of the filter functions
+func (b *SVGBackend) filter(filter backendbase.Filter) string {
+	var primitives strings.Builder
+	for _, f := range filter {
+		if m, ok := f.ColorMatrix(); ok {
+			values := make([]string, len(m))
+			for i, v := range m {
+				values[i] = num(v)
+			}
+			fmt.Fprintf(&primitives, "<feColorMatrix type=\"matrix\" values=\"%s\"/>", strings.Join(values, " "))
+			continue
+		}
+		switch f.Type {
+		case backendbase.FilterBlur:
+			fmt.Fprintf(&primitives, "<feGaussianBlur stdDeviation=\"%s\"/>", num(f.Amount))
+		case backendbase.FilterDropShadow:
+			fmt.Fprintf(&primitives, "<feDropShadow dx=\"%s\" dy=\"%s\" stdDeviation=\"%s\" flood-color=\"%s\" flood-opacity=\"%s\"/>",
+				num(f.OffsetX), num(f.OffsetY), num(f.Amount), colorString(f.Color), num(float64(f.Color.A)/255))
+		}
+	}
+
+	key := primitives.String()
+	if id, ok := b.filters[key]; ok {
+		return id
+	}
+	id := b.id("f")
+	fmt.Fprintf(&b.defs, "<filter id=\"%s\" filterUnits=\"userSpaceOnUse\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" color-interpolation-filters=\"sRGB\">%s</filter>\n",
+		id, b.w, b.h, key)
+	b.filters[key] = id
+	return id
+}
+
+// colorFilter returns a filter that turns everything white
+// or black while keeping the alpha values, which is used to
+// build masks
+func (b *SVGBackend) colorFilter(white bool) string {
+	idx, value := 0, "0"
+	if white {
+		idx, value = 1, "1"
+	}
+	if b.colorFilters[idx] != "" {
+		return b.colorFilters[idx]
+	}
+	id := b.id("f")
+	fmt.Fprintf(&b.defs, "<filter id=\"%s\" filterUnits=\"userSpaceOnUse\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\"><feColorMatrix type=\"matrix\" values=\"0 0 0 0 %s 0 0 0 0 %s 0 0 0 0 %s 0 0 0 1 0\"/></filter>\n",
+		id, b.w, b.h, value, value, value)
+	b.colorFilters[idx] = id
+	return id
+}
+
+// blendMode returns the CSS mix-blend-mode for the
+// operation. Porter-Duff operations that can't be expressed
+// in SVG are drawn like source-over
+func blendMode(op backendbase.CompositeOperation) string {
+	switch op {
+	case backendbase.Lighter:
+		return "plus-lighter"
+	case backendbase.Multiply:
+		return "multiply"
+	case backendbase.Screen:
+		return "screen"
+	case backendbase.Overlay:
+		return "overlay"
+	case backendbase.Darken:
+		return "darken"
+	case backendbase.Lighten:
+		return "lighten"
+	case backendbase.ColorDodge:
+		return "color-dodge"
+	case backendbase.ColorBurn:
+		return "color-burn"
+	case backendbase.HardLight:
+		return "hard-light"
+	case backendbase.SoftLight:
+		return "soft-light"
+	case backendbase.Difference:
+		return "difference"
+	case backendbase.Exclusion:
+		return "exclusion"
+	case backendbase.Hue:
+		return "hue"
+	case backendbase.Saturation:
+		return "saturation"
+	case backendbase.Color:
+		return "color"
+	case backendbase.Luminosity:
+		return "luminosity"
+	}
+	return ""
+}
+
+func colorString(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
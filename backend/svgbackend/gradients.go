@@ -0,0 +1,66 @@
+package svgbackend
+
+import (
+	"fmt"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// LinearGradient is a linear gradient for the SVG backend
+type LinearGradient struct {
+	gradient
+}
+
+// RadialGradient is a radial gradient for the SVG backend
+type RadialGradient struct {
+	gradient
+}
+
+// gradient holds the gradient stops. They are written to
+// the defs once and then referenced by the gradient
+// elements with the actual coordinates
+type gradient struct {
+	data   backendbase.Gradient
+	id     string
+	raster interface {
+		Delete()
+		Replace(data backendbase.Gradient)
+	}
+}
+
+func (b *SVGBackend) LoadLinearGradient(data backendbase.Gradient) backendbase.LinearGradient {
+	return &LinearGradient{gradient{data: data, raster: b.raster.LoadLinearGradient(data)}}
+}
+
+func (b *SVGBackend) LoadRadialGradient(data backendbase.Gradient) backendbase.RadialGradient {
+	return &RadialGradient{gradient{data: data, raster: b.raster.LoadRadialGradient(data)}}
+}
+
+func (g *gradient) Delete() {
+	g.raster.Delete()
+}
+
+func (g *gradient) Replace(data backendbase.Gradient) {
+	g.data = data
+	g.id = ""
+	g.raster.Replace(data)
+}
+
+// gradientStops returns the id of the element that holds
+// the gradient stops
+func (b *SVGBackend) gradientStops(g *gradient) string {
+	if g.id != "" {
+		return g.id
+	}
+	g.id = b.id("s")
+	fmt.Fprintf(&b.defs, "<linearGradient id=\"%s\">", g.id)
+	for _, stop := range g.data {
+		fmt.Fprintf(&b.defs, "<stop offset=\"%s\" stop-color=\"%s\"", num(stop.Pos), colorString(stop.Color))
+		if stop.Color.A < 255 {
+			fmt.Fprintf(&b.defs, " stop-opacity=\"%s\"", num(float64(stop.Color.A)/255))
+		}
+		b.defs.WriteString("/>")
+	}
+	b.defs.WriteString("</linearGradient>\n")
+	return g.id
+}
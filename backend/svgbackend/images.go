@@ -0,0 +1,177 @@
+package svgbackend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// Image is an image for the SVG backend. It is embedded
+// into the document as a PNG the first time it is used
+type Image struct {
+	b      *SVGBackend
+	src    image.Image
+	w, h   int
+	id     string
+	raster backendbase.Image
+}
+
+func (b *SVGBackend) LoadImage(src image.Image) (backendbase.Image, error) {
+	raster, err := b.raster.LoadImage(src)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	return &Image{b: b, src: src, w: bounds.Dx(), h: bounds.Dy(), raster: raster}, nil
+}
+
+// Width returns the width of the image
+func (img *Image) Width() int { return img.w }
+
+// Height returns the height of the image
+func (img *Image) Height() int { return img.h }
+
+// Size returns the width and height of the image
+func (img *Image) Size() (int, int) { return img.w, img.h }
+
+// Delete frees the resources of the image. Images that
+// were already used stay in the document
+func (img *Image) Delete() {
+	img.raster.Delete()
+}
+
+// Replace replaces the image with the new one
+func (img *Image) Replace(src image.Image) error {
+	err := img.raster.Replace(src)
+	if err != nil {
+		return err
+	}
+	bounds := src.Bounds()
+	img.src = src
+	img.w, img.h = bounds.Dx(), bounds.Dy()
+	img.id = ""
+	return nil
+}
+
+// ref returns the id of the image element in the defs
+func (img *Image) ref() string {
+	if img.id != "" {
+		return img.id
+	}
+	img.id = img.b.id("i")
+	fmt.Fprintf(&img.b.defs, "<image id=\"%s\" width=\"%d\" height=\"%d\" xlink:href=\"%s\"/>\n", img.id, img.w, img.h, pngDataURI(img.src))
+	return img.id
+}
+
+func (b *SVGBackend) DrawImage(style *backendbase.FillStyle, dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec) {
+	img := dimg.(*Image)
+
+	b.raster.DrawImage(b.rasterStyle(style), img.raster, sx, sy, sw, sh, pts)
+
+	// the transformation maps the source rectangle to the
+	// quad, which then also applies to the source clip
+	m := backendbase.MatTranslate(backendbase.Vec{-sx, -sy}).Mul(quadMatrix(pts, sw, sh))
+	var srcClip string
+	if sx > 0 || sy > 0 || sx+sw < float64(img.w) || sy+sh < float64(img.h) {
+		id := b.id("c")
+		fmt.Fprintf(&b.defs, "<clipPath id=\"%s\"><rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\"/></clipPath>\n", id, num(sx), num(sy), num(sw), num(sh))
+		srcClip = clipAttr(id)
+	}
+	ref := img.ref()
+
+	b.draw(style, false, backendbase.MatIdentity, func(paint string) string {
+		var opacity string
+		if style.Color.A < 255 {
+			opacity = fmt.Sprintf(" opacity=\"%s\"", num(float64(style.Color.A)/255))
+		}
+		return fmt.Sprintf("<g%s><use xlink:href=\"#%s\"%s%s%s/></g>", matrixAttr("transform", m), ref, srcClip, opacity, imageRendering(style.ImageSmoothing))
+	})
+}
+
+// ImagePattern is an image pattern for the SVG backend
+type ImagePattern struct {
+	data   backendbase.ImagePatternData
+	raster backendbase.ImagePattern
+}
+
+func (b *SVGBackend) LoadImagePattern(data backendbase.ImagePatternData) backendbase.ImagePattern {
+	return &ImagePattern{
+		data:   data,
+		raster: b.raster.LoadImagePattern(rasterPatternData(data)),
+	}
+}
+
+// Delete frees the resources of the image pattern
+func (ip *ImagePattern) Delete() {
+	ip.raster.Delete()
+}
+
+// Replace replaces the image pattern data
+func (ip *ImagePattern) Replace(data backendbase.ImagePatternData) {
+	ip.data = data
+	ip.raster.Replace(rasterPatternData(data))
+}
+
+func rasterPatternData(data backendbase.ImagePatternData) backendbase.ImagePatternData {
+	if img, ok := data.Image.(*Image); ok {
+		data.Image = img.raster
+	}
+	return data
+}
+
+// pattern writes a pattern element for the image pattern
+// and returns the fill reference. tf is the transform of
+// the element that uses the pattern. SVG patterns always
+// repeat in both directions, so for the other repeat modes
+// a clip path is returned that limits the pattern
+func (b *SVGBackend) pattern(ip *ImagePattern, tf backendbase.Mat, smoothing backendbase.ImageSmoothing) (fill, clip string) {
+	img := ip.data.Image.(*Image)
+	ref := img.ref()
+
+	t := ip.data.Transform
+	m := backendbase.Mat{t[0], t[3], t[1], t[4], t[2], t[5]}.Invert()
+
+	id := b.id("p")
+	fmt.Fprintf(&b.defs, "<pattern id=\"%s\" patternUnits=\"userSpaceOnUse\" width=\"%d\" height=\"%d\"%s><use xlink:href=\"#%s\"%s/></pattern>\n",
+		id, img.w, img.h, matrixAttr("patternTransform", m.Mul(tf.Invert())), ref, imageRendering(smoothing))
+
+	if ip.data.Repeat != backendbase.Repeat {
+		const far = 1e7
+		x, y, w, h := 0.0, 0.0, float64(img.w), float64(img.h)
+		if ip.data.Repeat == backendbase.RepeatX {
+			x, w = -far, 2*far
+		} else if ip.data.Repeat == backendbase.RepeatY {
+			y, h = -far, 2*far
+		}
+		clip = b.id("c")
+		fmt.Fprintf(&b.defs, "<clipPath id=\"%s\"><rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\"%s/></clipPath>\n",
+			clip, num(x), num(y), num(w), num(h), matrixAttr("transform", m))
+	}
+
+	return "url(#" + id + ")", clip
+}
+
+// imageRendering returns the image-rendering attribute for
+// the image smoothing setting
+func imageRendering(smoothing backendbase.ImageSmoothing) string {
+	switch smoothing {
+	case backendbase.SmoothingDisabled:
+		return " image-rendering=\"pixelated\""
+	case backendbase.SmoothingHigh:
+		return " image-rendering=\"optimizeQuality\""
+	}
+	return ""
+}
+
+func pngDataURI(img image.Image) string {
+	var buf bytes.Buffer
+	buf.WriteString("data:image/png;base64,")
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	png.Encode(enc, img)
+	enc.Close()
+	return buf.String()
+}
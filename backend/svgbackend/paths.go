@@ -0,0 +1,105 @@
+package svgbackend
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// FillPath fills the path as a single path element
+func (b *SVGBackend) FillPath(style *backendbase.FillStyle, path *backendbase.Path, tf backendbase.Mat) {
+	b.raster.Fill(b.rasterStyle(style), path.Triangles(), backendbase.MatIdentity, false)
+
+	d := pathData(path.SubPaths, tf, true)
+	if d == "" {
+		return
+	}
+	var rule string
+	if path.FillRule == backendbase.EvenOdd {
+		rule = " fill-rule=\"evenodd\""
+	}
+	b.draw(style, false, backendbase.MatIdentity, func(paint string) string {
+		return fmt.Sprintf("<path d=\"%s\"%s%s/>", d, rule, paint)
+	})
+}
+
+// StrokePath strokes the path as a single path element.
+// The transformation is set on the element so that the
+// line width is transformed as well
+func (b *SVGBackend) StrokePath(style *backendbase.FillStyle, path *backendbase.Path, line *backendbase.LineStyle, tf backendbase.Mat) {
+	b.raster.Fill(b.rasterStyle(style), path.Triangles(), backendbase.MatIdentity, true)
+
+	if tf[0]*tf[3]-tf[1]*tf[2] == 0 {
+		return
+	}
+	d := pathData(path.SubPaths, backendbase.MatIdentity, false)
+	if d == "" {
+		return
+	}
+	var transform string
+	if tf != backendbase.MatIdentity {
+		transform = matrixAttr("transform", tf)
+	}
+	attrs := lineAttrs(line)
+	b.draw(style, true, tf, func(paint string) string {
+		return fmt.Sprintf("<path d=\"%s\"%s%s%s/>", d, transform, paint, attrs)
+	})
+}
+
+// pathData returns the path data for the subpaths with the
+// matrix applied. If close is true, all subpaths are closed
+func pathData(subPaths []backendbase.SubPath, m backendbase.Mat, close bool) string {
+	var buf bytes.Buffer
+	for _, sp := range subPaths {
+		for i, pt := range sp.Points {
+			if i == 0 {
+				buf.WriteByte('M')
+			} else {
+				buf.WriteByte('L')
+			}
+			pt = pt.MulMat(m)
+			buf.WriteString(num(pt[0]))
+			buf.WriteByte(' ')
+			buf.WriteString(num(pt[1]))
+		}
+		if close || sp.Closed {
+			buf.WriteByte('Z')
+		}
+	}
+	return buf.String()
+}
+
+func lineAttrs(line *backendbase.LineStyle) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, " stroke-width=\"%s\"", num(line.Width))
+	switch line.Join {
+	case backendbase.MiterJoin:
+		fmt.Fprintf(&buf, " stroke-miterlimit=\"%s\"", num(math.Max(line.MiterLimit, 1)))
+	case backendbase.BevelJoin:
+		buf.WriteString(" stroke-linejoin=\"bevel\"")
+	case backendbase.RoundJoin:
+		buf.WriteString(" stroke-linejoin=\"round\"")
+	}
+	switch line.Cap {
+	case backendbase.SquareCap:
+		buf.WriteString(" stroke-linecap=\"square\"")
+	case backendbase.RoundCap:
+		buf.WriteString(" stroke-linecap=\"round\"")
+	}
+	if len(line.Dash) > 0 {
+		buf.WriteString(" stroke-dasharray=\"")
+		for i, v := range line.Dash {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(num(v))
+		}
+		buf.WriteByte('"')
+		if line.DashOffset != 0 {
+			fmt.Fprintf(&buf, " stroke-dashoffset=\"%s\"", num(line.DashOffset))
+		}
+	}
+	return buf.String()
+}
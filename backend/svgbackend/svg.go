@@ -0,0 +1,243 @@
+package svgbackend
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// SVGBackend is a canvas backend that records all drawing
+// operations as SVG elements. The result can be written
+// out with WriteTo. Everything is also rendered with a
+// software backend so that GetImageData keeps working
+type SVGBackend struct {
+	w, h int
+
+	defs bytes.Buffer
+	body bytes.Buffer
+
+	clip   string
+	nextID int
+
+	blurFilters  map[float64]string
+	colorFilters [2]string
+	filters      map[string]string
+
+	raster *softwarebackend.SoftwareBackend
+}
+
+// New returns a new SVG backend with the given size
+func New(w, h int) *SVGBackend {
+	return &SVGBackend{
+		w:           w,
+		h:           h,
+		blurFilters: make(map[float64]string),
+		filters:     make(map[string]string),
+		raster:      softwarebackend.New(w, h),
+	}
+}
+
+// Size returns the size of the SVG document
+func (b *SVGBackend) Size() (int, int) {
+	return b.w, b.h
+}
+
+// WriteTo writes the SVG document with everything drawn
+// so far to the given writer
+func (b *SVGBackend) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", b.w, b.h, b.w, b.h)
+	if b.defs.Len() > 0 {
+		buf.WriteString("<defs>\n")
+		buf.Write(b.defs.Bytes())
+		buf.WriteString("</defs>\n")
+	}
+	buf.Write(b.body.Bytes())
+	buf.WriteString("</svg>\n")
+	return buf.WriteTo(w)
+}
+
+// GetImageData returns the pixels as rendered by the
+// internal software backend
+func (b *SVGBackend) GetImageData(x, y, w, h int) *image.RGBA {
+	return b.raster.GetImageData(x, y, w, h)
+}
+
+func (b *SVGBackend) PutImageData(img *image.RGBA, x, y int) {
+	b.raster.PutImageData(img, x, y)
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	b.clearQuad(rectQuad(float64(x), float64(y), float64(w), float64(h)), "")
+	fmt.Fprintf(&b.body, "<image x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" xlink:href=\"%s\"/>\n", x, y, w, h, pngDataURI(img))
+}
+
+func (b *SVGBackend) CanUseAsImage(b2 backendbase.Backend) bool {
+	return false
+}
+
+func (b *SVGBackend) AsImage() backendbase.Image {
+	return nil
+}
+
+func (b *SVGBackend) Clear(pts [4]backendbase.Vec) {
+	b.raster.Clear(pts)
+	b.clearQuad(pts, b.clip)
+}
+
+// clearQuad makes everything drawn so far transparent
+// within the given quad and clip
+func (b *SVGBackend) clearQuad(pts [4]backendbase.Vec, clip string) {
+	if clip == "" && b.coversAll(pts) {
+		b.body.Reset()
+		return
+	}
+
+	id := b.id("m")
+	fmt.Fprintf(&b.defs, "<mask id=\"%s\" maskUnits=\"userSpaceOnUse\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\">", id, b.w, b.h)
+	fmt.Fprintf(&b.defs, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>", b.w, b.h)
+	fmt.Fprintf(&b.defs, "<path d=\"%s\" fill=\"black\"%s/>", polygonPath(pts[:]), clipAttr(clip))
+	b.defs.WriteString("</mask>\n")
+
+	b.wrapBody(fmt.Sprintf("<g mask=\"url(#%s)\">\n", id))
+}
+
+// wrapBody puts everything drawn so far into the given
+// group element
+func (b *SVGBackend) wrapBody(open string) {
+	var body bytes.Buffer
+	body.WriteString(open)
+	body.Write(b.body.Bytes())
+	body.WriteString("</g>\n")
+	b.body = body
+}
+
+func (b *SVGBackend) ClearClip() {
+	b.raster.ClearClip()
+	b.clip = ""
+}
+
+func (b *SVGBackend) Clip(pts []backendbase.Vec) {
+	b.raster.Clip(pts)
+
+	id := b.id("c")
+	fmt.Fprintf(&b.defs, "<clipPath id=\"%s\"%s><path d=\"%s\"/></clipPath>\n", id, clipAttr(b.clip), trianglesPath(pts))
+	b.clip = id
+}
+
+func (b *SVGBackend) id(prefix string) string {
+	b.nextID++
+	return prefix + strconv.Itoa(b.nextID)
+}
+
+// coversAll returns true if the quad is an axis aligned
+// rectangle that covers the entire document
+func (b *SVGBackend) coversAll(pts [4]backendbase.Vec) bool {
+	aligned := pts[0][0] == pts[1][0] && pts[2][0] == pts[3][0] && pts[0][1] == pts[3][1] && pts[1][1] == pts[2][1]
+	if !aligned {
+		aligned = pts[0][0] == pts[3][0] && pts[1][0] == pts[2][0] && pts[0][1] == pts[1][1] && pts[2][1] == pts[3][1]
+	}
+	if !aligned {
+		return false
+	}
+	minX, maxX := math.Min(pts[0][0], pts[2][0]), math.Max(pts[0][0], pts[2][0])
+	minY, maxY := math.Min(pts[0][1], pts[2][1]), math.Max(pts[0][1], pts[2][1])
+	return minX <= 0 && minY <= 0 && maxX >= float64(b.w) && maxY >= float64(b.h)
+}
+
+func rectQuad(x, y, w, h float64) [4]backendbase.Vec {
+	return [4]backendbase.Vec{{x, y}, {x, y + h}, {x + w, y + h}, {x + w, y}}
+}
+
+func clipAttr(clip string) string {
+	if clip == "" {
+		return ""
+	}
+	return fmt.Sprintf(" clip-path=\"url(#%s)\"", clip)
+}
+
+// num formats a coordinate with at most three decimals
+func num(v float64) string {
+	v = math.Round(v*1000) / 1000
+	if v == 0 {
+		v = 0 // avoid negative zero
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func polygonPath(pts []backendbase.Vec) string {
+	var buf bytes.Buffer
+	for i, pt := range pts {
+		if i == 0 {
+			buf.WriteByte('M')
+		} else {
+			buf.WriteByte('L')
+		}
+		buf.WriteString(num(pt[0]))
+		buf.WriteByte(' ')
+		buf.WriteString(num(pt[1]))
+	}
+	buf.WriteByte('Z')
+	return buf.String()
+}
+
+// trianglesPath merges the triangles into a single path.
+// Four points are treated as a quad like in the other
+// backends. All triangles are written with the same
+// orientation so that the nonzero fill rule fills the
+// union of the triangles
+func trianglesPath(pts []backendbase.Vec) string {
+	if len(pts) == 4 {
+		return polygonPath(pts)
+	}
+
+	var buf bytes.Buffer
+	for i := 3; i <= len(pts); i += 3 {
+		p0, p1, p2 := pts[i-3], pts[i-2], pts[i-1]
+		cross := (p1[0]-p0[0])*(p2[1]-p0[1]) - (p1[1]-p0[1])*(p2[0]-p0[0])
+		if cross == 0 {
+			continue
+		} else if cross < 0 {
+			p1, p2 = p2, p1
+		}
+		fmt.Fprintf(&buf, "M%s %sL%s %sL%s %sZ",
+			num(p0[0]), num(p0[1]),
+			num(p1[0]), num(p1[1]),
+			num(p2[0]), num(p2[1]))
+	}
+	return buf.String()
+}
+
+// quadMatrix returns the transformation that maps the
+// rectangle 0,0,w,h onto the quad, where the first point
+// is the top left, the second the bottom left and the
+// fourth the top right corner
+func quadMatrix(pts [4]backendbase.Vec, w, h float64) backendbase.Mat {
+	return backendbase.Mat{
+		(pts[3][0] - pts[0][0]) / w, (pts[3][1] - pts[0][1]) / w,
+		(pts[1][0] - pts[0][0]) / h, (pts[1][1] - pts[0][1]) / h,
+		pts[0][0], pts[0][1],
+	}
+}
+
+func matrixAttr(name string, m backendbase.Mat) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, " %s=\"matrix(", name)
+	for i, v := range m {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		if v == 0 {
+			v = 0 // avoid negative zero
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'g', 8, 64))
+	}
+	buf.WriteString(")\"")
+	return buf.String()
+}
@@ -0,0 +1,297 @@
+package svgbackend_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+	"github.com/tfriedel6/canvas/backend/svgbackend"
+)
+
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []node     `xml:",any"`
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// find returns all elements with the given name in the tree
+func (n *node) find(name string) []*node {
+	var result []*node
+	if n.XMLName.Local == name {
+		result = append(result, n)
+	}
+	for i := range n.Nodes {
+		result = append(result, n.Nodes[i].find(name)...)
+	}
+	return result
+}
+
+// render draws with fn on an SVG backend and returns the
+// parsed document along with the backend. It also checks
+// that all references point to elements that exist
+func render(t *testing.T, fn func(cv *canvas.Canvas)) (*node, *svgbackend.SVGBackend) {
+	backend := svgbackend.New(100, 100)
+	cv := canvas.New(backend)
+	fn(cv)
+
+	var buf bytes.Buffer
+	_, err := backend.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("failed to write SVG: %v", err)
+	}
+	var doc node
+	err = xml.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		t.Fatalf("failed to parse SVG: %v\n%s", err, buf.String())
+	}
+	if doc.XMLName.Local != "svg" || doc.attr("width") != "100" || doc.attr("height") != "100" {
+		t.Fatalf("unexpected root element %v", doc.XMLName)
+	}
+
+	ids := make(map[string]bool)
+	var collect func(n *node)
+	collect = func(n *node) {
+		if id := n.attr("id"); id != "" {
+			ids[id] = true
+		}
+		for i := range n.Nodes {
+			collect(&n.Nodes[i])
+		}
+	}
+	collect(&doc)
+	var check func(n *node)
+	check = func(n *node) {
+		for _, a := range n.Attrs {
+			ref := ""
+			if strings.HasPrefix(a.Value, "url(#") {
+				ref = strings.TrimSuffix(strings.TrimPrefix(a.Value, "url(#"), ")")
+			} else if a.Name.Local == "href" && strings.HasPrefix(a.Value, "#") {
+				ref = a.Value[1:]
+			}
+			if ref != "" && !ids[ref] {
+				t.Errorf("%s references missing element %s", n.XMLName.Local, ref)
+			}
+		}
+		for i := range n.Nodes {
+			check(&n.Nodes[i])
+		}
+	}
+	check(&doc)
+
+	return &doc, backend
+}
+
+func byID(doc *node, id string) *node {
+	id = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(id, "url(#"), "#"), ")")
+	var result *node
+	var find func(n *node)
+	find = func(n *node) {
+		if n.attr("id") == id {
+			result = n
+		}
+		for i := range n.Nodes {
+			find(&n.Nodes[i])
+		}
+	}
+	find(doc)
+	return result
+}
+
+func TestFill(t *testing.T) {
+	doc, _ := render(t, func(cv *canvas.Canvas) {
+		cv.SetFillStyle("#F00")
+		cv.FillRect(10, 10, 30, 20)
+		cv.SetFillStyle("#00F8")
+		cv.BeginPath()
+		cv.Rect(50, 10, 40, 40)
+		cv.Rect(60, 20, 20, 20)
+		cv.FillWithRule(canvas.EvenOdd)
+		cv.SetStrokeStyle("#0F0")
+		cv.SetLineWidth(3)
+		cv.SetLineJoin(canvas.Round)
+		cv.SetLineDash([]float64{4, 2})
+		cv.BeginPath()
+		cv.MoveTo(10, 80)
+		cv.LineTo(90, 80)
+		cv.Stroke()
+	})
+
+	paths := doc.find("path")
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(paths))
+	}
+	if p := paths[0]; p.attr("fill") != "#ff0000" || p.attr("fill-opacity") != "" {
+		t.Errorf("unexpected rect attributes %v", p.Attrs)
+	}
+	if p := paths[1]; p.attr("fill") != "#0000ff" || p.attr("fill-opacity") != "0.533" || p.attr("fill-rule") != "evenodd" {
+		t.Errorf("unexpected path attributes %v", p.Attrs)
+	}
+	if d := paths[1].attr("d"); d != "M50 10L90 10L90 50L50 50L50 10ZM60 20L80 20L80 40L60 40L60 20Z" {
+		t.Errorf("unexpected path data %s", d)
+	}
+	p := paths[2]
+	if p.attr("fill") != "none" || p.attr("stroke") != "#00ff00" || p.attr("stroke-width") != "3" ||
+		p.attr("stroke-linejoin") != "round" || p.attr("stroke-dasharray") != "4 2" {
+		t.Errorf("unexpected stroke attributes %v", p.Attrs)
+	}
+	if d := p.attr("d"); d != "M10 80L90 80" {
+		t.Errorf("unexpected stroke path data %s", d)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	doc, _ := render(t, func(cv *canvas.Canvas) {
+		lg := cv.CreateLinearGradient(10, 0, 90, 0)
+		lg.AddColorStop(0, "#F00")
+		lg.AddColorStop(1, "#00F8")
+		cv.SetFillStyle(lg)
+		cv.FillRect(10, 10, 80, 30)
+		rg := cv.CreateRadialGradient(50, 70, 5, 50, 70, 25)
+		rg.AddColorStop(0, "#FFF")
+		rg.AddColorStop(1, "#000")
+		cv.SetFillStyle(rg)
+		cv.FillRect(25, 45, 50, 50)
+	})
+
+	gradients := doc.find("linearGradient")
+	var lg *node
+	for _, g := range gradients {
+		if g.attr("x1") != "" {
+			lg = g
+		}
+	}
+	if lg == nil {
+		t.Fatal("no linear gradient found")
+	}
+	if lg.attr("x1") != "10" || lg.attr("x2") != "90" || lg.attr("gradientUnits") != "userSpaceOnUse" {
+		t.Errorf("unexpected linear gradient attributes %v", lg.Attrs)
+	}
+	stops := byID(doc, lg.attr("href")).find("stop")
+	if len(stops) != 2 || stops[0].attr("stop-color") != "#ff0000" || stops[1].attr("stop-color") != "#0000ff" || stops[1].attr("stop-opacity") != "0.533" {
+		t.Errorf("unexpected gradient stops")
+	}
+
+	rgs := doc.find("radialGradient")
+	if len(rgs) != 1 {
+		t.Fatalf("expected 1 radial gradient, got %d", len(rgs))
+	}
+	if rg := rgs[0]; rg.attr("cx") != "50" || rg.attr("cy") != "70" || rg.attr("r") != "25" || rg.attr("fr") != "5" {
+		t.Errorf("unexpected radial gradient attributes %v", rg.Attrs)
+	}
+
+	paths := doc.find("path")
+	if len(paths) != 2 || byID(doc, paths[0].attr("fill")) != lg || byID(doc, paths[1].attr("fill")) != rgs[0] {
+		t.Errorf("paths don't use the gradients")
+	}
+}
+
+func TestClip(t *testing.T) {
+	doc, _ := render(t, func(cv *canvas.Canvas) {
+		cv.BeginPath()
+		cv.Rect(20, 20, 60, 60)
+		cv.Clip()
+		cv.SetFillStyle("#F00")
+		cv.FillRect(0, 0, 100, 100)
+	})
+
+	clips := doc.find("clipPath")
+	if len(clips) != 1 {
+		t.Fatalf("expected 1 clip path, got %d", len(clips))
+	}
+	var clipped *node
+	for _, g := range doc.find("g") {
+		if g.attr("clip-path") != "" {
+			clipped = g
+		}
+	}
+	if clipped == nil || byID(doc, clipped.attr("clip-path")) != clips[0] {
+		t.Fatal("fill is not clipped")
+	}
+	if paths := clipped.find("path"); len(paths) != 1 || paths[0].attr("fill") != "#ff0000" {
+		t.Errorf("clip group doesn't contain the fill")
+	}
+}
+
+func TestImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	doc, _ := render(t, func(cv *canvas.Canvas) {
+		cv.SetGlobalAlpha(0.2)
+		cv.DrawImage(img, 10, 10, 40, 20)
+		cv.SetGlobalAlpha(1)
+		cv.SetImageSmoothingEnabled(false)
+		cv.DrawImage(img, 1, 1, 2, 2, 60, 60, 20, 20)
+	})
+
+	images := doc.find("image")
+	if len(images) != 1 {
+		t.Fatalf("expected the image to be embedded once, got %d", len(images))
+	}
+	if im := images[0]; im.attr("width") != "4" || im.attr("height") != "4" || !strings.HasPrefix(im.attr("href"), "data:image/png;base64,") {
+		t.Errorf("unexpected image attributes")
+	}
+
+	uses := doc.find("use")
+	if len(uses) != 2 {
+		t.Fatalf("expected 2 uses of the image, got %d", len(uses))
+	}
+	if u := uses[0]; byID(doc, u.attr("href")) != images[0] || u.attr("opacity") != "0.2" || u.attr("clip-path") != "" {
+		t.Errorf("unexpected attributes of the first image %v", u.Attrs)
+	}
+	if u := uses[1]; u.attr("opacity") != "" || u.attr("clip-path") == "" || u.attr("image-rendering") != "pixelated" {
+		t.Errorf("unexpected attributes of the second image %v", u.Attrs)
+	}
+
+	groups := doc.find("g")
+	if len(groups) != 2 || groups[0].attr("transform") != "matrix(10 0 0 5 10 10)" || groups[1].attr("transform") != "matrix(10 0 0 10 50 50)" {
+		t.Errorf("unexpected image transforms")
+	}
+}
+
+func TestMask(t *testing.T) {
+	doc, backend := render(t, func(cv *canvas.Canvas) {
+		cv.SetFont("../../testdata/Roboto-Light.ttf", 20)
+		cv.SetFillStyle("#0F0")
+		cv.FillText("A", 20, 70)
+	})
+
+	masks := doc.find("mask")
+	if len(masks) != 1 {
+		t.Fatalf("expected 1 mask, got %d", len(masks))
+	}
+	if images := masks[0].find("image"); len(images) != 1 || !strings.HasPrefix(images[0].attr("href"), "data:image/png;base64,") {
+		t.Errorf("mask doesn't contain the glyph image")
+	}
+	paths := doc.find("path")
+	if len(paths) != 1 || paths[0].attr("fill") != "#00ff00" || byID(doc, paths[0].attr("mask")) != masks[0] {
+		t.Errorf("text isn't drawn with the mask")
+	}
+
+	// the software rendering is kept for GetImageData
+	img := backend.GetImageData(0, 0, 100, 100)
+	found := false
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if c := img.RGBAAt(x, y); c.G > 128 && c.R == 0 && c.B == 0 {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("text was not rendered in the image data")
+	}
+}
@@ -0,0 +1,96 @@
+package xmobilebackend
+
+import (
+	"unsafe"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"golang.org/x/mobile/gl"
+)
+
+// activateLayer redirects rendering to an offscreen buffer
+// if the style requires a blur, a filter or a composite
+// operation other than source-over
+func (b *XMobileBackend) activateLayer(style *backendbase.FillStyle) {
+	if style.Blur <= 0 && len(style.Filter) == 0 && style.Composite == backendbase.SourceOver {
+		return
+	}
+
+	b.offscr1.alpha = true
+	b.enableTextureRenderTarget(&b.offscr1)
+	b.glctx.ClearColor(0, 0, 0, 0)
+	b.glctx.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+
+	if style.Composite != backendbase.SourceOver || len(style.Filter) > 0 {
+		// keep the layer premultiplied so that it can be
+		// composited and filtered correctly
+		b.glctx.BlendFuncSeparate(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA, gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	}
+}
+
+// drawLayer draws the offscreen buffer that was activated
+// with activateLayer onto the target
+func (b *XMobileBackend) drawLayer(style *backendbase.FillStyle, min, max backendbase.Vec) {
+	if style.Blur > 0 {
+		b.drawBlurred(style.Blur, min, max, style.Composite)
+	} else if len(style.Filter) > 0 {
+		b.drawFiltered(style.Filter, style.Composite)
+	} else if style.Composite != backendbase.SourceOver {
+		b.drawComposite(style.Composite)
+	}
+}
+
+// drawComposite combines the contents of offscr1 with the
+// target using the given operation. Since the operations
+// can also affect areas that offscr1 doesn't cover, the
+// entire target within the clip area is drawn
+func (b *XMobileBackend) drawComposite(op backendbase.CompositeOperation) {
+	compositeOp := int(op)
+
+	b.offscr2.alpha = true
+	b.enableTextureRenderTarget(&b.offscr2)
+	b.disableTextureRenderTarget()
+
+	b.glctx.ActiveTexture(gl.TEXTURE1)
+	b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr2.tex)
+	b.glctx.CopyTexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, b.x, b.y, b.w, b.h)
+
+	b.glctx.ActiveTexture(gl.TEXTURE0)
+	b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+
+	b.glctx.BindBuffer(gl.ARRAY_BUFFER, b.shadowBuf)
+	data := [16]float32{
+		0, 0,
+		0, float32(b.fh),
+		float32(b.fw), float32(b.fh),
+		float32(b.fw), 0,
+		0, 1,
+		0, 0,
+		1, 0,
+		1, 1,
+	}
+	b.glctx.BufferData(gl.ARRAY_BUFFER, byteSlice(unsafe.Pointer(&data[0]), len(data)*4), gl.STREAM_DRAW)
+
+	b.glctx.UseProgram(b.shd.ID)
+	b.glctx.Uniform1i(b.shd.Image, 0)
+	b.glctx.Uniform1i(b.shd.CompositeDest, 1)
+	b.glctx.Uniform1i(b.shd.CompositeOp, compositeOp)
+	b.glctx.Uniform2f(b.shd.CanvasSize, float32(b.fw), float32(b.fh))
+	b.glctx.UniformMatrix3fv(b.shd.Matrix, mat3identity[:])
+	b.glctx.Uniform1i(b.shd.UseAlphaTex, 0)
+	b.glctx.Uniform1i(b.shd.Func, shdFuncComposite)
+
+	b.glctx.Disable(gl.BLEND)
+	b.glctx.StencilFunc(gl.EQUAL, 0, 0xFF)
+
+	b.glctx.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, 0)
+	b.glctx.VertexAttribPointer(b.shd.TexCoord, 2, gl.FLOAT, false, 0, 8*4)
+	b.glctx.EnableVertexAttribArray(b.shd.Vertex)
+	b.glctx.EnableVertexAttribArray(b.shd.TexCoord)
+	b.glctx.DrawArrays(gl.TRIANGLE_FAN, 0, 4)
+	b.glctx.DisableVertexAttribArray(b.shd.Vertex)
+	b.glctx.DisableVertexAttribArray(b.shd.TexCoord)
+
+	b.glctx.StencilFunc(gl.ALWAYS, 0, 0xFF)
+	b.glctx.Enable(gl.BLEND)
+	b.glctx.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+}
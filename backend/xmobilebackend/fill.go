@@ -92,12 +92,7 @@ func extent(pts []backendbase.Vec) (min, max backendbase.Vec) {
 func (b *XMobileBackend) Fill(style *backendbase.FillStyle, pts []backendbase.Vec, tf backendbase.Mat, canOverlap bool) {
 	b.activate()
 
-	if style.Blur > 0 {
-		b.offscr1.alpha = true
-		b.enableTextureRenderTarget(&b.offscr1)
-		b.glctx.ClearColor(0, 0, 0, 0)
-		b.glctx.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
-	}
+	b.activateLayer(style)
 
 	b.ptsBuf = b.ptsBuf[:0]
 	min, max := extent(pts)
@@ -174,9 +169,7 @@ func (b *XMobileBackend) Fill(style *backendbase.FillStyle, pts []backendbase.Ve
 		b.glctx.StencilMask(0xFF)
 	}
 
-	if style.Blur > 0 {
-		b.drawBlurred(style.Blur, min, max)
-	}
+	b.drawLayer(style, min, max)
 }
 
 func (b *XMobileBackend) FillImageMask(style *backendbase.FillStyle, mask *image.Alpha, pts [4]backendbase.Vec) {
@@ -194,12 +187,7 @@ func (b *XMobileBackend) FillImageMask(style *backendbase.FillStyle, mask *image
 		b.glctx.TexSubImage2D(gl.TEXTURE_2D, 0, 0, h, w, 1, gl.ALPHA, gl.UNSIGNED_BYTE, zeroes[0:])
 	}
 
-	if style.Blur > 0 {
-		b.offscr1.alpha = true
-		b.enableTextureRenderTarget(&b.offscr1)
-		b.glctx.ClearColor(0, 0, 0, 0)
-		b.glctx.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
-	}
+	b.activateLayer(style)
 
 	b.glctx.StencilFunc(gl.EQUAL, 0, 0xFF)
 
@@ -235,27 +223,16 @@ func (b *XMobileBackend) FillImageMask(style *backendbase.FillStyle, mask *image
 
 	b.glctx.ActiveTexture(gl.TEXTURE0)
 
-	if style.Blur > 0 {
-		min, max := extent(pts[:])
-		b.drawBlurred(style.Blur, min, max)
-	}
+	min, max := extent(pts[:])
+	b.drawLayer(style, min, max)
 }
 
-func (b *XMobileBackend) drawBlurred(size float64, min, max backendbase.Vec) {
+func (b *XMobileBackend) drawBlurred(size float64, min, max backendbase.Vec, op backendbase.CompositeOperation) {
 	b.offscr1.alpha = true
 	b.offscr2.alpha = true
 
-	// calculate box blur size
-	fsize := math.Max(1, math.Floor(size))
-	sizea := int(fsize)
-	sizeb := sizea
-	sizec := sizea
-	if size-fsize > 0.333333333 {
-		sizeb++
-	}
-	if size-fsize > 0.666666666 {
-		sizec++
-	}
+	sizea, sizeb, sizec := boxSizes(size)
+	fsize := float64(sizea)
 
 	min[0] -= fsize * 3
 	min[1] -= fsize * 3
@@ -313,16 +290,41 @@ func (b *XMobileBackend) drawBlurred(size float64, min, max backendbase.Vec) {
 	b.enableTextureRenderTarget(&b.offscr2)
 	b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
 	b.box3(sizeb, -0.5, true)
-	b.glctx.Enable(gl.BLEND)
-	b.glctx.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
-	b.disableTextureRenderTarget()
+	if op == backendbase.SourceOver {
+		b.glctx.Enable(gl.BLEND)
+		b.glctx.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+		b.disableTextureRenderTarget()
+	} else {
+		b.enableTextureRenderTarget(&b.offscr1)
+		b.glctx.Clear(gl.COLOR_BUFFER_BIT)
+	}
 	b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr2.tex)
 	b.box3(sizec, 0, true)
 
 	b.glctx.DisableVertexAttribArray(b.shd.Vertex)
 	b.glctx.DisableVertexAttribArray(b.shd.TexCoord)
 
-	b.glctx.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	if op == backendbase.SourceOver {
+		b.glctx.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	} else {
+		b.drawComposite(op)
+	}
+}
+
+// boxSizes returns the sizes of the three box blurs that
+// approximate a gaussian blur of the given size
+func boxSizes(size float64) (sizea, sizeb, sizec int) {
+	fsize := math.Max(1, math.Floor(size))
+	sizea = int(fsize)
+	sizeb = sizea
+	sizec = sizea
+	if size-fsize > 0.333333333 {
+		sizeb++
+	}
+	if size-fsize > 0.666666666 {
+		sizec++
+	}
+	return
 }
 
 func (b *XMobileBackend) box3(size int, offset float32, vertical bool) {
@@ -0,0 +1,177 @@
+package xmobilebackend
+
+import (
+	"unsafe"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"golang.org/x/mobile/gl"
+)
+
+var identityColorMatrix = [20]float64{
+	1, 0, 0, 0, 0,
+	0, 1, 0, 0, 0,
+	0, 0, 1, 0, 0,
+	0, 0, 0, 1, 0,
+}
+
+// drawFiltered applies the filter functions one after the
+// other to the contents of offscr1 and then draws the
+// result onto the target using the given operation
+func (b *XMobileBackend) drawFiltered(filter backendbase.Filter, op backendbase.CompositeOperation) {
+	b.offscr2.alpha = true
+	b.offscr3.alpha = true
+
+	b.glctx.UseProgram(b.shd.ID)
+	b.glctx.Uniform1i(b.shd.Image, 0)
+	b.glctx.Uniform2f(b.shd.CanvasSize, float32(b.fw), float32(b.fh))
+	b.glctx.UniformMatrix3fv(b.shd.Matrix, mat3identity[:])
+	b.glctx.Uniform1i(b.shd.UseAlphaTex, 0)
+
+	b.glctx.Disable(gl.BLEND)
+	b.glctx.StencilFunc(gl.ALWAYS, 0, 0xFF)
+	b.glctx.ActiveTexture(gl.TEXTURE0)
+	b.glctx.ClearColor(0, 0, 0, 0)
+
+	for _, f := range filter {
+		if m, ok := f.ColorMatrix(); ok {
+			b.enableTextureRenderTarget(&b.offscr2)
+			b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+			b.colorMatrixPass(m, 0, 0)
+			b.offscr1, b.offscr2 = b.offscr2, b.offscr1
+			continue
+		}
+		switch f.Type {
+		case backendbase.FilterBlur:
+			if f.Amount > 0 {
+				b.blurOffscreen(&b.offscr1, &b.offscr2, f.Amount*2)
+			}
+		case backendbase.FilterDropShadow:
+			// draw the alpha channel in the shadow color into
+			// offscr3, blur it, and then draw the image over it
+			c := f.Color
+			shadow := [20]float64{
+				0, 0, 0, 0, float64(c.R) / 255,
+				0, 0, 0, 0, float64(c.G) / 255,
+				0, 0, 0, 0, float64(c.B) / 255,
+				0, 0, 0, float64(c.A) / 255, 0,
+			}
+			b.enableTextureRenderTarget(&b.offscr3)
+			b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+			b.colorMatrixPass(shadow, f.OffsetX, f.OffsetY)
+			if f.Amount > 0 {
+				b.blurOffscreen(&b.offscr3, &b.offscr2, f.Amount*2)
+			}
+			b.enableTextureRenderTarget(&b.offscr3)
+			b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+			b.glctx.Enable(gl.BLEND)
+			b.glctx.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+			b.colorMatrixPass(identityColorMatrix, 0, 0)
+			b.glctx.Disable(gl.BLEND)
+			b.offscr1, b.offscr3 = b.offscr3, b.offscr1
+		}
+	}
+
+	b.glctx.Enable(gl.BLEND)
+	if op == backendbase.SourceOver {
+		b.disableTextureRenderTarget()
+		b.glctx.BindTexture(gl.TEXTURE_2D, b.offscr1.tex)
+		b.glctx.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+		b.glctx.StencilFunc(gl.EQUAL, 0, 0xFF)
+		b.colorMatrixPass(identityColorMatrix, 0, 0)
+		b.glctx.StencilFunc(gl.ALWAYS, 0, 0xFF)
+		b.glctx.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	} else {
+		b.glctx.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		b.drawComposite(op)
+	}
+}
+
+// colorMatrixPass draws the bound texture over the entire
+// render target with the color matrix applied. The matrix
+// is in the row major form of backendbase.FilterFunc.
+// The image is moved by the offset in pixels
+func (b *XMobileBackend) colorMatrixPass(m [20]float64, dx, dy float64) {
+	tx := float32(dx / b.fw)
+	ty := float32(dy / b.fh)
+
+	b.glctx.BindBuffer(gl.ARRAY_BUFFER, b.shadowBuf)
+	data := [16]float32{
+		0, 0,
+		0, float32(b.fh),
+		float32(b.fw), float32(b.fh),
+		float32(b.fw), 0,
+		-tx, 1 + ty,
+		-tx, ty,
+		1 - tx, ty,
+		1 - tx, 1 + ty,
+	}
+	b.glctx.BufferData(gl.ARRAY_BUFFER, byteSlice(unsafe.Pointer(&data[0]), len(data)*4), gl.STREAM_DRAW)
+
+	var m4 [16]float32
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			m4[col*4+row] = float32(m[row*5+col])
+		}
+	}
+
+	b.glctx.Uniform1i(b.shd.Func, shdFuncColorMatrix)
+	b.glctx.UniformMatrix4fv(b.shd.ColorMatrix, m4[:])
+	b.glctx.Uniform4f(b.shd.ColorOffset, float32(m[4]), float32(m[9]), float32(m[14]), float32(m[19]))
+
+	b.glctx.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, 0)
+	b.glctx.VertexAttribPointer(b.shd.TexCoord, 2, gl.FLOAT, false, 0, 8*4)
+	b.glctx.EnableVertexAttribArray(b.shd.Vertex)
+	b.glctx.EnableVertexAttribArray(b.shd.TexCoord)
+	b.glctx.DrawArrays(gl.TRIANGLE_FAN, 0, 4)
+	b.glctx.DisableVertexAttribArray(b.shd.Vertex)
+	b.glctx.DisableVertexAttribArray(b.shd.TexCoord)
+}
+
+// blurOffscreen blurs the contents of the offscreen buffer
+// with the same box blur passes as drawBlurred, using tmp
+// for the intermediate results
+func (b *XMobileBackend) blurOffscreen(img, tmp *offscreenBuffer, size float64) {
+	sizea, sizeb, sizec := boxSizes(size)
+
+	b.glctx.BindBuffer(gl.ARRAY_BUFFER, b.shadowBuf)
+	data := [16]float32{
+		0, 0,
+		0, float32(b.fh),
+		float32(b.fw), float32(b.fh),
+		float32(b.fw), 0,
+		0, 1,
+		0, 0,
+		1, 0,
+		1, 1,
+	}
+	b.glctx.BufferData(gl.ARRAY_BUFFER, byteSlice(unsafe.Pointer(&data[0]), len(data)*4), gl.STREAM_DRAW)
+
+	b.glctx.Uniform1i(b.shd.Func, shdFuncBoxBlur)
+
+	b.glctx.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, 0)
+	b.glctx.VertexAttribPointer(b.shd.TexCoord, 2, gl.FLOAT, false, 0, 8*4)
+	b.glctx.EnableVertexAttribArray(b.shd.Vertex)
+	b.glctx.EnableVertexAttribArray(b.shd.TexCoord)
+
+	b.enableTextureRenderTarget(tmp)
+	b.glctx.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.box3(sizea, 0, false)
+	b.enableTextureRenderTarget(img)
+	b.glctx.BindTexture(gl.TEXTURE_2D, tmp.tex)
+	b.box3(sizeb, -0.5, false)
+	b.enableTextureRenderTarget(tmp)
+	b.glctx.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.box3(sizec, 0, false)
+	b.enableTextureRenderTarget(img)
+	b.glctx.BindTexture(gl.TEXTURE_2D, tmp.tex)
+	b.box3(sizea, 0, true)
+	b.enableTextureRenderTarget(tmp)
+	b.glctx.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.box3(sizeb, -0.5, true)
+	b.enableTextureRenderTarget(img)
+	b.glctx.BindTexture(gl.TEXTURE_2D, tmp.tex)
+	b.box3(sizec, 0, true)
+
+	b.glctx.DisableVertexAttribArray(b.shd.Vertex)
+	b.glctx.DisableVertexAttribArray(b.shd.TexCoord)
+}
@@ -151,6 +151,9 @@ func rewrite(filename, src string) (string, string) {
 	src = rewriteCalls(src, "b.glctx.UniformMatrix3fv", func(params []string) string {
 		return "b.glctx.UniformMatrix3fv(" + params[0] + ", " + params[3][1:len(params[3])-3] + "[:])"
 	})
+	src = rewriteCalls(src, "b.glctx.UniformMatrix4fv", func(params []string) string {
+		return "b.glctx.UniformMatrix4fv(" + params[0] + ", " + params[3][1:len(params[3])-3] + "[:])"
+	})
 	src = rewriteCalls(src, "b.glctx.TexImage2D", func(params []string) string {
 		params = append(params[:5], params[6:]...)
 		for i, param := range params {
@@ -172,6 +175,14 @@ func rewrite(filename, src string) (string, string) {
 		}
 		return "b.glctx.TexSubImage2D(" + strings.Join(params, ", ") + ")"
 	})
+	src = rewriteCalls(src, "b.glctx.CopyTexSubImage2D", func(params []string) string {
+		for i, param := range params {
+			if strings.HasPrefix(param, "int32(") {
+				params[i] = param[6 : len(param)-1]
+			}
+		}
+		return "b.glctx.CopyTexSubImage2D(" + strings.Join(params, ", ") + ")"
+	})
 	src = rewriteCalls(src, "b.glctx.GetIntegerv", func(params []string) string {
 		return "b.glctx.GetIntegerv(" + params[1][1:len(params[1])-2] + ":], " + params[0] + ")"
 	})
@@ -15,6 +15,11 @@ type Image struct {
 	w, h int
 	tex  gl.Texture
 	flip bool
+
+	// mipmaps is set for loaded images, and smoothing is
+	// the filter that the texture is currently set up for
+	mipmaps   bool
+	smoothing backendbase.ImageSmoothing
 }
 
 func (b *XMobileBackend) LoadImage(src image.Image) (backendbase.Image, error) {
@@ -62,9 +67,9 @@ func loadImage(b *XMobileBackend, src image.Image, tex gl.Texture) (*Image, erro
 }
 
 func loadImageRGBA(b *XMobileBackend, src *image.RGBA, tex gl.Texture) (*Image, error) {
-	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy()}
+	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy(), mipmaps: true}
 
-	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_NEAREST)
 	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
 	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
@@ -93,8 +98,8 @@ func loadImageRGBA(b *XMobileBackend, src *image.RGBA, tex gl.Texture) (*Image,
 }
 
 func loadImageConverted(b *XMobileBackend, src image.Image, tex gl.Texture) (*Image, error) {
-	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy()}
-	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	img := &Image{tex: tex, w: src.Bounds().Dx(), h: src.Bounds().Dy(), mipmaps: true}
+	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_NEAREST)
 	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
 	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
@@ -120,6 +125,31 @@ func loadImageConverted(b *XMobileBackend, src image.Image, tex gl.Texture) (*Im
 	return img, nil
 }
 
+// setImageFilter sets the filtering of the image texture,
+// which must already be bound, for the image smoothing
+// setting. SmoothingLow uses the closest mipmap level,
+// while SmoothingMedium and SmoothingHigh blend the two
+// levels around the size. Textures only support nearest and
+// linear filtering, so SmoothingHigh is the same as
+// SmoothingMedium. Images of offscreen canvases have no
+// mipmaps and always use nearest filtering
+func (b *XMobileBackend) setImageFilter(img *Image, smoothing backendbase.ImageSmoothing) {
+	if !img.mipmaps || smoothing == img.smoothing {
+		return
+	}
+	if smoothing == backendbase.SmoothingDisabled {
+		b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	} else if smoothing == backendbase.SmoothingLow {
+		b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_NEAREST)
+		b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	} else {
+		b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+		b.glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	}
+	img.smoothing = smoothing
+}
+
 // Width returns the width of the image
 func (img *Image) Width() int { return img.w }
 
@@ -154,9 +184,11 @@ func (img *Image) Replace(src image.Image) error {
 	return nil
 }
 
-func (b *XMobileBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec, alpha float64) {
+func (b *XMobileBackend) DrawImage(style *backendbase.FillStyle, dimg backendbase.Image, sx, sy, sw, sh float64, pts [4]backendbase.Vec) {
 	b.activate()
 
+	b.activateLayer(style)
+
 	img := dimg.(*Image)
 
 	sx /= float64(img.w)
@@ -188,12 +220,13 @@ func (b *XMobileBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float6
 
 	b.glctx.ActiveTexture(gl.TEXTURE0)
 	b.glctx.BindTexture(gl.TEXTURE_2D, img.tex)
+	b.setImageFilter(img, style.ImageSmoothing)
 
 	b.glctx.UseProgram(b.shd.ID)
 	b.glctx.Uniform1i(b.shd.Image, 0)
 	b.glctx.Uniform2f(b.shd.CanvasSize, float32(b.fw), float32(b.fh))
 	b.glctx.UniformMatrix3fv(b.shd.Matrix, mat3identity[:])
-	b.glctx.Uniform1f(b.shd.GlobalAlpha, float32(alpha))
+	b.glctx.Uniform1f(b.shd.GlobalAlpha, float32(style.Color.A)/255)
 	b.glctx.Uniform1i(b.shd.UseAlphaTex, 0)
 	b.glctx.Uniform1i(b.shd.Func, shdFuncImage)
 	b.glctx.VertexAttribPointer(b.shd.Vertex, 2, gl.FLOAT, false, 0, 0)
@@ -205,6 +238,9 @@ func (b *XMobileBackend) DrawImage(dimg backendbase.Image, sx, sy, sw, sh float6
 	b.glctx.DisableVertexAttribArray(b.shd.TexCoord)
 
 	b.glctx.StencilFunc(gl.ALWAYS, 0, 0xFF)
+
+	min, max := extent(pts[:])
+	b.drawLayer(style, min, max)
 }
 
 type ImagePattern struct {
@@ -50,10 +50,147 @@ uniform bool boxVertical;
 uniform float boxScale;
 uniform float boxOffset;
 
+uniform int compositeOp;
+uniform sampler2D compositeDest;
+
+uniform mat4 colorMatrix;
+uniform vec4 colorOffset;
+
 bool isNaN(float v) {
   return v < 0.0 || 0.0 < v || v == 0.0 ? false : true;
 }
 
+float blendChannel(float cb, float cs) {
+	if (compositeOp == 11) {
+		return cb * cs;
+	} else if (compositeOp == 12) {
+		return cb + cs - cb * cs;
+	} else if (compositeOp == 13) {
+		if (cb <= 0.5) {
+			return cs * 2.0 * cb;
+		}
+		float cb2 = 2.0 * cb - 1.0;
+		return cs + cb2 - cs * cb2;
+	} else if (compositeOp == 14) {
+		return min(cb, cs);
+	} else if (compositeOp == 15) {
+		return max(cb, cs);
+	} else if (compositeOp == 16) {
+		if (cb == 0.0) {
+			return 0.0;
+		} else if (cs >= 1.0) {
+			return 1.0;
+		}
+		return min(1.0, cb / (1.0 - cs));
+	} else if (compositeOp == 17) {
+		if (cb >= 1.0) {
+			return 1.0;
+		} else if (cs == 0.0) {
+			return 0.0;
+		}
+		return 1.0 - min(1.0, (1.0 - cb) / cs);
+	} else if (compositeOp == 18) {
+		if (cs <= 0.5) {
+			return cb * 2.0 * cs;
+		}
+		float cs2 = 2.0 * cs - 1.0;
+		return cb + cs2 - cb * cs2;
+	} else if (compositeOp == 19) {
+		if (cs <= 0.5) {
+			return cb - (1.0 - 2.0 * cs) * cb * (1.0 - cb);
+		}
+		float d = cb <= 0.25 ? ((16.0 * cb - 12.0) * cb + 4.0) * cb : sqrt(cb);
+		return cb + (2.0 * cs - 1.0) * (d - cb);
+	} else if (compositeOp == 20) {
+		return abs(cb - cs);
+	} else if (compositeOp == 21) {
+		return cb + cs - 2.0 * cb * cs;
+	}
+	return cs;
+}
+
+float lum(vec3 c) {
+	return dot(c, vec3(0.3, 0.59, 0.11));
+}
+
+vec3 clipColor(vec3 c) {
+	float l = lum(c);
+	float n = min(min(c.r, c.g), c.b);
+	float x = max(max(c.r, c.g), c.b);
+	if (n < 0.0) {
+		c = l + (c - l) * l / (l - n);
+	}
+	if (x > 1.0) {
+		c = l + (c - l) * (1.0 - l) / (x - l);
+	}
+	return c;
+}
+
+vec3 setLum(vec3 c, float l) {
+	return clipColor(c + (l - lum(c)));
+}
+
+float sat(vec3 c) {
+	return max(max(c.r, c.g), c.b) - min(min(c.r, c.g), c.b);
+}
+
+vec3 setSat(vec3 c, float s) {
+	float n = min(min(c.r, c.g), c.b);
+	float x = max(max(c.r, c.g), c.b);
+	if (x <= n) {
+		return vec3(0.0);
+	}
+	return (c - n) * s / (x - n);
+}
+
+vec4 composite(vec4 src, vec4 dst) {
+	float fa = 0.0;
+	float fb = 0.0;
+	if (compositeOp == 0) {
+		fa = 1.0; fb = 1.0 - src.a;
+	} else if (compositeOp == 1) {
+		fa = dst.a; fb = 0.0;
+	} else if (compositeOp == 2) {
+		fa = 1.0 - dst.a; fb = 0.0;
+	} else if (compositeOp == 3) {
+		fa = dst.a; fb = 1.0 - src.a;
+	} else if (compositeOp == 4) {
+		fa = 1.0 - dst.a; fb = 1.0;
+	} else if (compositeOp == 5) {
+		fa = 0.0; fb = src.a;
+	} else if (compositeOp == 6) {
+		fa = 0.0; fb = 1.0 - src.a;
+	} else if (compositeOp == 7) {
+		fa = 1.0 - dst.a; fb = src.a;
+	} else if (compositeOp == 8) {
+		fa = 1.0; fb = 1.0;
+	} else if (compositeOp == 9) {
+		fa = 1.0; fb = 0.0;
+	} else if (compositeOp == 10) {
+		fa = 1.0 - dst.a; fb = 1.0 - src.a;
+	}
+	if (compositeOp <= 10) {
+		return clamp(src * fa + dst * fb, 0.0, 1.0);
+	}
+
+	vec3 cs = src.a > 0.0 ? src.rgb / src.a : vec3(0.0);
+	vec3 cb = dst.a > 0.0 ? dst.rgb / dst.a : vec3(0.0);
+	vec3 blended;
+	if (compositeOp == 22) {
+		blended = setLum(setSat(cs, sat(cb)), lum(cb));
+	} else if (compositeOp == 23) {
+		blended = setLum(setSat(cb, sat(cs)), lum(cb));
+	} else if (compositeOp == 24) {
+		blended = setLum(cs, lum(cb));
+	} else if (compositeOp == 25) {
+		blended = setLum(cb, lum(cs));
+	} else {
+		blended = vec3(blendChannel(cb.r, cs.r), blendChannel(cb.g, cs.g), blendChannel(cb.b, cs.b));
+	}
+	vec3 rgb = src.rgb * (1.0 - dst.a) + dst.rgb * (1.0 - src.a) + src.a * dst.a * blended;
+	return clamp(vec4(rgb, src.a + dst.a * (1.0 - src.a)), 0.0, 1.0);
+}
+
 void main() {
 	vec4 col = color;
 
@@ -74,6 +211,24 @@ void main() {
 		return;
 	}
 
+	if (func == 6) {
+		gl_FragColor = composite(texture2D(image, v_tc), texture2D(compositeDest, v_tc));
+		return;
+	}
+
+	if (func == 7) {
+		vec4 c = vec4(0.0);
+		if (v_tc.x >= 0.0 && v_tc.x <= 1.0 && v_tc.y >= 0.0 && v_tc.y <= 1.0) {
+			c = texture2D(image, v_tc);
+		}
+		if (c.a > 0.0) {
+			c.rgb /= c.a;
+		}
+		c = clamp(colorMatrix * c + colorOffset, 0.0, 1.0);
+		gl_FragColor = vec4(c.rgb * c.a, c.a);
+		return;
+	}
+
 	if (func == 1) {
 		vec2 v = v_cp - from;
 		float r = dot(v, dir) / len;
@@ -127,6 +282,8 @@ const (
 	shdFuncImagePattern
 	shdFuncImage
 	shdFuncBoxBlur
+	shdFuncComposite
+	shdFuncColorMatrix
 )
 
 type unifiedShader struct {
@@ -159,4 +316,10 @@ type unifiedShader struct {
 	BoxVertical gl.Uniform
 	BoxScale    gl.Uniform
 	BoxOffset   gl.Uniform
+
+	CompositeOp   gl.Uniform
+	CompositeDest gl.Uniform
+
+	ColorMatrix gl.Uniform
+	ColorOffset gl.Uniform
 }
@@ -26,6 +26,7 @@ type GLContext struct {
 
 	offscr1 offscreenBuffer
 	offscr2 offscreenBuffer
+	offscr3 offscreenBuffer
 
 	imageBufTex gl.Texture
 	imageBuf    []byte
@@ -296,6 +297,7 @@ func (b *XMobileBackend) useShader(style *backendbase.FillStyle, tf [9]float32,
 		img := ipd.Image.(*Image)
 		b.glctx.ActiveTexture(gl.TEXTURE0)
 		b.glctx.BindTexture(gl.TEXTURE_2D, img.tex)
+		b.setImageFilter(img, style.ImageSmoothing)
 		b.glctx.Uniform2f(b.shd.ImageSize, float32(img.w), float32(img.h))
 		b.glctx.Uniform1i(b.shd.Image, 0)
 		var f32mat [9]float32
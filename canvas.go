@@ -8,7 +8,9 @@ import (
 	"math"
 	"time"
 
-	"github.com/golang/freetype/truetype"
+	"github.com/go-text/typesetting/bidi"
+	"github.com/go-text/typesetting/segmenter"
+	"github.com/go-text/typesetting/shaping"
 	"github.com/tfriedel6/canvas/backend/backendbase"
 	"golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
@@ -28,10 +30,17 @@ type Canvas struct {
 	stateStack []drawState
 
 	images        map[interface{}]*Image
+	imagePatterns map[interface{}]*ImagePattern
 	fonts         map[interface{}]*Font
+	fontFaces     map[string][]fontFace
 	fontCtxs      map[fontKey]*frCache
-	fontPathCache map[*Font]*fontPathCache
-	fontTriCache  map[*Font]*fontTriCache
+	fontPathCache map[fontKey]*fontPathCache
+	fontTriCache  map[fontKey]*fontTriCache
+
+	shaper    shaping.HarfbuzzShaper
+	segmenter shaping.Segmenter
+	bidi      bidi.Paragraph
+	breaker   segmenter.Segmenter
 
 	shadowBuf []backendbase.Vec
 }
@@ -41,22 +50,37 @@ type drawState struct {
 	fill          drawStyle
 	stroke        drawStyle
 	font          *Font
+	fontFallbacks []*Font
 	fontSize      fixed.Int26_6
 	fontMetrics   font.Metrics
 	textAlign     textAlign
 	textBaseline  textBaseline
+	direction     direction
+	letterSpacing float64
+	wordSpacing   float64
+	fontKerning   fontKerning
+	textRendering textRendering
 	lineAlpha     float64
 	lineWidth     float64
 	lineJoin      lineJoin
 	lineCap       lineCap
 	miterLimitSqr float64
 	globalAlpha   float64
+	composite     compositeOperation
+
+	imageSmoothing        bool
+	imageSmoothingQuality imageSmoothingQuality
+
+	filter backendbase.Filter
+
+	fontVariations []FontVariation
 
 	lineDash       []float64
 	lineDashPoint  int
 	lineDashOffset float64
 
-	clip Path2D
+	clip     Path2D
+	clipRule pathRule
 
 	shadowColor   color.RGBA
 	shadowOffsetX float64
@@ -70,7 +94,8 @@ type drawState struct {
 		The current values of the following attributes: strokeStyle, fillStyle, globalAlpha,
 			lineWidth, lineCap, lineJoin, miterLimit, lineDashOffset, shadowOffsetX,
 			shadowOffsetY, shadowBlur, shadowColor, globalCompositeOperation, font,
-			textAlign, textBaseline, direction, imageSmoothingEnabled
+			textAlign, textBaseline, direction, letterSpacing, wordSpacing,
+			fontKerning, textRendering, fontVariations, imageSmoothingEnabled
 	*/
 }
 
@@ -102,6 +127,7 @@ const (
 	Right
 	Start
 	End
+	Justify
 )
 
 type textBaseline uint8
@@ -116,6 +142,75 @@ const (
 	Bottom
 )
 
+type direction uint8
+
+// Text direction constants for SetDirection
+const (
+	Inherit direction = iota
+	LTR
+	RTL
+)
+
+type fontKerning uint8
+
+// Font kerning constants for SetFontKerning
+const (
+	KerningAuto fontKerning = iota
+	KerningNormal
+	KerningNone
+)
+
+type textRendering uint8
+
+// Text rendering constants for SetTextRendering
+const (
+	RenderingAuto textRendering = iota
+	OptimizeSpeed
+	OptimizeLegibility
+	GeometricPrecision
+)
+
+type compositeOperation uint8
+
+// Composite operation constants for SetGlobalCompositeOperation
+const (
+	CompositeSourceOver      compositeOperation = compositeOperation(backendbase.SourceOver)
+	CompositeSourceIn                           = compositeOperation(backendbase.SourceIn)
+	CompositeSourceOut                          = compositeOperation(backendbase.SourceOut)
+	CompositeSourceAtop                         = compositeOperation(backendbase.SourceAtop)
+	CompositeDestinationOver                    = compositeOperation(backendbase.DestinationOver)
+	CompositeDestinationIn                      = compositeOperation(backendbase.DestinationIn)
+	CompositeDestinationOut                     = compositeOperation(backendbase.DestinationOut)
+	CompositeDestinationAtop                    = compositeOperation(backendbase.DestinationAtop)
+	CompositeLighter                            = compositeOperation(backendbase.Lighter)
+	CompositeCopy                               = compositeOperation(backendbase.Copy)
+	CompositeXor                                = compositeOperation(backendbase.Xor)
+	CompositeMultiply                           = compositeOperation(backendbase.Multiply)
+	CompositeScreen                             = compositeOperation(backendbase.Screen)
+	CompositeOverlay                            = compositeOperation(backendbase.Overlay)
+	CompositeDarken                             = compositeOperation(backendbase.Darken)
+	CompositeLighten                            = compositeOperation(backendbase.Lighten)
+	CompositeColorDodge                         = compositeOperation(backendbase.ColorDodge)
+	CompositeColorBurn                          = compositeOperation(backendbase.ColorBurn)
+	CompositeHardLight                          = compositeOperation(backendbase.HardLight)
+	CompositeSoftLight                          = compositeOperation(backendbase.SoftLight)
+	CompositeDifference                         = compositeOperation(backendbase.Difference)
+	CompositeExclusion                          = compositeOperation(backendbase.Exclusion)
+	CompositeHue                                = compositeOperation(backendbase.Hue)
+	CompositeSaturation                         = compositeOperation(backendbase.Saturation)
+	CompositeColor                              = compositeOperation(backendbase.Color)
+	CompositeLuminosity                         = compositeOperation(backendbase.Luminosity)
+)
+
+type imageSmoothingQuality uint8
+
+// Image smoothing quality constants for SetImageSmoothingQuality
+const (
+	SmoothingLow    imageSmoothingQuality = imageSmoothingQuality(backendbase.SmoothingLow)
+	SmoothingMedium                       = imageSmoothingQuality(backendbase.SmoothingMedium)
+	SmoothingHigh                         = imageSmoothingQuality(backendbase.SmoothingHigh)
+)
+
 // Performance is a nonstandard setting to improve the
 // performance of the rendering in some circumstances.
 // Disabling self intersections will lead to incorrect
@@ -143,15 +238,18 @@ func New(backend backendbase.Backend) *Canvas {
 		b:             backend,
 		stateStack:    make([]drawState, 0, 20),
 		images:        make(map[interface{}]*Image),
+		imagePatterns: make(map[interface{}]*ImagePattern),
 		fonts:         make(map[interface{}]*Font),
+		fontFaces:     make(map[string][]fontFace),
 		fontCtxs:      make(map[fontKey]*frCache),
-		fontPathCache: make(map[*Font]*fontPathCache),
-		fontTriCache:  make(map[*Font]*fontTriCache),
+		fontPathCache: make(map[fontKey]*fontPathCache),
+		fontTriCache:  make(map[fontKey]*fontTriCache),
 	}
 	cv.state.lineWidth = 1
 	cv.state.lineAlpha = 1
 	cv.state.miterLimitSqr = 100
 	cv.state.globalAlpha = 1
+	cv.state.imageSmoothing = true
 	cv.state.fill.color = color.RGBA{A: 255}
 	cv.state.stroke.color = color.RGBA{A: 255}
 	cv.state.transform = backendbase.MatIdentity
@@ -205,8 +303,6 @@ func (cv *Canvas) SetStrokeStyle(value ...interface{}) {
 	cv.state.stroke = cv.parseStyle(value...)
 }
 
-var imagePatterns = make(map[interface{}]*ImagePattern)
-
 func (cv *Canvas) parseStyle(value ...interface{}) drawStyle {
 	var style drawStyle
 	if len(value) == 1 {
@@ -230,17 +326,22 @@ func (cv *Canvas) parseStyle(value ...interface{}) drawStyle {
 	if len(value) == 1 {
 		switch v := value[0].(type) {
 		case *Image, image.Image, string:
-			if _, ok := imagePatterns[v]; !ok {
-				imagePatterns[v] = cv.CreatePattern(v, Repeat)
+			if _, ok := cv.imagePatterns[v]; !ok {
+				cv.imagePatterns[v] = cv.CreatePattern(v, Repeat)
 			}
-			style.imagePattern = imagePatterns[v]
+			style.imagePattern = cv.imagePatterns[v]
 		}
 	}
 	return style
 }
 
 func (cv *Canvas) backendFillStyle(s *drawStyle, alpha float64) backendbase.FillStyle {
-	stl := backendbase.FillStyle{Color: s.color}
+	stl := backendbase.FillStyle{
+		Color:          s.color,
+		Composite:      backendbase.CompositeOperation(cv.state.composite),
+		ImageSmoothing: cv.imageSmoothing(),
+		Filter:         cv.state.filter,
+	}
 	alpha *= cv.state.globalAlpha
 	if lg := s.linearGradient; lg != nil {
 		lg.load()
@@ -292,21 +393,22 @@ func (cv *Canvas) SetLineWidth(width float64) {
 
 // SetFont sets the font and font size. The font parameter can be a font loaded
 // with the LoadFont function, a filename for a font to load (which will be
-// cached), or nil, in which case the first loaded font will be used
+// cached), or nil, in which case the first loaded font will be used. It can
+// also be a FontFamily or a slice of any of these, in which case each rune is
+// drawn with the first font that has a glyph for it
 func (cv *Canvas) SetFont(src interface{}, size float64) {
 	cv.state.fontSize = fixed.Int26_6(math.Round(size * 64))
-	if src == nil {
-		cv.state.font = defaultFont
-	} else {
-		cv.state.font = cv.getFont(src)
+	cv.state.font, cv.state.fontFallbacks = cv.resolveFont(src)
+	if cv.state.font == nil {
+		return
 	}
 
-	fontFace := truetype.NewFace(cv.state.font.font, &truetype.Options{Size: size})
-	cv.state.fontMetrics = fontFace.Metrics()
+	cv.state.fontMetrics = cv.state.font.metrics(size)
 }
 
 // SetTextAlign sets the text align for any text drawing calls.
-// The value can be Left, Center, Right, Start, or End
+// The value can be Left, Center, Right, Start, or End. Justify
+// is only used by text layouts and otherwise works like Start
 func (cv *Canvas) SetTextAlign(align textAlign) {
 	cv.state.textAlign = align
 }
@@ -318,6 +420,49 @@ func (cv *Canvas) SetTextBaseline(baseline textBaseline) {
 	cv.state.textBaseline = baseline
 }
 
+// SetDirection sets the base direction for any text drawing
+// calls, which is used to lay out text that mixes left-to-right
+// and right-to-left scripts and to resolve the Start and End
+// text align. The value can be Inherit (default), LTR, or RTL.
+// Since there is no parent element to inherit from, Inherit
+// takes the direction from the first strongly directional
+// character of the text and falls back to left-to-right
+func (cv *Canvas) SetDirection(dir direction) {
+	cv.state.direction = dir
+}
+
+// SetLetterSpacing sets the space in pixels that is added
+// after each character for any text drawing calls. Optional
+// ligatures are not used if it is not 0
+func (cv *Canvas) SetLetterSpacing(spacing float64) {
+	cv.state.letterSpacing = spacing
+}
+
+// SetWordSpacing sets the space in pixels that is added to
+// each space between words for any text drawing calls
+func (cv *Canvas) SetWordSpacing(spacing float64) {
+	cv.state.wordSpacing = spacing
+}
+
+// SetFontKerning sets whether text is kerned. The value can be
+// KerningAuto (default), KerningNormal, or KerningNone. With
+// KerningAuto the text is kerned unless the text rendering is
+// OptimizeSpeed
+func (cv *Canvas) SetFontKerning(kerning fontKerning) {
+	cv.state.fontKerning = kerning
+}
+
+// SetTextRendering sets the trade-offs for rendering text. The
+// value can be RenderingAuto (default), OptimizeSpeed,
+// OptimizeLegibility, or GeometricPrecision. OptimizeSpeed
+// turns off optional ligatures, and also kerning unless the
+// font kerning is KerningNormal. GeometricPrecision turns off
+// hinting, so that glyph outlines and advances scale exactly
+// with the font size
+func (cv *Canvas) SetTextRendering(rendering textRendering) {
+	cv.state.textRendering = rendering
+}
+
 // SetLineJoin sets the style of line joints for rendering a path with Stroke.
 // The value can be Miter, Bevel, or Round
 func (cv *Canvas) SetLineJoin(join lineJoin) {
@@ -370,6 +515,37 @@ func (cv *Canvas) SetGlobalAlpha(alpha float64) {
 	cv.state.globalAlpha = alpha
 }
 
+// SetGlobalCompositeOperation sets the operation that is used
+// to combine anything drawn with the existing content. The
+// default is CompositeSourceOver
+func (cv *Canvas) SetGlobalCompositeOperation(op compositeOperation) {
+	cv.state.composite = op
+}
+
+// SetImageSmoothingEnabled sets whether images and image
+// patterns are filtered when they are scaled. If disabled,
+// the nearest pixel is used, which keeps the pixels sharp
+// when scaling up. The default is enabled
+func (cv *Canvas) SetImageSmoothingEnabled(enabled bool) {
+	cv.state.imageSmoothing = enabled
+}
+
+// SetImageSmoothingQuality sets the quality of the filter
+// that is used when image smoothing is enabled. The default
+// is SmoothingLow. SmoothingMedium blends between mipmap
+// levels when scaling down, and SmoothingHigh also uses
+// bicubic interpolation where the backend supports it
+func (cv *Canvas) SetImageSmoothingQuality(quality imageSmoothingQuality) {
+	cv.state.imageSmoothingQuality = quality
+}
+
+func (cv *Canvas) imageSmoothing() backendbase.ImageSmoothing {
+	if !cv.state.imageSmoothing {
+		return backendbase.SmoothingDisabled
+	}
+	return backendbase.ImageSmoothing(cv.state.imageSmoothingQuality)
+}
+
 // Save saves the current draw state to a stack
 func (cv *Canvas) Save() {
 	cv.stateStack = append(cv.stateStack, cv.state)
@@ -384,7 +560,7 @@ func (cv *Canvas) Restore() {
 	cv.b.ClearClip()
 	for _, st := range cv.stateStack {
 		if len(st.clip.p) > 0 {
-			cv.clip(&st.clip, backendbase.MatIdentity)
+			cv.clip(&st.clip, backendbase.MatIdentity, st.clipRule)
 		}
 	}
 	cv.state = cv.stateStack[l-1]
@@ -483,8 +659,8 @@ func (cv *Canvas) reduceCache(keepSize, rec int) {
 	var total int
 	oldest := time.Now()
 	var oldestFontKey fontKey
-	var oldestFontKey2 *Font
-	var oldestFontKey3 *Font
+	var oldestFontKey2 fontKey
+	var oldestFontKey3 fontKey
 	var oldestImageKey interface{}
 	for src, img := range cv.images {
 		w, h := img.img.Size()
@@ -502,21 +678,21 @@ func (cv *Canvas) reduceCache(keepSize, rec int) {
 			oldestImageKey = nil
 		}
 	}
-	for fnt, cache := range cv.fontPathCache {
+	for key, cache := range cv.fontPathCache {
 		total += cache.size()
 		if cache.lastUsed.Before(oldest) {
 			oldest = cache.lastUsed
-			oldestFontKey2 = fnt
+			oldestFontKey2 = key
 			oldestFontKey = fontKey{}
 			oldestImageKey = nil
 		}
 	}
-	for fnt, cache := range cv.fontTriCache {
+	for key, cache := range cv.fontTriCache {
 		total += cache.size()
 		if cache.lastUsed.Before(oldest) {
 			oldest = cache.lastUsed
-			oldestFontKey3 = fnt
-			oldestFontKey2 = nil
+			oldestFontKey3 = key
+			oldestFontKey2 = fontKey{}
 			oldestFontKey = fontKey{}
 			oldestImageKey = nil
 		}
@@ -528,9 +704,9 @@ func (cv *Canvas) reduceCache(keepSize, rec int) {
 	if oldestImageKey != nil {
 		cv.images[oldestImageKey].Delete()
 		delete(cv.images, oldestImageKey)
-	} else if oldestFontKey2 != nil {
+	} else if oldestFontKey2.font != nil {
 		delete(cv.fontPathCache, oldestFontKey2)
-	} else if oldestFontKey3 != nil {
+	} else if oldestFontKey3.font != nil {
 		delete(cv.fontTriCache, oldestFontKey3)
 	} else {
 		cv.fontCtxs[oldestFontKey].ctx = nil
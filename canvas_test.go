@@ -46,6 +46,8 @@ func run(t *testing.T, fn func(cv *canvas.Canvas)) {
 		img = cv.GetImageData(0, 0, 100, 100)
 	}
 
+	checkWorkers(t, fn)
+
 	caller, _, _, ok := runtime.Caller(1)
 	if !ok {
 		t.Fatal("Failed to get caller")
@@ -105,6 +107,31 @@ func run(t *testing.T, fn func(cv *canvas.Canvas)) {
 	}
 }
 
+// checkWorkers renders with the software backend using one
+// and several workers and makes sure the results are the same
+func checkWorkers(t *testing.T, fn func(cv *canvas.Canvas)) {
+	for _, msaa := range []int{0, 2} {
+		var imgs [2]*image.RGBA
+		for i, workers := range []int{1, 4} {
+			backend := softwarebackend.New(100, 100)
+			backend.MSAA = msaa
+			backend.Workers = workers
+			cv := canvas.New(backend)
+			cv.SetFillStyle("#000")
+			cv.FillRect(0, 0, 100, 100)
+			fn(cv)
+			imgs[i] = cv.GetImageData(0, 0, 100, 100)
+		}
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 100; x++ {
+				if c1, c2 := imgs[0].RGBAAt(x, y), imgs[1].RGBAAt(x, y); c1 != c2 {
+					t.Fatalf("MSAA %d: pixel %d,%d is %v with one worker and %v with several", msaa, x, y, c1, c2)
+				}
+			}
+		}
+	}
+}
+
 func writeImage(img *image.RGBA, fileName string) error {
 	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0777)
 	if err != nil {
@@ -505,6 +532,64 @@ func TestConvexSelfIntersecting(t *testing.T) {
 	})
 }
 
+func TestFillRuleDonut(t *testing.T) {
+	run(t, func(cv *canvas.Canvas) {
+		donut := func(x, y float64, anticlockwise bool) {
+			cv.SetFillStyle("#F00")
+			cv.BeginPath()
+			cv.Arc(x, y, 20, 0, math.Pi*2, false)
+			cv.ClosePath()
+			cv.MoveTo(x+10, y)
+			cv.Arc(x, y, 10, 0, math.Pi*2, anticlockwise)
+			cv.ClosePath()
+		}
+		// the holes of the top donuts go the same way as the
+		// outside, the ones of the bottom donuts the other way
+		donut(25, 25, false)
+		cv.FillWithRule(canvas.NonZero)
+		donut(75, 25, false)
+		cv.FillWithRule(canvas.EvenOdd)
+		donut(25, 75, true)
+		cv.FillWithRule(canvas.NonZero)
+		donut(75, 75, true)
+		cv.FillWithRule(canvas.EvenOdd)
+	})
+}
+
+func TestFillRulePentagram(t *testing.T) {
+	run(t, func(cv *canvas.Canvas) {
+		pentagram := func(x float64) {
+			cv.SetFillStyle("#0F0")
+			cv.BeginPath()
+			for i := 0; i < 5; i++ {
+				s, c := math.Sincos(float64(i*2)*math.Pi*2/5 - math.Pi/2)
+				cv.LineTo(x+22*c, 28+22*s)
+			}
+			cv.ClosePath()
+		}
+		// the twisted quad only turns one way until it is
+		// closed, and crosses itself once, so both rules fill
+		// it the same
+		twisted := func(x float64) {
+			cv.SetFillStyle("#00F")
+			cv.BeginPath()
+			cv.MoveTo(x-20, 62)
+			cv.LineTo(x+20, 92)
+			cv.LineTo(x+3, 91)
+			cv.LineTo(x+4, 57)
+			cv.ClosePath()
+		}
+		pentagram(25)
+		cv.FillWithRule(canvas.NonZero)
+		pentagram(75)
+		cv.FillWithRule(canvas.EvenOdd)
+		twisted(25)
+		cv.FillWithRule(canvas.NonZero)
+		twisted(75)
+		cv.FillWithRule(canvas.EvenOdd)
+	})
+}
+
 func TestTransform(t *testing.T) {
 	run(t, func(cv *canvas.Canvas) {
 		path := cv.NewPath2D()
@@ -633,6 +718,100 @@ func TestShadow(t *testing.T) {
 	})
 }
 
+func TestCompositeOperation(t *testing.T) {
+	run(t, func(cv *canvas.Canvas) {
+		cv.SetFillStyle("#F00")
+		cv.FillRect(10, 10, 50, 50)
+		cv.SetGlobalCompositeOperation(canvas.CompositeMultiply)
+		cv.SetFillStyle("#0F0A")
+		cv.FillRect(30, 30, 50, 50)
+		cv.SetGlobalCompositeOperation(canvas.CompositeDestinationOut)
+		cv.SetFillStyle("#000")
+		cv.FillRect(15, 15, 20, 20)
+		cv.SetGlobalCompositeOperation(canvas.CompositeLighter)
+		cv.SetFillStyle("#00F")
+		cv.FillRect(50, 50, 40, 40)
+	})
+}
+
+// compositeCell draws op over an orange square in the cell
+// and then fills what is transparent with gray, since partly
+// transparent pixels don't survive the trip through PNG
+func compositeCell(cv *canvas.Canvas, i int, op func()) {
+	x, y := float64(i%4)*25, float64(i/4)*25
+	cv.Save()
+	cv.BeginPath()
+	cv.Rect(x, y, 25, 25)
+	cv.Clip()
+	cv.SetFillStyle("#F80C")
+	cv.FillRect(x+2, y+2, 14, 14)
+	op()
+	cv.SetGlobalCompositeOperation(canvas.CompositeDestinationOver)
+	cv.SetGlobalAlpha(1)
+	cv.SetFillStyle("#888")
+	cv.FillRect(x, y, 25, 25)
+	cv.Restore()
+}
+
+func TestCompositePorterDuff(t *testing.T) {
+	run(t, func(cv *canvas.Canvas) {
+		for op := canvas.CompositeSourceIn; op <= canvas.CompositeXor; op++ {
+			i := int(op - canvas.CompositeSourceIn)
+			x, y := float64(i%4)*25, float64(i/4)*25
+			compositeCell(cv, i, func() {
+				cv.SetGlobalCompositeOperation(op)
+				cv.SetFillStyle("#08F8")
+				cv.FillRect(x+9, y+9, 14, 14)
+			})
+		}
+	})
+}
+
+func TestCompositeBlend(t *testing.T) {
+	run(t, func(cv *canvas.Canvas) {
+		for op := canvas.CompositeMultiply; op <= canvas.CompositeLuminosity; op++ {
+			i := int(op - canvas.CompositeMultiply)
+			x, y := float64(i%4)*25, float64(i/4)*25
+			compositeCell(cv, i, func() {
+				cv.SetGlobalCompositeOperation(op)
+				cv.SetFillStyle("#3A6")
+				cv.FillRect(x+9, y+9, 14, 14)
+			})
+		}
+	})
+}
+
+func TestCompositeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 14, 14))
+	for y := 0; y < 14; y++ {
+		for x := 0; x < 14; x++ {
+			img.Pix[y*img.Stride+x*4] = uint8(x * 18)
+			img.Pix[y*img.Stride+x*4+1] = uint8(y * 18)
+			img.Pix[y*img.Stride+x*4+2] = 128
+			img.Pix[y*img.Stride+x*4+3] = 255
+		}
+	}
+	run(t, func(cv *canvas.Canvas) {
+		cv.SetImageSmoothingEnabled(false)
+		cv.SetFont("testdata/Roboto-Light.ttf", 20)
+		// destination-in, xor, lighten, difference and luminosity
+		for op := canvas.CompositeDestinationIn; op <= canvas.CompositeLuminosity; op += 5 {
+			i := int(op-canvas.CompositeDestinationIn) / 5
+			x, y := float64(i%4)*25, float64(i/4)*25
+			compositeCell(cv, i, func() {
+				cv.SetGlobalCompositeOperation(op)
+				cv.SetGlobalAlpha(0.75)
+				cv.DrawImage(img, x+9, y+9)
+			})
+			compositeCell(cv, i+8, func() {
+				cv.SetGlobalCompositeOperation(op)
+				cv.SetFillStyle("#0FF")
+				cv.FillText("W", x+4, y+50+20)
+			})
+		}
+	})
+}
+
 func TestReadme(t *testing.T) {
 	run(t, func(cv *canvas.Canvas) {
 		w, h := 100.0, 100.0
@@ -0,0 +1,387 @@
+package canvas
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	otfont "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/font/opentype/tables"
+	"github.com/golang/freetype/truetype"
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"golang.org/x/image/tiff"
+)
+
+// colorGlyph is the color data of a glyph. Glyphs from the
+// COLR table have a paint, glyphs from the CBDT or sbix
+// tables have a bitmap with its extents in font units
+type colorGlyph struct {
+	paint   tables.PaintTable
+	bitmap  image.Image
+	extents otfont.GlyphExtents
+}
+
+// maxPaintDepth limits the nesting of COLR paints, which
+// can refer to each other in broken fonts
+const maxPaintDepth = 64
+
+// colorGlyph returns the color data of the glyph, or nil if
+// the glyph is only an outline
+func (f *Font) colorGlyph(idx truetype.Index) *colorGlyph {
+	if !f.hasColor {
+		return nil
+	}
+	if cg, ok := f.colorGlyphs[idx]; ok {
+		return cg
+	}
+
+	var cg *colorGlyph
+	gid := otfont.GID(idx)
+	if data, ok := f.face.GlyphDataColor(gid); ok {
+		cg = &colorGlyph{paint: data.Paint}
+	} else if data, ok := f.face.GlyphDataBitmap(gid); ok {
+		img, err := decodeBitmap(data)
+		ext, ok := f.face.GlyphExtents(gid)
+		if err == nil && ok {
+			cg = &colorGlyph{bitmap: img, extents: ext}
+		}
+	}
+
+	if f.colorGlyphs == nil {
+		f.colorGlyphs = make(map[truetype.Index]*colorGlyph)
+	}
+	f.colorGlyphs[idx] = cg
+	return cg
+}
+
+// decodeBitmap decodes the image of a bitmap glyph. Black
+// and white bitmaps are not supported, the outlines of
+// these glyphs are drawn instead
+func decodeBitmap(data otfont.GlyphBitmap) (image.Image, error) {
+	r := bytes.NewReader(data.Data)
+	switch data.Format {
+	case otfont.PNG:
+		return png.Decode(r)
+	case otfont.JPG:
+		return jpeg.Decode(r)
+	case otfont.TIFF:
+		return tiff.Decode(r)
+	}
+	return nil, errors.New("unsupported bitmap format")
+}
+
+// fillColorGlyphs draws the color glyphs, which fillGlyphs
+// and fillGlyphTris skip. Bitmaps are drawn as images and
+// COLR glyphs as fills of their layers, with the given style
+// used for the foreground color
+func (cv *Canvas) fillColorGlyphs(glyphs []textGlyph, style *drawStyle, x, y, scale float64) {
+	for _, g := range glyphs {
+		gx := x + float64(g.offset.X)/64/scale
+		gy := y + float64(g.offset.Y)/64/scale
+		x += float64(g.advance) / 64 / scale
+
+		cg := g.font.colorGlyph(g.idx)
+		if cg == nil {
+			continue
+		}
+
+		// k is the size of a font unit
+		k := float64(g.size) / 64 / scale / float64(g.font.face.Upem())
+		if cg.bitmap != nil {
+			ext := cg.extents
+			cv.DrawImage(cg.bitmap,
+				gx+float64(ext.XBearing)*k, gy-float64(ext.YBearing)*k,
+				float64(ext.Width)*k, -float64(ext.Height)*k)
+			continue
+		}
+
+		p := colorPainter{
+			cv:   cv,
+			font: g.font,
+			fg:   style,
+			tf:   backendbase.Mat{k, 0, 0, -k, gx, gy},
+		}
+		// the shadows of all layers are drawn first so that
+		// they don't cover the layers below
+		if cv.state.shadowColor.A != 0 {
+			p.shadow = true
+			p.paint(cg.paint, backendbase.MatIdentity, 0)
+			p.shadow = false
+		}
+		p.paint(cg.paint, backendbase.MatIdentity, 0)
+	}
+}
+
+// colorPainter draws the paints of a COLR glyph. Paints are
+// in font units with the y axis going up, and tf transforms
+// them to the canvas. If shadow is set, only the shadows of
+// the fills are drawn
+type colorPainter struct {
+	cv     *Canvas
+	font   *Font
+	fg     *drawStyle
+	tf     backendbase.Mat
+	shadow bool
+}
+
+// paint draws the paint with the paint transform m. Solid
+// fills and linear and radial gradients are supported, sweep
+// gradients are filled with their first color, and
+// composites are drawn as the source over the backdrop
+// except for the modes that only draw one of them
+func (p *colorPainter) paint(paint tables.PaintTable, m backendbase.Mat, depth int) {
+	if depth > maxPaintDepth {
+		return
+	}
+	switch v := paint.(type) {
+	case tables.PaintColrLayersResolved:
+		for _, layer := range v {
+			style, alpha := p.solid(layer.PaletteIndex, 1)
+			p.fill(layer.GlyphID, &style, alpha, m)
+		}
+	case tables.PaintColrLayers:
+		layers, err := p.font.face.COLR.LayerList.Resolve(v)
+		if err != nil {
+			return
+		}
+		for _, layer := range layers {
+			p.paint(layer, m, depth+1)
+		}
+	case tables.PaintGlyph:
+		if p.shadow {
+			p.fill(tables.GlyphID(v.GlyphID), nil, 0, m)
+		} else if style, alpha, ok := p.style(v.Paint, m, depth+1); ok {
+			p.fill(tables.GlyphID(v.GlyphID), &style, alpha, m)
+		}
+	case tables.PaintColrGlyph:
+		if child, ok := p.font.face.COLR.Search(tables.GlyphID(v.GlyphID)); ok {
+			p.paint(child, m, depth+1)
+		}
+	case tables.PaintComposite:
+		switch v.CompositeMode {
+		case tables.CompositeClear:
+		case tables.CompositeSrc:
+			p.paint(v.SourcePaint, m, depth+1)
+		case tables.CompositeDest:
+			p.paint(v.BackdropPaint, m, depth+1)
+		case tables.CompositeDestOver:
+			p.paint(v.SourcePaint, m, depth+1)
+			p.paint(v.BackdropPaint, m, depth+1)
+		default:
+			p.paint(v.BackdropPaint, m, depth+1)
+			p.paint(v.SourcePaint, m, depth+1)
+		}
+	default:
+		if tf, child, ok := paintTransform(paint); ok {
+			p.paint(child, tf.Mul(m), depth+1)
+		}
+	}
+}
+
+// fill fills the outline of the glyph with the style, or
+// draws its shadow
+func (p *colorPainter) fill(gid tables.GlyphID, style *drawStyle, alpha float64, m backendbase.Mat) {
+	tris := p.cv.glyphTris(p.font, truetype.Index(gid))
+	if len(tris) == 0 {
+		return
+	}
+
+	// the triangles are at the base font size with the y
+	// axis going down
+	s := float64(p.font.face.Upem()) / (float64(baseFontSize) / 64)
+	tf := backendbase.MatScale(backendbase.Vec{s, -s}).Mul(m).Mul(p.tf).Mul(p.cv.state.transform)
+
+	if p.shadow {
+		shadow := make([]backendbase.Vec, len(tris))
+		for i, pt := range tris {
+			shadow[i] = pt.MulMat(tf)
+		}
+		p.cv.drawShadow(shadow, nil, false)
+		return
+	}
+
+	stl := p.cv.backendFillStyle(style, alpha)
+	p.cv.b.Fill(&stl, tris, tf, false)
+}
+
+// style returns the style and alpha for filling a glyph with
+// the paint
+func (p *colorPainter) style(paint tables.PaintTable, m backendbase.Mat, depth int) (drawStyle, float64, bool) {
+	if depth > maxPaintDepth {
+		return drawStyle{}, 0, false
+	}
+	// um transforms the gradient coordinates to the canvas
+	um := m.Mul(p.tf)
+
+	switch v := paint.(type) {
+	case tables.PaintSolid:
+		style, alpha := p.solid(v.PaletteIndex, f2dot14(v.Alpha))
+		return style, alpha, true
+	case tables.PaintLinearGradient:
+		stops, from, to := p.colorStops(v.ColorLine)
+		if len(stops) == 0 {
+			return drawStyle{}, 0, false
+		}
+		p0 := backendbase.Vec{float64(v.X0), float64(v.Y0)}.MulMat(um)
+		p1 := backendbase.Vec{float64(v.X1), float64(v.Y1)}.MulMat(um)
+		p2 := backendbase.Vec{float64(v.X2), float64(v.Y2)}.MulMat(um)
+
+		// the gradient is perpendicular to the line from p0
+		// to p2, so p1 is projected onto the normal
+		normal := backendbase.Vec{p0[1] - p2[1], p2[0] - p0[0]}
+		if lsqr := normal.LenSqr(); lsqr > 0 {
+			p1 = p0.Add(normal.Mulf(p1.Sub(p0).Dot(normal) / lsqr))
+		}
+		dir := p1.Sub(p0)
+		p0, p1 = p0.Add(dir.Mulf(from)), p0.Add(dir.Mulf(to))
+
+		lg := p.cv.CreateLinearGradient(p0[0], p0[1], p1[0], p1[1])
+		for _, stop := range stops {
+			lg.AddColorStop(stop.Pos, stop.Color)
+		}
+		return drawStyle{linearGradient: lg}, 1, true
+	case tables.PaintRadialGradient:
+		stops, from, to := p.colorStops(v.ColorLine)
+		if len(stops) == 0 {
+			return drawStyle{}, 0, false
+		}
+		c0 := backendbase.Vec{float64(v.X0), float64(v.Y0)}.MulMat(um)
+		c1 := backendbase.Vec{float64(v.X1), float64(v.Y1)}.MulMat(um)
+		scale := math.Sqrt(math.Abs(um[0]*um[3] - um[1]*um[2]))
+		r0, r1 := float64(v.Radius0)*scale, float64(v.Radius1)*scale
+
+		dir := c1.Sub(c0)
+		c0, c1 = c0.Add(dir.Mulf(from)), c0.Add(dir.Mulf(to))
+		r0, r1 = math.Max(r0+(r1-r0)*from, 0), math.Max(r0+(r1-r0)*to, 0)
+
+		rg := p.cv.CreateRadialGradient(c0[0], c0[1], r0, c1[0], c1[1], r1)
+		for _, stop := range stops {
+			rg.AddColorStop(stop.Pos, stop.Color)
+		}
+		return drawStyle{radialGradient: rg}, 1, true
+	case tables.PaintSweepGradient:
+		stops, _, _ := p.colorStops(v.ColorLine)
+		if len(stops) == 0 {
+			return drawStyle{}, 0, false
+		}
+		return drawStyle{color: stops[0].Color}, 1, true
+	}
+
+	if tf, child, ok := paintTransform(paint); ok {
+		return p.style(child, tf.Mul(m), depth+1)
+	}
+	return drawStyle{}, 0, false
+}
+
+// solid returns the style and alpha for a color of the
+// palette. The index 0xFFFF is the foreground color
+func (p *colorPainter) solid(paletteIndex uint16, alpha float64) (drawStyle, float64) {
+	if c, ok := p.paletteColor(paletteIndex); ok {
+		return drawStyle{color: c}, alpha
+	}
+	return *p.fg, alpha
+}
+
+// paletteColor returns the color from the first palette of
+// the font, or false for the foreground color
+func (p *colorPainter) paletteColor(paletteIndex uint16) (color.RGBA, bool) {
+	cpal := p.font.face.CPAL
+	if len(cpal) == 0 || int(paletteIndex) >= len(cpal[0]) {
+		return color.RGBA{}, false
+	}
+	c := cpal[0][paletteIndex]
+	return color.RGBA{R: c.Red, G: c.Green, B: c.Blue, A: c.Alpha}, true
+}
+
+// colorStops returns the stops of the color line with the
+// offsets moved into the range from 0 to 1, along with the
+// original range that the gradient has to be adjusted to.
+// Only padding is supported to extend the color line
+func (p *colorPainter) colorStops(cl tables.ColorLine) (backendbase.Gradient, float64, float64) {
+	if len(cl.ColorStops) == 0 {
+		return nil, 0, 0
+	}
+	from, to := math.Inf(1), math.Inf(-1)
+	for _, stop := range cl.ColorStops {
+		from = math.Min(from, f2dot14(stop.StopOffset))
+		to = math.Max(to, f2dot14(stop.StopOffset))
+	}
+
+	stops := make(backendbase.Gradient, len(cl.ColorStops))
+	for i, stop := range cl.ColorStops {
+		c, ok := p.paletteColor(stop.PaletteIndex)
+		if !ok {
+			c = p.fg.color
+		}
+		c.A = uint8(math.Round(float64(c.A) * math.Min(math.Max(f2dot14(stop.Alpha), 0), 1)))
+		stops[i].Color = c
+		if to > from {
+			stops[i].Pos = (f2dot14(stop.StopOffset) - from) / (to - from)
+		}
+	}
+	if to <= from {
+		// all stops are at the same offset, which is drawn
+		// with the last color
+		return stops[len(stops)-1:], 0, 1
+	}
+	return stops, from, to
+}
+
+// paintTransform returns the transformation of a transform
+// paint and the paint that it applies to
+func paintTransform(paint tables.PaintTable) (backendbase.Mat, tables.PaintTable, bool) {
+	around := func(m backendbase.Mat, cx, cy int16) backendbase.Mat {
+		c := backendbase.Vec{float64(cx), float64(cy)}
+		return backendbase.MatTranslate(c.Mulf(-1)).Mul(m).Mul(backendbase.MatTranslate(c))
+	}
+	skew := func(x, y tables.Fixed214) backendbase.Mat {
+		// the x skew angle is counter-clockwise, so it is
+		// negated for the shear of x
+		return backendbase.Mat{
+			1, math.Tan(f2dot14(y) * math.Pi),
+			-math.Tan(f2dot14(x) * math.Pi), 1,
+			0, 0,
+		}
+	}
+
+	switch v := paint.(type) {
+	case tables.PaintTransform:
+		t := v.Transform
+		return backendbase.Mat{
+			float64(t.Xx), float64(t.Yx),
+			float64(t.Xy), float64(t.Yy),
+			float64(t.Dx), float64(t.Dy),
+		}, v.Paint, true
+	case tables.PaintTranslate:
+		return backendbase.MatTranslate(backendbase.Vec{float64(v.Dx), float64(v.Dy)}), v.Paint, true
+	case tables.PaintScale:
+		return backendbase.MatScale(backendbase.Vec{f2dot14(v.ScaleX), f2dot14(v.ScaleY)}), v.Paint, true
+	case tables.PaintScaleAroundCenter:
+		m := backendbase.MatScale(backendbase.Vec{f2dot14(v.ScaleX), f2dot14(v.ScaleY)})
+		return around(m, v.CenterX, v.CenterY), v.Paint, true
+	case tables.PaintScaleUniform:
+		return backendbase.MatScale(backendbase.Vec{f2dot14(v.Scale), f2dot14(v.Scale)}), v.Paint, true
+	case tables.PaintScaleUniformAroundCenter:
+		m := backendbase.MatScale(backendbase.Vec{f2dot14(v.Scale), f2dot14(v.Scale)})
+		return around(m, v.CenterX, v.CenterY), v.Paint, true
+	case tables.PaintRotate:
+		return backendbase.MatRotate(f2dot14(v.Angle) * math.Pi), v.Paint, true
+	case tables.PaintRotateAroundCenter:
+		m := backendbase.MatRotate(f2dot14(v.Angle) * math.Pi)
+		return around(m, v.CenterX, v.CenterY), v.Paint, true
+	case tables.PaintSkew:
+		return skew(v.XSkewAngle, v.YSkewAngle), v.Paint, true
+	case tables.PaintSkewAroundCenter:
+		return around(skew(v.XSkewAngle, v.YSkewAngle), v.CenterX, v.CenterY), v.Paint, true
+	}
+	return backendbase.Mat{}, nil, false
+}
+
+// f2dot14 converts a 2.14 fixed point number
+func f2dot14(v tables.Fixed214) float64 {
+	return float64(v) / (1 << 14)
+}
@@ -0,0 +1,147 @@
+package canvas
+
+import (
+	"encoding/binary"
+	"image"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// buildColorFont adds COLR and CPAL tables to Roboto Light,
+// which draw H in red and O in the foreground color
+func buildColorFont(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("testdata/Roboto-Light.ttf")
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	font, err := truetype.Parse(data)
+	if err != nil {
+		t.Fatalf("failed to parse font: %v", err)
+	}
+	h, o := font.Index('H'), font.Index('O')
+
+	be := binary.BigEndian
+	colr := make([]byte, 14+2*6+2*4)
+	be.PutUint16(colr[2:], 2)  // base glyph records
+	be.PutUint32(colr[4:], 14) // base glyph records offset
+	be.PutUint32(colr[8:], 26) // layer records offset
+	be.PutUint16(colr[12:], 2) // layer records
+	for i, g := range []truetype.Index{h, o} {
+		rec := colr[14+6*i:]
+		be.PutUint16(rec, uint16(g))
+		be.PutUint16(rec[2:], uint16(i))
+		be.PutUint16(rec[4:], 1)
+	}
+	be.PutUint16(colr[26:], uint16(h))
+	be.PutUint16(colr[28:], 0) // red
+	be.PutUint16(colr[30:], uint16(o))
+	be.PutUint16(colr[32:], 0xffff) // foreground
+
+	cpal := make([]byte, 14+4)
+	be.PutUint16(cpal[2:], 1) // palette entries
+	be.PutUint16(cpal[4:], 1) // palettes
+	be.PutUint16(cpal[6:], 1) // color records
+	be.PutUint32(cpal[8:], 14)
+	copy(cpal[14:], []byte{0, 0, 255, 255}) // BGRA
+
+	f := readTestFont(t, data)
+	tables := append(f.tables, sfntTable{tag: sfntTag("COLR"), data: colr}, sfntTable{tag: sfntTag("CPAL"), data: cpal})
+	return buildSFNT(f.flavor, tables)
+}
+
+// colorCounts counts the red, blue and other colored pixels
+// in the rectangle
+func colorCounts(img *image.RGBA, r image.Rectangle) (red, blue, other int) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			switch {
+			case c.A == 0:
+			case c.G == 0 && c.B == 0:
+				red++
+			case c.R == 0 && c.G == 0:
+				blue++
+			default:
+				other++
+			}
+		}
+	}
+	return
+}
+
+func TestColorGlyphs(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	f, err := cv.LoadFont(buildColorFont(t))
+	if err != nil {
+		t.Fatalf("failed to load font: %v", err)
+	}
+	plain, err := cv.LoadFont("testdata/Roboto-Light.ttf")
+	if err != nil {
+		t.Fatalf("failed to load font: %v", err)
+	}
+	if !f.hasColor || plain.hasColor {
+		t.Fatalf("the color font has color %v and the plain font %v", f.hasColor, plain.hasColor)
+	}
+	if f.colorGlyph(f.font.Index('H')) == nil || f.colorGlyph(f.font.Index('O')) == nil {
+		t.Error("the color glyphs have no color data")
+	}
+	if f.colorGlyph(f.font.Index('x')) != nil {
+		t.Error("a glyph without layers has color data")
+	}
+
+	// the layers are filled with their palette colors or the
+	// fill style, and other glyphs with the fill style
+	cases := []struct {
+		text      string
+		red, blue bool
+	}{
+		{"H", true, false},
+		{"O", false, true},
+		{"x", false, true},
+	}
+	for _, tc := range cases {
+		cv := New(softwarebackend.New(100, 100))
+		cv.SetFont(f, 40)
+		cv.SetFillStyle("#00F")
+		cv.FillText(tc.text, 20, 60)
+		red, blue, other := colorCounts(cv.GetImageData(0, 0, 100, 100), image.Rect(0, 0, 100, 100))
+		if (red > 0) != tc.red || (blue > 0) != tc.blue || other > 0 {
+			t.Errorf("%s has %d red, %d blue and %d other pixels", tc.text, red, blue, other)
+		}
+	}
+
+	// color glyphs are drawn in place of the outline, at the
+	// same position as the plain glyph
+	render := func(f *Font) (*image.RGBA, float64) {
+		cv := New(softwarebackend.New(100, 100))
+		cv.SetFont(f, 40)
+		cv.SetFillStyle("#00F")
+		cv.FillText("xH", 10, 60)
+		return cv.GetImageData(0, 0, 100, 100), 10 + cv.MeasureText("x").Width
+	}
+	img, split := render(f)
+	plainImg, _ := render(plain)
+	var colorBounds, plainBounds image.Rectangle
+	for y := 0; y < 100; y++ {
+		for x := int(split) + 1; x < 100; x++ {
+			if img.RGBAAt(x, y).A != 0 {
+				colorBounds = colorBounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+			if plainImg.RGBAAt(x, y).A != 0 {
+				plainBounds = plainBounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if d := colorBounds.Min.Sub(plainBounds.Min); d.X < -1 || d.X > 1 || d.Y < -1 || d.Y > 1 || colorBounds.Empty() {
+		t.Errorf("the color glyph is at %v instead of %v", colorBounds, plainBounds)
+	}
+	if red, blue, other := colorCounts(img, image.Rect(0, 0, int(split)-1, 100)); red > 0 || blue == 0 || other > 0 {
+		t.Errorf("x next to a color glyph has %d red, %d blue and %d other pixels", red, blue, other)
+	}
+	if red, blue, other := colorCounts(img, image.Rect(int(split)+1, 0, 100, 100)); red == 0 || blue > 0 || other > 0 {
+		t.Errorf("H next to a regular glyph has %d red, %d blue and %d other pixels", red, blue, other)
+	}
+}
@@ -0,0 +1,214 @@
+package canvas
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// SetFilter sets the filter that is applied to everything
+// that is drawn, like the CSS filter property. It is a list
+// of the filter functions blur, brightness, contrast,
+// drop-shadow, grayscale, hue-rotate, invert, opacity,
+// saturate and sepia, for example "blur(4px) grayscale(50%)".
+// The functions are applied in order to the output of each
+// draw operation before it is combined with the canvas.
+// "none" removes the filter. Invalid values are ignored
+func (cv *Canvas) SetFilter(filter string) {
+	if f, ok := parseFilter(filter); ok {
+		cv.state.filter = f
+	}
+}
+
+func parseFilter(str string) (backendbase.Filter, bool) {
+	str = strings.TrimSpace(str)
+	if str == "none" || str == "" {
+		return nil, true
+	}
+
+	var filter backendbase.Filter
+	for len(str) > 0 {
+		open := strings.IndexByte(str, '(')
+		if open < 0 {
+			return nil, false
+		}
+		name := strings.ToLower(strings.TrimSpace(str[:open]))
+		end := matchingParen(str, open)
+		if end < 0 {
+			return nil, false
+		}
+		f, ok := parseFilterFunc(name, strings.TrimSpace(str[open+1:end]))
+		if !ok {
+			return nil, false
+		}
+		filter = append(filter, f)
+		str = strings.TrimSpace(str[end+1:])
+	}
+	return filter, true
+}
+
+// matchingParen returns the index of the parenthesis that
+// closes the one at the given index, or -1
+func matchingParen(str string, open int) int {
+	depth := 0
+	for i := open; i < len(str); i++ {
+		switch str[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseFilterFunc(name, arg string) (backendbase.FilterFunc, bool) {
+	var f backendbase.FilterFunc
+	var ok bool
+	switch name {
+	case "blur":
+		f.Type = backendbase.FilterBlur
+		f.Amount, ok = parseFilterLength(arg, 0)
+		return f, ok && f.Amount >= 0
+	case "hue-rotate":
+		f.Type = backendbase.FilterHueRotate
+		f.Amount, ok = parseFilterAngle(arg)
+		return f, ok
+	case "drop-shadow":
+		return parseDropShadow(arg)
+	}
+
+	types := map[string]backendbase.FilterType{
+		"brightness": backendbase.FilterBrightness,
+		"contrast":   backendbase.FilterContrast,
+		"grayscale":  backendbase.FilterGrayscale,
+		"invert":     backendbase.FilterInvert,
+		"opacity":    backendbase.FilterOpacity,
+		"saturate":   backendbase.FilterSaturate,
+		"sepia":      backendbase.FilterSepia,
+	}
+	f.Type, ok = types[name]
+	if !ok {
+		return f, false
+	}
+	f.Amount, ok = parseFilterAmount(arg)
+	return f, ok && f.Amount >= 0
+}
+
+// parseFilterAmount parses a number or percentage, which
+// defaults to 1
+func parseFilterAmount(arg string) (float64, bool) {
+	if arg == "" {
+		return 1, true
+	}
+	scale := 1.0
+	if strings.HasSuffix(arg, "%") {
+		arg = arg[:len(arg)-1]
+		scale = 0.01
+	}
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// parseFilterLength parses a length in pixels. Numbers
+// without unit are only valid for 0
+func parseFilterLength(arg string, def float64) (float64, bool) {
+	if arg == "" {
+		return def, true
+	}
+	if strings.HasSuffix(arg, "px") {
+		v, err := strconv.ParseFloat(arg[:len(arg)-2], 64)
+		return v, err == nil
+	}
+	return 0, arg == "0"
+}
+
+// parseFilterAngle parses an angle and returns it in degrees
+func parseFilterAngle(arg string) (float64, bool) {
+	if arg == "" || arg == "0" {
+		return 0, true
+	}
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"deg", 1},
+		{"grad", 0.9},
+		{"rad", 180 / math.Pi},
+		{"turn", 360},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(arg, u.suffix) {
+			v, err := strconv.ParseFloat(arg[:len(arg)-len(u.suffix)], 64)
+			return v * u.scale, err == nil
+		}
+	}
+	return 0, false
+}
+
+// parseDropShadow parses the arguments of drop-shadow, which
+// are two or three lengths for the offset and blur radius
+// and an optional color before or after them
+func parseDropShadow(arg string) (backendbase.FilterFunc, bool) {
+	f := backendbase.FilterFunc{Type: backendbase.FilterDropShadow}
+	f.Color.A = 255
+
+	var lengths []float64
+	var colorStr string
+	colorPos := -1
+	for len(arg) > 0 {
+		var token string
+		if idx := strings.IndexAny(arg, " ("); idx >= 0 && arg[idx] == '(' {
+			end := matchingParen(arg, idx)
+			if end < 0 {
+				return f, false
+			}
+			token, arg = arg[:end+1], arg[end+1:]
+		} else if idx >= 0 {
+			token, arg = arg[:idx], arg[idx:]
+		} else {
+			token, arg = arg, ""
+		}
+		arg = strings.TrimSpace(arg)
+
+		if v, ok := parseFilterLength(token, 0); ok {
+			if colorPos > 0 {
+				// the color is between the lengths
+				return f, false
+			}
+			lengths = append(lengths, v)
+		} else if colorStr == "" {
+			colorStr = token
+			colorPos = len(lengths)
+		} else {
+			return f, false
+		}
+	}
+
+	if len(lengths) < 2 || len(lengths) > 3 {
+		return f, false
+	}
+	f.OffsetX, f.OffsetY = lengths[0], lengths[1]
+	if len(lengths) == 3 {
+		if lengths[2] < 0 {
+			return f, false
+		}
+		f.Amount = lengths[2]
+	}
+	if colorStr != "" && !strings.EqualFold(colorStr, "currentcolor") {
+		c, ok := parseColor(colorStr)
+		if !ok {
+			return f, false
+		}
+		f.Color = c
+	}
+	return f, true
+}
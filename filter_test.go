@@ -0,0 +1,83 @@
+package canvas
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+func TestParseFilter(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	cases := []struct {
+		str    string
+		filter backendbase.Filter
+	}{
+		{"none", nil},
+		{"", nil},
+		{"blur(4px)", backendbase.Filter{{Type: backendbase.FilterBlur, Amount: 4}}},
+		{"blur(0)", backendbase.Filter{{Type: backendbase.FilterBlur}}},
+		{"blur()", backendbase.Filter{{Type: backendbase.FilterBlur}}},
+		{"grayscale(50%) invert(0.25)", backendbase.Filter{
+			{Type: backendbase.FilterGrayscale, Amount: 0.5},
+			{Type: backendbase.FilterInvert, Amount: 0.25},
+		}},
+		{" Sepia() ", backendbase.Filter{{Type: backendbase.FilterSepia, Amount: 1}}},
+		{"brightness(150%)", backendbase.Filter{{Type: backendbase.FilterBrightness, Amount: 1.5}}},
+		{"hue-rotate(0.5turn)", backendbase.Filter{{Type: backendbase.FilterHueRotate, Amount: 180}}},
+		{"hue-rotate(-90deg)", backendbase.Filter{{Type: backendbase.FilterHueRotate, Amount: -90}}},
+		{"drop-shadow(2px 3px)", backendbase.Filter{{Type: backendbase.FilterDropShadow, OffsetX: 2, OffsetY: 3, Color: color.RGBA{A: 255}}}},
+		{"drop-shadow(2px 3px 4px #F00)", backendbase.Filter{{Type: backendbase.FilterDropShadow, OffsetX: 2, OffsetY: 3, Amount: 4, Color: red}}},
+		{"drop-shadow(rgb(255, 0, 0) -2px 3px 4px)", backendbase.Filter{{Type: backendbase.FilterDropShadow, OffsetX: -2, OffsetY: 3, Amount: 4, Color: red}}},
+	}
+	for _, c := range cases {
+		filter, ok := parseFilter(c.str)
+		if !ok {
+			t.Errorf("%q was not accepted", c.str)
+		} else if !reflect.DeepEqual(filter, c.filter) {
+			t.Errorf("%q was parsed as %v instead of %v", c.str, filter, c.filter)
+		}
+	}
+
+	for _, str := range []string{
+		"blur(4)",
+		"blur(-1px)",
+		"blur(4px",
+		"grayscale(-10%)",
+		"grayscale(abc)",
+		"hue-rotate(90)",
+		"unknown(1)",
+		"grayscale(1) sepia",
+		"drop-shadow(2px)",
+		"drop-shadow(2px 3px 4px 5px)",
+		"drop-shadow(2px 3px -4px)",
+		"drop-shadow(2px #F00 3px)",
+		"drop-shadow(#F00 2px 3px #00F)",
+		"drop-shadow(2px 3px nocolor)",
+	} {
+		if _, ok := parseFilter(str); ok {
+			t.Errorf("%q was accepted", str)
+		}
+	}
+}
+
+func TestFilterClamp(t *testing.T) {
+	for _, typ := range []backendbase.FilterType{
+		backendbase.FilterGrayscale,
+		backendbase.FilterInvert,
+		backendbase.FilterOpacity,
+		backendbase.FilterSepia,
+	} {
+		m1, _ := backendbase.FilterFunc{Type: typ, Amount: 1}.ColorMatrix()
+		m2, _ := backendbase.FilterFunc{Type: typ, Amount: 2.5}.ColorMatrix()
+		if m1 != m2 {
+			t.Errorf("amount of filter type %d is not clamped to 1", typ)
+		}
+	}
+	m1, _ := backendbase.FilterFunc{Type: backendbase.FilterBrightness, Amount: 1}.ColorMatrix()
+	m2, _ := backendbase.FilterFunc{Type: backendbase.FilterBrightness, Amount: 2.5}.ColorMatrix()
+	if m1 == m2 {
+		t.Error("amount of brightness is clamped")
+	}
+}
@@ -0,0 +1,335 @@
+package canvas
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type fontStyle uint8
+
+// Font style constants for RegisterFontFace
+const (
+	FontNormal fontStyle = iota
+	FontItalic
+	FontOblique
+)
+
+// fontFace is a font registered for a family
+type fontFace struct {
+	weight int
+	style  fontStyle
+	font   *Font
+}
+
+// cssFont is a parsed CSS font shorthand
+type cssFont struct {
+	style    fontStyle
+	weight   int
+	size     float64
+	families []string
+}
+
+// RegisterFontFace registers a font for the given family name,
+// weight (100 to 900, where 400 is normal and 700 is bold)
+// and style, so that it can be selected with SetFontCSS. The
+// font can be any source accepted by LoadFont. Family names
+// are case insensitive
+func (cv *Canvas) RegisterFontFace(family string, weight int, style fontStyle, src interface{}) error {
+	f, err := cv.LoadFont(src)
+	if err != nil {
+		return err
+	}
+	family = strings.ToLower(family)
+	faces := cv.fontFaces[family]
+	for i, face := range faces {
+		if face.weight == weight && face.style == style {
+			faces[i].font = f
+			return nil
+		}
+	}
+	cv.fontFaces[family] = append(faces, fontFace{weight: weight, style: style, font: f})
+	return nil
+}
+
+// SetFontCSS sets the font from a CSS font shorthand like
+// "italic bold 16px 'Noto Sans', sans-serif". For each family
+// in the list, the registered face that best matches the
+// style and weight is selected like browsers do, and the
+// families after the first one that has registered faces are
+// used as fallbacks, followed by the default font. The weight
+// axis of variable fonts is set to the weight. Families
+// without registered faces, including generic ones like
+// sans-serif unless they were registered, are skipped. The
+// size can be given in px, pt, pc, in, cm, mm, Q, em, rem or
+// %, where em, rem and % are relative to 16px, or as a
+// keyword like medium. The variant, stretch and line height
+// are parsed but ignored. Invalid values are ignored like in
+// browsers
+func (cv *Canvas) SetFontCSS(css string) {
+	f, ok := parseCSSFont(css)
+	if !ok {
+		return
+	}
+
+	var family FontFamily
+	for _, name := range f.families {
+		font := matchFontFace(cv.fontFaces[strings.ToLower(name)], f.style, f.weight)
+		if font != nil {
+			font = font.Variation(FontVariation{Tag: "wght", Value: float64(f.weight)})
+		}
+		if font != nil && !containsFont(family, font) {
+			family = append(family, font)
+		}
+	}
+	if defaultFont != nil && !containsFont(family, defaultFont) {
+		family = append(family, defaultFont)
+	}
+	cv.SetFont(family, f.size)
+}
+
+func containsFont(family FontFamily, f *Font) bool {
+	for _, ff := range family {
+		if ff == f {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFontFace returns the face that best matches the style
+// and weight, following the font matching algorithm of CSS.
+// The style is matched first, with italic falling back to
+// oblique and the other way round before falling back to
+// normal. Weights between 400 and 500 try heavier weights up
+// to 500 first, then lighter weights, then heavier ones.
+// Lighter weights try lighter weights first and heavier ones
+// try heavier weights first
+func matchFontFace(faces []fontFace, style fontStyle, weight int) *Font {
+	var best *fontFace
+	bestStyle, bestWeight := 0, 0
+	for i := range faces {
+		face := &faces[i]
+		s := styleDistance(style, face.style)
+		w := weightDistance(weight, face.weight)
+		if best == nil || s < bestStyle || (s == bestStyle && w < bestWeight) {
+			best, bestStyle, bestWeight = face, s, w
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.font
+}
+
+func styleDistance(want, have fontStyle) int {
+	if want == have {
+		return 0
+	}
+	switch want {
+	case FontItalic:
+		if have == FontOblique {
+			return 1
+		}
+	case FontOblique:
+		if have == FontItalic {
+			return 1
+		}
+	case FontNormal:
+		if have == FontOblique {
+			return 1
+		}
+	}
+	return 2
+}
+
+func weightDistance(want, have int) int {
+	switch {
+	case want >= 400 && want <= 500:
+		if have >= want && have <= 500 {
+			return have - want
+		} else if have < want {
+			return 1000 + want - have
+		}
+		return 2000 + have - want
+	case want < 400:
+		if have <= want {
+			return want - have
+		}
+		return 1000 + have - want
+	}
+	if have >= want {
+		return have - want
+	}
+	return 1000 + want - have
+}
+
+// parseCSSFont parses a font shorthand in the form
+// [style || variant || weight || stretch] size[/line-height]
+// family[, family]*
+func parseCSSFont(css string) (cssFont, bool) {
+	f := cssFont{weight: 400}
+	rest := css
+	var tok string
+	prev := ""
+	for count := 0; ; count++ {
+		tok, rest = nextCSSToken(rest)
+		if tok == "" {
+			return cssFont{}, false
+		}
+		if size, ok := parseFontSize(tok); ok {
+			f.size = size
+			break
+		}
+		if count >= 4 || !f.parseKeyword(strings.ToLower(tok), prev) {
+			return cssFont{}, false
+		}
+		prev = strings.ToLower(tok)
+	}
+
+	rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if strings.HasPrefix(rest, "/") {
+		tok, rest = nextCSSToken(rest[1:])
+		if tok == "" {
+			return cssFont{}, false
+		}
+	}
+
+	families, ok := parseFontFamilies(rest)
+	if !ok {
+		return cssFont{}, false
+	}
+	f.families = families
+	return f, true
+}
+
+// nextCSSToken returns the next token separated by spaces or
+// a slash and the rest of the string
+func nextCSSToken(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	end := strings.IndexFunc(s, func(rn rune) bool {
+		return unicode.IsSpace(rn) || rn == '/'
+	})
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// parseKeyword parses a style, variant, weight or stretch
+// value in front of the size
+func (f *cssFont) parseKeyword(tok, prev string) bool {
+	switch tok {
+	case "normal", "small-caps":
+		return true
+	case "italic":
+		f.style = FontItalic
+		return true
+	case "oblique":
+		f.style = FontOblique
+		return true
+	case "bold":
+		f.weight = 700
+		return true
+	case "ultra-condensed", "extra-condensed", "condensed", "semi-condensed",
+		"semi-expanded", "expanded", "extra-expanded", "ultra-expanded":
+		return true
+	}
+	if prev == "oblique" && strings.HasSuffix(tok, "deg") {
+		_, err := strconv.ParseFloat(tok[:len(tok)-3], 64)
+		return err == nil
+	}
+	if w, err := strconv.ParseFloat(tok, 64); err == nil && w >= 1 && w <= 1000 {
+		f.weight = int(w)
+		return true
+	}
+	return false
+}
+
+var fontSizeKeywords = map[string]float64{
+	"xx-small":  9,
+	"x-small":   10,
+	"small":     13,
+	"medium":    16,
+	"large":     18,
+	"x-large":   24,
+	"xx-large":  32,
+	"xxx-large": 48,
+	"smaller":   16 / 1.2,
+	"larger":    16 * 1.2,
+}
+
+var fontSizeUnits = []struct {
+	unit  string
+	scale float64
+}{
+	{"px", 1},
+	{"pt", 96.0 / 72.0},
+	{"pc", 16},
+	{"in", 96},
+	{"cm", 96 / 2.54},
+	{"mm", 96 / 25.4},
+	{"q", 96 / 101.6},
+	{"rem", 16},
+	{"em", 16},
+	{"%", 0.16},
+}
+
+// parseFontSize parses a length, percentage or keyword that
+// is a font size and returns it in pixels
+func parseFontSize(tok string) (float64, bool) {
+	tok = strings.ToLower(tok)
+	if size, ok := fontSizeKeywords[tok]; ok {
+		return size, true
+	}
+	for _, u := range fontSizeUnits {
+		if !strings.HasSuffix(tok, u.unit) {
+			continue
+		}
+		v, err := strconv.ParseFloat(tok[:len(tok)-len(u.unit)], 64)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		return v * u.scale, true
+	}
+	return 0, false
+}
+
+// parseFontFamilies parses a comma separated list of family
+// names, which are either quoted or a sequence of identifiers
+func parseFontFamilies(s string) ([]string, bool) {
+	var families []string
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		var name string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return nil, false
+			}
+			name, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			name = strings.Join(strings.Fields(s[:end]), " ")
+			s = s[end:]
+			if strings.ContainsAny(name, "\"'") {
+				return nil, false
+			}
+		}
+		if name == "" {
+			return nil, false
+		}
+		families = append(families, name)
+
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return families, true
+		} else if s[0] != ',' {
+			return nil, false
+		}
+		s = s[1:]
+	}
+}
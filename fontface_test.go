@@ -0,0 +1,202 @@
+package canvas
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestParseCSSFont(t *testing.T) {
+	valid := []struct {
+		css      string
+		expected cssFont
+	}{
+		{"16px sans-serif", cssFont{weight: 400, size: 16, families: []string{"sans-serif"}}},
+		{"italic bold 12px/30px Georgia, serif", cssFont{style: FontItalic, weight: 700, size: 12, families: []string{"Georgia", "serif"}}},
+		{"bold italic 12px Georgia", cssFont{style: FontItalic, weight: 700, size: 12, families: []string{"Georgia"}}},
+		{"oblique 10deg 300 condensed 1em 'Noto Sans', \"Font, With Comma\"", cssFont{style: FontOblique, weight: 300, size: 16, families: []string{"Noto Sans", "Font, With Comma"}}},
+		{"normal small-caps normal normal 12pt  Open   Sans ", cssFont{weight: 400, size: 16, families: []string{"Open Sans"}}},
+		{"650 large/1.5 x", cssFont{weight: 650, size: 18, families: []string{"x"}}},
+		{"bold 150% x", cssFont{weight: 700, size: 24, families: []string{"x"}}},
+		{"BOLD 1in X", cssFont{weight: 700, size: 96, families: []string{"X"}}},
+		{"0.5rem x", cssFont{weight: 400, size: 8, families: []string{"x"}}},
+		{"medium x", cssFont{weight: 400, size: 16, families: []string{"x"}}},
+	}
+	for _, tc := range valid {
+		f, ok := parseCSSFont(tc.css)
+		if !ok {
+			t.Errorf("failed to parse %q", tc.css)
+			continue
+		}
+		if math.Abs(f.size-tc.expected.size) > 1e-9 {
+			t.Errorf("%q has the size %g instead of %g", tc.css, f.size, tc.expected.size)
+		}
+		f.size = tc.expected.size
+		if !reflect.DeepEqual(f, tc.expected) {
+			t.Errorf("%q is parsed as %+v instead of %+v", tc.css, f, tc.expected)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"sans-serif",
+		"16px",
+		"16 sans-serif",
+		"-2px x",
+		"heavy 16px x",
+		// the font shorthand doesn't allow relative weights
+		"bolder 16px x",
+		"lighter 150% x",
+		"bold italic normal normal normal 16px x",
+		"10deg 16px x",
+		"1001 16px x",
+		"16px/ x",
+		"16px 'unterminated",
+		"16px x,",
+		"16px x, , y",
+		"16px 'a' b",
+		"16px a'b",
+	}
+	for _, css := range invalid {
+		if f, ok := parseCSSFont(css); ok {
+			t.Errorf("%q is parsed as %+v instead of failing", css, f)
+		}
+	}
+}
+
+func TestMatchFontFace(t *testing.T) {
+	fonts := make(map[string]*Font)
+	face := func(name string, weight int, style fontStyle) fontFace {
+		fonts[name] = &Font{}
+		return fontFace{weight: weight, style: style, font: fonts[name]}
+	}
+	faces := []fontFace{
+		face("300", 300, FontNormal),
+		face("400", 400, FontNormal),
+		face("600", 600, FontNormal),
+		face("700", 700, FontNormal),
+		face("italic 400", 400, FontItalic),
+		face("oblique 700", 700, FontOblique),
+	}
+
+	cases := []struct {
+		faces    []fontFace
+		style    fontStyle
+		weight   int
+		expected string
+	}{
+		{faces, FontNormal, 400, "400"},
+		// 400 to 500 look up to 500 first, then lighter
+		{faces, FontNormal, 500, "400"},
+		{faces[:4], FontNormal, 450, "400"},
+		// heavier weights look heavier first
+		{faces, FontNormal, 550, "600"},
+		{faces, FontNormal, 650, "700"},
+		{faces, FontNormal, 900, "700"},
+		// lighter weights look lighter first
+		{faces, FontNormal, 350, "300"},
+		{faces, FontNormal, 100, "300"},
+		{faces[1:], FontNormal, 300, "400"},
+		// the style is matched before the weight
+		{faces, FontItalic, 700, "italic 400"},
+		{faces, FontOblique, 400, "oblique 700"},
+		{faces[:5], FontOblique, 700, "italic 400"},
+		{faces[5:], FontItalic, 400, "oblique 700"},
+		{faces[4:], FontNormal, 400, "oblique 700"},
+		{faces[4:5], FontNormal, 700, "italic 400"},
+		{faces[:4], FontItalic, 700, "700"},
+	}
+	for _, tc := range cases {
+		got := matchFontFace(tc.faces, tc.style, tc.weight)
+		if got != fonts[tc.expected] {
+			for name, f := range fonts {
+				if f == got {
+					t.Errorf("style %d weight %d matched %s instead of %s", tc.style, tc.weight, name, tc.expected)
+				}
+			}
+		}
+	}
+	if matchFontFace(nil, FontNormal, 400) != nil {
+		t.Error("a font was matched without faces")
+	}
+}
+
+func TestSetFontCSS(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	if err := cv.RegisterFontFace("Test", 400, FontNormal, "testdata/Roboto-Light.ttf"); err != nil {
+		t.Fatalf("failed to register font: %v", err)
+	}
+	if err := cv.RegisterFontFace("test", 700, FontNormal, goregular.TTF); err != nil {
+		t.Fatalf("failed to register font: %v", err)
+	}
+	if err := cv.RegisterFontFace("Other", 400, FontItalic, goregular.TTF); err != nil {
+		t.Fatalf("failed to register font: %v", err)
+	}
+	if err := cv.RegisterFontFace("broken", 400, FontNormal, []byte("no font")); err == nil {
+		t.Error("registering invalid font data didn't fail")
+	}
+	gofont := cv.fontFaces["test"][1].font
+	other := cv.fontFaces["other"][0].font
+
+	// registering the same weight and style again replaces
+	// the face
+	if err := cv.RegisterFontFace("TEST", 400, FontNormal, "testdata/Roboto-Light.ttf"); err != nil {
+		t.Fatalf("failed to register font: %v", err)
+	}
+	if len(cv.fontFaces["test"]) != 2 {
+		t.Errorf("test has %d faces after registering a face again", len(cv.fontFaces["test"]))
+	}
+	roboto := cv.fontFaces["test"][0].font
+
+	// base returns the font that a variation was made from
+	base := func(f *Font) *Font {
+		if f != nil && f.base != nil {
+			return f.base
+		}
+		return f
+	}
+
+	cases := []struct {
+		css       string
+		size      float64
+		font      *Font
+		fallbacks []*Font
+	}{
+		{"20px test", 20, roboto, nil},
+		{"bold 12pt Test", 16, gofont, nil},
+		{"900 10px missing, TEST, other, sans-serif", 10, gofont, []*Font{other}},
+		{"italic 10px other, test", 10, other, []*Font{roboto}},
+	}
+	for _, tc := range cases {
+		// the default font comes last unless it is already used
+		expected := append([]*Font{tc.font}, tc.fallbacks...)
+		if !containsFont(expected, defaultFont) {
+			expected = append(expected, defaultFont)
+		}
+
+		cv.SetFontCSS(tc.css)
+		if size := float64(cv.state.fontSize) / 64; size != tc.size {
+			t.Errorf("%q has the size %g instead of %g", tc.css, size, tc.size)
+		}
+		got := append([]*Font{cv.state.font}, cv.state.fontFallbacks...)
+		if len(got) != len(expected) {
+			t.Errorf("%q selected %d fonts instead of %d", tc.css, len(got), len(expected))
+			continue
+		}
+		for i, f := range got {
+			if base(f) != expected[i] {
+				t.Errorf("%q has the wrong font %d", tc.css, i)
+			}
+		}
+	}
+
+	// invalid values are ignored
+	cv.SetFontCSS("20px test")
+	cv.SetFontCSS("bold test")
+	if base(cv.state.font) != roboto || cv.state.fontSize != 20*64 {
+		t.Error("an invalid font changed the font")
+	}
+}
@@ -0,0 +1,256 @@
+package canvas
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"sort"
+)
+
+var (
+	errFontIndex  = errors.New("font index out of range")
+	errFontFormat = errors.New("invalid font data")
+)
+
+// sfntTable is a table of a font in sfnt format
+type sfntTable struct {
+	tag  uint32
+	data []byte
+}
+
+func sfntTag(s string) uint32 {
+	return binary.BigEndian.Uint32([]byte(s))
+}
+
+// sfntData returns the font with the given index in the data
+// as a single font in sfnt format, which is what TTF and OTF
+// files contain. Font collections (TTC) and WOFF and WOFF2
+// files are converted, other data is returned as it is
+func sfntData(data []byte, index int) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errFontFormat
+	}
+	switch string(data[:4]) {
+	case "ttcf":
+		return collectionFont(data, index)
+	case "wOFF":
+		if index != 0 {
+			return nil, errFontIndex
+		}
+		return woffFont(data)
+	case "wOF2":
+		return woff2Font(data, index)
+	}
+	if index != 0 {
+		return nil, errFontIndex
+	}
+	return data, nil
+}
+
+// sfntHasTable returns whether a font in sfnt format has a
+// table with the given tag
+func sfntHasTable(data []byte, tag string) bool {
+	r := fontReader{data: data, pos: 4}
+	numTables := int(r.u16())
+	r.pos = 12
+	for i := 0; i < numTables && !r.err; i++ {
+		if r.u32() == sfntTag(tag) && !r.err {
+			return true
+		}
+		r.pos += 12
+	}
+	return false
+}
+
+// collectionFont copies the tables of a font in a font
+// collection into a new font
+func collectionFont(data []byte, index int) ([]byte, error) {
+	r := fontReader{data: data, pos: 8}
+	numFonts := int(r.u32())
+	if r.err {
+		return nil, errFontFormat
+	}
+	if index < 0 || index >= numFonts {
+		return nil, errFontIndex
+	}
+	r.pos += index * 4
+	r.pos = int(r.u32())
+
+	flavor := r.u32()
+	numTables := int(r.u16())
+	r.pos += 6
+	tables := make([]sfntTable, 0, numTables)
+	for i := 0; i < numTables && !r.err; i++ {
+		tag := r.u32()
+		r.u32() // checksum
+		offset, length := r.u32(), r.u32()
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, errFontFormat
+		}
+		tables = append(tables, sfntTable{tag: tag, data: data[offset : offset+length]})
+	}
+	if r.err {
+		return nil, errFontFormat
+	}
+	return buildSFNT(flavor, tables), nil
+}
+
+// woffFont decompresses the tables of a WOFF file
+func woffFont(data []byte) ([]byte, error) {
+	r := fontReader{data: data, pos: 4}
+	flavor := r.u32()
+	r.pos = 12
+	numTables := int(r.u16())
+	r.pos = 44
+	tables := make([]sfntTable, 0, numTables)
+	for i := 0; i < numTables && !r.err; i++ {
+		tag := r.u32()
+		offset, compLength, origLength := r.u32(), r.u32(), r.u32()
+		r.u32() // checksum
+		if uint64(offset)+uint64(compLength) > uint64(len(data)) {
+			return nil, errFontFormat
+		}
+		table := data[offset : offset+compLength]
+		if compLength < origLength {
+			zr, err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return nil, err
+			}
+			table, err = ioutil.ReadAll(zr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if uint32(len(table)) != origLength {
+			return nil, errFontFormat
+		}
+		tables = append(tables, sfntTable{tag: tag, data: table})
+	}
+	if r.err {
+		return nil, errFontFormat
+	}
+	return buildSFNT(flavor, tables), nil
+}
+
+// buildSFNT writes the tables into a new font with a table
+// directory sorted by tag
+func buildSFNT(flavor uint32, tables []sfntTable) []byte {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].tag < tables[j].tag })
+
+	size := 12 + 16*len(tables)
+	for _, t := range tables {
+		size += (len(t.data) + 3) &^ 3
+	}
+	out := make([]byte, size)
+
+	be := binary.BigEndian
+	entrySelector := 0
+	for 2<<uint(entrySelector) <= len(tables) {
+		entrySelector++
+	}
+	searchRange := 16 << uint(entrySelector)
+	be.PutUint32(out, flavor)
+	be.PutUint16(out[4:], uint16(len(tables)))
+	be.PutUint16(out[6:], uint16(searchRange))
+	be.PutUint16(out[8:], uint16(entrySelector))
+	be.PutUint16(out[10:], uint16(16*len(tables)-searchRange))
+
+	offset := 12 + 16*len(tables)
+	for i, t := range tables {
+		rec := out[12+16*i:]
+		be.PutUint32(rec, t.tag)
+		be.PutUint32(rec[4:], sfntChecksum(t.data))
+		be.PutUint32(rec[8:], uint32(offset))
+		be.PutUint32(rec[12:], uint32(len(t.data)))
+		copy(out[offset:], t.data)
+		offset += (len(t.data) + 3) &^ 3
+	}
+	return out
+}
+
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// fontReader reads big endian values from font data. Reading
+// past the end sets err and returns zero values, so that
+// errors only have to be checked once after reading
+type fontReader struct {
+	data []byte
+	pos  int
+	err  bool
+}
+
+func (r *fontReader) bytes(n int) []byte {
+	if r.err || n < 0 || r.pos < 0 || n > len(r.data)-r.pos {
+		r.err = true
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *fontReader) u8() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *fontReader) u16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *fontReader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// base128 reads a UIntBase128 value of WOFF2
+func (r *fontReader) base128() uint32 {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		if r.err || (i == 0 && b == 0x80) || v&0xfe000000 != 0 {
+			r.err = true
+			return 0
+		}
+		v = v<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = true
+	return 0
+}
+
+// u255 reads a 255UInt16 value of WOFF2
+func (r *fontReader) u255() int {
+	switch code := r.u8(); code {
+	case 253:
+		return int(r.u16())
+	case 254:
+		return int(r.u8()) + 506
+	case 255:
+		return int(r.u8()) + 253
+	default:
+		return int(code)
+	}
+}
@@ -0,0 +1,308 @@
+package canvas
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// testFont is a font in sfnt format split into its tables
+type testFont struct {
+	flavor uint32
+	tables []sfntTable
+}
+
+func readTestFont(t *testing.T, data []byte) testFont {
+	r := fontReader{data: data}
+	f := testFont{flavor: r.u32()}
+	numTables := int(r.u16())
+	r.pos = 12
+	for i := 0; i < numTables; i++ {
+		tag := r.u32()
+		r.u32() // checksum
+		offset, length := int(r.u32()), int(r.u32())
+		f.tables = append(f.tables, sfntTable{tag: tag, data: data[offset : offset+length]})
+	}
+	if r.err {
+		t.Fatal("failed to read the table directory")
+	}
+	return f
+}
+
+// buildTTC writes the fonts into a font collection
+func buildTTC(fonts ...testFont) []byte {
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	header := make([]byte, 12+4*len(fonts))
+	copy(header, "ttcf")
+	be.PutUint32(header[4:], 0x00010000)
+	be.PutUint32(header[8:], uint32(len(fonts)))
+
+	// the table directories come first, followed by the data
+	// of all tables
+	offset := len(header)
+	for i, f := range fonts {
+		be.PutUint32(header[12+4*i:], uint32(offset))
+		offset += 12 + 16*len(f.tables)
+	}
+	buf.Write(header)
+	var data []byte
+	for _, f := range fonts {
+		dir := make([]byte, 12+16*len(f.tables))
+		be.PutUint32(dir, f.flavor)
+		be.PutUint16(dir[4:], uint16(len(f.tables)))
+		for i, table := range f.tables {
+			rec := dir[12+16*i:]
+			be.PutUint32(rec, table.tag)
+			be.PutUint32(rec[4:], sfntChecksum(table.data))
+			be.PutUint32(rec[8:], uint32(offset+len(data)))
+			be.PutUint32(rec[12:], uint32(len(table.data)))
+			data = append(data, table.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		buf.Write(dir)
+	}
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// buildWOFF writes the font into a WOFF file, compressing the
+// tables that get smaller
+func buildWOFF(f testFont) []byte {
+	be := binary.BigEndian
+	header := make([]byte, 44+20*len(f.tables))
+	copy(header, "wOFF")
+	be.PutUint32(header[4:], f.flavor)
+	be.PutUint16(header[12:], uint16(len(f.tables)))
+
+	var data []byte
+	for i, table := range f.tables {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(table.data)
+		zw.Close()
+		stored := table.data
+		if compressed.Len() < len(table.data) {
+			stored = compressed.Bytes()
+		}
+
+		rec := header[44+20*i:]
+		be.PutUint32(rec, table.tag)
+		be.PutUint32(rec[4:], uint32(len(header)+len(data)))
+		be.PutUint32(rec[8:], uint32(len(stored)))
+		be.PutUint32(rec[12:], uint32(len(table.data)))
+		be.PutUint32(rec[16:], sfntChecksum(table.data))
+		data = append(data, stored...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	out := append(header, data...)
+	be.PutUint32(out[8:], uint32(len(out)))
+	return out
+}
+
+// buildWOFF2 writes the fonts into a WOFF2 file with null
+// transforms, as a collection if there is more than one font
+func buildWOFF2(fonts ...testFont) []byte {
+	be := binary.BigEndian
+	var dir, stream []byte
+	numTables := 0
+	var indices [][]int
+	for _, f := range fonts {
+		var idx []int
+		for _, table := range f.tables {
+			known := -1
+			for i, tag := range woff2Tags {
+				if sfntTag(tag) == table.tag {
+					known = i
+				}
+			}
+			// glyf and loca need version 3 for the null
+			// transform, all other tables version 0
+			flags := byte(0)
+			if table.tag == tagGlyf || table.tag == tagLoca {
+				flags = 3 << 6
+			}
+			if known >= 0 {
+				dir = append(dir, flags|byte(known))
+			} else {
+				dir = append(dir, flags|0x3f)
+				dir = append(dir, 0, 0, 0, 0)
+				be.PutUint32(dir[len(dir)-4:], table.tag)
+			}
+			dir = appendBase128(dir, uint32(len(table.data)))
+			stream = append(stream, table.data...)
+			idx = append(idx, numTables)
+			numTables++
+		}
+		indices = append(indices, idx)
+	}
+
+	flavor := fonts[0].flavor
+	if len(fonts) > 1 {
+		flavor = tagTTCF
+		dir = append(dir, 0, 1, 0, 0)
+		dir = appendU255(dir, len(fonts))
+		for i, f := range fonts {
+			dir = appendU255(dir, len(indices[i]))
+			dir = append(dir, 0, 0, 0, 0)
+			be.PutUint32(dir[len(dir)-4:], f.flavor)
+			for _, idx := range indices[i] {
+				dir = appendU255(dir, idx)
+			}
+		}
+	}
+
+	var compressed bytes.Buffer
+	bw := brotli.NewWriter(&compressed)
+	bw.Write(stream)
+	bw.Close()
+
+	header := make([]byte, 48)
+	copy(header, "wOF2")
+	be.PutUint32(header[4:], flavor)
+	be.PutUint16(header[12:], uint16(numTables))
+	be.PutUint32(header[20:], uint32(compressed.Len()))
+	out := append(append(header, dir...), compressed.Bytes()...)
+	be.PutUint32(out[8:], uint32(len(out)))
+	return out
+}
+
+func appendBase128(b []byte, v uint32) []byte {
+	var groups []byte
+	for {
+		groups = append([]byte{byte(v & 0x7f)}, groups...)
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := range groups[:len(groups)-1] {
+		groups[i] |= 0x80
+	}
+	return append(b, groups...)
+}
+
+func appendU255(b []byte, v int) []byte {
+	if v < 253 {
+		return append(b, byte(v))
+	}
+	return append(b, 253, byte(v>>8), byte(v))
+}
+
+// checkTables checks that the font data has the same tables
+// as the font
+func checkTables(t *testing.T, name string, data []byte, expected testFont) {
+	got := readTestFont(t, data)
+	if got.flavor != expected.flavor {
+		t.Errorf("%s: the flavor is %x instead of %x", name, got.flavor, expected.flavor)
+	}
+	tables := make(map[uint32][]byte)
+	for _, table := range got.tables {
+		tables[table.tag] = table.data
+	}
+	if len(tables) != len(expected.tables) {
+		t.Errorf("%s: %d tables instead of %d", name, len(tables), len(expected.tables))
+	}
+	for _, table := range expected.tables {
+		if !bytes.Equal(tables[table.tag], table.data) {
+			var tag [4]byte
+			binary.BigEndian.PutUint32(tag[:], table.tag)
+			t.Errorf("%s: table %q differs", name, tag[:])
+		}
+	}
+}
+
+func TestFontFormats(t *testing.T) {
+	robotoData, err := ioutil.ReadFile("testdata/Roboto-Light.ttf")
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	roboto, gofont := readTestFont(t, robotoData), readTestFont(t, goregular.TTF)
+	ttc := buildTTC(roboto, gofont)
+	woff2Collection := buildWOFF2(roboto, gofont)
+
+	cases := []struct {
+		name     string
+		data     []byte
+		index    int
+		expected testFont
+		original []byte
+	}{
+		{"ttc 0", ttc, 0, roboto, robotoData},
+		{"ttc 1", ttc, 1, gofont, goregular.TTF},
+		{"woff", buildWOFF(roboto), 0, roboto, robotoData},
+		{"woff2", buildWOFF2(gofont), 0, gofont, goregular.TTF},
+		{"woff2 collection 0", woff2Collection, 0, roboto, robotoData},
+		{"woff2 collection 1", woff2Collection, 1, gofont, goregular.TTF},
+	}
+	cv := New(softwarebackend.New(100, 100))
+	for _, tc := range cases {
+		data, err := sfntData(tc.data, tc.index)
+		if err != nil {
+			t.Errorf("%s: failed to convert the font: %v", tc.name, err)
+			continue
+		}
+		checkTables(t, tc.name, data, tc.expected)
+
+		// the fonts load and measure like the original ones
+		f, err := cv.LoadFontIndex(tc.data, tc.index)
+		if err != nil {
+			t.Errorf("%s: failed to load the font: %v", tc.name, err)
+			continue
+		}
+		cv.SetFont(f, 20)
+		w := cv.MeasureText("Hello, World").Width
+		original, err := cv.LoadFont(tc.original)
+		if err != nil {
+			t.Fatalf("failed to load font: %v", err)
+		}
+		cv.SetFont(original, 20)
+		if expected := cv.MeasureText("Hello, World").Width; w != expected {
+			t.Errorf("%s: the text is %g wide instead of %g", tc.name, w, expected)
+		}
+	}
+
+	// fonts that aren't collections only have index 0
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		index int
+	}{
+		{"ttf", robotoData, 1},
+		{"ttc", ttc, 2},
+		{"negative ttc", ttc, -1},
+		{"woff", buildWOFF(roboto), 1},
+		{"woff2", buildWOFF2(roboto), 1},
+		{"woff2 collection", woff2Collection, 2},
+	} {
+		if _, err := cv.LoadFontIndex(tc.data, tc.index); err != errFontIndex {
+			t.Errorf("%s: loading index %d returned %v instead of %v", tc.name, tc.index, err, errFontIndex)
+		}
+	}
+
+	// cut off data returns an error instead of a font, using
+	// the last font of the collections since the tables of
+	// the first one may be complete
+	for _, tc := range []struct {
+		data  []byte
+		index int
+	}{
+		{ttc, 1},
+		{buildWOFF(roboto), 0},
+		{woff2Collection, 1},
+	} {
+		if _, err := cv.LoadFontIndex(tc.data[:len(tc.data)-16], tc.index); err == nil {
+			t.Errorf("cut off %q data didn't return an error", tc.data[:4])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package canvas
+
+import (
+	"fmt"
+
+	otfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+// FontVariation is a value for a variation axis of a variable
+// font. The tag is the four letter name of the axis, like
+// "wght" for the weight, "wdth" for the width, "slnt" for the
+// slant, "opsz" for the optical size, or the tag of a custom
+// axis of the font
+type FontVariation struct {
+	Tag   string
+	Value float64
+}
+
+// Variation returns the instance of a variable font with the
+// given values for its variation axes. Axes that are not
+// given keep the values of the font, values outside of the
+// range of an axis are clamped, and axes that the font
+// doesn't have are ignored. The instances are cached, so
+// that the same values return the same font. Fonts that are
+// not variable, and values that are the defaults of the
+// font, return the font itself
+func (f *Font) Variation(axes ...FontVariation) *Font {
+	base := f
+	if f.base != nil {
+		base = f.base
+	}
+	if base.face == nil || len(axes) == 0 {
+		return f
+	}
+
+	variations := append([]otfont.Variation(nil), f.variations...)
+	for _, axis := range axes {
+		if len(axis.Tag) == 0 || len(axis.Tag) > 4 {
+			continue
+		}
+		tag := axis.Tag
+		for len(tag) < 4 {
+			tag += " "
+		}
+		variations = append(variations, otfont.Variation{Tag: ot.MustNewTag(tag), Value: float32(axis.Value)})
+	}
+
+	key := fmt.Sprint(variations)
+	if inst, ok := base.instances[key]; ok {
+		return inst
+	}
+	if base.instances == nil {
+		base.instances = make(map[string]*Font)
+	}
+
+	// the shaper caches fonts by their otfont.Font, so each
+	// instance needs its own copy
+	font := *base.face.Font
+	face := otfont.NewFace(&font)
+	face.SetVariations(variations)
+	isDefault := true
+	for _, c := range face.Coords() {
+		if c != 0 {
+			isDefault = false
+		}
+	}
+	if isDefault {
+		base.instances[key] = base
+		return base
+	}
+
+	inst := *base
+	inst.font, inst.cff = nil, nil
+	inst.face = face
+	inst.base = base
+	inst.variations = variations
+	inst.instances = nil
+	base.instances[key] = &inst
+	return &inst
+}
+
+// SetFontVariations sets values for the variation axes of
+// variable fonts, which are applied to the font and the
+// fallback fonts for any text drawing calls like the CSS
+// font-variation-settings. Calling it without values resets
+// the axes to the values of the fonts
+func (cv *Canvas) SetFontVariations(axes ...FontVariation) {
+	cv.state.fontVariations = append([]FontVariation(nil), axes...)
+}
+
+// varySpans returns the spans with the font variations of
+// the state applied to their fonts
+func (cv *Canvas) varySpans(spans []shapeSpan) []shapeSpan {
+	if len(cv.state.fontVariations) == 0 {
+		return spans
+	}
+	varied := make([]shapeSpan, len(spans))
+	for i, span := range spans {
+		span.font = span.font.Variation(cv.state.fontVariations...)
+		fallbacks := make([]*Font, len(span.fallbacks))
+		for j, f := range span.fallbacks {
+			fallbacks[j] = f.Variation(cv.state.fontVariations...)
+		}
+		span.fallbacks = fallbacks
+		varied[i] = span
+	}
+	return varied
+}
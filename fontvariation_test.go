@@ -0,0 +1,176 @@
+package canvas
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// weightDelta is the amount in font units that the advance of
+// every glyph of the variable test font grows by at the
+// maximum weight
+const weightDelta = 200
+
+// buildVariableFont turns Roboto Light into a variable font
+// with a wght axis from 100 to 900 with the default at 300,
+// where the advance of every glyph grows by weightDelta from
+// the default to the maximum weight. It returns the font and
+// its units per em
+func buildVariableFont(t *testing.T) ([]byte, int) {
+	data, err := ioutil.ReadFile("testdata/Roboto-Light.ttf")
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	f := readTestFont(t, data)
+	var upem, numGlyphs int
+	for _, table := range f.tables {
+		switch table.tag {
+		case sfntTag("head"):
+			upem = int(binary.BigEndian.Uint16(table.data[18:]))
+		case tagMaxp:
+			numGlyphs = int(binary.BigEndian.Uint16(table.data[4:]))
+		}
+	}
+
+	be := binary.BigEndian
+	fvar := make([]byte, 16+20)
+	be.PutUint16(fvar, 1)
+	be.PutUint16(fvar[4:], 16) // axes array offset
+	be.PutUint16(fvar[6:], 2)
+	be.PutUint16(fvar[8:], 1)   // axis count
+	be.PutUint16(fvar[10:], 20) // axis size
+	be.PutUint16(fvar[14:], 8)  // instance size
+	axis := fvar[16:]
+	be.PutUint32(axis, sfntTag("wght"))
+	be.PutUint32(axis[4:], 100<<16)
+	be.PutUint32(axis[8:], 300<<16)
+	be.PutUint32(axis[12:], 900<<16)
+	be.PutUint16(axis[18:], 256)
+
+	// the item variation store has one region that peaks at
+	// the maximum weight, and the glyph ids map directly to
+	// the deltas
+	const storeOffset, regionsOffset, dataOffset = 20, 12, 22
+	hvar := make([]byte, storeOffset+dataOffset+8+2*numGlyphs)
+	be.PutUint16(hvar, 1)
+	be.PutUint32(hvar[4:], storeOffset)
+	store := hvar[storeOffset:]
+	be.PutUint16(store, 1)
+	be.PutUint32(store[2:], regionsOffset)
+	be.PutUint16(store[6:], 1)
+	be.PutUint32(store[8:], dataOffset)
+	regions := store[regionsOffset:]
+	be.PutUint16(regions, 1)
+	be.PutUint16(regions[2:], 1)
+	be.PutUint16(regions[6:], 0x4000) // peak
+	be.PutUint16(regions[8:], 0x4000) // end
+	items := store[dataOffset:]
+	be.PutUint16(items, uint16(numGlyphs))
+	be.PutUint16(items[2:], 1) // the deltas are words
+	be.PutUint16(items[4:], 1)
+	for i := 0; i < numGlyphs; i++ {
+		be.PutUint16(items[8+2*i:], weightDelta)
+	}
+
+	tables := append(f.tables, sfntTable{tag: sfntTag("fvar"), data: fvar}, sfntTable{tag: sfntTag("HVAR"), data: hvar})
+	return buildSFNT(f.flavor, tables), upem
+}
+
+func TestFontVariationAdvances(t *testing.T) {
+	data, upem := buildVariableFont(t)
+	cv := New(softwarebackend.New(100, 100))
+	f, err := cv.LoadFont(data)
+	if err != nil {
+		t.Fatalf("failed to load font: %v", err)
+	}
+	// without hinting the advances aren't rounded to pixels
+	cv.SetTextRendering(GeometricPrecision)
+	measure := func(f *Font) float64 {
+		cv.SetFont(f, 40)
+		return cv.MeasureText("HHH").Width
+	}
+	base := measure(f)
+
+	// the advances are interpolated from the default to the
+	// maximum weight, and clamped to the range of the axis
+	for _, tc := range []struct {
+		weight, delta float64
+	}{
+		{900, weightDelta},
+		{600, weightDelta / 2},
+		{2000, weightDelta},
+		{100, 0},
+	} {
+		inst := f.Variation(FontVariation{Tag: "wght", Value: tc.weight})
+		expected := base + 3*tc.delta*40/float64(upem)
+		if w := measure(inst); math.Abs(w-expected) > 0.1 {
+			t.Errorf("wght %g: the text is %g wide instead of %g", tc.weight, w, expected)
+		}
+	}
+
+	// instances are cached, and the defaults, unknown axes and
+	// fonts that aren't variable return the font itself
+	if f.Variation(FontVariation{Tag: "wght", Value: 900}) != f.Variation(FontVariation{Tag: "wght", Value: 900}) {
+		t.Error("the same variation returned different instances")
+	}
+	if f.Variation(FontVariation{Tag: "wght", Value: 300}) != f || f.Variation(FontVariation{Tag: "wdth", Value: 50}) != f {
+		t.Error("a variation with the default values didn't return the font")
+	}
+	gofont, err := cv.LoadFont(goregular.TTF)
+	if err != nil {
+		t.Fatalf("failed to load font: %v", err)
+	}
+	if gofont.Variation(FontVariation{Tag: "wght", Value: 900}) != gofont {
+		t.Error("a variation of a font that isn't variable didn't return the font")
+	}
+
+	// the variations of the state apply to the font, and are
+	// saved and restored
+	heavy := measure(f.Variation(FontVariation{Tag: "wght", Value: 900}))
+	cv.SetFont(f, 40)
+	cv.SetFontVariations(FontVariation{Tag: "wght", Value: 900})
+	if w := cv.MeasureText("HHH").Width; w != heavy {
+		t.Errorf("the text is %g wide with the variations of the state instead of %g", w, heavy)
+	}
+	cv.Save()
+	cv.SetFontVariations()
+	if w := cv.MeasureText("HHH").Width; w != base {
+		t.Errorf("the text is %g wide after resetting the variations instead of %g", w, base)
+	}
+	cv.Restore()
+	if w := cv.MeasureText("HHH").Width; w != heavy {
+		t.Errorf("the text is %g wide after restoring the variations instead of %g", w, heavy)
+	}
+
+	// drawn glyphs are placed with the varied advances
+	ink := func(weight float64) int {
+		cv := New(softwarebackend.New(200, 100))
+		cv.SetFont(f, 40)
+		cv.SetFontVariations(FontVariation{Tag: "wght", Value: weight})
+		cv.FillText("HHH", 10, 60)
+		img := cv.GetImageData(0, 0, 200, 100)
+		minX, maxX := 200, 0
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 200; x++ {
+				if img.RGBAAt(x, y).A == 0 {
+					continue
+				}
+				if x < minX {
+					minX = x
+				}
+				if x > maxX {
+					maxX = x
+				}
+			}
+		}
+		return maxX - minX
+	}
+	expected := float64(ink(300)) + 2*weightDelta*40/float64(upem)
+	if w := float64(ink(900)); math.Abs(w-expected) > 1.5 {
+		t.Errorf("the drawn text is %g pixels wide instead of %g", w, expected)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/golang/freetype/raster"
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
 	"golang.org/x/image/math/fixed"
 )
 
@@ -39,9 +40,22 @@ type cacheEntry struct {
 // A Context holds the state for drawing text in a given font and size.
 type frContext struct {
 	r        *raster.Rasterizer
-	f        *truetype.Font
+	f        *Font
 	glyphBuf truetype.GlyphBuf
 
+	// buf and segments are used instead of glyphBuf for
+	// fonts that are not loaded with the freetype package
+	buf      sfnt.Buffer
+	segments sfnt.Segments
+
+	// advance and bounds of the last loaded glyph, with the
+	// y axis of the bounds going down
+	advance fixed.Int26_6
+	bounds  fixed.Rectangle26_6
+
+	// width and height of the rasterizer bounds
+	width, height int
+
 	fontSize fixed.Int26_6
 	hinting  font.Hinting
 	// cache is the glyph cache.
@@ -122,22 +136,125 @@ func (c *frContext) drawContour(ps []truetype.Point, dx, dy fixed.Int26_6) {
 	}
 }
 
+// drawSegments draws the given outline segments with the given offset. The
+// contours don't have to be explicitly closed.
+func (c *frContext) drawSegments(segs sfnt.Segments, dx, dy fixed.Int26_6) {
+	var start, last fixed.Point26_6
+	closeContour := func() {
+		if last != start {
+			c.r.Add1(start)
+		}
+	}
+	offset := func(p fixed.Point26_6) fixed.Point26_6 {
+		return fixed.Point26_6{X: dx + p.X, Y: dy + p.Y}
+	}
+	for i, seg := range segs {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				closeContour()
+			}
+			start = offset(seg.Args[0])
+			last = start
+			c.r.Start(start)
+		case sfnt.SegmentOpLineTo:
+			last = offset(seg.Args[0])
+			c.r.Add1(last)
+		case sfnt.SegmentOpQuadTo:
+			last = offset(seg.Args[1])
+			c.r.Add2(offset(seg.Args[0]), last)
+		case sfnt.SegmentOpCubeTo:
+			last = offset(seg.Args[2])
+			c.r.Add3(offset(seg.Args[0]), offset(seg.Args[1]), last)
+		}
+	}
+	if len(segs) > 0 {
+		closeContour()
+	}
+}
+
+// load loads the outline, advance width and bounds of the given glyph.
+func (c *frContext) load(glyph truetype.Index) error {
+	if c.f.font != nil {
+		if err := c.glyphBuf.Load(c.f.font, c.fontSize, glyph, c.hinting); err != nil {
+			return err
+		}
+		c.advance = c.glyphBuf.AdvanceWidth
+		b := c.glyphBuf.Bounds
+		c.bounds = fixed.Rectangle26_6{
+			Min: fixed.Point26_6{X: b.Min.X, Y: -b.Max.Y},
+			Max: fixed.Point26_6{X: b.Max.X, Y: -b.Min.Y},
+		}
+		return nil
+	}
+
+	segs, advance, err := c.f.loadOutline(&c.buf, glyph, c.fontSize, c.hinting)
+	if err != nil {
+		return err
+	}
+	c.advance = advance
+	c.segments = append(c.segments[:0], segs...)
+	c.bounds = fixed.Rectangle26_6{}
+	for i, seg := range c.segments {
+		for j, p := range seg.Args[:segmentArgs(seg.Op)] {
+			if i == 0 && j == 0 {
+				c.bounds = fixed.Rectangle26_6{Min: p, Max: p}
+				continue
+			}
+			if p.X < c.bounds.Min.X {
+				c.bounds.Min.X = p.X
+			}
+			if p.Y < c.bounds.Min.Y {
+				c.bounds.Min.Y = p.Y
+			}
+			if p.X > c.bounds.Max.X {
+				c.bounds.Max.X = p.X
+			}
+			if p.Y > c.bounds.Max.Y {
+				c.bounds.Max.Y = p.Y
+			}
+		}
+	}
+	return nil
+}
+
+// segmentArgs returns the number of points a segment uses.
+func segmentArgs(op sfnt.SegmentOp) int {
+	switch op {
+	case sfnt.SegmentOpQuadTo:
+		return 2
+	case sfnt.SegmentOpCubeTo:
+		return 3
+	}
+	return 1
+}
+
 // rasterize returns the advance width, glyph mask and integer-pixel offset
 // to render the given glyph at the given sub-pixel offsets.
 // The 26.6 fixed point arguments fx and fy must be in the range [0, 1).
 func (c *frContext) rasterize(glyph truetype.Index, fx, fy fixed.Int26_6) (fixed.Int26_6, *image.Alpha, image.Point, error) {
 
-	if err := c.glyphBuf.Load(c.f, c.fontSize, glyph, c.hinting); err != nil {
+	if err := c.load(glyph); err != nil {
 		return 0, nil, image.Point{}, err
 	}
 	// Calculate the integer-pixel bounds for the glyph.
-	xmin := int(fx+c.glyphBuf.Bounds.Min.X) >> 6
-	ymin := int(fy-c.glyphBuf.Bounds.Max.Y) >> 6
-	xmax := int(fx+c.glyphBuf.Bounds.Max.X+0x3f) >> 6
-	ymax := int(fy-c.glyphBuf.Bounds.Min.Y+0x3f) >> 6
+	xmin := int(fx+c.bounds.Min.X) >> 6
+	ymin := int(fy+c.bounds.Min.Y) >> 6
+	xmax := int(fx+c.bounds.Max.X+0x3f) >> 6
+	ymax := int(fy+c.bounds.Max.Y+0x3f) >> 6
 	if xmin > xmax || ymin > ymax {
 		return 0, nil, image.Point{}, errors.New("freetype: negative sized glyph")
 	}
+	// Instances of variable fonts can be larger than the bounds of the font.
+	if xmax-xmin > c.width || ymax-ymin > c.height {
+		if xmax-xmin > c.width {
+			c.width = xmax - xmin
+		}
+		if ymax-ymin > c.height {
+			c.height = ymax - ymin
+		}
+		c.r.SetBounds(c.width, c.height)
+	}
 	// A TrueType's glyph's nodes can have negative co-ordinates, but the
 	// rasterizer clips anything left of x=0 or above y=0. xmin and ymin are
 	// the pixel offsets, based on the font's FUnit metrics, that let a
@@ -147,14 +264,18 @@ func (c *frContext) rasterize(glyph truetype.Index, fx, fy fixed.Int26_6) (fixed
 	fy -= fixed.Int26_6(ymin << 6)
 	// Rasterize the glyph's vectors.
 	c.r.Clear()
-	e0 := 0
-	for _, e1 := range c.glyphBuf.Ends {
-		c.drawContour(c.glyphBuf.Points[e0:e1], fx, fy)
-		e0 = e1
+	if c.f.font == nil {
+		c.drawSegments(c.segments, fx, fy)
+	} else {
+		e0 := 0
+		for _, e1 := range c.glyphBuf.Ends {
+			c.drawContour(c.glyphBuf.Points[e0:e1], fx, fy)
+			e0 = e1
+		}
 	}
 	a := image.NewAlpha(image.Rect(0, 0, xmax-xmin, ymax-ymin))
 	c.r.Rasterize(raster.NewAlphaSrcPainter(a))
-	return c.glyphBuf.AdvanceWidth, a, image.Point{xmin, ymin}, nil
+	return c.advance, a, image.Point{xmin, ymin}, nil
 }
 
 // glyph returns the advance width, glyph mask and integer-pixel offset to
@@ -185,28 +306,28 @@ func (c *frContext) glyph(glyph truetype.Index, p fixed.Point26_6) (fixed.Int26_
 }
 
 func (c *frContext) glyphAdvance(glyph truetype.Index) (fixed.Int26_6, error) {
-	if err := c.glyphBuf.Load(c.f, c.fontSize, glyph, c.hinting); err != nil {
+	if err := c.load(glyph); err != nil {
 		return 0, err
 	}
-	return c.glyphBuf.AdvanceWidth, nil
+	return c.advance, nil
 }
 
 func (c *frContext) glyphMeasure(glyph truetype.Index, p fixed.Point26_6) (fixed.Int26_6, image.Rectangle, error) {
-	if err := c.glyphBuf.Load(c.f, c.fontSize, glyph, c.hinting); err != nil {
+	if err := c.load(glyph); err != nil {
 		return 0, image.Rectangle{}, err
 	}
 
 	fx := p.X & 0x3f
 	fy := p.Y & 0x3f
-	xmin := int(fx+c.glyphBuf.Bounds.Min.X) >> 6
-	ymin := int(fy-c.glyphBuf.Bounds.Max.Y) >> 6
-	xmax := int(fx+c.glyphBuf.Bounds.Max.X+0x3f) >> 6
-	ymax := int(fy-c.glyphBuf.Bounds.Min.Y+0x3f) >> 6
+	xmin := int(fx+c.bounds.Min.X) >> 6
+	ymin := int(fy+c.bounds.Min.Y) >> 6
+	xmax := int(fx+c.bounds.Max.X+0x3f) >> 6
+	ymax := int(fy+c.bounds.Max.Y+0x3f) >> 6
 	bounds := image.Rectangle{
 		Min: image.Point{X: xmin, Y: ymin},
 		Max: image.Point{X: xmax, Y: ymax}}
 
-	return c.glyphBuf.AdvanceWidth, bounds, nil
+	return c.advance, bounds, nil
 }
 
 func (c *frContext) glyphBounds(glyph truetype.Index, p fixed.Point26_6) (image.Rectangle, error) {
@@ -218,15 +339,17 @@ const maxInt = int(^uint(0) >> 1)
 
 func (c *frContext) recalc() {
 	if c.f == nil {
+		c.width, c.height = 0, 0
 		c.r.SetBounds(0, 0)
 	} else {
 		// Set the rasterizer's bounds to be big enough to handle the largest glyph.
-		b := c.f.Bounds(c.fontSize)
-		xmin := +int(b.Min.X) >> 6
-		ymin := -int(b.Max.Y) >> 6
-		xmax := +int(b.Max.X+63) >> 6
-		ymax := -int(b.Min.Y-63) >> 6
-		c.r.SetBounds(xmax-xmin, ymax-ymin)
+		b := c.f.bounds(c.fontSize)
+		xmin := int(b.Min.X) >> 6
+		ymin := int(b.Min.Y) >> 6
+		xmax := int(b.Max.X+63) >> 6
+		ymax := int(b.Max.Y+63) >> 6
+		c.width, c.height = xmax-xmin, ymax-ymin
+		c.r.SetBounds(c.width, c.height)
 	}
 	for i := range c.cache {
 		c.cache[i] = cacheEntry{}
@@ -238,11 +361,11 @@ func (c *frContext) cacheSize() int {
 		return 0
 	}
 
-	b := c.f.Bounds(c.fontSize)
-	xmin := +int(b.Min.X) >> 6
-	ymin := -int(b.Max.Y) >> 6
-	xmax := +int(b.Max.X+63) >> 6
-	ymax := -int(b.Min.Y-63) >> 6
+	b := c.f.bounds(c.fontSize)
+	xmin := int(b.Min.X) >> 6
+	ymin := int(b.Min.Y) >> 6
+	xmax := int(b.Max.X+63) >> 6
+	ymax := int(b.Max.Y+63) >> 6
 	w := xmax - xmin
 	h := ymax - ymin
 	return w * h * len(c.cache)
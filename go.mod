@@ -1,14 +1,16 @@
 module github.com/tfriedel6/canvas
 
 require (
+	github.com/andybalholm/brotli v1.0.6
 	github.com/go-gl/gl v0.0.0-20190320180904-bf2b1f2f34d7
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20200707082815-5321531c36a2
+	github.com/go-text/typesetting v0.3.5
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/veandco/go-sdl2 v0.4.4
 	golang.org/x/exp v0.0.0-20200513190911-00229845015e
-	golang.org/x/image v0.0.0-20200801110659-972c09e46d76
+	golang.org/x/image v0.23.0
 	golang.org/x/mobile v0.0.0-20200801112145-973feb4309de
-	golang.org/x/sys v0.0.0-20200810151505-1b9f1253b3ed // indirect
+	golang.org/x/text v0.21.0
 )
 
-go 1.13
+go 1.19
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"io/ioutil"
+	"math"
 	"os"
 	"strings"
 	"time"
@@ -195,7 +197,13 @@ func (cv *Canvas) DrawImage(image interface{}, coords ...float64) {
 
 	cv.drawShadow(data[:], nil, false)
 
-	cv.b.DrawImage(img.img, sx, sy, sw, sh, data, cv.state.globalAlpha)
+	style := backendbase.FillStyle{
+		Color:          color.RGBA{A: uint8(math.Round(cv.state.globalAlpha * 255))},
+		Composite:      backendbase.CompositeOperation(cv.state.composite),
+		ImageSmoothing: cv.imageSmoothing(),
+		Filter:         cv.state.filter,
+	}
+	cv.b.DrawImage(&style, img.img, sx, sy, sw, sh, data)
 }
 
 // GetImageData returns an RGBA image of the current image
@@ -16,6 +16,7 @@ type Path2D struct {
 
 	standalone bool
 	fillCache  []backendbase.Vec
+	fillRule   pathRule
 
 	noSelfIntersection bool
 }
@@ -46,8 +47,77 @@ func (p *Path2D) clearCache() {
 	p.fillCache = nil
 }
 
-// func (p *Path2D) AddPath(p2 *Path2D) {
-// }
+// AddPath adds the subpaths of p2 to the path, transformed by
+// the given matrix, which is in the same order as the values
+// of Canvas.Transform
+func (p *Path2D) AddPath(p2 *Path2D, mat [6]float64) {
+	p.addPath(p2, backendbase.Mat(mat))
+}
+
+// addPath adds the subpaths of p2 to the path, transformed
+// by the given matrix. Since the transformation is affine,
+// the subpaths stay convex or self intersecting, so the
+// flags of the points are kept
+func (p *Path2D) addPath(p2 *Path2D, m backendbase.Mat) {
+	if p2 == nil || len(p2.p) == 0 {
+		return
+	}
+	p.clearCache()
+
+	flip := m[0]*m[3]-m[1]*m[2] < 0
+	keepRect := m[1] == 0 && m[2] == 0
+	src := p2.p
+	for _, pt := range src {
+		pt.pos = pt.pos.MulMat(m)
+		if pt.flags&pathAttach != 0 {
+			pt.next = pt.next.MulMat(m)
+		}
+		if flip {
+			pt.flags ^= pathIsClockwise
+		}
+		if !keepRect {
+			pt.flags &^= pathIsRect
+		}
+		p.p = append(p.p, pt)
+	}
+
+	// continue the last subpath where p2 left off
+	start := len(p.p) - 1
+	for p.p[start].flags&pathMove == 0 {
+		start--
+	}
+	p.move = p.p[start].pos
+	p.cwSum = 0
+	for i := start + 1; i < len(p.p); i++ {
+		prev, pt := p.p[i-1].pos, p.p[i].pos
+		p.cwSum += (pt[0] - prev[0]) * (pt[1] + prev[1])
+	}
+}
+
+// Transform returns a copy of the path transformed by the
+// given matrix, which is in the same order as the values of
+// Canvas.Transform
+func (p *Path2D) Transform(mat [6]float64) *Path2D {
+	m := backendbase.Mat(mat)
+	p2 := &Path2D{cv: p.cv, p: make([]pathPoint, 0, len(p.p)), standalone: true, noSelfIntersection: p.noSelfIntersection}
+	p2.addPath(p, m)
+	if p.fillCache != nil {
+		p2.fillCache = make([]backendbase.Vec, len(p.fillCache))
+		for i, pt := range p.fillCache {
+			p2.fillCache[i] = pt.MulMat(m)
+		}
+		p2.fillRule = p.fillRule
+	}
+	return p2
+}
+
+// Clone returns a copy of the path
+func (p *Path2D) Clone() *Path2D {
+	p2 := *p
+	p2.p = append([]pathPoint(nil), p.p...)
+	p2.fillCache = append([]backendbase.Vec(nil), p.fillCache...)
+	return &p2
+}
 
 // MoveTo (see equivalent function on canvas type)
 func (p *Path2D) MoveTo(x, y float64) {
@@ -444,9 +514,8 @@ const (
 // IsPointInPath returns true if the point is in the path according
 // to the given rule
 func (p *Path2D) IsPointInPath(x, y float64, rule pathRule) bool {
-	inside := false
+	num := 0
 	runSubPaths(p.p, false, func(sp []pathPoint) bool {
-		num := 0
 		prev := sp[len(sp)-1].pos
 		for _, pt := range sp {
 			r, dir := pointIsRightOfLine(prev, pt.pos, backendbase.Vec{x, y})
@@ -460,16 +529,13 @@ func (p *Path2D) IsPointInPath(x, y float64, rule pathRule) bool {
 				num--
 			}
 		}
-
-		if rule == NonZero {
-			inside = num != 0
-		} else {
-			inside = num%2 == 0
-		}
-
-		return inside
+		return false
 	})
-	return inside
+
+	if rule == EvenOdd {
+		return num%2 != 0
+	}
+	return num != 0
 }
 
 // IsPointInStroke returns true if the point is in the stroke
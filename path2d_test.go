@@ -0,0 +1,197 @@
+package canvas
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// checkPathPoints checks that the points of the path are at
+// the expected positions
+func checkPathPoints(t *testing.T, name string, p *Path2D, expected []backendbase.Vec) {
+	t.Helper()
+	if len(p.p) != len(expected) {
+		t.Errorf("%s: the path has %d points instead of %d", name, len(p.p), len(expected))
+		return
+	}
+	for i, pt := range p.p {
+		if math.Abs(pt.pos[0]-expected[i][0]) > 1e-9 || math.Abs(pt.pos[1]-expected[i][1]) > 1e-9 {
+			t.Errorf("%s: point %d is at %v instead of %v", name, i, pt.pos, expected[i])
+		}
+	}
+}
+
+func TestAddPath(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	square := cv.NewPath2D()
+	square.Rect(10, 10, 20, 20)
+	star := cv.NewPath2D()
+	star.MoveTo(50, 10)
+	star.LineTo(70, 80)
+	star.LineTo(20, 35)
+	star.LineTo(80, 35)
+	star.LineTo(30, 80)
+	star.ClosePath()
+
+	p := cv.NewPath2D()
+	p.AddPath(nil, [6]float64{1, 0, 0, 1, 0, 0})
+	p.AddPath(cv.NewPath2D(), [6]float64{1, 0, 0, 1, 0, 0})
+	if len(p.p) != 0 {
+		t.Fatalf("adding nil and empty paths added %d points", len(p.p))
+	}
+
+	// the points are transformed and their flags kept, and
+	// the last subpath is continued
+	cv.FillPath(p)
+	p.AddPath(square, [6]float64{1, 0, 0, 1, 5, 7})
+	p.AddPath(star, [6]float64{2, 0, 0, 2, 0, 0})
+	p.LineTo(0, 0)
+	if p.fillCache != nil {
+		t.Error("adding a path kept the fill cache")
+	}
+	var expected []backendbase.Vec
+	for _, pt := range square.p {
+		expected = append(expected, pt.pos.Add(backendbase.Vec{5, 7}))
+	}
+	for _, pt := range star.p {
+		expected = append(expected, pt.pos.Mulf(2))
+	}
+	expected = append(expected, backendbase.Vec{0, 0})
+	checkPathPoints(t, "added paths", p, expected)
+	src := append(append([]pathPoint(nil), square.p...), star.p...)
+	for i, pt := range src {
+		if p.p[i].flags != pt.flags {
+			t.Errorf("point %d has the flags %b instead of %b", i, p.p[i].flags, pt.flags)
+		}
+	}
+	if p.move != star.p[0].pos.Mulf(2) {
+		t.Errorf("the subpath starts at %v instead of %v", p.move, star.p[0].pos.Mulf(2))
+	}
+
+	// mirroring changes the direction, and rotating or
+	// skewing turns rectangles into other shapes
+	for _, tc := range []struct {
+		name      string
+		mat       [6]float64
+		clockwise bool
+		rect      bool
+	}{
+		{"scale", [6]float64{2, 0, 0, 3, 0, 0}, false, true},
+		{"mirror", [6]float64{-1, 0, 0, 1, 0, 0}, true, true},
+		{"rotate", [6]float64{0, 1, -1, 0, 0, 0}, false, false},
+		{"skew", [6]float64{1, 0, 0.5, 1, 0, 0}, false, false},
+	} {
+		p := cv.NewPath2D()
+		p.AddPath(square, tc.mat)
+		for i, pt := range p.p {
+			flipped := (pt.flags^square.p[i].flags)&pathIsClockwise != 0
+			if flipped != tc.clockwise {
+				t.Errorf("%s: the direction of point %d is flipped %v", tc.name, i, flipped)
+			}
+			if rect := pt.flags&pathIsRect != 0; rect != (tc.rect && square.p[i].flags&pathIsRect != 0) {
+				t.Errorf("%s: point %d is a rectangle %v", tc.name, i, rect)
+			}
+		}
+	}
+}
+
+func TestPathTransform(t *testing.T) {
+	draw := func(fn func(cv *Canvas)) *softwarebackend.SoftwareBackend {
+		b := softwarebackend.New(100, 100)
+		cv := New(b)
+		cv.SetFillStyle("#FFF")
+		fn(cv)
+		return b
+	}
+	var p *Path2D
+	mat := [6]float64{0.5, 0.5, -0.5, 0.5, 50, 10}
+	expected := draw(func(cv *Canvas) {
+		p = cv.NewPath2D()
+		p.Arc(40, 40, 30, 0, math.Pi*2, false)
+		p.MoveTo(40, 40)
+		p.LineTo(90, 10)
+		p.LineTo(90, 90)
+		p.ClosePath()
+		cv.Transform(mat[0], mat[1], mat[2], mat[3], mat[4], mat[5])
+		cv.FillPath(p)
+	})
+	original := append([]pathPoint(nil), p.p...)
+	cache := append([]backendbase.Vec(nil), p.fillCache...)
+
+	// the transformed path fills the same pixels as the path
+	// on a transformed canvas, with the fill cache transformed
+	// along with the path
+	p2 := p.Transform(mat)
+	if len(p2.fillCache) != len(cache) {
+		t.Fatalf("the transformed fill cache has %d points instead of %d", len(p2.fillCache), len(cache))
+	}
+	for i, pt := range cache {
+		if d := p2.fillCache[i].Sub(pt.MulMat(backendbase.Mat(mat))); d.Len() > 1e-9 {
+			t.Errorf("fill cache point %d is %v instead of %v", i, p2.fillCache[i], pt.MulMat(backendbase.Mat(mat)))
+		}
+	}
+	// without the cache the triangles are made from rounded
+	// points, so a few pixels on the edges may differ
+	for _, tc := range []struct {
+		cached  bool
+		maxDiff int
+	}{
+		{true, 0},
+		{false, 4},
+	} {
+		b := draw(func(cv *Canvas) {
+			if !tc.cached {
+				p2.clearCache()
+			}
+			cv.FillPath(p2)
+		})
+		diff := 0
+		for i := 3; i < len(b.Image.Pix); i += 4 {
+			if b.Image.Pix[i] != expected.Image.Pix[i] {
+				diff++
+			}
+		}
+		if diff > tc.maxDiff {
+			t.Errorf("the transformed path with cache %v fills %d different pixels", tc.cached, diff)
+		}
+	}
+
+	// the original path is unchanged
+	if len(p.p) != len(original) {
+		t.Fatalf("the original path has %d points instead of %d", len(p.p), len(original))
+	}
+	for i := range original {
+		if p.p[i] != original[i] {
+			t.Fatalf("point %d of the original path changed", i)
+		}
+	}
+}
+
+func TestPathClone(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	p := cv.NewPath2D()
+	p.MoveTo(10, 10)
+	p.LineTo(50, 10)
+	p.LineTo(50, 50)
+	cv.FillPath(p)
+
+	c := p.Clone()
+	checkPathPoints(t, "clone", c, []backendbase.Vec{{10, 10}, {50, 10}, {50, 50}})
+	if len(c.fillCache) != len(p.fillCache) || c.fillRule != p.fillRule {
+		t.Error("the clone doesn't have the fill cache of the path")
+	}
+
+	// changing the clone doesn't change the path and the
+	// clone continues the subpath of the path
+	c.LineTo(10, 50)
+	c.ClosePath()
+	if len(p.p) != 3 || p.fillCache == nil {
+		t.Errorf("changing the clone changed the path to %d points", len(p.p))
+	}
+	checkPathPoints(t, "changed clone", c, []backendbase.Vec{{10, 10}, {50, 10}, {50, 50}, {10, 50}, {10, 10}})
+	if !c.IsPointInPath(20, 40, NonZero) || p.IsPointInPath(20, 40, NonZero) {
+		t.Error("the clone and the path don't contain the points of their own shapes")
+	}
+}
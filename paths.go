@@ -104,6 +104,23 @@ func (cv *Canvas) strokePath(path *Path2D, tf backendbase.Mat, inv backendbase.M
 		return
 	}
 
+	if pb, ok := cv.b.(backendbase.PathBackend); ok {
+		tris := cv.strokeTris(path, tf, inv, doInv, nil)
+		cv.drawShadow(tris, nil, true)
+
+		var bp backendbase.Path
+		if doInv {
+			bp = backendPath(path.p, inv)
+		} else {
+			bp = backendPath(path.p, backendbase.MatIdentity)
+		}
+		bp.Triangles = func() []backendbase.Vec { return tris }
+		line := cv.backendLineStyle()
+		stl := cv.backendFillStyle(&cv.state.stroke, 1)
+		pb.StrokePath(&stl, &bp, &line, tf)
+		return
+	}
+
 	var triBuf [500]backendbase.Vec
 	tris := cv.strokeTris(path, tf, inv, doInv, triBuf[:0])
 
@@ -113,6 +130,61 @@ func (cv *Canvas) strokePath(path *Path2D, tf backendbase.Mat, inv backendbase.M
 	cv.b.Fill(&stl, tris, backendbase.MatIdentity, true)
 }
 
+func (cv *Canvas) backendLineStyle() backendbase.LineStyle {
+	line := backendbase.LineStyle{
+		Width:      cv.state.lineWidth,
+		MiterLimit: math.Sqrt(cv.state.miterLimitSqr),
+	}
+	switch cv.state.lineJoin {
+	case Miter:
+		line.Join = backendbase.MiterJoin
+	case Bevel:
+		line.Join = backendbase.BevelJoin
+	case Round:
+		line.Join = backendbase.RoundJoin
+	}
+	switch cv.state.lineCap {
+	case Butt:
+		line.Cap = backendbase.ButtCap
+	case Square:
+		line.Cap = backendbase.SquareCap
+	case Round:
+		line.Cap = backendbase.RoundCap
+	}
+	if len(cv.state.lineDash) >= 2 {
+		line.Dash = cv.state.lineDash
+		line.DashOffset = cv.state.lineDashOffset
+	}
+	return line
+}
+
+// backendPath converts the path for a PathBackend, with
+// the given matrix applied to the points
+func backendPath(path []pathPoint, mat backendbase.Mat) backendbase.Path {
+	var bp backendbase.Path
+	start := 0
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i].flags&pathMove == 0 {
+			continue
+		}
+		sp := path[start:i]
+		start = i
+		if len(sp) < 2 {
+			continue
+		}
+		closed := sp[len(sp)-1].flags&pathAttach != 0
+		if closed && sp[0].pos == sp[len(sp)-1].pos {
+			sp = sp[:len(sp)-1]
+		}
+		pts := make([]backendbase.Vec, len(sp))
+		for j, p := range sp {
+			pts[j] = p.pos.MulMat(mat)
+		}
+		bp.SubPaths = append(bp.SubPaths, backendbase.SubPath{Points: pts, Closed: closed})
+	}
+	return bp
+}
+
 func (cv *Canvas) strokeTris(path *Path2D, tf backendbase.Mat, inv backendbase.Mat, doInv bool, target []backendbase.Vec) []backendbase.Vec {
 	if len(path.p) == 0 {
 		return target
@@ -366,24 +438,39 @@ func linePointDistSqr(a, b, p backendbase.Vec) float64 {
 }
 
 // Fill fills the current path with the current FillStyle
+// using the nonzero rule
 func (cv *Canvas) Fill() {
-	cv.fillPath(&cv.path, backendbase.MatIdentity)
+	cv.fillPath(&cv.path, backendbase.MatIdentity, NonZero)
+}
+
+// FillWithRule fills the current path with the current
+// FillStyle using the given rule to decide which areas of
+// overlapping subpaths are inside
+func (cv *Canvas) FillWithRule(rule pathRule) {
+	cv.fillPath(&cv.path, backendbase.MatIdentity, rule)
 }
 
 // FillPath fills the given path with the current FillStyle
+// using the nonzero rule
 func (cv *Canvas) FillPath(path *Path2D) {
-	cv.fillPath(path, cv.state.transform)
+	cv.fillPath(path, cv.state.transform, NonZero)
+}
+
+// FillPathWithRule fills the given path with the current
+// FillStyle using the given rule
+func (cv *Canvas) FillPathWithRule(path *Path2D, rule pathRule) {
+	cv.fillPath(path, cv.state.transform, rule)
 }
 
 // FillPath fills the given path with the current FillStyle
-func (cv *Canvas) fillPath(path *Path2D, tf backendbase.Mat) {
+func (cv *Canvas) fillPath(path *Path2D, tf backendbase.Mat, rule pathRule) {
 	if len(path.p) < 3 {
 		return
 	}
 
 	var tris []backendbase.Vec
 	var triBuf [500]backendbase.Vec
-	if path.standalone && path.fillCache != nil {
+	if path.standalone && path.fillCache != nil && path.fillRule == rule {
 		tris = path.fillCache
 	} else {
 		if path.standalone {
@@ -391,12 +478,10 @@ func (cv *Canvas) fillPath(path *Path2D, tf backendbase.Mat) {
 		} else {
 			tris = triBuf[:0]
 		}
-		runSubPaths(path.p, true, func(sp []pathPoint) bool {
-			tris = appendSubPathTriangles(tris, backendbase.MatIdentity, sp)
-			return false
-		})
+		tris = appendPathTriangles(tris, backendbase.MatIdentity, path.p, rule)
 		if path.standalone {
 			path.fillCache = tris
+			path.fillRule = rule
 		}
 	}
 
@@ -407,9 +492,37 @@ func (cv *Canvas) fillPath(path *Path2D, tf backendbase.Mat) {
 	cv.drawShadow(tris, nil, false)
 
 	stl := cv.backendFillStyle(&cv.state.fill, 1)
+	if pb, ok := cv.b.(backendbase.PathBackend); ok {
+		tftris := make([]backendbase.Vec, len(tris))
+		for i, pt := range tris {
+			tftris[i] = pt.MulMat(tf)
+		}
+		bp := backendPath(path.p, backendbase.MatIdentity)
+		bp.FillRule = backendbase.FillRule(rule)
+		bp.Triangles = func() []backendbase.Vec { return tftris }
+		pb.FillPath(&stl, &bp, tf)
+		return
+	}
 	cv.b.Fill(&stl, tris, tf, false)
 }
 
+// appendPathTriangles appends the triangles of the area of
+// the path that is inside according to the rule. Subpaths
+// that don't overlap any others are triangulated on their own
+func appendPathTriangles(tris []backendbase.Vec, mat backendbase.Mat, path []pathPoint, rule pathRule) []backendbase.Vec {
+	for _, group := range subPathGroups(path) {
+		if len(group) > 1 || needsWindingFill(group[0]) {
+			tris = windingTriangles(group, mat, rule, tris)
+			continue
+		}
+		runSubPath(group[0], true, func(sp []pathPoint) bool {
+			tris = appendSubPathTriangles(tris, mat, sp)
+			return false
+		})
+	}
+	return tris
+}
+
 func appendSubPathTriangles(tris []backendbase.Vec, mat backendbase.Mat, path []pathPoint) []backendbase.Vec {
 	last := path[len(path)-1]
 	if last.flags&pathIsConvex != 0 {
@@ -437,10 +550,16 @@ func appendSubPathTriangles(tris []backendbase.Vec, mat backendbase.Mat, path []
 // Clip uses the current path to clip any further drawing. Use Save/Restore to
 // remove the clipping again
 func (cv *Canvas) Clip() {
-	cv.clip(&cv.path, backendbase.MatIdentity)
+	cv.clip(&cv.path, backendbase.MatIdentity, NonZero)
+}
+
+// ClipWithRule is like Clip, but uses the given rule to
+// decide which areas of overlapping subpaths are inside
+func (cv *Canvas) ClipWithRule(rule pathRule) {
+	cv.clip(&cv.path, backendbase.MatIdentity, rule)
 }
 
-func (cv *Canvas) clip(path *Path2D, tf backendbase.Mat) {
+func (cv *Canvas) clip(path *Path2D, tf backendbase.Mat, rule pathRule) {
 	if len(path.p) < 3 {
 		return
 	}
@@ -459,17 +578,14 @@ func (cv *Canvas) clip(path *Path2D, tf backendbase.Mat) {
 		return
 	}
 
-	tris := buf[:0]
-	runSubPaths(path.p, true, func(sp []pathPoint) bool {
-		tris = appendSubPathTriangles(tris, tf, sp)
-		return false
-	})
+	tris := appendPathTriangles(buf[:0], tf, path.p, rule)
 	if len(tris) == 0 {
 		return
 	}
 
 	cv.state.clip.p = make([]pathPoint, len(path.p))
 	copy(cv.state.clip.p, path.p)
+	cv.state.clipRule = rule
 
 	cv.b.Clip(tris)
 }
@@ -0,0 +1,272 @@
+package canvas_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// fillRecorder records the triangles passed to Fill, with
+// the transformation applied
+type fillRecorder struct {
+	*softwarebackend.SoftwareBackend
+	fills [][]backendbase.Vec
+}
+
+func (b *fillRecorder) Fill(style *backendbase.FillStyle, pts []backendbase.Vec, tf backendbase.Mat, canOverlap bool) {
+	tris := make([]backendbase.Vec, len(pts))
+	for i, pt := range pts {
+		tris[i] = pt.MulMat(tf)
+	}
+	b.fills = append(b.fills, tris)
+	b.SoftwareBackend.Fill(style, pts, tf, canOverlap)
+}
+
+// pathRecorder implements backendbase.PathBackend and
+// records the paths
+type pathRecorder struct {
+	fillRecorder
+	paths     []backendbase.Path
+	triangles [][]backendbase.Vec
+	lines     []backendbase.LineStyle
+	tfs       []backendbase.Mat
+}
+
+func (b *pathRecorder) FillPath(style *backendbase.FillStyle, path *backendbase.Path, tf backendbase.Mat) {
+	b.paths = append(b.paths, *path)
+	b.triangles = append(b.triangles, path.Triangles())
+	b.lines = append(b.lines, backendbase.LineStyle{})
+	b.tfs = append(b.tfs, tf)
+}
+
+func (b *pathRecorder) StrokePath(style *backendbase.FillStyle, path *backendbase.Path, line *backendbase.LineStyle, tf backendbase.Mat) {
+	b.paths = append(b.paths, *path)
+	b.triangles = append(b.triangles, path.Triangles())
+	b.lines = append(b.lines, *line)
+	b.tfs = append(b.tfs, tf)
+}
+
+func trianglesArea(tris []backendbase.Vec) float64 {
+	var area float64
+	for i := 2; i < len(tris); i += 3 {
+		a, b, c := tris[i-2], tris[i-1], tris[i]
+		area += math.Abs((b[0]-a[0])*(c[1]-a[1])-(b[1]-a[1])*(c[0]-a[0])) / 2
+	}
+	return area
+}
+
+func drawDonut(cv *canvas.Canvas) {
+	path := cv.NewPath2D()
+	for _, r := range [][4]float64{{10, 10, 30, 30}, {20, 20, 10, 10}} {
+		path.MoveTo(r[0], r[1])
+		path.LineTo(r[0]+r[2], r[1])
+		path.LineTo(r[0]+r[2], r[1]+r[3])
+		path.LineTo(r[0], r[1]+r[3])
+		path.ClosePath()
+	}
+	cv.Translate(5, 0)
+	cv.FillPathWithRule(path, canvas.EvenOdd)
+}
+
+func TestPathBackendFill(t *testing.T) {
+	pb := &pathRecorder{fillRecorder: fillRecorder{SoftwareBackend: softwarebackend.New(100, 100)}}
+	drawDonut(canvas.New(pb))
+
+	if len(pb.fills) != 0 {
+		t.Fatalf("Fill was called %d times instead of FillPath", len(pb.fills))
+	}
+	if len(pb.paths) != 1 {
+		t.Fatalf("FillPath was called %d times", len(pb.paths))
+	}
+	path := pb.paths[0]
+	if path.FillRule != backendbase.EvenOdd {
+		t.Errorf("fill rule is %v instead of evenodd", path.FillRule)
+	}
+	if pb.tfs[0] != backendbase.MatTranslate(backendbase.Vec{5, 0}) {
+		t.Errorf("unexpected transformation %v", pb.tfs[0])
+	}
+	expected := [][]backendbase.Vec{
+		{{10, 10}, {40, 10}, {40, 40}, {10, 40}},
+		{{20, 20}, {30, 20}, {30, 30}, {20, 30}},
+	}
+	if len(path.SubPaths) != len(expected) {
+		t.Fatalf("expected %d subpaths, got %d", len(expected), len(path.SubPaths))
+	}
+	for i, sp := range path.SubPaths {
+		if !sp.Closed {
+			t.Errorf("subpath %d is not closed", i)
+		}
+		if len(sp.Points) != len(expected[i]) {
+			t.Errorf("subpath %d has points %v instead of %v", i, sp.Points, expected[i])
+			continue
+		}
+		for j, pt := range sp.Points {
+			if pt != expected[i][j] {
+				t.Errorf("subpath %d has points %v instead of %v", i, sp.Points, expected[i])
+				break
+			}
+		}
+	}
+
+	if area := trianglesArea(pb.triangles[0]); math.Abs(area-800) > 1e-9 {
+		t.Errorf("triangles cover an area of %g instead of 800", area)
+	}
+	for _, pt := range pb.triangles[0] {
+		if pt[0] < 15 || pt[0] > 45 || pt[1] < 10 || pt[1] > 40 {
+			t.Errorf("triangle point %v is not transformed", pt)
+			break
+		}
+	}
+}
+
+func TestPathBackendStroke(t *testing.T) {
+	pb := &pathRecorder{fillRecorder: fillRecorder{SoftwareBackend: softwarebackend.New(100, 100)}}
+	cv := canvas.New(pb)
+	cv.Scale(2, 2)
+	cv.SetLineWidth(4)
+	cv.SetLineJoin(canvas.Round)
+	cv.SetLineCap(canvas.Square)
+	cv.SetMiterLimit(7)
+	cv.SetLineDash([]float64{3, 2})
+	cv.SetLineDashOffset(1)
+	cv.BeginPath()
+	cv.MoveTo(10, 10)
+	cv.LineTo(30, 10)
+	cv.LineTo(30, 30)
+	cv.Stroke()
+
+	if len(pb.fills) != 0 || len(pb.paths) != 1 {
+		t.Fatalf("expected a single StrokePath call, got %d paths and %d fills", len(pb.paths), len(pb.fills))
+	}
+	line := pb.lines[0]
+	if line.Width != 4 || line.Join != backendbase.RoundJoin || line.Cap != backendbase.SquareCap || math.Abs(line.MiterLimit-7) > 1e-9 {
+		t.Errorf("unexpected line style %+v", line)
+	}
+	if len(line.Dash) != 2 || line.Dash[0] != 3 || line.Dash[1] != 2 || line.DashOffset != 1 {
+		t.Errorf("unexpected line dash %v offset %g", line.Dash, line.DashOffset)
+	}
+	if pb.tfs[0] != backendbase.MatScale(backendbase.Vec{2, 2}) {
+		t.Errorf("unexpected transformation %v", pb.tfs[0])
+	}
+	sp := pb.paths[0].SubPaths
+	if len(sp) != 1 || sp[0].Closed || len(sp[0].Points) != 3 || sp[0].Points[2] != (backendbase.Vec{30, 30}) {
+		t.Errorf("unexpected subpaths %v", sp)
+	}
+	if len(pb.triangles[0]) == 0 {
+		t.Error("stroke has no triangles")
+	}
+}
+
+func TestPathBackendFallback(t *testing.T) {
+	fb := &fillRecorder{SoftwareBackend: softwarebackend.New(100, 100)}
+	drawDonut(canvas.New(fb))
+	pb := &pathRecorder{fillRecorder: fillRecorder{SoftwareBackend: softwarebackend.New(100, 100)}}
+	drawDonut(canvas.New(pb))
+
+	if len(fb.fills) != 1 {
+		t.Fatalf("Fill was called %d times", len(fb.fills))
+	}
+	fallback, tris := fb.fills[0], pb.triangles[0]
+	if len(fallback) != len(tris) {
+		t.Fatalf("fallback has %d triangle points, the path backend got %d", len(fallback), len(tris))
+	}
+	for i := range tris {
+		if math.Abs(tris[i][0]-fallback[i][0]) > 1e-9 || math.Abs(tris[i][1]-fallback[i][1]) > 1e-9 {
+			t.Fatalf("triangle point %d is %v instead of %v", i, fallback[i], tris[i])
+		}
+	}
+
+	// the hole is not filled
+	img := fb.GetImageData(0, 0, 100, 100)
+	if img.RGBAAt(30, 25).A != 0 || img.RGBAAt(18, 25).A != 255 {
+		t.Errorf("the donut was not filled correctly")
+	}
+}
+
+func TestFillSelfIntersecting(t *testing.T) {
+	shapes := []struct {
+		name string
+		pts  [][2]float64
+	}{
+		// convex until it is closed
+		{"twisted quad", [][2]float64{{5.2, 62.3}, {45.1, 92.4}, {28.3, 91.2}, {29.4, 57.1}}},
+		// only the closing line crosses another one
+		{"closing line", [][2]float64{{11.56, 80.87}, {85.3, 6.76}, {67.23, 94.14}, {36.51, 27.03}}},
+		// the crossing lines come before the path turns the
+		// other way
+		{"convex start", [][2]float64{{50.1, 10.2}, {73.51, 82.36}, {11.96, 37.64}, {88.04, 37.64}, {70.3, 20.1}}},
+	}
+	for _, shape := range shapes {
+		for _, evenOdd := range []bool{false, true} {
+			cv := canvas.New(softwarebackend.New(100, 100))
+			path := cv.NewPath2D()
+			for _, pt := range shape.pts {
+				path.LineTo(pt[0], pt[1])
+			}
+			path.ClosePath()
+			cv.SetFillStyle("#FFF")
+			rule := canvas.NonZero
+			if evenOdd {
+				rule = canvas.EvenOdd
+			}
+			cv.FillPathWithRule(path, rule)
+
+			// the pixels are filled if their center is inside
+			img := cv.GetImageData(0, 0, 100, 100)
+			wrong := 0
+			for y := 0; y < 100; y++ {
+				for x := 0; x < 100; x++ {
+					inside := path.IsPointInPath(float64(x)+0.5, float64(y)+0.5, rule)
+					if inside != (img.RGBAAt(x, y).A != 0) {
+						wrong++
+					}
+				}
+			}
+			if wrong > 0 {
+				t.Errorf("%s with even-odd %v: %d pixels are filled wrong", shape.name, evenOdd, wrong)
+			}
+		}
+	}
+}
+
+func TestFillNonFinite(t *testing.T) {
+	star := [][2]float64{{50.1, 10.2}, {73.51, 82.36}, {11.96, 37.64}, {88.04, 37.64}, {26.49, 82.36}}
+	fill := func(bad ...[2]float64) *fillRecorder {
+		fb := &fillRecorder{SoftwareBackend: softwarebackend.New(100, 100)}
+		cv := canvas.New(fb)
+		cv.SetFillStyle("#FFF")
+		path := cv.NewPath2D()
+		for i, pt := range star {
+			path.LineTo(pt[0], pt[1])
+			if i == 2 {
+				for _, pt := range bad {
+					path.LineTo(pt[0], pt[1])
+				}
+			}
+		}
+		path.ClosePath()
+		cv.FillPathWithRule(path, canvas.EvenOdd)
+		return fb
+	}
+
+	// points that are not finite are left out of the star
+	// that is filled across its crossing lines
+	expected := fill().GetImageData(0, 0, 100, 100)
+	fb := fill([2]float64{math.NaN(), 50}, [2]float64{30, math.Inf(1)}, [2]float64{math.NaN(), math.NaN()})
+	for _, tris := range fb.fills {
+		for _, pt := range tris {
+			if math.IsNaN(pt[0]) || math.IsNaN(pt[1]) || math.IsInf(pt[0], 0) || math.IsInf(pt[1], 0) {
+				t.Fatalf("the triangles have the point %v", pt)
+			}
+		}
+	}
+	img := fb.GetImageData(0, 0, 100, 100)
+	for i := range img.Pix {
+		if img.Pix[i] != expected.Pix[i] {
+			t.Fatalf("the star with points that are not finite differs at %d,%d", i/4%100, i/4/100)
+		}
+	}
+}
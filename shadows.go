@@ -29,7 +29,11 @@ func (cv *Canvas) drawShadow(pts []backendbase.Vec, mask *image.Alpha, canOverla
 
 	color := cv.state.shadowColor
 	color.A = uint8(math.Round(((float64(color.A) / 255.0) * cv.state.globalAlpha) * 255.0))
-	style := backendbase.FillStyle{Color: color, Blur: cv.state.shadowBlur}
+	style := backendbase.FillStyle{
+		Color:     color,
+		Blur:      cv.state.shadowBlur,
+		Composite: backendbase.CompositeOperation(cv.state.composite),
+	}
 	if mask != nil {
 		if len(cv.shadowBuf) != 4 {
 			panic("invalid number of points to fill with mask, must be 4")
@@ -0,0 +1,471 @@
+package canvas
+
+import (
+	"image"
+	"math"
+
+	"github.com/go-text/typesetting/bidi"
+	"github.com/go-text/typesetting/di"
+	otfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/shaping"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	ubidi "golang.org/x/text/unicode/bidi"
+)
+
+// textGlyph is a positioned glyph of a shaped string along
+// with the font and size that are used to draw it. The
+// advance and offset are in pixels at that size, the offset
+// is y-down. The cluster is the index of the first rune the
+// glyph belongs to, span is the index of the span of that
+// rune, and rtl is set if the glyph is part of right-to-left
+// text
+type textGlyph struct {
+	font    *Font
+	idx     truetype.Index
+	size    fixed.Int26_6
+	cluster int
+	span    int
+	rtl     bool
+	advance fixed.Int26_6
+	offset  fixed.Point26_6
+}
+
+// shapeSpan is a part of a text that is shaped with its own
+// font, fallbacks and size. It ends before the rune at end
+type shapeSpan struct {
+	end       int
+	font      *Font
+	fallbacks []*Font
+	size      fixed.Int26_6
+}
+
+// singleFace is a shaping.Fontmap that always resolves to
+// the same face, since the runs are already split by font
+type singleFace struct {
+	face *otfont.Face
+}
+
+func (sf singleFace) ResolveFace(rn rune) *otfont.Face { return sf.face }
+
+// textRun is a run of glyphs that are drawn with the same
+// font and have the same bidi embedding level
+type textRun struct {
+	level  bidi.Level
+	glyphs []textGlyph
+}
+
+// shapeText turns the string into glyphs positioned at the
+// given font size using the current font
+func (cv *Canvas) shapeText(str string, size fixed.Int26_6) ([]textGlyph, bool) {
+	runes := []rune(str)
+	return cv.shapeSpans(runes, []shapeSpan{{
+		end:       len(runes),
+		font:      cv.state.font,
+		fallbacks: cv.state.fontFallbacks,
+		size:      size,
+	}}, float64(size)/float64(cv.state.fontSize))
+}
+
+// shapeSpans turns the runes into glyphs. The runes are split
+// into runs by the font of each span and its fallbacks and by
+// the embedding levels of the Unicode Bidirectional Algorithm,
+// so that spans that only differ in style are shaped together.
+// Runs in fonts loaded from font data are shaped with their
+// GSUB and GPOS tables so that ligatures, contextual forms,
+// mark positioning and kerning are applied. Fonts passed in as
+// a *truetype.Font are laid out rune by rune with kerning from
+// the kern table. The font variations of the state are
+// applied to the fonts. The letter and word spacing are added
+// multiplied by scale, which is the size the text is shaped at
+// relative to the size it is drawn at. The glyphs are returned
+// in visual order, along with whether the base direction is
+// right-to-left
+func (cv *Canvas) shapeSpans(runes []rune, spans []shapeSpan, scale float64) ([]textGlyph, bool) {
+	for i, rn := range runes {
+		switch rn {
+		case '\t', '\n', '\f', '\r':
+			runes[i] = ' '
+		}
+	}
+	levels, rtl := cv.bidiLevels(runes)
+	spans = cv.varySpans(spans)
+	fonts := make([]*Font, len(runes))
+	spanIdx := make([]int, len(runes))
+	span := 0
+	for i, rn := range runes {
+		for span < len(spans)-1 && i >= spans[span].end {
+			span++
+		}
+		spanIdx[i] = span
+		fonts[i], runes[i] = spans[span].runeFont(rn)
+	}
+
+	var runs []textRun
+	count := 0
+	for start := 0; start < len(runes); {
+		size := spans[spanIdx[start]].size
+		end := start + 1
+		for end < len(runes) && fonts[end] == fonts[start] && levels[end] == levels[start] && spans[spanIdx[end]].size == size {
+			end++
+		}
+		run := textRun{level: levels[start]}
+		if fonts[start].face != nil {
+			run.glyphs = cv.shapeRun(fonts[start], runes, start, end, size, run.level%2 == 1)
+		} else {
+			run.glyphs = cv.layoutRun(fonts[start], runes, start, end, size, run.level%2 == 1)
+		}
+		runs = append(runs, run)
+		count += len(run.glyphs)
+		start = end
+	}
+	reorderRuns(runs)
+
+	glyphs := make([]textGlyph, 0, count)
+	for _, run := range runs {
+		for _, g := range run.glyphs {
+			g.span = spanIdx[g.cluster]
+			glyphs = append(glyphs, g)
+		}
+	}
+
+	// the spacing is added to the last glyph of each cluster,
+	// so that it comes after any marks
+	if cv.state.letterSpacing != 0 || cv.state.wordSpacing != 0 {
+		letterSpacing := fixed.Int26_6(math.Round(cv.state.letterSpacing * scale * 64))
+		wordSpacing := fixed.Int26_6(math.Round(cv.state.wordSpacing * scale * 64))
+		for i, g := range glyphs {
+			if i+1 < len(glyphs) && glyphs[i+1].cluster == g.cluster {
+				continue
+			}
+			glyphs[i].advance += letterSpacing
+			if isWordSeparator(runes[g.cluster]) {
+				glyphs[i].advance += wordSpacing
+			}
+		}
+	}
+	return glyphs, rtl
+}
+
+var (
+	tagKern = ot.MustNewTag("kern")
+	tagLiga = ot.MustNewTag("liga")
+	tagClig = ot.MustNewTag("clig")
+)
+
+// fontFeatures returns the OpenType features that are turned
+// off by the font kerning, text rendering and letter spacing
+func (cv *Canvas) fontFeatures() []shaping.FontFeature {
+	var features []shaping.FontFeature
+	if !cv.kerning() {
+		features = append(features, shaping.FontFeature{Tag: tagKern, Value: 0})
+	}
+	if cv.state.letterSpacing != 0 || cv.state.textRendering == OptimizeSpeed {
+		features = append(features,
+			shaping.FontFeature{Tag: tagLiga, Value: 0},
+			shaping.FontFeature{Tag: tagClig, Value: 0})
+	}
+	return features
+}
+
+// bidiLevels resolves the embedding level of each rune with
+// the Unicode Bidirectional Algorithm and returns whether
+// the base direction is right-to-left. With the Inherit
+// direction the base direction is taken from the first
+// strong character. Each paragraph is resolved on its own,
+// with the paragraph separators at the base level
+func (cv *Canvas) bidiLevels(runes []rune) ([]bidi.Level, bool) {
+	rtl := cv.state.direction == RTL
+	if cv.state.direction == Inherit {
+		rtl = firstStrongRTL(runes)
+	}
+	def, base := bidi.LeftToRight, bidi.Level(0)
+	if rtl {
+		def, base = bidi.RightToLeft, 1
+	}
+
+	levels := make([]bidi.Level, len(runes))
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) {
+			props, _ := ubidi.LookupRune(runes[i])
+			if props.Class() != ubidi.B {
+				continue
+			}
+			levels[i] = base
+		}
+		if i > start {
+			runLevels(runes[start:i], cv.bidi.Segment(runes[start:i], def), levels[start:i], rtl)
+		}
+		start = i + 1
+	}
+	return levels, rtl
+}
+
+// runLevels sets the levels of the runes from the runs that
+// the bidi package resolved. The runs only split where the
+// direction changes and have the level of their first rune,
+// so that for example numbers at level 2 after right-to-left
+// text share a run with the left-to-right text at level 0
+// that follows them. Without explicit embeddings in a
+// left-to-right paragraph these numbers are the only runes
+// at level 2, so they are raised again by numberLevels. With
+// explicit embeddings the levels of the runs are used
+func runLevels(runes []rune, runs bidi.Runs, levels []bidi.Level, rtl bool) {
+	for i := 0; i < runs.NumRuns(); i++ {
+		run := runs.Run(i)
+		for j := run.Start; j < run.End; j++ {
+			levels[j] = run.Level
+		}
+	}
+	if rtl {
+		return
+	}
+	for _, rn := range runes {
+		props, _ := ubidi.LookupRune(rn)
+		switch props.Class() {
+		case ubidi.LRE, ubidi.RLE, ubidi.LRO, ubidi.RLO, ubidi.PDF, ubidi.LRI, ubidi.RLI, ubidi.FSI, ubidi.PDI:
+			return
+		}
+	}
+	for i, level := range levels {
+		if level%2 == 0 {
+			levels[i] = 0
+		}
+	}
+	numberLevels(runes, levels)
+}
+
+// numberLevels raises numbers in a left-to-right paragraph
+// to level 2 where they are not turned into left-to-right
+// text, which is when they follow right-to-left text or are
+// Arabic numbers (rules W2, W4, W5 and W7). Only runes at
+// level 0 are raised, so the directions stay as resolved
+func numberLevels(runes []rune, levels []bidi.Level) {
+	classes := make([]ubidi.Class, len(runes))
+	for i, rn := range runes {
+		props, _ := ubidi.LookupRune(rn)
+		classes[i] = props.Class()
+	}
+
+	strong := ubidi.L
+	for i, class := range classes {
+		switch class {
+		case ubidi.L, ubidi.R, ubidi.AL:
+			strong = class
+		case ubidi.AN, ubidi.EN:
+			if levels[i] == 0 && (class == ubidi.AN || strong != ubidi.L) {
+				levels[i] = 2
+			}
+		}
+	}
+
+	isNumber := func(i int) bool {
+		return i >= 0 && i < len(levels) && levels[i] == 2 && (classes[i] == ubidi.EN || classes[i] == ubidi.AN)
+	}
+	for i, class := range classes {
+		if levels[i] != 0 {
+			continue
+		}
+		switch class {
+		case ubidi.ES, ubidi.CS:
+			// a single separator between two numbers, where
+			// only European numbers can have a plus or minus
+			// sign between them
+			if isNumber(i-1) && isNumber(i+1) && classes[i-1] == classes[i+1] && (class == ubidi.CS || classes[i-1] == ubidi.EN) {
+				levels[i] = 2
+			}
+		case ubidi.NSM:
+			if i > 0 && levels[i-1] == 2 {
+				levels[i] = 2
+			}
+		}
+	}
+	// terminators like currency symbols next to numbers
+	for i, class := range classes {
+		if class != ubidi.ET || levels[i] != 0 {
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == ubidi.ET {
+			j++
+		}
+		if (isNumber(i-1) && classes[i-1] == ubidi.EN) || (isNumber(j) && classes[j] == ubidi.EN) {
+			for k := i; k < j; k++ {
+				levels[k] = 2
+			}
+		}
+	}
+}
+
+// firstStrongRTL returns whether the first strongly
+// directional character is right-to-left
+func firstStrongRTL(runes []rune) bool {
+	for _, rn := range runes {
+		props, _ := ubidi.LookupRune(rn)
+		switch props.Class() {
+		case ubidi.L:
+			return false
+		case ubidi.R, ubidi.AL:
+			return true
+		}
+	}
+	return false
+}
+
+// reorderRuns reorders the runs from logical to visual order
+// as in rule L2 of the bidi algorithm, by reversing every
+// sequence of runs at a level or higher, from the highest
+// level down to the lowest odd level
+func reorderRuns(runs []textRun) {
+	var maxLevel bidi.Level
+	minOdd := bidi.Level(math.MaxInt8)
+	for _, run := range runs {
+		if run.level > maxLevel {
+			maxLevel = run.level
+		}
+		if run.level%2 == 1 && run.level < minOdd {
+			minOdd = run.level
+		}
+	}
+
+	for level := maxLevel; level >= minOdd; level-- {
+		for i := 0; i < len(runs); {
+			if runs[i].level < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runs) && runs[j].level >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				runs[a], runs[b] = runs[b], runs[a]
+			}
+			i = j
+		}
+	}
+}
+
+// runeFont returns the first of the font of the span and its
+// fallbacks that has a glyph for the rune. Runes that none of
+// the fonts have are replaced by a space of the span font
+func (s *shapeSpan) runeFont(rn rune) (*Font, rune) {
+	if s.font.index(rn) != 0 {
+		return s.font, rn
+	}
+	for _, f := range s.fallbacks {
+		if f.index(rn) != 0 {
+			return f, rn
+		}
+	}
+	return s.font, ' '
+}
+
+// shapeRun shapes the runes from start to end, which all use
+// the given font and direction, and returns the glyphs in
+// visual order. The advances are
+// based on the hinted advances of the rasterizer, to which
+// the adjustments of the shaper are added, so that shaped
+// text lines up with the rendered glyphs
+func (cv *Canvas) shapeRun(fnt *Font, runes []rune, start, end int, size fixed.Int26_6, rtl bool) []textGlyph {
+	frc := cv.getFRContext(fnt, size)
+	hinting := frc.hinting != font.HintingNone
+	scale := float32(size) / float32(fnt.face.Upem())
+
+	input := shaping.Input{
+		Text:      runes,
+		RunStart:  start,
+		RunEnd:    end,
+		Direction: di.DirectionLTR,
+		Face:      fnt.face,
+		Size:      size,
+
+		FontFeatures: cv.fontFeatures(),
+	}
+	if rtl {
+		input.Direction = di.DirectionRTL
+	}
+	runs := cv.segmenter.Split(input, singleFace{face: fnt.face})
+	glyphs := make([]textGlyph, 0, end-start)
+	for i := range runs {
+		if rtl {
+			i = len(runs) - 1 - i
+		}
+		out := cv.shaper.Shape(runs[i])
+		for _, sg := range out.Glyphs {
+			g := textGlyph{
+				font:    fnt,
+				idx:     truetype.Index(sg.GlyphID),
+				size:    size,
+				cluster: sg.ClusterIndex,
+				rtl:     rtl,
+				offset:  fixed.Point26_6{X: sg.XOffset, Y: -sg.YOffset},
+			}
+			nominal := fixed.Int26_6(fnt.face.HorizontalAdvance(sg.GlyphID)*scale + 0.5)
+			adjust := sg.XAdvance - nominal
+			if hinting {
+				adjust = roundFixed(adjust)
+				g.offset = fixed.Point26_6{X: roundFixed(g.offset.X), Y: roundFixed(g.offset.Y)}
+			}
+			advance, err := frc.glyphAdvance(g.idx)
+			if err != nil {
+				advance = nominal
+			}
+			g.advance = advance + adjust
+			glyphs = append(glyphs, g)
+		}
+	}
+	return glyphs
+}
+
+// layoutRun returns a glyph for each of the runes from start
+// to end in visual order without shaping, which is used for
+// fonts that only have a *truetype.Font
+func (cv *Canvas) layoutRun(fnt *Font, runes []rune, start, end int, size fixed.Int26_6, rtl bool) []textGlyph {
+	frc := cv.getFRContext(fnt, size)
+	glyphs := make([]textGlyph, 0, end-start)
+	for i := start; i < end; i++ {
+		cluster := i
+		if rtl {
+			cluster = end - 1 - (i - start)
+		}
+		idx := fnt.index(runes[cluster])
+		if i > start && cv.kerning() {
+			prev := &glyphs[len(glyphs)-1]
+			prev.advance += frc.kern(prev.idx, idx)
+		}
+		advance, _ := frc.glyphAdvance(idx)
+		glyphs = append(glyphs, textGlyph{font: fnt, idx: idx, size: size, cluster: cluster, rtl: rtl, advance: advance})
+	}
+	return glyphs
+}
+
+// kern returns the kerning between the two glyphs
+func (c *frContext) kern(prev, idx truetype.Index) fixed.Int26_6 {
+	var kern fixed.Int26_6
+	if c.f.font != nil {
+		kern = c.f.font.Kern(c.fontSize, prev, idx)
+	} else if c.f.cff != nil {
+		kern, _ = c.f.cff.Kern(&c.buf, sfnt.GlyphIndex(prev), sfnt.GlyphIndex(idx), c.fontSize, c.hinting)
+	}
+	if c.hinting != font.HintingNone {
+		kern = roundFixed(kern)
+	}
+	return kern
+}
+
+// bounds returns the pixel bounds of the glyph drawn with
+// the pen at p, relative to the integer part of p
+func (g textGlyph) bounds(frc *frContext, p fixed.Point26_6) (image.Rectangle, error) {
+	q := p.Add(g.offset)
+	bounds, err := frc.glyphBounds(g.idx, q)
+	return bounds.Add(image.Point{X: q.X.Floor() - p.X.Floor(), Y: q.Y.Floor() - p.Y.Floor()}), err
+}
+
+func roundFixed(v fixed.Int26_6) fixed.Int26_6 {
+	return (v + 32) &^ 63
+}
@@ -0,0 +1,54 @@
+package canvas
+
+import (
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+// visualOrder shapes the text and returns its runes in the
+// order of the glyphs
+func visualOrder(cv *Canvas, str string) string {
+	runes := []rune(str)
+	glyphs, _ := cv.shapeText(str, cv.state.fontSize)
+	var visual []rune
+	for i, g := range glyphs {
+		if i > 0 && glyphs[i-1].cluster == g.cluster {
+			continue
+		}
+		visual = append(visual, runes[g.cluster])
+	}
+	return string(visual)
+}
+
+func TestBidiVisualOrder(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	cv.SetFont("testdata/Roboto-Light.ttf", 20)
+
+	cases := []struct {
+		dir             direction
+		logical, visual string
+	}{
+		{LTR, "abc אבג 123 def", "abc 123 גבא def"},
+		{LTR, "אבג 123abc", "123 גבאabc"},
+		{LTR, "x אבג 1,234.5 ד", "x ד 1,234.5 גבא"},
+		{LTR, "abc ابج ١٢٣", "abc ١٢٣ جبا"},
+		{LTR, "ابج 12", "12 جبا"},
+		{Inherit, "אבג 50% דה", "הד 50% גבא"},
+		{Inherit, "ابج ١٢,٣٤", "١٢,٣٤ جبا"},
+		{Inherit, "abc $5 אבג", "abc $5 גבא"},
+		{RTL, "abc 123 אבג", "גבא abc 123"},
+		{RTL, "אבג -5%", "5%- גבא"},
+		{LTR, "abc אבג\u2029דה 12", "abc גבא\u202912 הד"},
+		// left-to-right text in right-to-left text in
+		// left-to-right text, with numbers and with isolates
+		{LTR, "abc אבג 123 דה def", "abc הד 123 גבא def"},
+		{LTR, "abc \u2067אבג \u2066def 12\u2069 דה\u2069 ghi", "abc \u2067הד \u2069def 12\u2066 גבא\u2069 ghi"},
+	}
+	for _, c := range cases {
+		cv.SetDirection(c.dir)
+		if visual := visualOrder(cv, c.logical); visual != c.visual {
+			t.Errorf("%q is laid out as %q instead of %q", c.logical, visual, c.visual)
+		}
+	}
+}
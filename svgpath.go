@@ -0,0 +1,287 @@
+package canvas
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+)
+
+// NewPath2DFromSVG creates a new Path2D from SVG path data,
+// like the d attribute of an SVG path element. All commands
+// are supported in their absolute and relative forms. If the
+// data has an error, the path up to the error is returned
+// along with the error, like SVG renderers draw it
+func (cv *Canvas) NewPath2DFromSVG(data string) (*Path2D, error) {
+	p := cv.NewPath2D()
+	sp := svgPathParser{data: data}
+	err := sp.parse(p)
+	return p, err
+}
+
+type svgPathParser struct {
+	data string
+	pos  int
+
+	cur, start backendbase.Vec
+
+	// ctrl is the last control point of a curve, which is
+	// reflected by the S and T commands
+	ctrl backendbase.Vec
+}
+
+func (sp *svgPathParser) parse(p *Path2D) error {
+	var cmd, prev byte
+	for {
+		sp.skipSeparators()
+		if sp.pos >= len(sp.data) {
+			return nil
+		}
+		c := sp.data[sp.pos]
+		if isSVGCommand(c) {
+			if prev == 0 && c != 'M' && c != 'm' {
+				return sp.errorf("path data must start with a move")
+			}
+			cmd = c
+			sp.pos++
+		} else if cmd == 0 || cmd == 'Z' || cmd == 'z' {
+			return sp.errorf("expected command")
+		} else if cmd == 'M' {
+			// coordinates after a move are lines
+			cmd = 'L'
+		} else if cmd == 'm' {
+			cmd = 'l'
+		}
+
+		rel := cmd >= 'a'
+		var origin backendbase.Vec
+		if rel {
+			origin = sp.cur
+		}
+		var args [7]float64
+		n := svgArgCount(cmd)
+		for i := 0; i < n; i++ {
+			var err error
+			if (cmd == 'A' || cmd == 'a') && (i == 3 || i == 4) {
+				args[i], err = sp.flag()
+			} else {
+				args[i], err = sp.number()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		pt := func(i int) backendbase.Vec {
+			return backendbase.Vec{args[i] + origin[0], args[i+1] + origin[1]}
+		}
+
+		ctrl := sp.cur
+		switch cmd {
+		case 'M', 'm':
+			sp.cur = pt(0)
+			sp.start = sp.cur
+			p.MoveTo(sp.cur[0], sp.cur[1])
+		case 'L', 'l':
+			sp.cur = pt(0)
+			p.LineTo(sp.cur[0], sp.cur[1])
+		case 'H', 'h':
+			sp.cur[0] = args[0] + origin[0]
+			p.LineTo(sp.cur[0], sp.cur[1])
+		case 'V', 'v':
+			sp.cur[1] = args[0] + origin[1]
+			p.LineTo(sp.cur[0], sp.cur[1])
+		case 'C', 'c', 'S', 's':
+			var c1, c2 backendbase.Vec
+			if cmd == 'C' || cmd == 'c' {
+				c1, c2, sp.cur = pt(0), pt(2), pt(4)
+			} else {
+				c1 = sp.cur
+				switch prev {
+				case 'C', 'c', 'S', 's':
+					c1 = sp.cur.Mulf(2).Sub(sp.ctrl)
+				}
+				c2, sp.cur = pt(0), pt(2)
+			}
+			p.BezierCurveTo(c1[0], c1[1], c2[0], c2[1], sp.cur[0], sp.cur[1])
+			ctrl = c2
+		case 'Q', 'q', 'T', 't':
+			var c1 backendbase.Vec
+			if cmd == 'Q' || cmd == 'q' {
+				c1, sp.cur = pt(0), pt(2)
+			} else {
+				c1 = sp.cur
+				switch prev {
+				case 'Q', 'q', 'T', 't':
+					c1 = sp.cur.Mulf(2).Sub(sp.ctrl)
+				}
+				sp.cur = pt(0)
+			}
+			p.QuadraticCurveTo(c1[0], c1[1], sp.cur[0], sp.cur[1])
+			ctrl = c1
+		case 'A', 'a':
+			from := sp.cur
+			sp.cur = pt(5)
+			svgArc(p, from, sp.cur, args[0], args[1], args[2]*math.Pi/180, args[3] != 0, args[4] != 0)
+		case 'Z', 'z':
+			p.ClosePath()
+			sp.cur = sp.start
+		}
+		sp.ctrl = ctrl
+		prev = cmd
+	}
+}
+
+func (sp *svgPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid path data at %d: "+format, append([]interface{}{sp.pos}, args...)...)
+}
+
+func (sp *svgPathParser) skipSeparators() {
+	for sp.pos < len(sp.data) {
+		switch sp.data[sp.pos] {
+		case ' ', '\t', '\n', '\r', '\f', ',':
+			sp.pos++
+		default:
+			return
+		}
+	}
+}
+
+// number parses a number, which can directly follow the
+// previous one if it starts with a sign or a second dot,
+// like in "1-2" or "0.5.5"
+func (sp *svgPathParser) number() (float64, error) {
+	sp.skipSeparators()
+	start := sp.pos
+	i := sp.pos
+	if i < len(sp.data) && (sp.data[i] == '+' || sp.data[i] == '-') {
+		i++
+	}
+	digits := 0
+	for i < len(sp.data) && isDigit(sp.data[i]) {
+		i++
+		digits++
+	}
+	if i < len(sp.data) && sp.data[i] == '.' {
+		i++
+		for i < len(sp.data) && isDigit(sp.data[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, sp.errorf("expected number")
+	}
+	if i < len(sp.data) && (sp.data[i] == 'e' || sp.data[i] == 'E') {
+		j := i + 1
+		if j < len(sp.data) && (sp.data[j] == '+' || sp.data[j] == '-') {
+			j++
+		}
+		if j < len(sp.data) && isDigit(sp.data[j]) {
+			for j < len(sp.data) && isDigit(sp.data[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	v, err := strconv.ParseFloat(sp.data[start:i], 64)
+	if err != nil {
+		return 0, sp.errorf("%v", err)
+	}
+	sp.pos = i
+	return v, nil
+}
+
+// flag parses an arc flag, which is a single 0 or 1 that
+// doesn't need to be separated from what follows it
+func (sp *svgPathParser) flag() (float64, error) {
+	sp.skipSeparators()
+	if sp.pos < len(sp.data) {
+		switch sp.data[sp.pos] {
+		case '0':
+			sp.pos++
+			return 0, nil
+		case '1':
+			sp.pos++
+			return 1, nil
+		}
+	}
+	return 0, sp.errorf("expected flag")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSVGCommand(c byte) bool {
+	switch c {
+	case 'M', 'm', 'L', 'l', 'H', 'h', 'V', 'v', 'C', 'c', 'S', 's', 'Q', 'q', 'T', 't', 'A', 'a', 'Z', 'z':
+		return true
+	}
+	return false
+}
+
+func svgArgCount(cmd byte) int {
+	switch cmd {
+	case 'H', 'h', 'V', 'v':
+		return 1
+	case 'M', 'm', 'L', 'l', 'T', 't':
+		return 2
+	case 'S', 's', 'Q', 'q':
+		return 4
+	case 'C', 'c':
+		return 6
+	case 'A', 'a':
+		return 7
+	}
+	return 0
+}
+
+// svgArc adds an SVG elliptical arc from p0 to p1 to the path
+// by converting it to the center parameterization, see
+// https://www.w3.org/TR/SVG/implnote.html#ArcConversionEndpointToCenter
+func svgArc(p *Path2D, p0, p1 backendbase.Vec, rx, ry, phi float64, large, sweep bool) {
+	if p0 == p1 {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		p.LineTo(p1[0], p1[1])
+		return
+	}
+
+	sin, cos := math.Sincos(phi)
+	dx, dy := (p0[0]-p1[0])/2, (p0[1]-p1[1])/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+
+	// scale up radii that are too small to reach the end point
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		l = math.Sqrt(l)
+		rx *= l
+		ry *= l
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	k := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cx1 := k * rx * y1 / ry
+	cy1 := -k * ry * x1 / rx
+	cx := cos*cx1 - sin*cy1 + (p0[0]+p1[0])/2
+	cy := sin*cx1 + cos*cy1 + (p0[1]+p1[1])/2
+
+	start := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	end := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx)
+	delta := end - start
+	if sweep && delta < 0 {
+		delta += math.Pi * 2
+	} else if !sweep && delta > 0 {
+		delta -= math.Pi * 2
+	}
+
+	p.Ellipse(cx, cy, rx, ry, phi, start, start+delta, !sweep)
+	p.LineTo(p1[0], p1[1])
+}
@@ -0,0 +1,199 @@
+package canvas
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+func parseSVGPath(t *testing.T, cv *Canvas, data string) *Path2D {
+	t.Helper()
+	p, err := cv.NewPath2DFromSVG(data)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", data, err)
+	}
+	return p
+}
+
+func pathPositions(p *Path2D) []backendbase.Vec {
+	pts := make([]backendbase.Vec, len(p.p))
+	for i, pt := range p.p {
+		pts[i] = pt.pos
+	}
+	return pts
+}
+
+func TestNewPath2DFromSVG(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	cases := []struct {
+		data  string
+		build func(p *Path2D)
+	}{
+		{"M10 20 L30 40 H50 V60 Z", func(p *Path2D) {
+			p.MoveTo(10, 20)
+			p.LineTo(30, 40)
+			p.LineTo(50, 40)
+			p.LineTo(50, 60)
+			p.ClosePath()
+		}},
+		// coordinates after a move are lines
+		{"M10 20 30 40 50 60", func(p *Path2D) {
+			p.MoveTo(10, 20)
+			p.LineTo(30, 40)
+			p.LineTo(50, 60)
+		}},
+		// numbers can follow each other without separators
+		{"M1-2.5.5e1,3", func(p *Path2D) {
+			p.MoveTo(1, -2.5)
+			p.LineTo(5, 3)
+		}},
+		// S and T reflect the last control point of the curve
+		// before them
+		{"M10 10 C20 0 30 0 40 10 S60 20 70 10", func(p *Path2D) {
+			p.MoveTo(10, 10)
+			p.BezierCurveTo(20, 0, 30, 0, 40, 10)
+			p.BezierCurveTo(50, 20, 60, 20, 70, 10)
+		}},
+		{"M10 10 Q20 0 30 10 T50 10 T70 10", func(p *Path2D) {
+			p.MoveTo(10, 10)
+			p.QuadraticCurveTo(20, 0, 30, 10)
+			p.QuadraticCurveTo(40, 20, 50, 10)
+			p.QuadraticCurveTo(60, 0, 70, 10)
+		}},
+		// without a curve of the same kind before them, the
+		// control point is the current point
+		{"M10 10 Q20 0 30 10 S50 0 60 10", func(p *Path2D) {
+			p.MoveTo(10, 10)
+			p.QuadraticCurveTo(20, 0, 30, 10)
+			p.BezierCurveTo(30, 10, 50, 0, 60, 10)
+		}},
+		{"M10 10 C20 0 30 0 40 10 T60 10", func(p *Path2D) {
+			p.MoveTo(10, 10)
+			p.BezierCurveTo(20, 0, 30, 0, 40, 10)
+			p.QuadraticCurveTo(40, 10, 60, 10)
+		}},
+		// arcs with a radius of zero are lines
+		{"M10 10 A0 5 0 0 1 30 10", func(p *Path2D) {
+			p.MoveTo(10, 10)
+			p.LineTo(30, 10)
+		}},
+	}
+	for _, tc := range cases {
+		expected := cv.NewPath2D()
+		tc.build(expected)
+		checkPathPoints(t, tc.data, parseSVGPath(t, cv, tc.data), pathPositions(expected))
+	}
+
+	// the relative commands give the same paths as the
+	// absolute ones
+	for _, tc := range []struct {
+		abs, rel string
+	}{
+		{"M10 20 L30 40 H50 V60 Z", "m10 20 l20 20 h20 v20 z"},
+		{"M10 20 30 40 50 60", "m10 20 20 20 20 20"},
+		{"M10 10 C20 0 30 0 40 10 S60 20 70 10", "m10 10 c10-10 20-10 30 0 s20 10 30 0"},
+		{"M10 10 Q20 0 30 10 T50 10 T70 10", "m10 10 q10-10 20 0 t20 0 t20 0"},
+		{"M10 50 A40 30 20 1 0 90 50", "m10 50 a40 30 20 1 0 80 0"},
+		// a move after closing a subpath is relative to its
+		// start
+		{"M10 10 L20 10 L20 20 Z M15 15 L25 15", "m10 10 l10 0 l0 10 z m5 5 l10 0"},
+	} {
+		checkPathPoints(t, tc.rel, parseSVGPath(t, cv, tc.rel), pathPositions(parseSVGPath(t, cv, tc.abs)))
+	}
+
+	// arc flags don't need separators
+	for _, tc := range []struct {
+		data, separated string
+	}{
+		{"M10 50A40 40 0 0190 50", "M10 50 A40 40 0 0 1 90 50"},
+		{"M10 50a40,40,0,1,0,80,0", "M10 50 a40 40 0 1 0 80 0"},
+		{"M10 50A40 40 0 1090 50", "M10 50 A40 40 0 1 0 90 50"},
+	} {
+		checkPathPoints(t, tc.data, parseSVGPath(t, cv, tc.data), pathPositions(parseSVGPath(t, cv, tc.separated)))
+	}
+}
+
+func TestSVGArc(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	// the arc from 0,0 to 50,50 with a radius of 50 has its
+	// center at 0,50 or 50,0, and the flags decide which one
+	// and which way around it goes
+	r := 50 / math.Sqrt2
+	for _, tc := range []struct {
+		large, sweep int
+		center, mid  backendbase.Vec
+	}{
+		{0, 1, backendbase.Vec{0, 50}, backendbase.Vec{r, 50 - r}},
+		{1, 1, backendbase.Vec{50, 0}, backendbase.Vec{50 + r, -r}},
+		{0, 0, backendbase.Vec{50, 0}, backendbase.Vec{50 - r, r}},
+		{1, 0, backendbase.Vec{0, 50}, backendbase.Vec{-r, 50 + r}},
+	} {
+		data := fmt.Sprintf("M0 0 A50 50 0 %d %d 50 50", tc.large, tc.sweep)
+		p := parseSVGPath(t, cv, data)
+		if end := p.p[len(p.p)-1].pos; end.Sub(backendbase.Vec{50, 50}).Len() > 1e-6 {
+			t.Errorf("%s ends at %v", data, end)
+		}
+		closest := math.Inf(1)
+		for _, pt := range p.p {
+			if d := pt.pos.Sub(tc.center).Len(); math.Abs(d-50) > 1e-6 {
+				t.Errorf("%s has the point %v off the circle around %v", data, pt.pos, tc.center)
+				break
+			}
+			closest = math.Min(closest, pt.pos.Sub(tc.mid).Len())
+		}
+		if closest > 5 {
+			t.Errorf("%s doesn't go through %v", data, tc.mid)
+		}
+	}
+
+	// radii that are too small are scaled up to reach the end
+	p := parseSVGPath(t, cv, "M10 50 A10 10 0 0 1 90 50")
+	for _, pt := range p.p {
+		if d := pt.pos.Sub(backendbase.Vec{50, 50}).Len(); math.Abs(d-40) > 1e-6 {
+			t.Errorf("the scaled up arc has the point %v off the circle", pt.pos)
+			break
+		}
+	}
+}
+
+func TestNewPath2DFromSVGErrors(t *testing.T) {
+	cv := New(softwarebackend.New(100, 100))
+	if p := parseSVGPath(t, cv, " \n"); len(p.p) != 0 {
+		t.Errorf("empty path data has %d points", len(p.p))
+	}
+
+	// the error has the offset of the problem, and the path up
+	// to it is returned
+	for _, tc := range []struct {
+		data    string
+		offset  int
+		partial string
+	}{
+		{"L10 10", 0, ""},
+		{"10 10", 0, ""},
+		{"M10 10 L20 20 L30", 17, "M10 10 L20 20"},
+		{"M10 10 L20 x", 11, "M10 10"},
+		{"M10 10 Q20 20 30", 16, "M10 10"},
+		{"M10 10 A10 10 0 2 1 20 20", 16, "M10 10"},
+		{"M10 10 L20 20 Z 20 20", 16, "M10 10 L20 20 Z"},
+		{"M10 10 L20 20 X", 14, "M10 10 L20 20"},
+	} {
+		p, err := cv.NewPath2DFromSVG(tc.data)
+		if err == nil {
+			t.Errorf("%q didn't return an error", tc.data)
+			continue
+		}
+		if prefix := fmt.Sprintf("invalid path data at %d:", tc.offset); !strings.HasPrefix(err.Error(), prefix) {
+			t.Errorf("%q returned %q instead of an error at %d", tc.data, err, tc.offset)
+		}
+		if p == nil {
+			t.Errorf("%q didn't return a path", tc.data)
+			continue
+		}
+		checkPathPoints(t, tc.data, p, pathPositions(parseSVGPath(t, cv, tc.partial)))
+	}
+}
@@ -1,6 +1,7 @@
 package canvas
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"image"
@@ -11,10 +12,12 @@ import (
 	"time"
 	"unsafe"
 
+	otfont "github.com/go-text/typesetting/font"
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
 	"github.com/tfriedel6/canvas/backend/backendbase"
 	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
 	"golang.org/x/image/math/fixed"
 )
 
@@ -22,11 +25,47 @@ import (
 // SetFont method
 type Font struct {
 	font *truetype.Font
+	face *otfont.Face
+
+	// cff is used instead of font for fonts with CFF
+	// outlines, which the freetype package can't load. If
+	// both are nil, the outlines are loaded from face,
+	// which is the case for instances of variable fonts
+	cff *sfnt.Font
+
+	// base is the font that an instance of a variable font
+	// was created from with the variations, and instances
+	// are the instances of a base font by coordinates
+	base       *Font
+	variations []otfont.Variation
+	instances  map[string]*Font
+
+	// hasColor is set if the font has COLR, CBDT or sbix
+	// glyphs, which are looked up once and cached in
+	// colorGlyphs
+	hasColor    bool
+	colorGlyphs map[truetype.Index]*colorGlyph
+
+	// ascent and descent from the hhea or OS/2 table
+	// relative to the em size
+	ascent, descent float64
+
+	// top and thickness of the underline and strikeout
+	// relative to the em size, going up from the baseline
+	underlinePosition, underlineThickness float64
+	strikeoutPosition, strikeoutThickness float64
 }
 
+// FontFamily is an ordered list of fonts that can be
+// passed to the SetFont method. Each rune is drawn with the
+// first font that has a glyph for it, so that for example
+// a Latin font can fall back to a CJK or a symbol font
+type FontFamily []*Font
+
 type fontKey struct {
-	font *Font
-	size fixed.Int26_6
+	font    *Font
+	size    fixed.Int26_6
+	hinting font.Hinting
 }
 
 type frCache struct {
@@ -69,12 +108,28 @@ var defaultFont *Font
 var baseFontSize = fixed.I(42)
 
 // LoadFont loads a font and returns the result. The font
-// can be a file name or a byte slice in TTF format
+// can be a file name or a byte slice in TTF, OTF, WOFF or
+// WOFF2 format. Of font collections like TTC files, the
+// first font is loaded
 func (cv *Canvas) LoadFont(src interface{}) (*Font, error) {
+	return cv.LoadFontIndex(src, 0)
+}
+
+// LoadFontIndex loads the font with the given index from a
+// font collection like a TTC file, or from a WOFF2 file that
+// contains a collection. All other fonts only have index 0
+func (cv *Canvas) LoadFontIndex(src interface{}, index int) (*Font, error) {
+	key := src
+	if index != 0 {
+		key = fontIndexKey{src: src, index: index}
+	}
 	if f, ok := src.(*Font); ok {
+		if index != 0 {
+			return nil, errFontIndex
+		}
 		return f, nil
 	} else if _, ok := src.([]byte); !ok {
-		if f, ok := cv.fonts[src]; ok {
+		if f, ok := cv.fonts[key]; ok {
 			return f, nil
 		}
 	}
@@ -82,23 +137,25 @@ func (cv *Canvas) LoadFont(src interface{}) (*Font, error) {
 	var f *Font
 	switch v := src.(type) {
 	case *truetype.Font:
-		f = &Font{font: v}
+		if index != 0 {
+			return nil, errFontIndex
+		}
+		f = newFont(&Font{font: v}, nil)
 	case string:
 		data, err := ioutil.ReadFile(v)
 		if err != nil {
 			return nil, err
 		}
-		font, err := freetype.ParseFont(data)
+		f, err = parseFont(data, index)
 		if err != nil {
 			return nil, err
 		}
-		f = &Font{font: font}
 	case []byte:
-		font, err := freetype.ParseFont(v)
+		var err error
+		f, err = parseFont(v, index)
 		if err != nil {
 			return nil, err
 		}
-		f = &Font{font: font}
 	default:
 		return nil, errors.New("Unsupported source type")
 	}
@@ -107,11 +164,188 @@ func (cv *Canvas) LoadFont(src interface{}) (*Font, error) {
 	}
 
 	if _, ok := src.([]byte); !ok {
-		cv.fonts[src] = f
+		cv.fonts[key] = f
 	}
 	return f, nil
 }
 
+// fontIndexKey is the key of fonts in collections other than
+// the first one in the font cache
+type fontIndexKey struct {
+	src   interface{}
+	index int
+}
+
+// parseFont parses the font with the given index in the data.
+// Fonts with TrueType outlines are loaded with the freetype
+// package to keep its hinting, fonts with CFF outlines with
+// the sfnt package, and fonts with CFF2 outlines or without
+// outlines only with the typesetting package
+func parseFont(data []byte, index int) (*Font, error) {
+	data, err := sfntData(data, index)
+	if err != nil {
+		return nil, err
+	}
+	if string(data[:4]) == "OTTO" {
+		cff, err := sfnt.Parse(data)
+		if err != nil {
+			// the sfnt package can't load CFF2 outlines
+			if face := parseFace(data); face != nil {
+				return newFont(&Font{face: face}, nil), nil
+			}
+			return nil, err
+		}
+		return newFont(&Font{cff: cff}, data), nil
+	}
+	if !sfntHasTable(data, "glyf") {
+		// fonts with only bitmap glyphs like CBDT emoji fonts
+		// have no outlines that the freetype package can load
+		if face := parseFace(data); face != nil {
+			return newFont(&Font{face: face}, nil), nil
+		}
+	}
+	font, err := freetype.ParseFont(data)
+	if err != nil {
+		return nil, err
+	}
+	return newFont(&Font{font: font}, data), nil
+}
+
+func newFont(f *Font, data []byte) *Font {
+	if data != nil {
+		f.face = parseFace(data)
+	}
+
+	const size = 64
+	metrics := f.metrics(size)
+	f.ascent = float64(metrics.Ascent) / 64 / size
+	f.descent = float64(metrics.Descent) / 64 / size
+	f.underlinePosition, f.underlineThickness = -0.1, 0.05
+	f.strikeoutPosition, f.strikeoutThickness = 0.3, 0.05
+	if f.face != nil {
+		upem := float64(f.face.Upem())
+		// this also takes OS/2 typo metrics into account
+		if ext, ok := f.face.FontHExtents(); ok {
+			f.ascent = float64(ext.Ascender) / upem
+			f.descent = -float64(ext.Descender) / upem
+		}
+		if t := f.face.LineMetric(otfont.UnderlineThickness); t > 0 {
+			f.underlinePosition = float64(f.face.LineMetric(otfont.UnderlinePosition)) / upem
+			f.underlineThickness = float64(t) / upem
+		}
+		if t := f.face.LineMetric(otfont.StrikethroughThickness); t > 0 {
+			f.strikeoutPosition = float64(f.face.LineMetric(otfont.StrikethroughPosition)) / upem
+			f.strikeoutThickness = float64(t) / upem
+		}
+		f.hasColor = f.face.COLR != nil || len(f.face.BitmapSizes()) > 0
+	}
+	return f
+}
+
+// metrics returns the metrics of the font at the given size
+// in pixels
+func (f *Font) metrics(size float64) font.Metrics {
+	switch {
+	case f.font != nil:
+		return truetype.NewFace(f.font, &truetype.Options{Size: size}).Metrics()
+	case f.cff != nil:
+		metrics, _ := f.cff.Metrics(nil, fixed.Int26_6(math.Round(size*64)), font.HintingNone)
+		return metrics
+	}
+	ext, _ := f.face.FontHExtents()
+	scale := size * 64 / float64(f.face.Upem())
+	return font.Metrics{
+		Height:  fixed.Int26_6(math.Round(float64(ext.Ascender-ext.Descender+ext.LineGap) * scale)),
+		Ascent:  fixed.Int26_6(math.Round(float64(ext.Ascender) * scale)),
+		Descent: fixed.Int26_6(math.Round(float64(-ext.Descender) * scale)),
+	}
+}
+
+// index returns the glyph index of the rune, or 0 if the
+// font doesn't have a glyph for it
+func (f *Font) index(rn rune) truetype.Index {
+	switch {
+	case f.face != nil:
+		// the freetype package only reads one cmap subtable,
+		// which misses runes like emoji outside of the BMP in
+		// fonts that have both
+		idx, _ := f.face.NominalGlyph(rn)
+		return truetype.Index(idx)
+	case f.cff != nil:
+		idx, _ := f.cff.GlyphIndex(nil, rn)
+		return truetype.Index(idx)
+	}
+	return f.font.Index(rn)
+}
+
+// bounds returns the union of all glyph bounds at the given
+// size with the y axis going down. For fonts that are loaded
+// from the face, the bounds of the em box are returned, since
+// the bounds of the instances are not known
+func (f *Font) bounds(size fixed.Int26_6) fixed.Rectangle26_6 {
+	switch {
+	case f.font != nil:
+		b := f.font.Bounds(size)
+		return fixed.Rectangle26_6{
+			Min: fixed.Point26_6{X: b.Min.X, Y: -b.Max.Y},
+			Max: fixed.Point26_6{X: b.Max.X, Y: -b.Min.Y},
+		}
+	case f.cff != nil:
+		b, _ := f.cff.Bounds(nil, size, font.HintingNone)
+		return b
+	}
+	return fixed.Rectangle26_6{
+		Min: fixed.Point26_6{Y: -fixed.Int26_6(math.Ceil(f.ascent * float64(size)))},
+		Max: fixed.Point26_6{X: size, Y: fixed.Int26_6(math.Ceil(f.descent * float64(size)))},
+	}
+}
+
+// loadOutline loads the outline segments and advance of a
+// glyph of a font that is not loaded with the freetype
+// package. The segments may use the buffer
+func (f *Font) loadOutline(buf *sfnt.Buffer, idx truetype.Index, size fixed.Int26_6, hinting font.Hinting) (sfnt.Segments, fixed.Int26_6, error) {
+	if f.cff != nil {
+		advance, err := f.cff.GlyphAdvance(buf, sfnt.GlyphIndex(idx), size, hinting)
+		if err != nil {
+			return nil, 0, err
+		}
+		segs, err := f.cff.LoadGlyph(buf, sfnt.GlyphIndex(idx), size, nil)
+		return segs, advance, err
+	}
+
+	outline, ok := f.face.GlyphDataOutline(otfont.GID(idx))
+	if !ok {
+		return nil, 0, errors.New("glyph has no outline")
+	}
+	scale := float64(size) / float64(f.face.Upem())
+	segs := make(sfnt.Segments, len(outline.Segments))
+	for i, seg := range outline.Segments {
+		// the ops are in the same order in both packages
+		segs[i].Op = sfnt.SegmentOp(seg.Op)
+		for j, p := range seg.Args {
+			segs[i].Args[j] = fixed.Point26_6{
+				X: fixed.Int26_6(math.Round(float64(p.X) * scale)),
+				Y: fixed.Int26_6(math.Round(-float64(p.Y) * scale)),
+			}
+		}
+	}
+	advance := fixed.Int26_6(math.Round(float64(f.face.HorizontalAdvance(otfont.GID(idx))) * scale))
+	if hinting != font.HintingNone {
+		advance = roundFixed(advance)
+	}
+	return segs, advance, nil
+}
+
+// parseFace parses the font data for shaping. Fonts that
+// can't be parsed are drawn without shaping
+func parseFace(data []byte) *otfont.Face {
+	face, err := otfont.ParseTTF(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return face
+}
+
 func (cv *Canvas) getFont(src interface{}) *Font {
 	f, err := cv.LoadFont(src)
 	if err != nil {
@@ -124,7 +358,7 @@ func (cv *Canvas) getFont(src interface{}) *Font {
 }
 
 func (cv *Canvas) getFRContext(font *Font, size fixed.Int26_6) *frContext {
-	k := fontKey{font: font, size: size}
+	k := fontKey{font: font, size: size, hinting: cv.hinting()}
 	if frctx, ok := cv.fontCtxs[k]; ok {
 		frctx.lastUsed = time.Now()
 		return frctx.ctx
@@ -133,7 +367,8 @@ func (cv *Canvas) getFRContext(font *Font, size fixed.Int26_6) *frContext {
 	cv.reduceCache(Performance.CacheSize, 0)
 	frctx := newFRContext()
 	frctx.fontSize = size
-	frctx.f = font.font
+	frctx.hinting = k.hinting
+	frctx.f = font
 	frctx.recalc()
 
 	cv.fontCtxs[k] = &frCache{ctx: frctx, lastUsed: time.Now()}
@@ -141,34 +376,106 @@ func (cv *Canvas) getFRContext(font *Font, size fixed.Int26_6) *frContext {
 	return frctx
 }
 
+// resolveFont returns the font and its fallbacks for any of
+// the font sources accepted by SetFont
+func (cv *Canvas) resolveFont(src interface{}) (*Font, []*Font) {
+	switch v := src.(type) {
+	case nil:
+		return defaultFont, nil
+	case FontFamily:
+		return fontFamily(v)
+	case []*Font:
+		return fontFamily(v)
+	case []interface{}:
+		family := make(FontFamily, 0, len(v))
+		for _, fsrc := range v {
+			if f := cv.getFont(fsrc); f != nil {
+				family = append(family, f)
+			}
+		}
+		return fontFamily(family)
+	}
+	return cv.getFont(src), nil
+}
+
+func fontFamily(family FontFamily) (*Font, []*Font) {
+	if len(family) == 0 {
+		return defaultFont, nil
+	}
+	return family[0], family[1:]
+}
+
 // FillText draws the given string at the given coordinates
-// using the currently set font and font height
+// using the currently set font and font height. Color glyphs
+// like emoji are drawn in their own colors
 func (cv *Canvas) FillText(str string, x, y float64) {
-	if cv.state.font.font == nil {
+	if cv.state.font == nil {
 		return
 	}
+	cv.fillText(cv.stringSource(str), []drawStyle{cv.state.fill}, x, y, true)
+}
+
+// textSource returns the glyphs of a text shaped at the given
+// font size and whether its base direction is right-to-left.
+// The text is shaped again at the size it is rendered at
+type textSource func(size fixed.Int26_6) ([]textGlyph, bool)
+
+func (cv *Canvas) stringSource(str string) textSource {
+	return func(size fixed.Int26_6) ([]textGlyph, bool) {
+		return cv.shapeText(str, size)
+	}
+}
 
+// fillText draws the text from the source, with each glyph
+// using the style at the index of its span. If align is set,
+// x and y are the alignment point given by the text align and
+// baseline, otherwise they are the start of the text on the
+// alphabetic baseline
+func (cv *Canvas) fillText(src textSource, styles []drawStyle, x, y float64, align bool) {
 	scaleX := backendbase.Vec{cv.state.transform[0], cv.state.transform[1]}.Len()
 	scaleY := backendbase.Vec{cv.state.transform[2], cv.state.transform[3]}.Len()
 	scale := (scaleX + scaleY) * 0.5
-	fontSize := fixed.Int26_6(math.Round(float64(cv.state.fontSize) * scale))
 
-	// if the font size is large or rotated or skewed in some way, use the
+	// if the text is rotated or skewed in some way, use the
 	// triangulated font rendering
-	if fontSize > fixed.I(25) {
-		cv.fillText2(str, x, y)
-		return
-	}
 	mat := cv.state.transform
-	if mat[1] != 0 || mat[2] != 0 || mat[0] != mat[3] {
-		cv.fillText2(str, x, y)
-		return
+	rotated := mat[1] != 0 || mat[2] != 0 || mat[0] != mat[3]
+	if rotated {
+		scale = 1
 	}
+	fontSize := fixed.Int26_6(math.Round(float64(cv.state.fontSize) * scale))
 
-	frc := cv.getFRContext(cv.state.font, fontSize)
-	fnt := cv.state.font.font
+	glyphs, rtl := src(fontSize)
+	if align {
+		cv.alignText(glyphs, rtl, &x, &y, scale)
+	}
+	for start := 0; start < len(glyphs); {
+		end := start + 1
+		large := glyphs[start].size > fixed.I(25)
+		for end < len(glyphs) && glyphs[end].span == glyphs[start].span {
+			large = large || glyphs[end].size > fixed.I(25)
+			end++
+		}
 
-	strWidth, strHeight, textOffset, str := cv.measureTextRendering(str, &x, &y, frc, scale)
+		// large text is also triangulated
+		style := &styles[glyphs[start].span]
+		if rotated || large {
+			cv.fillGlyphTris(glyphs[start:end], style, x, y, scale)
+		} else {
+			cv.fillGlyphs(glyphs[start:end], style, x, y, scale)
+		}
+		cv.fillColorGlyphs(glyphs[start:end], style, x, y, scale)
+		for _, g := range glyphs[start:end] {
+			x += float64(g.advance) / 64 / scale
+		}
+		start = end
+	}
+}
+
+// fillGlyphs renders the glyphs into the text image and draws
+// it with the given style
+func (cv *Canvas) fillGlyphs(glyphs []textGlyph, style *drawStyle, x, y, scale float64) {
+	strWidth, strHeight, textOffset, glyphs := cv.measureTextRendering(glyphs, &x, &y, scale)
 	if strWidth <= 0 || strHeight <= 0 {
 		return
 	}
@@ -197,34 +504,20 @@ func (cv *Canvas) FillText(str string, x, y float64) {
 	}
 
 	// render the string into textImage
-	curX := x
 	p := fixed.Point26_6{}
-	prev, hasPrev := truetype.Index(0), false
-	for _, rn := range str {
-		idx := fnt.Index(rn)
-		if idx == 0 {
-			prev = 0
-			hasPrev = false
+	for _, g := range glyphs {
+		if g.font.colorGlyph(g.idx) != nil {
+			p.X += g.advance
 			continue
 		}
-		if hasPrev {
-			kern := fnt.Kern(fontSize, prev, idx)
-			if frc.hinting != font.HintingNone {
-				kern = (kern + 32) &^ 63
-			}
-			curX += float64(kern) / 64
-		}
-		advance, mask, offset, err := frc.glyph(idx, p)
+		frc := cv.getFRContext(g.font, g.size)
+		_, mask, offset, err := frc.glyph(g.idx, p.Add(g.offset))
+		p.X += g.advance
 		if err != nil {
-			prev = 0
-			hasPrev = false
 			continue
 		}
-		p.X += advance
 
 		draw.Draw(textImage, mask.Bounds().Add(offset).Sub(textOffset), mask, image.ZP, draw.Over)
-
-		curX += float64(advance) / 64
 	}
 
 	// render textImage to the screen
@@ -238,54 +531,34 @@ func (cv *Canvas) FillText(str string, x, y float64) {
 
 	cv.drawShadow(pts[:], mask, false)
 
-	stl := cv.backendFillStyle(&cv.state.fill, 1)
+	stl := cv.backendFillStyle(style, 1)
 	cv.b.FillImageMask(&stl, mask, pts)
 }
 
-func (cv *Canvas) fillText2(str string, x, y float64) {
-	if cv.state.font == nil {
-		return
-	}
-
-	frc := cv.getFRContext(cv.state.font, cv.state.fontSize)
-	fnt := cv.state.font.font
-
-	strWidth, strHeight, _, str := cv.measureTextRendering(str, &x, &y, frc, 1)
+// fillGlyphTris draws the glyphs as triangles with the given
+// style
+func (cv *Canvas) fillGlyphTris(glyphs []textGlyph, style *drawStyle, x, y, scale float64) {
+	strWidth, strHeight, _, glyphs := cv.measureTextRendering(glyphs, &x, &y, scale)
 	if strWidth <= 0 || strHeight <= 0 {
 		return
 	}
 
-	scale := float64(cv.state.fontSize) / float64(baseFontSize)
-	scaleMat := backendbase.MatScale(backendbase.Vec{scale, scale})
-
-	prev, hasPrev := truetype.Index(0), false
-	for _, rn := range str {
-		idx := fnt.Index(rn)
-		if idx == 0 {
-			idx = fnt.Index(' ')
-		}
-
-		if hasPrev {
-			kern := fnt.Kern(cv.state.fontSize, prev, idx)
-			if frc.hinting != font.HintingNone {
-				kern = (kern + 32) &^ 63
-			}
-			x += float64(kern) / 64
-		}
-		advance, _, err := frc.glyphMeasure(idx, fixed.Point26_6{})
-		if err != nil {
+	for _, g := range glyphs {
+		gx := x + float64(g.offset.X)/64/scale
+		gy := y + float64(g.offset.Y)/64/scale
+		x += float64(g.advance) / 64 / scale
+		if g.font.colorGlyph(g.idx) != nil {
 			continue
 		}
 
-		tris := cv.runeTris(rn)
-		tf := scaleMat.Mul(backendbase.MatTranslate(backendbase.Vec{x, y})).Mul(cv.state.transform)
+		glyphScale := float64(g.size) / scale / float64(baseFontSize)
+		scaleMat := backendbase.MatScale(backendbase.Vec{glyphScale, glyphScale})
+		tris := cv.glyphTris(g.font, g.idx)
+		tf := scaleMat.Mul(backendbase.MatTranslate(backendbase.Vec{gx, gy})).Mul(cv.state.transform)
 		cv.drawShadow(tris, nil, false)
-		stl := cv.backendFillStyle(&cv.state.fill, 1)
+		stl := cv.backendFillStyle(style, 1)
 		cv.b.Fill(&stl, tris, tf, false)
-
-		x += float64(advance) / 64
 	}
-
 }
 
 // StrokeText draws the given string at the given coordinates
@@ -295,69 +568,132 @@ func (cv *Canvas) StrokeText(str string, x, y float64) {
 	if cv.state.font == nil {
 		return
 	}
+	cv.strokeText(cv.stringSource(str), []drawStyle{cv.state.stroke}, x, y, true)
+}
 
-	frc := cv.getFRContext(cv.state.font, cv.state.fontSize)
-	fnt := cv.state.font.font
-
-	strWidth, strHeight, _, str := cv.measureTextRendering(str, &x, &y, frc, 1)
+// strokeText draws the outline of the text from the source,
+// with the styles, x, y and align as in fillText
+func (cv *Canvas) strokeText(src textSource, styles []drawStyle, x, y float64, align bool) {
+	glyphs, rtl := src(cv.state.fontSize)
+	if align {
+		cv.alignText(glyphs, rtl, &x, &y, 1)
+	}
+	strWidth, strHeight, _, glyphs := cv.measureTextRendering(glyphs, &x, &y, 1)
 	if strWidth <= 0 || strHeight <= 0 {
 		return
 	}
 
-	scale := float64(cv.state.fontSize) / float64(baseFontSize)
-	scaleMat := backendbase.MatScale(backendbase.Vec{scale, scale})
+	stroke := cv.state.stroke
+	defer func() { cv.state.stroke = stroke }()
 
-	prev, hasPrev := truetype.Index(0), false
-	for _, rn := range str {
-		idx := fnt.Index(rn)
-		if idx == 0 {
-			idx = fnt.Index(' ')
-		}
+	for _, g := range glyphs {
+		gx := x + float64(g.offset.X)/64
+		gy := y + float64(g.offset.Y)/64
+		x += float64(g.advance) / 64
 
-		if hasPrev {
-			kern := fnt.Kern(cv.state.fontSize, prev, idx)
-			if frc.hinting != font.HintingNone {
-				kern = (kern + 32) &^ 63
-			}
-			x += float64(kern) / 64
-		}
-		advance, _, err := frc.glyphMeasure(idx, fixed.Point26_6{})
-		if err != nil {
-			continue
-		}
-
-		path := cv.runePath(rn)
-		tf := scaleMat.Mul(backendbase.MatTranslate(backendbase.Vec{x, y})).Mul(cv.state.transform)
+		scale := float64(g.size) / float64(baseFontSize)
+		scaleMat := backendbase.MatScale(backendbase.Vec{scale, scale})
+		path := cv.glyphPath(g.font, g.idx)
+		tf := scaleMat.Mul(backendbase.MatTranslate(backendbase.Vec{gx, gy})).Mul(cv.state.transform)
+		cv.state.stroke = styles[g.span]
 		cv.strokePath(path, tf, backendbase.Mat{}, false)
+	}
 
-		x += float64(advance) / 64
+}
+
+// TextPath returns the outline of the given string as a path,
+// as FillText would draw it at the given coordinates with the
+// current font, font size, text align and baseline, direction,
+// spacing and kerning. The path is not transformed, so that
+// FillPath draws it where FillText would. It can also be
+// stroked, used for clipping or hit tested like other paths
+func (cv *Canvas) TextPath(str string, x, y float64) *Path2D {
+	path := &Path2D{cv: cv, p: make([]pathPoint, 0, 100), standalone: true, noSelfIntersection: true}
+	if cv.state.font == nil {
+		return path
+	}
+	glyphs, rtl := cv.shapeText(str, cv.state.fontSize)
+	cv.alignText(glyphs, rtl, &x, &y, 1)
+	cv.addGlyphPaths(path, glyphs, x, y)
+	return path
+}
+
+// TextPath returns the outline of the given string in the font
+// at the given size as a path, starting at x on the alphabetic
+// baseline at y. Unlike Canvas.TextPath it ignores the font,
+// text align and baseline of the canvas, but the direction,
+// spacing and kerning of the canvas are used
+func (f *Font) TextPath(cv *Canvas, str string, size, x, y float64) *Path2D {
+	path := &Path2D{cv: cv, p: make([]pathPoint, 0, 100), standalone: true, noSelfIntersection: true}
+	runes := []rune(str)
+	glyphs, _ := cv.shapeSpans(runes, []shapeSpan{{
+		end:  len(runes),
+		font: f,
+		size: fixed.Int26_6(math.Round(size * 64)),
+	}}, 1)
+	cv.addGlyphPaths(path, glyphs, x, y)
+	return path
+}
+
+// addGlyphPaths adds the outlines of the glyphs starting at x
+// on the alphabetic baseline at y to the path
+func (cv *Canvas) addGlyphPaths(path *Path2D, glyphs []textGlyph, x, y float64) {
+	for _, g := range glyphs {
+		gx := x + float64(g.offset.X)/64
+		gy := y + float64(g.offset.Y)/64
+		x += float64(g.advance) / 64
+
+		scale := float64(g.size) / float64(baseFontSize)
+		tf := backendbase.MatScale(backendbase.Vec{scale, scale}).Mul(backendbase.MatTranslate(backendbase.Vec{gx, gy}))
+		path.addPath(cv.glyphPath(g.font, g.idx), tf)
 	}
+}
+
+// alignText moves x and y from the alignment point given by
+// the text align and baseline to the start of the glyphs on
+// the alphabetic baseline, where Start and End depend on the
+// direction
+func (cv *Canvas) alignText(glyphs []textGlyph, rtl bool, x, y *float64, scale float64) {
+	*x -= cv.textAlignOffset(cv.alignWidth(glyphs)/scale, rtl)
+	*y += cv.textBaselineOffset(cv.state.textBaseline)
+}
 
+// alignWidth returns the width that the text align is applied
+// to, which goes from the left edge of the first glyph to the
+// rounded up end of the advances
+func (cv *Canvas) alignWidth(glyphs []textGlyph) float64 {
+	if len(glyphs) == 0 {
+		return 0
+	}
+	var width fixed.Int26_6
+	for _, g := range glyphs {
+		width += g.advance
+	}
+	first := glyphs[0]
+	bounds, err := first.bounds(cv.getFRContext(first.font, first.size), fixed.Point26_6{})
+	if err != nil {
+		return float64(width.Ceil())
+	}
+	return float64(width.Ceil() - bounds.Min.X)
 }
 
-func (cv *Canvas) measureTextRendering(str string, x, y *float64, frc *frContext, scale float64) (int, int, image.Point, string) {
+// measureTextRendering measures the glyphs at their font
+// size. It returns the size and offset of the rendered text
+// and only the glyphs that are inside the visible area, with
+// x moved to the first of them
+func (cv *Canvas) measureTextRendering(glyphs []textGlyph, x, y *float64, scale float64) (int, int, image.Point, []textGlyph) {
 	// measure rendered text size
 	var p fixed.Point26_6
-	prev, hasPrev := truetype.Index(0), false
 	var textOffset image.Point
 	var strWidth, strMaxY int
 	strMinY := math.MaxInt32
-	for i, rn := range str {
-		idx := frc.f.Index(rn)
-		if idx == 0 {
-			idx = frc.f.Index(' ')
-		}
-		advance, bounds, err := frc.glyphMeasure(idx, p)
+	for i, g := range glyphs {
+		frc := cv.getFRContext(g.font, g.size)
+		bounds, err := g.bounds(frc, p)
+		p.X += g.advance
 		if err != nil {
 			continue
 		}
-		var kern fixed.Int26_6
-		if hasPrev {
-			kern = frc.f.Kern(frc.fontSize, prev, idx)
-			if frc.hinting != font.HintingNone {
-				kern = (kern + 32) &^ 63
-			}
-		}
 
 		if i == 0 {
 			textOffset.X = bounds.Min.X
@@ -368,57 +704,29 @@ func (cv *Canvas) measureTextRendering(str string, x, y *float64, frc *frContext
 		if bounds.Min.Y < strMinY {
 			strMinY = bounds.Min.Y
 		}
-		p.X += advance + kern
 	}
 	textOffset.Y = strMinY
 	strWidth = p.X.Ceil() - textOffset.X
 	strHeight := strMaxY - textOffset.Y
 
 	if strWidth <= 0 || strHeight <= 0 {
-		return 0, 0, image.Point{}, ""
+		return 0, 0, image.Point{}, nil
 	}
 
-	// calculate offsets
-	if cv.state.textAlign == Center {
-		*x -= float64(strWidth) / scale * 0.5
-	} else if cv.state.textAlign == Right || cv.state.textAlign == End {
-		*x -= float64(strWidth) / scale
-	}
-	metrics := cv.state.fontMetrics
-	switch cv.state.textBaseline {
-	case Alphabetic:
-	case Middle:
-		*y += -float64(metrics.Descent)/64 + float64(metrics.Height)*0.5/64
-	case Top, Hanging:
-		*y += -float64(metrics.Descent)/64 + float64(metrics.Height)/64
-	case Bottom, Ideographic:
-		*y += -float64(metrics.Descent) / 64
-	}
-
-	// find out which characters are inside the visible area
+	// find out which glyphs are inside the visible area
 	p = fixed.Point26_6{}
-	prev, hasPrev = truetype.Index(0), false
 	var insideCount int
-	strFrom, strTo := 0, len(str)
+	strFrom, strTo := 0, len(glyphs)
 	curInside := false
 	curX := *x
-	for i, rn := range str {
-		idx := frc.f.Index(rn)
-		if idx == 0 {
-			idx = frc.f.Index(' ')
-		}
-		advance, bounds, err := frc.glyphMeasure(idx, p)
+	for i, g := range glyphs {
+		frc := cv.getFRContext(g.font, g.size)
+		bounds, err := g.bounds(frc, p)
 		if err != nil {
+			p.X += g.advance
+			curX += float64(g.advance) / 64 / scale
 			continue
 		}
-		var kern fixed.Int26_6
-		if hasPrev {
-			kern = frc.f.Kern(frc.fontSize, prev, idx)
-			if frc.hinting != font.HintingNone {
-				kern = (kern + 32) &^ 63
-			}
-			curX += float64(kern) / 64 / scale
-		}
 
 		w, h := cv.b.Size()
 		fw, fh := float64(w), float64(h)
@@ -444,37 +752,27 @@ func (cv *Canvas) measureTextRendering(str string, x, y *float64, frc *frContext
 			break
 		}
 
-		p.X += advance + kern
-		curX += float64(advance) / 64 / scale
+		p.X += g.advance
+		curX += float64(g.advance) / 64 / scale
 	}
 
 	if strFrom == strTo || insideCount == 0 {
-		return 0, 0, image.Point{}, ""
+		return 0, 0, image.Point{}, nil
 	}
 
-	// if necessary, measure rendered text size again with the substring
-	if strFrom > 0 || strTo < len(str) {
-		str = str[strFrom:strTo]
+	// if necessary, measure rendered text size again with the visible glyphs
+	if strFrom > 0 || strTo < len(glyphs) {
+		glyphs = glyphs[strFrom:strTo]
 		p = fixed.Point26_6{}
-		prev, hasPrev = truetype.Index(0), false
 		textOffset = image.Point{}
 		strWidth, strMaxY = 0, 0
-		for i, rn := range str {
-			idx := frc.f.Index(rn)
-			if idx == 0 {
-				idx = frc.f.Index(' ')
-			}
-			advance, bounds, err := frc.glyphMeasure(idx, p)
+		for i, g := range glyphs {
+			frc := cv.getFRContext(g.font, g.size)
+			bounds, err := g.bounds(frc, p)
+			p.X += g.advance
 			if err != nil {
 				continue
 			}
-			var kern fixed.Int26_6
-			if hasPrev {
-				kern = frc.f.Kern(frc.fontSize, prev, idx)
-				if frc.hinting != font.HintingNone {
-					kern = (kern + 32) &^ 63
-				}
-			}
 
 			if i == 0 {
 				textOffset.X = bounds.Min.X
@@ -485,26 +783,23 @@ func (cv *Canvas) measureTextRendering(str string, x, y *float64, frc *frContext
 			if bounds.Max.Y > strMaxY {
 				strMaxY = bounds.Max.Y
 			}
-			p.X += advance + kern
 		}
 		strWidth = p.X.Ceil() - textOffset.X
 		strHeight = strMaxY - textOffset.Y
 
 		if strWidth <= 0 || strHeight <= 0 {
-			return 0, 0, image.Point{}, ""
+			return 0, 0, image.Point{}, nil
 		}
 	}
 
-	return strWidth, strHeight, textOffset, str
+	return strWidth, strHeight, textOffset, glyphs
 }
 
-func (cv *Canvas) runePath(rn rune) *Path2D {
-	idx := cv.state.font.font.Index(rn)
-	if idx == 0 {
-		idx = cv.state.font.font.Index(' ')
-	}
-
-	if cache, ok := cv.fontPathCache[cv.state.font]; ok {
+// glyphPath returns the outline of the glyph at the base
+// font size
+func (cv *Canvas) glyphPath(fnt *Font, idx truetype.Index) *Path2D {
+	key := fontKey{font: fnt, hinting: cv.hinting()}
+	if cache, ok := cv.fontPathCache[key]; ok {
 		if path, ok := cache.cache[idx]; ok {
 			cache.lastUsed = time.Now()
 			return path
@@ -516,7 +811,13 @@ func (cv *Canvas) runePath(rn rune) *Path2D {
 	const scale = 1.0 / 64.0
 
 	var gb truetype.GlyphBuf
-	gb.Load(cv.state.font.font, baseFontSize, idx, font.HintingFull)
+	if fnt.font == nil {
+		for _, contour := range glyphContours(fnt, idx) {
+			appendSegments(path, contour)
+		}
+	} else {
+		gb.Load(fnt.font, baseFontSize, idx, key.hinting)
+	}
 
 	from := 0
 	for _, to := range gb.Ends {
@@ -574,10 +875,10 @@ func (cv *Canvas) runePath(rn rune) *Path2D {
 		from = to
 	}
 
-	cache, ok := cv.fontPathCache[cv.state.font]
+	cache, ok := cv.fontPathCache[key]
 	if !ok {
 		cache = &fontPathCache{cache: make(map[truetype.Index]*Path2D, 1024)}
-		cv.fontPathCache[cv.state.font] = cache
+		cv.fontPathCache[key] = cache
 	}
 	cache.lastUsed = time.Now()
 	cache.cache[idx] = path
@@ -585,13 +886,52 @@ func (cv *Canvas) runePath(rn rune) *Path2D {
 	return path
 }
 
-func (cv *Canvas) runeTris(rn rune) []backendbase.Vec {
-	idx := cv.state.font.font.Index(rn)
-	if idx == 0 {
-		idx = cv.state.font.font.Index(' ')
+// glyphContours returns the contours of a glyph of a font
+// that is not loaded with the freetype package at the base
+// font size
+func glyphContours(fnt *Font, idx truetype.Index) []sfnt.Segments {
+	segs, _, err := fnt.loadOutline(nil, idx, baseFontSize, font.HintingNone)
+	if err != nil {
+		return nil
 	}
+	var contours []sfnt.Segments
+	for i, seg := range segs {
+		if seg.Op == sfnt.SegmentOpMoveTo {
+			contours = append(contours, nil)
+		} else if i == 0 {
+			break
+		}
+		contours[len(contours)-1] = append(contours[len(contours)-1], seg)
+	}
+	return contours
+}
 
-	if cache, ok := cv.fontTriCache[cv.state.font]; ok {
+// appendSegments adds a closed contour of outline segments
+// to the path
+func appendSegments(path *Path2D, segs sfnt.Segments) {
+	const scale = 1.0 / 64.0
+
+	for _, seg := range segs {
+		a := seg.Args
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			path.MoveTo(float64(a[0].X)*scale, float64(a[0].Y)*scale)
+		case sfnt.SegmentOpLineTo:
+			path.LineTo(float64(a[0].X)*scale, float64(a[0].Y)*scale)
+		case sfnt.SegmentOpQuadTo:
+			path.QuadraticCurveTo(float64(a[0].X)*scale, float64(a[0].Y)*scale, float64(a[1].X)*scale, float64(a[1].Y)*scale)
+		case sfnt.SegmentOpCubeTo:
+			path.BezierCurveTo(float64(a[0].X)*scale, float64(a[0].Y)*scale, float64(a[1].X)*scale, float64(a[1].Y)*scale, float64(a[2].X)*scale, float64(a[2].Y)*scale)
+		}
+	}
+	path.ClosePath()
+}
+
+// glyphTris returns the triangulated glyph at the base font
+// size
+func (cv *Canvas) glyphTris(fnt *Font, idx truetype.Index) []backendbase.Vec {
+	key := fontKey{font: fnt, hinting: cv.hinting()}
+	if cache, ok := cv.fontTriCache[key]; ok {
 		if tris, ok := cache.cache[idx]; ok {
 			cache.lastUsed = time.Now()
 			return tris
@@ -601,9 +941,23 @@ func (cv *Canvas) runeTris(rn rune) []backendbase.Vec {
 	const scale = 1.0 / 64.0
 
 	var gb truetype.GlyphBuf
-	gb.Load(cv.state.font.font, baseFontSize, idx, font.HintingFull)
+	var outline []sfnt.Segments
+	if fnt.font == nil {
+		outline = glyphContours(fnt, idx)
+	} else {
+		gb.Load(fnt.font, baseFontSize, idx, key.hinting)
+	}
 
-	contours := make([][]backendbase.Vec, 0, len(gb.Ends))
+	contours := make([][]backendbase.Vec, 0, len(gb.Ends)+len(outline))
+	for _, segs := range outline {
+		path := &Path2D{cv: cv, p: make([]pathPoint, 0, 50), standalone: true, noSelfIntersection: true}
+		appendSegments(path, segs)
+		contour := make([]backendbase.Vec, len(path.p))
+		for i, pt := range path.p {
+			contour[i] = pt.pos
+		}
+		contours = append(contours, contour)
+	}
 
 	from := 0
 	for _, to := range gb.Ends {
@@ -717,10 +1071,10 @@ func (cv *Canvas) runeTris(rn rune) []backendbase.Vec {
 		pos += len(tris)
 	}
 
-	cache, ok := cv.fontTriCache[cv.state.font]
+	cache, ok := cv.fontTriCache[key]
 	if !ok {
 		cache = &fontTriCache{cache: make(map[truetype.Index][]backendbase.Vec, 1024)}
-		cv.fontTriCache[cv.state.font] = cache
+		cv.fontTriCache[key] = cache
 	}
 	cache.lastUsed = time.Now()
 	cache.cache[idx] = allTris
@@ -728,11 +1082,46 @@ func (cv *Canvas) runeTris(rn rune) []backendbase.Vec {
 	return allTris
 }
 
-// TextMetrics is the result of a MeasureText call
+// TextMetrics is the result of a MeasureText call. The
+// horizontal distances are relative to the alignment point
+// given by the text align, and the vertical distances are
+// relative to the line given by the text baseline, with
+// positive values going up for the ascents and baselines
+// and down for the descents
 type TextMetrics struct {
-	Width                    float64
+	// Width is the advance width of the text
+	Width float64
+
+	// ActualBoundingBoxLeft and ActualBoundingBoxRight are
+	// the distances to the left and right side of the
+	// bounding box of the glyphs, positive values going left
+	// and right respectively
+	ActualBoundingBoxLeft  float64
+	ActualBoundingBoxRight float64
+
+	// ActualBoundingBoxAscent and ActualBoundingBoxDescent
+	// are the distances to the top and bottom of the
+	// bounding box of the glyphs
 	ActualBoundingBoxAscent  float64
 	ActualBoundingBoxDescent float64
+
+	// FontBoundingBoxAscent and FontBoundingBoxDescent are
+	// the distances to the ascent and descent of the fonts
+	// used for the text
+	FontBoundingBoxAscent  float64
+	FontBoundingBoxDescent float64
+
+	// EmHeightAscent and EmHeightDescent are the distances
+	// to the top and bottom of the em square
+	EmHeightAscent  float64
+	EmHeightDescent float64
+
+	// HangingBaseline, AlphabeticBaseline and
+	// IdeographicBaseline are the distances to the
+	// respective baselines
+	HangingBaseline     float64
+	AlphabeticBaseline  float64
+	IdeographicBaseline float64
 }
 
 // MeasureText measures the given string using the
@@ -741,50 +1130,125 @@ func (cv *Canvas) MeasureText(str string) TextMetrics {
 	if cv.state.font == nil {
 		return TextMetrics{}
 	}
+	return cv.measureGlyphs(cv.shapeText(str, cv.state.fontSize))
+}
 
-	frc := cv.getFRContext(cv.state.font, cv.state.fontSize)
-	fnt := cv.state.font.font
-
+// measureGlyphs returns the metrics of glyphs shaped at the
+// current font size
+func (cv *Canvas) measureGlyphs(glyphs []textGlyph, rtl bool) TextMetrics {
 	var p fixed.Point26_6
-	var x float64
-	var minY float64
-	var maxY float64
-	prev, hasPrev := truetype.Index(0), false
-	for _, rn := range str {
-		idx := fnt.Index(rn)
-		if idx == 0 {
-			prev = 0
-			hasPrev = false
+	var bounds image.Rectangle
+	hasBounds := false
+	size := float64(cv.state.fontSize) / 64
+	fontAscent := cv.state.font.ascent * size
+	fontDescent := cv.state.font.descent * size
+	for _, g := range glyphs {
+		fontAscent = math.Max(fontAscent, g.font.ascent*float64(g.size)/64)
+		fontDescent = math.Max(fontDescent, g.font.descent*float64(g.size)/64)
+
+		frc := cv.getFRContext(g.font, g.size)
+		glyphBounds, err := g.bounds(frc, p)
+		glyphBounds = glyphBounds.Add(image.Point{X: p.X.Floor()})
+		p.X += g.advance
+		if err != nil || glyphBounds.Empty() {
 			continue
 		}
-		var kern fixed.Int26_6
-		if hasPrev {
-			kern = fnt.Kern(frc.fontSize, prev, idx)
-			if frc.hinting != font.HintingNone {
-				kern = (kern + 32) &^ 63
-			}
-			x += float64(kern) / 64
+		if hasBounds {
+			bounds = bounds.Union(glyphBounds)
+		} else {
+			bounds, hasBounds = glyphBounds, true
 		}
+	}
 
-		advance, glyphBounds, err := frc.glyphMeasure(idx, p)
-		if err != nil {
-			prev = 0
-			hasPrev = false
-			continue
-		}
-		if glyphMinY := float64(glyphBounds.Min.Y); glyphMinY < minY {
-			minY = glyphMinY
+	width := float64(p.X) / 64
+	alignX := cv.textAlignOffset(cv.alignWidth(glyphs), rtl)
+	baseline := cv.textBaselineOffset(cv.state.textBaseline)
+	emAscent, emDescent := cv.emHeight()
+
+	return TextMetrics{
+		Width:                    width,
+		ActualBoundingBoxLeft:    alignX - float64(bounds.Min.X),
+		ActualBoundingBoxRight:   float64(bounds.Max.X) - alignX,
+		ActualBoundingBoxAscent:  -float64(bounds.Min.Y) - baseline,
+		ActualBoundingBoxDescent: float64(bounds.Max.Y) + baseline,
+		FontBoundingBoxAscent:    fontAscent - baseline,
+		FontBoundingBoxDescent:   fontDescent + baseline,
+		EmHeightAscent:           emAscent - baseline,
+		EmHeightDescent:          emDescent + baseline,
+		HangingBaseline:          cv.textBaselineOffset(Hanging) - baseline,
+		AlphabeticBaseline:       -baseline,
+		IdeographicBaseline:      cv.textBaselineOffset(Ideographic) - baseline,
+	}
+}
+
+// hinting returns the hinting that glyphs are loaded with,
+// which is turned off by the GeometricPrecision text rendering
+func (cv *Canvas) hinting() font.Hinting {
+	if cv.state.textRendering == GeometricPrecision {
+		return font.HintingNone
+	}
+	return font.HintingFull
+}
+
+// kerning returns whether text is kerned with the current font
+// kerning and text rendering
+func (cv *Canvas) kerning() bool {
+	switch cv.state.fontKerning {
+	case KerningNormal:
+		return true
+	case KerningNone:
+		return false
+	}
+	return cv.state.textRendering != OptimizeSpeed
+}
+
+// textAlignOffset returns the distance from the alignment
+// point to the start of text with the given width, where
+// Start and End depend on the direction
+func (cv *Canvas) textAlignOffset(width float64, rtl bool) float64 {
+	switch cv.state.textAlign {
+	case Center:
+		return width * 0.5
+	case Right:
+		return width
+	case Start, Justify:
+		if rtl {
+			return width
 		}
-		if glyphMaxY := float64(glyphBounds.Max.Y); glyphMaxY > maxY {
-			maxY = glyphMaxY
+	case End:
+		if !rtl {
+			return width
 		}
-		x += float64(advance) / 64
-		p.X += advance + kern
 	}
+	return 0
+}
 
-	return TextMetrics{
-		Width:                    x,
-		ActualBoundingBoxAscent:  -minY,
-		ActualBoundingBoxDescent: +maxY,
+// textBaselineOffset returns the distance from the line
+// given by the baseline down to the alphabetic baseline.
+// Bottom and ideographic are at the descent, and top and
+// hanging the height of the font metrics above it
+func (cv *Canvas) textBaselineOffset(baseline textBaseline) float64 {
+	metrics := cv.state.fontMetrics
+	switch baseline {
+	case Top, Hanging:
+		return -float64(metrics.Descent)/64 + float64(metrics.Height)/64
+	case Middle:
+		return -float64(metrics.Descent)/64 + float64(metrics.Height)*0.5/64
+	case Bottom, Ideographic:
+		return -float64(metrics.Descent) / 64
+	}
+	return 0
+}
+
+// emHeight returns the distances from the alphabetic baseline
+// to the top and bottom of the em square, which is the font
+// size split in the ratio of the ascent and descent of the font
+func (cv *Canvas) emHeight() (ascent, descent float64) {
+	size := float64(cv.state.fontSize) / 64
+	font := cv.state.font
+	if font.ascent+font.descent <= 0 {
+		return size, 0
 	}
+	descent = size * font.descent / (font.ascent + font.descent)
+	return size - descent, descent
 }
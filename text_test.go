@@ -0,0 +1,328 @@
+package canvas_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// newTextCanvas returns a canvas with the software backend
+// along with the Roboto font and the Go font, which has
+// glyphs like arrows that Roboto doesn't have
+func newTextCanvas(t *testing.T) (*canvas.Canvas, *canvas.Font, *canvas.Font) {
+	cv := canvas.New(softwarebackend.New(100, 100))
+	roboto, err := cv.LoadFont("testdata/Roboto-Light.ttf")
+	if err != nil {
+		t.Fatalf("failed to load font: %v", err)
+	}
+	gofont, err := cv.LoadFont(goregular.TTF)
+	if err != nil {
+		t.Fatalf("failed to load font: %v", err)
+	}
+	return cv, roboto, gofont
+}
+
+// textWidth measures the parts with the given fonts and
+// returns the sum of the widths
+func textWidth(cv *canvas.Canvas, size float64, parts ...interface{}) float64 {
+	var width float64
+	for i := 0; i < len(parts); i += 2 {
+		cv.SetFont(parts[i], size)
+		width += cv.MeasureText(parts[i+1].(string)).Width
+	}
+	return width
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestFontFallback(t *testing.T) {
+	cv, roboto, gofont := newTextCanvas(t)
+
+	cv.SetFont(canvas.FontFamily{roboto, gofont}, 20)
+	w := cv.MeasureText("A→B").Width
+	expected := textWidth(cv, 20, roboto, "A", gofont, "→", roboto, "B")
+	if !near(w, expected) {
+		t.Errorf("width with fallback is %g instead of %g", w, expected)
+	}
+
+	// the order of the family decides which font is used
+	cv.SetFont(canvas.FontFamily{gofont, roboto}, 20)
+	if w, expected := cv.MeasureText("A→B").Width, textWidth(cv, 20, gofont, "A→B"); !near(w, expected) {
+		t.Errorf("width with the Go font first is %g instead of %g", w, expected)
+	}
+
+	// fonts can also be given by file name, and runes that
+	// none of the fonts have are replaced by spaces
+	cv.SetFont([]interface{}{"testdata/Roboto-Light.ttf", gofont}, 20)
+	if w, expected := cv.MeasureText("A∀→").Width, textWidth(cv, 20, roboto, "A", roboto, " ", gofont, "→"); !near(w, expected) {
+		t.Errorf("width with a missing rune is %g instead of %g", w, expected)
+	}
+
+	cv.SetFont(roboto, 20)
+	if w, expected := cv.MeasureText("A→B").Width, textWidth(cv, 20, roboto, "A", roboto, " ", roboto, "B"); !near(w, expected) {
+		t.Errorf("width without fallback is %g instead of %g", w, expected)
+	}
+}
+
+func TestShapingKerning(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 40)
+
+	kerned := cv.MeasureText("AV").Width
+	if separate := textWidth(cv, 40, roboto, "A", roboto, "V"); kerned >= separate-1 {
+		t.Errorf("AV is %g wide, which is not kerned compared to %g", kerned, separate)
+	}
+
+	// combining marks are positioned over the base and don't
+	// advance
+	if w, expected := cv.MeasureText("e\u0301").Width, textWidth(cv, 40, roboto, "e"); !near(w, expected) {
+		t.Errorf("e with a combining accent is %g wide instead of %g", w, expected)
+	}
+}
+
+func TestTextMetrics(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 40)
+	base := cv.MeasureText("Hgx")
+
+	if sum := base.EmHeightAscent + base.EmHeightDescent; !near(sum, 40) {
+		t.Errorf("em height is %g instead of the font size", sum)
+	}
+	if ratio, expected := base.EmHeightAscent/base.EmHeightDescent, base.FontBoundingBoxAscent/base.FontBoundingBoxDescent; !near(ratio, expected) {
+		t.Errorf("em height is split %g instead of %g like the ascent and descent", ratio, expected)
+	}
+	// the ideographic baseline is at the descent and the
+	// hanging one the font size above it, like the bottom and
+	// top of FillText
+	if base.AlphabeticBaseline != 0 || !near(base.IdeographicBaseline, -base.FontBoundingBoxDescent) {
+		t.Errorf("unexpected baselines %+v", base)
+	}
+	if height := base.HangingBaseline - base.IdeographicBaseline; !near(height, 40) {
+		t.Errorf("hanging baseline is %g above the ideographic one instead of the font size", height)
+	}
+	if base.ActualBoundingBoxAscent <= 0 || base.ActualBoundingBoxAscent > base.FontBoundingBoxAscent ||
+		base.ActualBoundingBoxDescent <= 0 || base.ActualBoundingBoxDescent > base.FontBoundingBoxDescent {
+		t.Errorf("glyph bounds %g, %g are not within the font bounds %g, %g", base.ActualBoundingBoxAscent,
+			base.ActualBoundingBoxDescent, base.FontBoundingBoxAscent, base.FontBoundingBoxDescent)
+	}
+	if base.ActualBoundingBoxLeft > 1 || base.ActualBoundingBoxRight < base.Width-2 || base.ActualBoundingBoxRight > base.Width+1 {
+		t.Errorf("glyph bounds %g, %g don't match the width %g", base.ActualBoundingBoxLeft, base.ActualBoundingBoxRight, base.Width)
+	}
+
+	// each baseline moves all vertical distances by the same
+	// amount, and puts the line where its name says
+	for _, tc := range []struct {
+		set  func()
+		name string
+		line func(m canvas.TextMetrics) float64
+	}{
+		{func() { cv.SetTextBaseline(canvas.Top) }, "top", func(m canvas.TextMetrics) float64 { return m.HangingBaseline }},
+		{func() { cv.SetTextBaseline(canvas.Hanging) }, "hanging", func(m canvas.TextMetrics) float64 { return m.HangingBaseline }},
+		{func() { cv.SetTextBaseline(canvas.Middle) }, "middle", func(m canvas.TextMetrics) float64 { return m.HangingBaseline + m.IdeographicBaseline }},
+		{func() { cv.SetTextBaseline(canvas.Alphabetic) }, "alphabetic", func(m canvas.TextMetrics) float64 { return m.AlphabeticBaseline }},
+		{func() { cv.SetTextBaseline(canvas.Ideographic) }, "ideographic", func(m canvas.TextMetrics) float64 { return m.IdeographicBaseline }},
+		{func() { cv.SetTextBaseline(canvas.Bottom) }, "bottom", func(m canvas.TextMetrics) float64 { return m.IdeographicBaseline }},
+	} {
+		tc.set()
+		m := cv.MeasureText("Hgx")
+		if line := tc.line(m); !near(line, 0) {
+			t.Errorf("%s: the line is %g away from the %s baseline", tc.name, line, tc.name)
+		}
+		shift := m.AlphabeticBaseline
+		for _, d := range []struct {
+			field    string
+			got, exp float64
+		}{
+			{"Width", m.Width, base.Width},
+			{"ActualBoundingBoxLeft", m.ActualBoundingBoxLeft, base.ActualBoundingBoxLeft},
+			{"ActualBoundingBoxRight", m.ActualBoundingBoxRight, base.ActualBoundingBoxRight},
+			{"ActualBoundingBoxAscent", m.ActualBoundingBoxAscent, base.ActualBoundingBoxAscent + shift},
+			{"ActualBoundingBoxDescent", m.ActualBoundingBoxDescent, base.ActualBoundingBoxDescent - shift},
+			{"FontBoundingBoxAscent", m.FontBoundingBoxAscent, base.FontBoundingBoxAscent + shift},
+			{"FontBoundingBoxDescent", m.FontBoundingBoxDescent, base.FontBoundingBoxDescent - shift},
+			{"EmHeightAscent", m.EmHeightAscent, base.EmHeightAscent + shift},
+			{"EmHeightDescent", m.EmHeightDescent, base.EmHeightDescent - shift},
+			{"HangingBaseline", m.HangingBaseline, base.HangingBaseline + shift},
+			{"IdeographicBaseline", m.IdeographicBaseline, base.IdeographicBaseline + shift},
+		} {
+			if !near(d.got, d.exp) {
+				t.Errorf("%s: %s is %g instead of %g", tc.name, d.field, d.got, d.exp)
+			}
+		}
+	}
+	cv.SetTextBaseline(canvas.Alphabetic)
+
+	// the alignment moves the horizontal distances by the
+	// width from the left edge of the glyphs to the rounded
+	// up end of the text
+	alignWidth := math.Ceil(base.Width) + base.ActualBoundingBoxLeft
+	for _, tc := range []struct {
+		set   func()
+		name  string
+		shift float64
+	}{
+		{func() { cv.SetTextAlign(canvas.Left) }, "left", 0},
+		{func() { cv.SetTextAlign(canvas.Start) }, "start", 0},
+		{func() { cv.SetTextAlign(canvas.Center) }, "center", alignWidth / 2},
+		{func() { cv.SetTextAlign(canvas.Right) }, "right", alignWidth},
+		{func() { cv.SetTextAlign(canvas.End) }, "end", alignWidth},
+	} {
+		tc.set()
+		m := cv.MeasureText("Hgx")
+		if !near(m.ActualBoundingBoxLeft, base.ActualBoundingBoxLeft+tc.shift) || !near(m.ActualBoundingBoxRight, base.ActualBoundingBoxRight-tc.shift) {
+			t.Errorf("%s: glyph bounds are %g, %g instead of %g, %g", tc.name, m.ActualBoundingBoxLeft, m.ActualBoundingBoxRight,
+				base.ActualBoundingBoxLeft+tc.shift, base.ActualBoundingBoxRight-tc.shift)
+		}
+	}
+}
+
+func TestTextSpacing(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 20)
+	base := cv.MeasureText("ab c").Width
+
+	for _, tc := range []struct {
+		letter, word float64
+		str          string
+		expected     float64
+	}{
+		// letter spacing is added after every character,
+		// and word spacing after every space
+		{3, 0, "ab c", base + 4*3},
+		{0, 5, "ab c", base + 5},
+		{3, 5, "ab c", base + 4*3 + 5},
+		{-1, 0, "ab c", base - 4},
+		// combining marks are part of the character before
+		{3, 0, "e\u0301", cv.MeasureText("e").Width + 3},
+	} {
+		cv.SetLetterSpacing(tc.letter)
+		cv.SetWordSpacing(tc.word)
+		if w := cv.MeasureText(tc.str).Width; !near(w, tc.expected) {
+			t.Errorf("%q with letter spacing %g and word spacing %g is %g wide instead of %g", tc.str, tc.letter, tc.word, w, tc.expected)
+		}
+	}
+
+	// the spacing is saved and restored with the state
+	cv.SetLetterSpacing(3)
+	cv.SetWordSpacing(5)
+	cv.Save()
+	cv.SetLetterSpacing(10)
+	cv.SetWordSpacing(10)
+	cv.Restore()
+	if w, expected := cv.MeasureText("ab c").Width, base+4*3+5; !near(w, expected) {
+		t.Errorf("width after restoring is %g instead of %g", w, expected)
+	}
+	cv.SetLetterSpacing(0)
+	cv.SetWordSpacing(0)
+
+	// drawn text is spread out like it is measured, with the
+	// last letter moving by the spacing of the letters before it
+	ink := func(draw func(cv *canvas.Canvas), spacing float64) int {
+		cv, roboto, _ := newTextCanvas(t)
+		cv.SetFont(roboto, 20)
+		cv.SetLetterSpacing(spacing)
+		draw(cv)
+		return inkBounds(cv.GetImageData(0, 0, 100, 100)).Dx()
+	}
+	for _, draw := range []struct {
+		name string
+		fn   func(cv *canvas.Canvas)
+	}{
+		{"fill", func(cv *canvas.Canvas) { cv.FillText("HHH", 10, 50) }},
+		{"stroke", func(cv *canvas.Canvas) { cv.StrokeText("HHH", 10, 50) }},
+	} {
+		if w, expected := ink(draw.fn, 8), ink(draw.fn, 0)+16; w < expected-1 || w > expected+1 {
+			t.Errorf("%s: the text with letter spacing is %d pixels wide instead of %d", draw.name, w, expected)
+		}
+	}
+}
+
+func TestFontKerning(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 40)
+	separate := textWidth(cv, 40, roboto, "A", roboto, "V")
+
+	for _, tc := range []struct {
+		name   string
+		set    func()
+		kerned bool
+	}{
+		{"auto", func() { cv.SetFontKerning(canvas.KerningAuto) }, true},
+		{"normal", func() { cv.SetFontKerning(canvas.KerningNormal) }, true},
+		{"none", func() { cv.SetFontKerning(canvas.KerningNone) }, false},
+		{"auto optimizeSpeed", func() {
+			cv.SetFontKerning(canvas.KerningAuto)
+			cv.SetTextRendering(canvas.OptimizeSpeed)
+		}, false},
+		{"normal optimizeSpeed", func() {
+			cv.SetFontKerning(canvas.KerningNormal)
+			cv.SetTextRendering(canvas.OptimizeSpeed)
+		}, true},
+		{"none optimizeLegibility", func() {
+			cv.SetFontKerning(canvas.KerningNone)
+			cv.SetTextRendering(canvas.OptimizeLegibility)
+		}, false},
+	} {
+		cv.Save()
+		tc.set()
+		w := cv.MeasureText("AV").Width
+		if tc.kerned && w >= separate-1 {
+			t.Errorf("%s: AV is %g wide, which is not kerned compared to %g", tc.name, w, separate)
+		} else if !tc.kerned && !near(w, separate) {
+			t.Errorf("%s: AV is %g wide instead of %g without kerning", tc.name, w, separate)
+		}
+		cv.Restore()
+	}
+}
+
+// checkTextPath checks that the pixels that the text covers
+// completely are in the path, and the pixels that it doesn't
+// touch are outside
+func checkTextPath(t *testing.T, name string, img *image.RGBA, path *canvas.Path2D) {
+	inside := 0
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			a := img.RGBAAt(x, y).A
+			in := path.IsPointInPath(float64(x)+0.5, float64(y)+0.5, canvas.NonZero)
+			if a == 255 && !in || a == 0 && in {
+				t.Fatalf("%s: pixel %d,%d has alpha %d, but is in the path: %v", name, x, y, a, in)
+			}
+			if in {
+				inside++
+			}
+		}
+	}
+	if inside < 100 {
+		t.Fatalf("%s: only %d pixels are in the text path", name, inside)
+	}
+}
+
+func TestTextPath(t *testing.T) {
+	render := func(set func(cv *canvas.Canvas)) (*canvas.Canvas, *image.RGBA) {
+		cv, roboto, _ := newTextCanvas(t)
+		cv.SetFont(roboto, 40)
+		cv.SetTextRendering(canvas.GeometricPrecision)
+		set(cv)
+		cv.FillText("Hog", 50, 60)
+		return cv, cv.GetImageData(0, 0, 100, 100)
+	}
+
+	cv, img := render(func(cv *canvas.Canvas) { cv.SetTextAlign(canvas.Center) })
+	checkTextPath(t, "center", img, cv.TextPath("Hog", 50, 60))
+
+	// the path is placed by the text align and baseline like
+	// text, while Font.TextPath ignores them
+	cv, img = render(func(cv *canvas.Canvas) { cv.SetTextBaseline(canvas.Top) })
+	checkTextPath(t, "top", img, cv.TextPath("Hog", 50, 60))
+	cv, img = render(func(cv *canvas.Canvas) {})
+	roboto, _ := cv.LoadFont("testdata/Roboto-Light.ttf")
+	cv.SetTextAlign(canvas.Right)
+	cv.SetTextBaseline(canvas.Bottom)
+	checkTextPath(t, "font", img, roboto.TextPath(cv, "Hog", 40, 50, 60))
+}
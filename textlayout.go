@@ -0,0 +1,670 @@
+package canvas
+
+import (
+	"math"
+	"sort"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// TextLayout is a block of text that is broken into lines
+// that fit into a maximum width. It is created with
+// CreateTextLayout and drawn with FillTextLayout or
+// StrokeTextLayout
+type TextLayout struct {
+	cv         *Canvas
+	runes      []rune
+	maxWidth   float64
+	align      textAlign
+	maxLines   int
+	ellipsis   string
+	lineHeight float64
+
+	font          *Font
+	fontFallbacks []*Font
+	fontSize      fixed.Int26_6
+	fontMetrics   font.Metrics
+	direction     direction
+	letterSpacing float64
+	wordSpacing   float64
+	fontKerning   fontKerning
+	textRendering textRendering
+	variations    []FontVariation
+
+	lines  []TextLayoutLine
+	shaped []layoutLine
+	width  float64
+	height float64
+}
+
+// TextLayoutLine is the box of a line of a TextLayout
+// relative to the top left corner of the layout
+type TextLayoutLine struct {
+	// Start and End are the rune indices of the text in the
+	// line. End includes trailing spaces and line breaks
+	Start, End int
+
+	// X, Y, Width and Height are the line box. The width is
+	// the advance width of the text without trailing spaces
+	X, Y          float64
+	Width, Height float64
+
+	// Baseline is the y position of the alphabetic baseline
+	Baseline float64
+
+	// Truncated is set on the last line if the text didn't
+	// fit into the maximum number of lines and the end of the
+	// line was replaced by the ellipsis
+	Truncated bool
+}
+
+// layoutLine is the text of a line as it is drawn. The first
+// count runes are from the layout text, the rest is the
+// ellipsis. Extra is the space in ems added to each space
+// when the line is justified, and pen is the x position of
+// the first glyph relative to the layout
+type layoutLine struct {
+	text   []rune
+	count  int
+	rtl    bool
+	extra  float64
+	glyphs []textGlyph
+	pen    float64
+}
+
+// clusterBox is the horizontal extent of the glyphs of the
+// runes from start to end of a line
+type clusterBox struct {
+	start, end int
+	x0, x1     float64
+	rtl        bool
+}
+
+// CreateTextLayout breaks the text into lines using the
+// Unicode line breaking rules so that they fit into the given
+// width, using the current font, text align, direction,
+// spacing, kerning, text rendering and font variations. Line
+// breaks in the text always start a new line, and words that
+// are wider than the width are broken between characters. If
+// the width is 0 or less the text is only broken at line
+// breaks
+func (cv *Canvas) CreateTextLayout(text string, maxWidth float64) *TextLayout {
+	tl := &TextLayout{
+		cv:            cv,
+		runes:         []rune(text),
+		maxWidth:      maxWidth,
+		align:         cv.state.textAlign,
+		ellipsis:      "…",
+		font:          cv.state.font,
+		fontFallbacks: cv.state.fontFallbacks,
+		fontSize:      cv.state.fontSize,
+		fontMetrics:   cv.state.fontMetrics,
+		direction:     cv.state.direction,
+		letterSpacing: cv.state.letterSpacing,
+		wordSpacing:   cv.state.wordSpacing,
+		fontKerning:   cv.state.fontKerning,
+		textRendering: cv.state.textRendering,
+		variations:    cv.state.fontVariations,
+	}
+	tl.layout()
+	return tl
+}
+
+// SetAlign sets the alignment of the lines within the width
+// of the layout. The value can be Left, Center, Right, Start,
+// End, or Justify. Justified lines are stretched to the width
+// at their spaces, except for the last line of a paragraph
+// which is aligned like Start
+func (tl *TextLayout) SetAlign(align textAlign) {
+	tl.align = align
+	tl.layout()
+}
+
+// SetMaxLines limits the number of lines. If the text needs
+// more lines, the last line is cut off and ends with the
+// ellipsis. A value of 0 or less means no limit
+func (tl *TextLayout) SetMaxLines(lines int) {
+	tl.maxLines = lines
+	tl.layout()
+}
+
+// SetEllipsis sets the string that marks the end of text
+// that was cut off by SetMaxLines. The default is "…"
+func (tl *TextLayout) SetEllipsis(ellipsis string) {
+	tl.ellipsis = ellipsis
+	tl.layout()
+}
+
+// SetLineHeight sets the distance between the lines. A value
+// of 0 or less uses the ascent plus the descent of the font
+func (tl *TextLayout) SetLineHeight(height float64) {
+	tl.lineHeight = height
+	tl.layout()
+}
+
+// Size returns the width and height of the layout. The width
+// is the maximum width, or the width of the widest line if
+// there is none
+func (tl *TextLayout) Size() (float64, float64) {
+	return tl.width, tl.height
+}
+
+// Lines returns the line boxes of the layout
+func (tl *TextLayout) Lines() []TextLayoutLine {
+	lines := make([]TextLayoutLine, len(tl.lines))
+	copy(lines, tl.lines)
+	return lines
+}
+
+// IndexAt returns the rune index in the text of the caret
+// position closest to the given point relative to the top
+// left corner of the layout
+func (tl *TextLayout) IndexAt(x, y float64) int {
+	if len(tl.lines) == 0 {
+		return 0
+	}
+	i := int(math.Floor(y / tl.lines[0].Height))
+	if i < 0 {
+		i = 0
+	} else if i >= len(tl.lines) {
+		i = len(tl.lines) - 1
+	}
+	line, l := &tl.lines[i], &tl.shaped[i]
+
+	boxes := l.clusterBoxes()
+	if len(boxes) == 0 {
+		return line.Start
+	}
+	// find the box at x, or the one at the nearest end of the
+	// line
+	box := boxes[0]
+	left, right := box, box
+	found := false
+	for _, b := range boxes {
+		if b.x0 < left.x0 {
+			left = b
+		}
+		if b.x1 > right.x1 {
+			right = b
+		}
+		if !found && x >= b.x0 && x < b.x1 {
+			box, found = b, true
+		}
+	}
+	if !found {
+		if x < left.x0 {
+			box, x = left, left.x0
+		} else {
+			box, x = right, right.x1
+		}
+	}
+
+	// ligatures are split evenly between their runes, and the
+	// caret goes after a rune if x is past its middle in the
+	// direction of the text
+	var pos float64
+	if box.x1 > box.x0 {
+		pos = (x - box.x0) / (box.x1 - box.x0) * float64(box.end-box.start)
+	}
+	if box.rtl {
+		pos = float64(box.end-box.start) - pos
+	}
+	idx := box.start + int(math.Round(pos))
+	if idx > l.count {
+		idx = l.count
+	}
+	return line.Start + idx
+}
+
+// CaretPosition returns the position of the caret before the
+// rune at the given index in the text, relative to the top
+// left corner of the layout. The caret is a vertical line from
+// x, y going down by height
+func (tl *TextLayout) CaretPosition(index int) (x, y, height float64) {
+	if len(tl.lines) == 0 {
+		return 0, 0, 0
+	}
+	i := 0
+	for i < len(tl.lines)-1 && index >= tl.lines[i].End {
+		i++
+	}
+	line, l := &tl.lines[i], &tl.shaped[i]
+	idx := index - line.Start
+	if idx < 0 {
+		idx = 0
+	} else if idx > l.count {
+		idx = l.count
+	}
+
+	boxes := l.clusterBoxes()
+	x = line.X
+	if l.rtl {
+		x += line.Width
+	}
+	for _, b := range boxes {
+		if b.start >= l.count {
+			continue
+		}
+		end := b.end
+		if end > l.count {
+			end = l.count
+		}
+		if idx < b.start || idx > end {
+			continue
+		}
+		// the position within the cluster, measured from the
+		// start in the direction of the text
+		pos := (b.x1 - b.x0) * float64(idx-b.start) / float64(b.end-b.start)
+		if b.rtl {
+			x = b.x1 - pos
+		} else {
+			x = b.x0 + pos
+		}
+		if idx < end {
+			break
+		}
+	}
+	return x, line.Y, line.Height
+}
+
+// FillTextLayout draws the text layout with its top left
+// corner at the given coordinates using the current fill
+// style
+func (cv *Canvas) FillTextLayout(tl *TextLayout, x, y float64) {
+	if tl.font == nil {
+		return
+	}
+	defer tl.use(cv)()
+	for i := range tl.shaped {
+		cv.fillText(tl.source(cv, &tl.shaped[i]), []drawStyle{cv.state.fill}, x+tl.shaped[i].pen, y+tl.lines[i].Baseline, false)
+	}
+}
+
+// StrokeTextLayout draws the outline of the text layout with
+// its top left corner at the given coordinates using the
+// current stroke style
+func (cv *Canvas) StrokeTextLayout(tl *TextLayout, x, y float64) {
+	if tl.font == nil {
+		return
+	}
+	defer tl.use(cv)()
+	for i := range tl.shaped {
+		cv.strokeText(tl.source(cv, &tl.shaped[i]), []drawStyle{cv.state.stroke}, x+tl.shaped[i].pen, y+tl.lines[i].Baseline, false)
+	}
+}
+
+// use sets the font and text settings of the canvas to the
+// ones of the layout and returns a function that restores the
+// previous settings
+func (tl *TextLayout) use(cv *Canvas) func() {
+	prev := cv.state
+	cv.state.font = tl.font
+	cv.state.fontFallbacks = tl.fontFallbacks
+	cv.state.fontSize = tl.fontSize
+	cv.state.fontMetrics = tl.fontMetrics
+	cv.state.letterSpacing = tl.letterSpacing
+	cv.state.wordSpacing = tl.wordSpacing
+	cv.state.fontKerning = tl.fontKerning
+	cv.state.textRendering = tl.textRendering
+	cv.state.fontVariations = tl.variations
+	return func() {
+		cv.state.font = prev.font
+		cv.state.fontFallbacks = prev.fontFallbacks
+		cv.state.fontSize = prev.fontSize
+		cv.state.fontMetrics = prev.fontMetrics
+		cv.state.direction = prev.direction
+		cv.state.letterSpacing = prev.letterSpacing
+		cv.state.wordSpacing = prev.wordSpacing
+		cv.state.fontKerning = prev.fontKerning
+		cv.state.textRendering = prev.textRendering
+		cv.state.fontVariations = prev.fontVariations
+	}
+}
+
+// source returns the text source for drawing the line, which
+// shapes it in the direction of its paragraph and adds the
+// space of justified lines scaled to the font size
+func (tl *TextLayout) source(cv *Canvas, l *layoutLine) textSource {
+	str := string(l.text)
+	return func(size fixed.Int26_6) ([]textGlyph, bool) {
+		cv.state.direction = LTR
+		if l.rtl {
+			cv.state.direction = RTL
+		}
+		glyphs, _ := cv.shapeText(str, size)
+		if l.extra != 0 {
+			extra := fixed.Int26_6(math.Round(l.extra * float64(size)))
+			for i, g := range glyphs {
+				if g.cluster < l.count && isWordSeparator(l.text[g.cluster]) {
+					glyphs[i].advance += extra
+				}
+			}
+		}
+		return glyphs, l.rtl
+	}
+}
+
+// layout breaks the text into lines and positions them
+func (tl *TextLayout) layout() {
+	tl.lines, tl.shaped = tl.lines[:0], tl.shaped[:0]
+	tl.width, tl.height = 0, 0
+	if tl.font == nil {
+		return
+	}
+	cv := tl.cv
+	defer tl.use(cv)()
+
+	size := float64(tl.fontSize) / 64
+	lineHeight := tl.lineHeight
+	if lineHeight <= 0 {
+		lineHeight = (tl.font.ascent + tl.font.descent) * size
+	}
+	baseline := (lineHeight-(tl.font.ascent+tl.font.descent)*size)*0.5 + tl.font.ascent*size
+
+	// split the text into paragraphs at the mandatory breaks,
+	// and the paragraphs into lines
+	var paragraphs [][2]int
+	start := 0
+	cv.breaker.Init(tl.runes)
+	iter := cv.breaker.LineIterator()
+	for iter.Next() {
+		seg := iter.Line()
+		if seg.IsMandatoryBreak {
+			end := seg.Offset + len(seg.Text)
+			paragraphs = append(paragraphs, [2]int{start, end})
+			start = end
+		}
+	}
+	if len(tl.runes) == 0 || isLineBreak(tl.runes[len(tl.runes)-1]) {
+		paragraphs = append(paragraphs, [2]int{len(tl.runes), len(tl.runes)})
+	}
+
+	type brokenLine struct {
+		start, end int
+		rtl        bool
+		last       bool
+	}
+	var broken []brokenLine
+	for _, para := range paragraphs {
+		rtl := tl.direction == RTL
+		if tl.direction == Inherit {
+			rtl = firstStrongRTL(tl.runes[para[0]:para[1]])
+		}
+		for _, ln := range tl.breakParagraph(para[0], para[1], rtl) {
+			broken = append(broken, brokenLine{start: ln[0], end: ln[1], rtl: rtl})
+		}
+		broken[len(broken)-1].last = true
+	}
+
+	truncated := tl.maxLines > 0 && len(broken) > tl.maxLines
+	if truncated {
+		broken = broken[:tl.maxLines]
+	}
+
+	for i, bl := range broken {
+		contentEnd := trimEnd(tl.runes, bl.start, bl.end, isLineBreak)
+		l := layoutLine{rtl: bl.rtl}
+		line := TextLayoutLine{Start: bl.start, End: bl.end}
+		if truncated && i == len(broken)-1 {
+			l.text, line.End = tl.truncate(bl.start, contentEnd, bl.rtl)
+			l.count = line.End - bl.start
+			line.Truncated = true
+		} else {
+			l.text = tl.runes[bl.start:contentEnd]
+			l.count = len(l.text)
+		}
+		l.glyphs, _ = tl.source(cv, &l)(tl.fontSize)
+
+		width, trailing := l.measure()
+		if tl.align == Justify && tl.maxWidth > 0 && !bl.last && !line.Truncated && width < tl.maxWidth {
+			spaces := 0
+			for _, rn := range l.text[:trimEnd(l.text, 0, l.count, unicode.IsSpace)] {
+				if isWordSeparator(rn) {
+					spaces++
+				}
+			}
+			if spaces > 0 {
+				l.extra = (tl.maxWidth - width) / float64(spaces) / size
+				l.glyphs, _ = tl.source(cv, &l)(tl.fontSize)
+				width, trailing = l.measure()
+			}
+		}
+
+		line.Y = float64(i) * lineHeight
+		line.Width = width
+		line.Height = lineHeight
+		line.Baseline = line.Y + baseline
+		if bl.rtl {
+			// the trailing spaces of right-to-left text are to
+			// the left of it
+			l.pen = -trailing
+		}
+		tl.lines = append(tl.lines, line)
+		tl.shaped = append(tl.shaped, l)
+		tl.width = math.Max(tl.width, width)
+	}
+
+	if tl.maxWidth > 0 {
+		tl.width = tl.maxWidth
+	}
+	tl.height = float64(len(tl.lines)) * lineHeight
+	for i := range tl.lines {
+		line := &tl.lines[i]
+		line.X = tl.alignOffset(line.Width, tl.shaped[i].rtl)
+		tl.shaped[i].pen += line.X
+	}
+}
+
+// breakParagraph breaks the runes from start to end into
+// lines that fit into the maximum width and returns the start
+// and end of each line
+func (tl *TextLayout) breakParagraph(start, end int, rtl bool) [][2]int {
+	cv := tl.cv
+	runes := tl.runes[start:end]
+	contentEnd := trimEnd(runes, 0, len(runes), isLineBreak)
+
+	// the advances of the runes are taken from the shaped
+	// paragraph, which is close enough to the width of the
+	// shaped lines
+	advances := make([]float64, len(runes)+1)
+	if rtl {
+		cv.state.direction = RTL
+	} else {
+		cv.state.direction = LTR
+	}
+	glyphs, _ := cv.shapeText(string(runes[:contentEnd]), tl.fontSize)
+	for _, g := range glyphs {
+		advances[g.cluster+1] += float64(g.advance) / 64
+	}
+	for i := 1; i < len(advances); i++ {
+		advances[i] += advances[i-1]
+	}
+	width := func(from, to int) float64 {
+		to = trimEnd(runes, from, to, unicode.IsSpace)
+		return advances[to] - advances[from]
+	}
+
+	if tl.maxWidth <= 0 {
+		return [][2]int{{start, end}}
+	}
+
+	var breaks, graphemes []int
+	cv.breaker.Init(runes)
+	lineIter := cv.breaker.LineIterator()
+	for lineIter.Next() {
+		seg := lineIter.Line()
+		breaks = append(breaks, seg.Offset+len(seg.Text))
+	}
+	graphemeIter := cv.breaker.GraphemeIterator()
+	for graphemeIter.Next() {
+		gr := graphemeIter.Grapheme()
+		graphemes = append(graphemes, gr.Offset+len(gr.Text))
+	}
+
+	var lines [][2]int
+	lineStart, prev := 0, 0
+	for _, brk := range breaks {
+		if prev > lineStart && width(lineStart, brk) > tl.maxWidth {
+			lines = append(lines, [2]int{start + lineStart, start + prev})
+			lineStart = prev
+		}
+		// words that don't fit on a line by themselves are
+		// broken between graphemes
+		for width(lineStart, brk) > tl.maxWidth {
+			split := -1
+			for _, g := range graphemes {
+				if g <= lineStart {
+					continue
+				} else if g >= brk || (split >= 0 && width(lineStart, g) > tl.maxWidth) {
+					break
+				}
+				split = g
+			}
+			if split < 0 {
+				break
+			}
+			lines = append(lines, [2]int{start + lineStart, start + split})
+			lineStart = split
+		}
+		prev = brk
+	}
+	return append(lines, [2]int{start + lineStart, end})
+}
+
+// truncate returns the text of the line from start to end
+// cut off so that it fits into the maximum width along with
+// the ellipsis, and the index where the text was cut off
+func (tl *TextLayout) truncate(start, end int, rtl bool) ([]rune, int) {
+	cv := tl.cv
+	ellipsis := []rune(tl.ellipsis)
+	if rtl {
+		cv.state.direction = RTL
+	} else {
+		cv.state.direction = LTR
+	}
+
+	cv.breaker.Init(tl.runes[start:end])
+	cuts := []int{0}
+	iter := cv.breaker.GraphemeIterator()
+	for iter.Next() {
+		gr := iter.Grapheme()
+		cuts = append(cuts, gr.Offset+len(gr.Text))
+	}
+
+	// find the longest text that fits using a binary search
+	// on the grapheme boundaries
+	text := func(cut int) []rune {
+		cutEnd := trimEnd(tl.runes, start, start+cut, unicode.IsSpace)
+		text := make([]rune, 0, cutEnd-start+len(ellipsis))
+		return append(append(text, tl.runes[start:cutEnd]...), ellipsis...)
+	}
+	fits := func(cut int) bool {
+		glyphs, _ := cv.shapeText(string(text(cut)), tl.fontSize)
+		var width fixed.Int26_6
+		for _, g := range glyphs {
+			width += g.advance
+		}
+		return float64(width)/64 <= tl.maxWidth
+	}
+	n := len(cuts) - 1
+	if tl.maxWidth > 0 {
+		n = sort.Search(len(cuts), func(i int) bool { return !fits(cuts[i]) }) - 1
+		if n < 0 {
+			n = 0
+		}
+	}
+	cut := trimEnd(tl.runes, start, start+cuts[n], unicode.IsSpace)
+	return text(cuts[n]), cut
+}
+
+// alignOffset returns the x position of a line with the given
+// width in the layout
+func (tl *TextLayout) alignOffset(width float64, rtl bool) float64 {
+	switch tl.align {
+	case Center:
+		return (tl.width - width) * 0.5
+	case Right:
+		return tl.width - width
+	case Start, Justify:
+		if rtl {
+			return tl.width - width
+		}
+	case End:
+		if !rtl {
+			return tl.width - width
+		}
+	}
+	return 0
+}
+
+// measure returns the advance width of the line without
+// trailing spaces and the width of the trailing spaces
+func (l *layoutLine) measure() (float64, float64) {
+	contentEnd := trimEnd(l.text[:l.count], 0, l.count, unicode.IsSpace)
+	var width, trailing fixed.Int26_6
+	for _, g := range l.glyphs {
+		if g.cluster >= contentEnd && g.cluster < l.count {
+			trailing += g.advance
+		} else {
+			width += g.advance
+		}
+	}
+	return float64(width) / 64, float64(trailing) / 64
+}
+
+// clusterBoxes returns the extents of the clusters of the
+// line relative to the layout, ordered by their start
+func (l *layoutLine) clusterBoxes() []clusterBox {
+	var boxes []clusterBox
+	index := make(map[int]int)
+	x := l.pen
+	for _, g := range l.glyphs {
+		x0, x1 := x, x+float64(g.advance)/64
+		x = x1
+		if i, ok := index[g.cluster]; ok {
+			boxes[i].x0 = math.Min(boxes[i].x0, x0)
+			boxes[i].x1 = math.Max(boxes[i].x1, x1)
+			continue
+		}
+		index[g.cluster] = len(boxes)
+		boxes = append(boxes, clusterBox{start: g.cluster, x0: x0, x1: x1, rtl: g.rtl})
+	}
+	sort.Slice(boxes, func(i, j int) bool { return boxes[i].start < boxes[j].start })
+	for i := range boxes {
+		if i+1 < len(boxes) {
+			boxes[i].end = boxes[i+1].start
+		} else {
+			boxes[i].end = len(l.text)
+		}
+	}
+	return boxes
+}
+
+// trimEnd returns the end of the runes from start to end
+// without the trailing runes matching the function
+func trimEnd(runes []rune, start, end int, fn func(rune) bool) int {
+	for end > start && fn(runes[end-1]) {
+		end--
+	}
+	return end
+}
+
+func isLineBreak(rn rune) bool {
+	switch rn {
+	case '\n', '\r', '\f', '\v', '\u0085', '\u2028', '\u2029':
+		return true
+	}
+	return false
+}
+
+// isWordSeparator returns whether the rune is a space that is
+// stretched when a line is justified
+func isWordSeparator(rn rune) bool {
+	switch rn {
+	case ' ', '\t', '\u00a0', '\u3000':
+		return true
+	}
+	return false
+}
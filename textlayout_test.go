@@ -0,0 +1,273 @@
+package canvas_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+)
+
+const layoutText = "the quick brown fox jumps over the lazy dog and keeps running"
+
+// lineText returns the text of a line without the trailing
+// spaces and line breaks
+func lineText(text []rune, line canvas.TextLayoutLine) string {
+	return strings.TrimRight(string(text[line.Start:line.End]), " \n")
+}
+
+func TestTextLayoutLines(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 20)
+	text := []rune(layoutText + "\nend")
+	tl := cv.CreateTextLayout(string(text), 150)
+
+	lines := tl.Lines()
+	if len(lines) < 3 {
+		t.Fatalf("text is broken into %d lines", len(lines))
+	}
+	height := lines[0].Height
+	for i, line := range lines {
+		str := lineText(text, line)
+		if i == 0 && line.Start != 0 || i > 0 && line.Start != lines[i-1].End {
+			t.Errorf("line %d starts at %d, which doesn't follow the previous line", i, line.Start)
+		}
+		if !near(line.Width, cv.MeasureText(str).Width) {
+			t.Errorf("line %d %q is %g wide instead of %g", i, str, line.Width, cv.MeasureText(str).Width)
+		}
+		if line.Width > 150 || line.X != 0 {
+			t.Errorf("line %d %q is at %g with width %g, outside of the layout", i, str, line.X, line.Width)
+		}
+		if !near(line.Y, float64(i)*height) || line.Height != height || line.Baseline <= line.Y || line.Baseline >= line.Y+height {
+			t.Errorf("line %d has the box %+v", i, line)
+		}
+		// lines are broken as late as possible
+		if i+1 < len(lines) && !strings.HasSuffix(str, "running") {
+			next := strings.SplitN(lineText(text, lines[i+1]), " ", 2)[0]
+			if w := cv.MeasureText(str + " " + next).Width; w <= 150 {
+				t.Errorf("line %d %q is broken although %q fits at %g", i, str, next, w)
+			}
+		}
+	}
+	if last := lines[len(lines)-1]; lineText(text, last) != "end" || last.End != len(text) {
+		t.Errorf("the line break doesn't start a new line, the last line is %q", lineText(text, last))
+	}
+	if w, h := tl.Size(); w != 150 || !near(h, float64(len(lines))*height) {
+		t.Errorf("the layout size is %gx%g", w, h)
+	}
+
+	// words wider than the layout are broken between
+	// characters
+	tl = cv.CreateTextLayout("abcdefghijklmnopqrstuvwxyz", 50)
+	if len(tl.Lines()) < 2 {
+		t.Errorf("a long word takes %d lines", len(tl.Lines()))
+	}
+	for i, line := range tl.Lines() {
+		if line.Width > 50 {
+			t.Errorf("line %d of a long word is %g wide", i, line.Width)
+		}
+	}
+
+	// lines are aligned within the width of the layout
+	for _, tc := range []struct {
+		set  func(tl *canvas.TextLayout)
+		name string
+		x    func(line canvas.TextLayoutLine) float64
+	}{
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.Center) }, "center", func(line canvas.TextLayoutLine) float64 { return (150 - line.Width) / 2 }},
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.Right) }, "right", func(line canvas.TextLayoutLine) float64 { return 150 - line.Width }},
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.End) }, "end", func(line canvas.TextLayoutLine) float64 { return 150 - line.Width }},
+	} {
+		tl := cv.CreateTextLayout(layoutText, 150)
+		tc.set(tl)
+		for i, line := range tl.Lines() {
+			if !near(line.X, tc.x(line)) {
+				t.Errorf("%s: line %d is at %g instead of %g", tc.name, i, line.X, tc.x(line))
+			}
+		}
+	}
+
+	tl = cv.CreateTextLayout(layoutText, 150)
+	tl.SetLineHeight(40)
+	for i, line := range tl.Lines() {
+		if line.Y != float64(i)*40 || line.Height != 40 {
+			t.Errorf("line %d is at %g with height %g with a line height of 40", i, line.Y, line.Height)
+		}
+	}
+}
+
+func TestTextLayoutMaxLines(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 20)
+	text := []rune(layoutText)
+	tl := cv.CreateTextLayout(layoutText, 150)
+	full := tl.Lines()
+
+	for _, ellipsis := range []string{"…", "..."} {
+		tl.SetEllipsis(ellipsis)
+		tl.SetMaxLines(2)
+		lines := tl.Lines()
+		if len(lines) != 2 {
+			t.Fatalf("%d lines instead of 2", len(lines))
+		}
+		if lines[0] != full[0] || lines[0].Truncated {
+			t.Errorf("the first line is %+v instead of %+v", lines[0], full[0])
+		}
+		last := lines[1]
+		if !last.Truncated || last.End >= full[1].End || last.Start != full[1].Start {
+			t.Errorf("the last line %+v is not cut off", last)
+		}
+		// the ellipsis is drawn after the text that fits
+		str := lineText(text, last) + ellipsis
+		if w := cv.MeasureText(str).Width; !near(last.Width, w) || last.Width > 150 {
+			t.Errorf("the last line is %g wide instead of %g for %q", last.Width, w, str)
+		}
+		// the trailing spaces are removed before the ellipsis,
+		// so the next text that could fit includes the next word
+		next := last.End
+		for text[next] == ' ' {
+			next++
+		}
+		if w := cv.MeasureText(string(text[last.Start:next+1]) + ellipsis).Width; w <= 150 {
+			t.Errorf("the last line %q is cut off too early", str)
+		}
+	}
+
+	tl.SetMaxLines(len(full))
+	for i, line := range tl.Lines() {
+		if line.Truncated {
+			t.Errorf("line %d is truncated although all lines fit", i)
+		}
+	}
+}
+
+func TestTextLayoutJustify(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 20)
+	text := []rune(layoutText + "\n" + layoutText)
+	tl := cv.CreateTextLayout(string(text), 150)
+	tl.SetAlign(canvas.Justify)
+
+	lines := tl.Lines()
+	for i, line := range lines {
+		str := lineText(text, line)
+		last := i == len(lines)-1 || isLineEnd(text, line)
+		if last {
+			// the last line of a paragraph is not stretched
+			if w := cv.MeasureText(str).Width; !near(line.Width, w) || line.X != 0 {
+				t.Errorf("line %d %q is at %g with width %g instead of %g", i, str, line.X, line.Width, w)
+			}
+			continue
+		}
+		if !near(line.Width, 150) || line.X != 0 {
+			t.Errorf("justified line %d %q is at %g with width %g", i, str, line.X, line.Width)
+		}
+	}
+
+	// the space is distributed evenly, so the caret positions
+	// of the words move by the same amount per space
+	line := lines[0]
+	spaces := strings.Count(lineText(text, line), " ")
+	if spaces < 2 {
+		t.Fatalf("the first line %q has too few spaces", lineText(text, line))
+	}
+	extra := (150 - cv.MeasureText(lineText(text, line)).Width) / float64(spaces)
+	n := 0
+	for i := line.Start; i < line.End; i++ {
+		if text[i] != ' ' || i+1 >= line.End {
+			continue
+		}
+		n++
+		x, _, _ := tl.CaretPosition(i + 1)
+		expected := cv.MeasureText(string(text[line.Start:i+1])).Width + float64(n)*extra
+		if !near(x, expected) {
+			t.Errorf("word %d starts at %g instead of %g", n, x, expected)
+		}
+	}
+}
+
+// isLineEnd returns whether the line ends with a line break
+func isLineEnd(text []rune, line canvas.TextLayoutLine) bool {
+	return line.End > line.Start && text[line.End-1] == '\n'
+}
+
+func TestTextLayoutRTL(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 20)
+
+	// right-to-left paragraphs are aligned to the right by
+	// Start and Justify, and to the left by End
+	cv.SetDirection(canvas.RTL)
+	for _, tc := range []struct {
+		set   func(tl *canvas.TextLayout)
+		name  string
+		right bool
+	}{
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.Start) }, "start", true},
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.Justify) }, "justify", true},
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.End) }, "end", false},
+		{func(tl *canvas.TextLayout) { tl.SetAlign(canvas.Left) }, "left", false},
+	} {
+		tl := cv.CreateTextLayout(layoutText, 150)
+		tc.set(tl)
+		lines := tl.Lines()
+		last := lines[len(lines)-1]
+		expected := 0.0
+		if tc.right {
+			expected = 150 - last.Width
+		}
+		if !near(last.X, expected) {
+			t.Errorf("%s: the last line is at %g instead of %g", tc.name, last.X, expected)
+		}
+	}
+
+	// the caret of right-to-left text starts on the right and
+	// moves to the left
+	cv.SetDirection(canvas.Inherit)
+	tl := cv.CreateTextLayout("אבג דהו זחט", 0)
+	line := tl.Lines()[0]
+	x0, _, _ := tl.CaretPosition(0)
+	x1, _, _ := tl.CaretPosition(1)
+	xn, _, _ := tl.CaretPosition(line.End)
+	if !near(x0, line.X+line.Width) || x1 >= x0 || !near(xn, line.X) {
+		t.Errorf("the carets of right-to-left text are at %g, %g and %g in the line from %g to %g", x0, x1, xn, line.X, line.X+line.Width)
+	}
+}
+
+func TestTextLayoutCaretRoundTrip(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 20)
+
+	for _, tc := range []struct {
+		name string
+		dir  func()
+		text string
+	}{
+		{"ltr", func() { cv.SetDirection(canvas.LTR) }, layoutText + "\nend"},
+		{"rtl", func() { cv.SetDirection(canvas.Inherit) }, "אבג דהו זחט יכל מנס עפצ קרש"},
+		{"mixed", func() { cv.SetDirection(canvas.LTR) }, "abc אבג def"},
+	} {
+		tc.dir()
+		tl := cv.CreateTextLayout(tc.text, 150)
+		tl.SetAlign(canvas.Center)
+		n := len([]rune(tc.text))
+		for i := 0; i <= n; i++ {
+			x, y, h := tl.CaretPosition(i)
+			// where the direction changes, two indices share a
+			// caret position
+			idx := tl.IndexAt(x, y+h/2)
+			if x2, y2, _ := tl.CaretPosition(idx); idx != i && (!near(x, x2) || y != y2) {
+				t.Errorf("%s: the caret of index %d is at %g, %g, which is index %d", tc.name, i, x, y, idx)
+			}
+		}
+
+		// points outside of the layout are clamped to the
+		// nearest line
+		lines := tl.Lines()
+		if idx := tl.IndexAt(-100, -100); idx < 0 || idx > lines[0].End {
+			t.Errorf("%s: the point above the layout is index %d outside of the first line", tc.name, idx)
+		}
+		if idx := tl.IndexAt(1000, 1000); idx < lines[len(lines)-1].Start || idx > n {
+			t.Errorf("%s: the point below the layout is index %d outside of the last line", tc.name, idx)
+		}
+	}
+}
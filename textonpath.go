@@ -0,0 +1,186 @@
+package canvas
+
+import (
+	"math"
+	"sort"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"golang.org/x/image/math/fixed"
+)
+
+type textPathSide uint8
+
+// Path side constants for FillTextOnPath and StrokeTextOnPath
+const (
+	LeftSide textPathSide = iota
+	RightSide
+)
+
+// pathSegment is a line of a flattened path, starting at the
+// given distance along the path
+type pathSegment struct {
+	a, b   backendbase.Vec
+	dir    backendbase.Vec
+	start  float64
+	length float64
+}
+
+// FillTextOnPath draws the given string along the path using
+// the current font, font size and fill style. Each glyph is
+// rotated to the direction of the path at its center. The
+// text align is applied at offset, which is the distance along
+// the path, and the text baseline is placed on the path. With
+// LeftSide the tops of the glyphs point to the left of the
+// path direction, with RightSide the path is followed in
+// reverse so that the text is on its other side. Glyphs that
+// are beyond the ends of the path are not drawn, except for
+// paths that are a single closed shape, where the text wraps
+// around
+func (cv *Canvas) FillTextOnPath(str string, path *Path2D, offset float64, side textPathSide) {
+	if cv.state.font == nil || path == nil {
+		return
+	}
+	glyphs, mats := cv.textOnPath(str, path, offset, side)
+	transform := cv.state.transform
+	defer func() { cv.state.transform = transform }()
+
+	// if any glyph is rotated, all of them are triangulated so
+	// that they look the same
+	rotated := false
+	for _, m := range mats {
+		rotated = rotated || m[1] != 0 || m[2] != 0 || m[0] != m[3]
+	}
+	styles := []drawStyle{cv.state.fill}
+	for i, g := range glyphs {
+		cv.state.transform = mats[i]
+		if rotated {
+			cv.fillGlyphTris(glyphs[i:i+1], &styles[0], 0, 0, 1)
+			cv.fillColorGlyphs(glyphs[i:i+1], &styles[0], 0, 0, 1)
+		} else {
+			cv.fillText(glyphSource(g, cv.state.fontSize), styles, 0, 0, false)
+		}
+	}
+}
+
+// StrokeTextOnPath draws the outline of the given string along
+// the path using the current stroke style, with the glyphs
+// placed like FillTextOnPath does
+func (cv *Canvas) StrokeTextOnPath(str string, path *Path2D, offset float64, side textPathSide) {
+	if cv.state.font == nil || path == nil {
+		return
+	}
+	glyphs, mats := cv.textOnPath(str, path, offset, side)
+	transform := cv.state.transform
+	defer func() { cv.state.transform = transform }()
+
+	styles := []drawStyle{cv.state.stroke}
+	for i, g := range glyphs {
+		cv.state.transform = mats[i]
+		cv.strokeText(glyphSource(g, cv.state.fontSize), styles, 0, 0, false)
+	}
+}
+
+// textOnPath shapes the string once, so that kerning and
+// ligatures are kept, and returns the glyphs that are on the
+// path along with the transforms that place them
+func (cv *Canvas) textOnPath(str string, path *Path2D, offset float64, side textPathSide) ([]textGlyph, []backendbase.Mat) {
+	segs, closed := flattenPath(path.p, side == RightSide)
+	if len(segs) == 0 {
+		return nil, nil
+	}
+	length := segs[len(segs)-1].start + segs[len(segs)-1].length
+
+	glyphs, rtl := cv.shapeText(str, cv.state.fontSize)
+	var width fixed.Int26_6
+	for _, g := range glyphs {
+		width += g.advance
+	}
+	pos := offset - cv.textAlignOffset(float64(width)/64, rtl)
+	baseline := cv.textBaselineOffset(cv.state.textBaseline)
+
+	placed := glyphs[:0]
+	var mats []backendbase.Mat
+	for _, g := range glyphs {
+		half := float64(g.advance) / 64 * 0.5
+		mid := pos + half
+		pos += float64(g.advance) / 64
+		if closed {
+			mid = math.Mod(mid, length)
+			if mid < 0 {
+				mid += length
+			}
+		} else if mid < 0 || mid > length {
+			continue
+		}
+
+		i := sort.Search(len(segs), func(i int) bool { return segs[i].start+segs[i].length >= mid })
+		if i == len(segs) {
+			i--
+		}
+		seg := &segs[i]
+		dir := seg.dir
+		normal := backendbase.Vec{-dir[1], dir[0]}
+		origin := seg.a.Add(dir.Mulf(mid - seg.start - half)).Add(normal.Mulf(baseline))
+
+		placed = append(placed, g)
+		mats = append(mats, backendbase.Mat{dir[0], dir[1], normal[0], normal[1], origin[0], origin[1]}.Mul(cv.state.transform))
+	}
+	return placed, mats
+}
+
+// glyphSource returns a text source for a single glyph that
+// was shaped at the given size, which is scaled to the size
+// it is rendered at
+func glyphSource(g textGlyph, shapedSize fixed.Int26_6) textSource {
+	return func(size fixed.Int26_6) ([]textGlyph, bool) {
+		if size == shapedSize {
+			return []textGlyph{g}, false
+		}
+		k := float64(size) / float64(shapedSize)
+		sg := g
+		sg.size = fixed.Int26_6(math.Round(float64(g.size) * k))
+		sg.advance = fixed.Int26_6(math.Round(float64(g.advance) * k))
+		sg.offset.X = fixed.Int26_6(math.Round(float64(g.offset.X) * k))
+		sg.offset.Y = fixed.Int26_6(math.Round(float64(g.offset.Y) * k))
+		return []textGlyph{sg}, false
+	}
+}
+
+// flattenPath returns the lines of the path with their
+// distances along it, leaving out the gaps between subpaths,
+// optionally in reverse. It also returns whether the path is
+// a single closed subpath
+func flattenPath(path []pathPoint, reverse bool) ([]pathSegment, bool) {
+	var segs []pathSegment
+	subPaths := 0
+	closed := false
+	for i, pt := range path {
+		if pt.flags&pathMove != 0 || i == 0 {
+			subPaths++
+			closed = false
+			continue
+		}
+		closed = pt.flags&pathAttach != 0
+		a, b := path[i-1].pos, pt.pos
+		if reverse {
+			a, b = b, a
+		}
+		l := b.Sub(a).Len()
+		if l == 0 {
+			continue
+		}
+		segs = append(segs, pathSegment{a: a, b: b, dir: b.Sub(a).Divf(l), length: l})
+	}
+
+	if reverse {
+		for i, j := 0, len(segs)-1; i < j; i, j = i+1, j-1 {
+			segs[i], segs[j] = segs[j], segs[i]
+		}
+	}
+	var dist float64
+	for i := range segs {
+		segs[i].start = dist
+		dist += segs[i].length
+	}
+	return segs, closed && subPaths == 1
+}
@@ -0,0 +1,180 @@
+package canvas
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tfriedel6/canvas/backend/backendbase"
+	"github.com/tfriedel6/canvas/backend/softwarebackend"
+)
+
+func newTextOnPathCanvas(t *testing.T) *Canvas {
+	cv := New(softwarebackend.New(100, 100))
+	cv.SetFont("testdata/Roboto-Light.ttf", 20)
+	if cv.state.font == nil {
+		t.Fatal("failed to load font")
+	}
+	return cv
+}
+
+// glyphOrigins returns where the origins of the glyphs are
+// expected on a horizontal line starting at x
+func glyphOrigins(cv *Canvas, str string, x float64) []float64 {
+	glyphs, _ := cv.shapeText(str, cv.state.fontSize)
+	origins := make([]float64, len(glyphs))
+	for i, g := range glyphs {
+		origins[i] = x
+		x += float64(g.advance) / 64
+	}
+	return origins
+}
+
+func nearMat(a, b backendbase.Mat) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTextOnPathPlacement(t *testing.T) {
+	cv := newTextOnPathCanvas(t)
+	line := cv.NewPath2D()
+	line.MoveTo(10, 50)
+	line.LineTo(200, 50)
+	width := cv.MeasureText("abc").Width
+
+	cases := []struct {
+		name   string
+		align  textAlign
+		offset float64
+		start  float64
+	}{
+		{"start", Start, 0, 10},
+		{"offset", Start, 25, 35},
+		{"center", Center, 50, 60 - width/2},
+		{"right", Right, 50, 60 - width},
+		{"end", End, 50, 60 - width},
+	}
+	for _, tc := range cases {
+		cv.SetTextAlign(tc.align)
+		glyphs, mats := cv.textOnPath("abc", line, tc.offset, LeftSide)
+		origins := glyphOrigins(cv, "abc", tc.start)
+		if len(glyphs) != len(origins) {
+			t.Errorf("%s: %d glyphs are placed instead of %d", tc.name, len(glyphs), len(origins))
+			continue
+		}
+		for i, m := range mats {
+			if expected := (backendbase.Mat{1, 0, 0, 1, origins[i], 50}); !nearMat(m, expected) {
+				t.Errorf("%s: glyph %d is placed with %v instead of %v", tc.name, i, m, expected)
+			}
+		}
+	}
+	cv.SetTextAlign(Start)
+
+	// with RightSide the path is followed from its end, with
+	// the glyphs upside down below the line
+	_, mats := cv.textOnPath("abc", line, 5, RightSide)
+	origins := glyphOrigins(cv, "abc", 5)
+	for i, m := range mats {
+		if expected := (backendbase.Mat{-1, 0, 0, -1, 200 - origins[i], 50}); !nearMat(m, expected) {
+			t.Errorf("right side: glyph %d is placed with %v instead of %v", i, m, expected)
+		}
+	}
+
+	// the baseline is moved along the normal of the path
+	cv.SetTextBaseline(Top)
+	top := cv.textBaselineOffset(Top)
+	_, mats = cv.textOnPath("abc", line, 0, LeftSide)
+	if expected := (backendbase.Mat{1, 0, 0, 1, 10, 50 + top}); !nearMat(mats[0], expected) {
+		t.Errorf("top baseline: the first glyph is placed with %v instead of %v", mats[0], expected)
+	}
+	cv.SetTextBaseline(Alphabetic)
+
+	// the glyphs follow the direction of the segment that
+	// their center is on, and the transform of the canvas is
+	// applied
+	corner := cv.NewPath2D()
+	corner.MoveTo(10, 10)
+	corner.LineTo(20, 10)
+	corner.LineTo(20, 200)
+	cv.Translate(5, 7)
+	glyphs, mats := cv.textOnPath("abcdef", corner, 15, LeftSide)
+	cv.SetTransform(1, 0, 0, 1, 0, 0)
+	if len(glyphs) != 6 {
+		t.Fatalf("%d glyphs are placed on the corner path instead of 6", len(glyphs))
+	}
+	if m := mats[len(mats)-1]; math.Abs(m[0]) > 1e-9 || m[1] != 1 || m[2] != -1 || m[4] != 25 {
+		t.Errorf("the last glyph on the corner path is placed with %v", m)
+	}
+}
+
+func TestTextOnPathEnds(t *testing.T) {
+	cv := newTextOnPathCanvas(t)
+	str := "abcdefghij"
+	all, _ := cv.shapeText(str, cv.state.fontSize)
+	width := cv.MeasureText(str).Width
+
+	// glyphs with their center beyond the ends of an open path
+	// are left out
+	line := cv.NewPath2D()
+	line.MoveTo(0, 50)
+	line.LineTo(width, 50)
+	for _, offset := range []float64{0, width / 2, -width / 2} {
+		glyphs, _ := cv.textOnPath(str, line, offset, LeftSide)
+		kept := 0
+		x := offset
+		for _, g := range all {
+			mid := x + float64(g.advance)/64/2
+			if mid >= 0 && mid <= width {
+				kept++
+			}
+			x += float64(g.advance) / 64
+		}
+		if len(glyphs) != kept || kept == len(all) && offset != 0 {
+			t.Errorf("offset %g: %d glyphs are placed instead of %d", offset, len(glyphs), kept)
+		}
+	}
+
+	// on a closed path the text wraps around to the start
+	square := cv.NewPath2D()
+	square.Rect(10, 10, 30, 30)
+	square.ClosePath()
+	glyphs, mats := cv.textOnPath(str, square, 100, LeftSide)
+	if len(glyphs) != len(all) {
+		t.Fatalf("%d glyphs are placed on the closed path instead of %d", len(glyphs), len(all))
+	}
+	// the sides of the square in the order of the path
+	sides := []backendbase.Vec{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	x, wrapped := 100.0, false
+	for i, g := range all {
+		mid := x + float64(g.advance)/64/2
+		x += float64(g.advance) / 64
+		wrapped = wrapped || mid >= 120
+		side := sides[int(math.Mod(mid, 120)/30)]
+		if math.Abs(mats[i][0]-side[0]) > 1e-9 || math.Abs(mats[i][1]-side[1]) > 1e-9 {
+			t.Errorf("glyph %d at %g is placed with %v instead of along %v", i, mid, mats[i], side)
+		}
+	}
+	if !wrapped {
+		t.Error("no glyph wraps around the square")
+	}
+
+	// a path of several subpaths doesn't wrap around, even if
+	// they are closed
+	square.Rect(50, 50, 10, 10)
+	square.ClosePath()
+	if glyphs, _ := cv.textOnPath(str, square, 130, LeftSide); len(glyphs) == len(all) {
+		t.Error("the text wraps around on a path with several subpaths")
+	}
+}
+
+func TestTextOnPathNil(t *testing.T) {
+	cv := newTextOnPathCanvas(t)
+	cv.FillTextOnPath("abc", nil, 0, LeftSide)
+	cv.StrokeTextOnPath("abc", nil, 0, RightSide)
+	if glyphs, _ := cv.textOnPath("abc", cv.NewPath2D(), 0, LeftSide); len(glyphs) != 0 {
+		t.Errorf("%d glyphs are placed on an empty path", len(glyphs))
+	}
+}
@@ -0,0 +1,191 @@
+package canvas
+
+import (
+	"math"
+
+	"golang.org/x/image/math/fixed"
+)
+
+type textDecoration uint8
+
+// Text decoration flags for TextSpan, which can be combined
+const (
+	Underline textDecoration = 1 << iota
+	Overline
+	LineThrough
+)
+
+// TextSpan is a part of a text with its own style that is
+// drawn with FillTextSpans or StrokeTextSpans. Fields that
+// are left at their zero value use the current settings of
+// the canvas
+type TextSpan struct {
+	Text string
+
+	// Font can be any of the font sources accepted by SetFont,
+	// and Size is the font size
+	Font interface{}
+	Size float64
+
+	// FillStyle and StrokeStyle can be any single value
+	// accepted by SetFillStyle and SetStrokeStyle
+	FillStyle   interface{}
+	StrokeStyle interface{}
+
+	// Decoration is a combination of Underline, Overline and
+	// LineThrough
+	Decoration textDecoration
+
+	// BaselineShift moves the span up from the baseline, or
+	// down if it is negative, for superscript and subscript
+	BaselineShift float64
+}
+
+// textSpans are spans with their fonts resolved
+type textSpans struct {
+	runes  []rune
+	spans  []TextSpan
+	shapes []shapeSpan
+}
+
+// FillTextSpans draws the spans one after another on a
+// shared baseline, each with its own font, size, fill style
+// and decoration. The spans are shaped together, so kerning
+// and the bidi algorithm apply across spans that use the same
+// font. The text align and baseline apply to the spans as a
+// whole, with the baseline given by the current font
+func (cv *Canvas) FillTextSpans(spans []TextSpan, x, y float64) {
+	if cv.state.font == nil {
+		return
+	}
+	styles := make([]drawStyle, len(spans))
+	for i, span := range spans {
+		styles[i] = cv.state.fill
+		if span.FillStyle != nil {
+			styles[i] = cv.parseStyle(span.FillStyle)
+		}
+	}
+	ts := cv.resolveSpans(spans)
+	src := ts.source(cv)
+	glyphs, rtl := src(cv.state.fontSize)
+	cv.alignText(glyphs, rtl, &x, &y, 1)
+	cv.fillText(src, styles, x, y, false)
+	cv.drawDecorations(ts, glyphs, styles, x, y)
+}
+
+// StrokeTextSpans draws the outlines of the spans like
+// FillTextSpans, each with its own stroke style. The
+// decorations are filled with the stroke style
+func (cv *Canvas) StrokeTextSpans(spans []TextSpan, x, y float64) {
+	if cv.state.font == nil {
+		return
+	}
+	styles := make([]drawStyle, len(spans))
+	for i, span := range spans {
+		styles[i] = cv.state.stroke
+		if span.StrokeStyle != nil {
+			styles[i] = cv.parseStyle(span.StrokeStyle)
+		}
+	}
+	ts := cv.resolveSpans(spans)
+	src := ts.source(cv)
+	glyphs, rtl := src(cv.state.fontSize)
+	cv.alignText(glyphs, rtl, &x, &y, 1)
+	cv.strokeText(src, styles, x, y, false)
+	cv.drawDecorations(ts, glyphs, styles, x, y)
+}
+
+// MeasureTextSpans measures the spans as they are drawn by
+// FillTextSpans
+func (cv *Canvas) MeasureTextSpans(spans []TextSpan) TextMetrics {
+	if cv.state.font == nil {
+		return TextMetrics{}
+	}
+	return cv.measureGlyphs(cv.resolveSpans(spans).source(cv)(cv.state.fontSize))
+}
+
+// resolveSpans loads the fonts of the spans
+func (cv *Canvas) resolveSpans(spans []TextSpan) *textSpans {
+	ts := &textSpans{spans: spans, shapes: make([]shapeSpan, len(spans))}
+	for i, span := range spans {
+		ts.runes = append(ts.runes, []rune(span.Text)...)
+		shape := shapeSpan{
+			end:       len(ts.runes),
+			font:      cv.state.font,
+			fallbacks: cv.state.fontFallbacks,
+			size:      cv.state.fontSize,
+		}
+		if span.Font != nil {
+			if f, fallbacks := cv.resolveFont(span.Font); f != nil {
+				shape.font, shape.fallbacks = f, fallbacks
+			}
+		}
+		if span.Size > 0 {
+			shape.size = fixed.Int26_6(math.Round(span.Size * 64))
+		}
+		ts.shapes[i] = shape
+	}
+	return ts
+}
+
+// source returns the text source of the spans, which scales
+// the font sizes of the spans along with the current font
+// size and moves the glyphs by the baseline shift
+func (ts *textSpans) source(cv *Canvas) textSource {
+	return func(size fixed.Int26_6) ([]textGlyph, bool) {
+		scale := float64(size) / float64(cv.state.fontSize)
+		shapes := make([]shapeSpan, len(ts.shapes))
+		for i, shape := range ts.shapes {
+			shape.size = fixed.Int26_6(math.Round(float64(shape.size) * scale))
+			shapes[i] = shape
+		}
+		runes := make([]rune, len(ts.runes))
+		copy(runes, ts.runes)
+
+		glyphs, rtl := cv.shapeSpans(runes, shapes, scale)
+		for i, g := range glyphs {
+			if shift := ts.spans[g.span].BaselineShift; shift != 0 {
+				glyphs[i].offset.Y -= fixed.Int26_6(math.Round(shift * scale * 64))
+			}
+		}
+		return glyphs, rtl
+	}
+}
+
+// drawDecorations draws the lines of the decorations of the
+// spans under, over and through the glyphs, which start at
+// x on the alphabetic baseline y
+func (cv *Canvas) drawDecorations(ts *textSpans, glyphs []textGlyph, styles []drawStyle, x, y float64) {
+	fill := cv.state.fill
+	defer func() { cv.state.fill = fill }()
+
+	for start := 0; start < len(glyphs); {
+		span := glyphs[start].span
+		end := start + 1
+		width := float64(glyphs[start].advance) / 64
+		for end < len(glyphs) && glyphs[end].span == span {
+			width += float64(glyphs[end].advance) / 64
+			end++
+		}
+
+		deco := ts.spans[span].Decoration
+		if deco != 0 {
+			fnt := ts.shapes[span].font
+			size := float64(ts.shapes[span].size) / 64
+			baseline := y - ts.spans[span].BaselineShift
+			cv.state.fill = styles[span]
+			if deco&Underline != 0 {
+				cv.FillRect(x, baseline-fnt.underlinePosition*size, width, fnt.underlineThickness*size)
+			}
+			if deco&Overline != 0 {
+				cv.FillRect(x, baseline-fnt.ascent*size, width, fnt.underlineThickness*size)
+			}
+			if deco&LineThrough != 0 {
+				cv.FillRect(x, baseline-fnt.strikeoutPosition*size, width, fnt.strikeoutThickness*size)
+			}
+		}
+
+		x += width
+		start = end
+	}
+}
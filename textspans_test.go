@@ -0,0 +1,186 @@
+package canvas_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/tfriedel6/canvas"
+)
+
+// inkBounds returns the bounds of the pixels that are not
+// transparent
+func inkBounds(img *image.RGBA) image.Rectangle {
+	var bounds image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, y).A != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+// diffBounds returns the bounds of the pixels that differ
+func diffBounds(a, b *image.RGBA) image.Rectangle {
+	var bounds image.Rectangle
+	for y := a.Rect.Min.Y; y < a.Rect.Max.Y; y++ {
+		for x := a.Rect.Min.X; x < a.Rect.Max.X; x++ {
+			if a.RGBAAt(x, y) != b.RGBAAt(x, y) {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+func TestTextSpansMeasure(t *testing.T) {
+	cv, roboto, gofont := newTextCanvas(t)
+	cv.SetFont(roboto, 30)
+
+	// spans with the same font measure like the concatenated
+	// text, including the kerning across the spans
+	spans := []canvas.TextSpan{{Text: "A"}, {Text: "Vo", FillStyle: "#F00"}, {Text: "g", Decoration: canvas.Underline}}
+	for _, align := range []struct {
+		set  func()
+		name string
+	}{
+		{func() { cv.SetTextAlign(canvas.Left) }, "left"},
+		{func() { cv.SetTextAlign(canvas.Center) }, "center"},
+		{func() { cv.SetTextBaseline(canvas.Top) }, "top"},
+	} {
+		align.set()
+		got, expected := cv.MeasureTextSpans(spans), cv.MeasureText("AVog")
+		for _, d := range []struct {
+			field    string
+			got, exp float64
+		}{
+			{"Width", got.Width, expected.Width},
+			{"ActualBoundingBoxLeft", got.ActualBoundingBoxLeft, expected.ActualBoundingBoxLeft},
+			{"ActualBoundingBoxRight", got.ActualBoundingBoxRight, expected.ActualBoundingBoxRight},
+			{"ActualBoundingBoxAscent", got.ActualBoundingBoxAscent, expected.ActualBoundingBoxAscent},
+			{"ActualBoundingBoxDescent", got.ActualBoundingBoxDescent, expected.ActualBoundingBoxDescent},
+			{"FontBoundingBoxAscent", got.FontBoundingBoxAscent, expected.FontBoundingBoxAscent},
+			{"FontBoundingBoxDescent", got.FontBoundingBoxDescent, expected.FontBoundingBoxDescent},
+			{"EmHeightAscent", got.EmHeightAscent, expected.EmHeightAscent},
+			{"EmHeightDescent", got.EmHeightDescent, expected.EmHeightDescent},
+		} {
+			if !near(d.got, d.exp) {
+				t.Errorf("%s: %s of the spans is %g instead of %g", align.name, d.field, d.got, d.exp)
+			}
+		}
+	}
+	cv.SetTextAlign(canvas.Left)
+	cv.SetTextBaseline(canvas.Alphabetic)
+
+	// spans with their own font and size measure like the
+	// parts measured separately
+	spans = []canvas.TextSpan{{Text: "ab "}, {Text: "cd ", Size: 15}, {Text: "ef", Font: gofont}}
+	w := cv.MeasureTextSpans(spans).Width
+	expected := textWidth(cv, 30, roboto, "ab ") + textWidth(cv, 15, roboto, "cd ") + textWidth(cv, 30, gofont, "ef")
+	if !near(w, expected) {
+		t.Errorf("spans with different fonts are %g wide instead of %g", w, expected)
+	}
+}
+
+func TestTextSpansStyle(t *testing.T) {
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 30)
+	cv.SetFillStyle("#0F0")
+	first := cv.MeasureText("HH").Width
+	cv.FillTextSpans([]canvas.TextSpan{{Text: "HH", FillStyle: "#F00"}, {Text: "HH"}}, 10, 50)
+
+	// the first span is red and the second one uses the
+	// current fill style
+	img := cv.GetImageData(0, 0, 100, 100)
+	red, green := 0, 0
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+			if float64(x) < 10+first-1 && c.G != 0 || float64(x) > 10+first+1 && c.R != 0 {
+				t.Fatalf("pixel %d,%d is %v, which is not the color of its span", x, y, c)
+			}
+			if c.R == 255 {
+				red++
+			} else if c.G == 255 {
+				green++
+			}
+		}
+	}
+	if red == 0 || green == 0 {
+		t.Errorf("the spans have %d red and %d green pixels", red, green)
+	}
+}
+
+func TestTextSpansBaselineShift(t *testing.T) {
+	render := func(shift float64) image.Rectangle {
+		cv, roboto, _ := newTextCanvas(t)
+		cv.SetFont(roboto, 30)
+		cv.FillTextSpans([]canvas.TextSpan{{Text: "H"}, {Text: "H", BaselineShift: shift, Size: 15}}, 10, 50)
+		img := cv.GetImageData(0, 0, 100, 100)
+		first := cv.MeasureText("H").Width
+		return inkBounds(img.SubImage(image.Rect(10+int(first)+1, 0, 100, 100)).(*image.RGBA))
+	}
+
+	// positive shifts move the span up for superscript and
+	// negative ones down for subscript
+	base := render(0)
+	if base.Empty() {
+		t.Fatal("the second span is not drawn")
+	}
+	for _, shift := range []int{10, -8} {
+		if b := render(float64(shift)); b != base.Sub(image.Pt(0, shift)) {
+			t.Errorf("the span shifted by %d is at %v instead of %v", shift, b, base.Sub(image.Pt(0, shift)))
+		}
+	}
+}
+
+func TestTextSpansDecoration(t *testing.T) {
+	render := func(deco canvas.TextSpan) *image.RGBA {
+		cv, roboto, _ := newTextCanvas(t)
+		cv.SetFont(roboto, 30)
+		deco.Text = "HH"
+		cv.FillTextSpans([]canvas.TextSpan{{Text: "x"}, deco, {Text: "x"}}, 10, 50)
+		return cv.GetImageData(0, 0, 100, 100)
+	}
+	cv, roboto, _ := newTextCanvas(t)
+	cv.SetFont(roboto, 30)
+	start, width := 10+cv.MeasureText("x").Width, cv.MeasureText("HH").Width
+	capTop := 50 - cv.MeasureText("H").ActualBoundingBoxAscent
+
+	for _, tc := range []struct {
+		name string
+		span canvas.TextSpan
+		// the rows that the line must be in
+		top, bottom float64
+	}{
+		{"underline", canvas.TextSpan{Decoration: canvas.Underline}, 50, 60},
+		{"overline", canvas.TextSpan{Decoration: canvas.Overline}, 10, capTop},
+		{"line-through", canvas.TextSpan{Decoration: canvas.LineThrough}, capTop, 50},
+		{"shifted underline", canvas.TextSpan{Decoration: canvas.Underline, BaselineShift: 10}, 40, 50},
+	} {
+		// the decoration only covers the span
+		undecorated := tc.span
+		undecorated.Decoration = 0
+		b := diffBounds(render(undecorated), render(tc.span))
+		if b.Empty() {
+			t.Errorf("%s: the decoration is not drawn", tc.name)
+			continue
+		}
+		if b.Dx() > int(width)+2 || float64(b.Min.X) < start-1 || float64(b.Max.X) > start+width+1 {
+			t.Errorf("%s: the decoration spans %d to %d instead of %g to %g", tc.name, b.Min.X, b.Max.X, start, start+width)
+		}
+		if float64(b.Min.Y) < tc.top || float64(b.Max.Y) > tc.bottom {
+			t.Errorf("%s: the decoration is in the rows %d to %d instead of between %g and %g", tc.name, b.Min.Y, b.Max.Y, tc.top, tc.bottom)
+		}
+	}
+
+	// decorations can be combined
+	both := diffBounds(render(canvas.TextSpan{}), render(canvas.TextSpan{Decoration: canvas.Underline | canvas.Overline}))
+	if float64(both.Min.Y) >= capTop || both.Max.Y <= 50 {
+		t.Errorf("the combined decorations are in the rows %d to %d", both.Min.Y, both.Max.Y)
+	}
+}
@@ -477,3 +477,336 @@ func selfIntersectingPathParts(p []pathPoint, partFn func(sp []pathPoint) bool)
 		return false
 	})
 }
+
+/*
+fill rule strategy:
+
+- collect the non-horizontal edges of all subpaths, with a direction
+  of 1 for edges going down and -1 for edges going up
+- split the area at the y coordinates of all vertices into slabs, and
+  split slabs further where edges cross, so that the edges in a slab
+  don't cross each other
+- walk through the edges of each slab from left to right, summing up
+  their directions, and add a trapezoid for each span in which the
+  sum is inside according to the fill rule
+
+*/
+
+const windingTolerance = 1e-9
+
+type windingEdge struct {
+	top, bottom backendbase.Vec
+	dir         int
+}
+
+func (e *windingEdge) x(y float64) float64 {
+	if y == e.bottom[1] {
+		return e.bottom[0]
+	}
+	return e.top[0] + (y-e.top[1])*(e.bottom[0]-e.top[0])/(e.bottom[1]-e.top[1])
+}
+
+type windingSpan struct {
+	e          *windingEdge
+	xTop, xBot float64
+}
+
+// subPathGroups splits the path into its subpaths with at
+// least three points and groups the ones whose bounds
+// overlap, since only those can cover each other
+func subPathGroups(path []pathPoint) [][][]pathPoint {
+	type subPath struct {
+		p        []pathPoint
+		min, max backendbase.Vec
+		group    int
+	}
+	var subPaths []subPath
+	for i := 0; i < len(path); {
+		j := i + 1
+		for j < len(path) && path[j].flags&pathMove == 0 {
+			j++
+		}
+		if j-i >= 3 {
+			min, max := path[i].pos, path[i].pos
+			for _, pt := range path[i+1 : j] {
+				min = backendbase.Vec{math.Min(min[0], pt.pos[0]), math.Min(min[1], pt.pos[1])}
+				max = backendbase.Vec{math.Max(max[0], pt.pos[0]), math.Max(max[1], pt.pos[1])}
+			}
+			subPaths = append(subPaths, subPath{p: path[i:j], min: min, max: max, group: len(subPaths)})
+		}
+		i = j
+	}
+	if len(subPaths) == 1 {
+		return [][][]pathPoint{{subPaths[0].p}}
+	}
+
+	find := func(i int) int {
+		for subPaths[i].group != i {
+			i = subPaths[i].group
+		}
+		return i
+	}
+	order := make([]int, len(subPaths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return subPaths[order[i]].min[0] < subPaths[order[j]].min[0] })
+	for i, a := range order {
+		for _, b := range order[i+1:] {
+			sa, sb := &subPaths[a], &subPaths[b]
+			if sb.min[0] >= sa.max[0] {
+				break
+			}
+			if sa.min[1] < sb.max[1] && sa.max[1] > sb.min[1] {
+				ga, gb := find(a), find(b)
+				if ga < gb {
+					subPaths[gb].group = ga
+				} else {
+					subPaths[ga].group = gb
+				}
+			}
+		}
+	}
+
+	var groups [][][]pathPoint
+	index := make([]int, len(subPaths))
+	for i := range subPaths {
+		g := find(i)
+		if g == i {
+			index[i] = len(groups)
+			groups = append(groups, nil)
+		}
+		groups[index[g]] = append(groups[index[g]], subPaths[i].p)
+	}
+	return groups
+}
+
+// needsWindingFill returns whether the subpath can't be
+// triangulated on its own because it may intersect itself.
+// The flags of the points are set while the path is built
+// and don't cover the corners and the line where the subpath
+// is closed, so a subpath is only triangulated on its own if
+// it is known to be simple
+func needsWindingFill(path []pathPoint) bool {
+	last := path[len(path)-1].flags
+	if last&pathSelfIntersects != 0 {
+		return true
+	}
+	if last&pathIsConvex != 0 {
+		return !Performance.AssumeConvex && (!turnsOneWay(path) || windsMoreThanOnce(path))
+	}
+	return !Performance.IgnoreSelfIntersections && cutsItself(path)
+}
+
+// turnsOneWay returns whether all corners of the subpath,
+// including the ones where it is closed, turn the same way
+// and none of them turns back
+func turnsOneWay(path []pathPoint) bool {
+	n := len(path)
+	if n > 1 && path[0].pos == path[n-1].pos {
+		n--
+	}
+	if n < 3 {
+		return true
+	}
+	var turn float64
+	for i := 0; i < n; i++ {
+		a, b, c := path[i].pos, path[(i+1)%n].pos, path[(i+2)%n].pos
+		v0, v1 := b.Sub(a), c.Sub(b)
+		cross := v0[0]*v1[1] - v0[1]*v1[0]
+		if math.Abs(cross) <= 1e-9*v0.Len()*v1.Len() {
+			if v0.Dot(v1) < 0 {
+				return false
+			}
+			continue
+		}
+		if turn*cross < 0 {
+			return false
+		}
+		turn = cross
+	}
+	return true
+}
+
+// cutsItself returns whether any lines of the subpath that
+// weren't checked against each other when they were added
+// cross. Lines are only checked against the ones before them
+// once the subpath stops being convex, and the line that
+// closes the subpath isn't checked at all
+func cutsItself(path []pathPoint) bool {
+	cuts := func(a0, a1, b0, b1 backendbase.Vec) bool {
+		_, r1, r2 := lineIntersection(a0, a1, b0, b1)
+		return r1 > 0 && r1 < 1 && r2 > 0 && r2 < 1
+	}
+	n := len(path)
+	convex := 1
+	for convex < n && path[convex].flags&pathIsConvex != 0 {
+		convex++
+	}
+	for i := 3; i < convex; i++ {
+		for j := 1; j < i-1; j++ {
+			if cuts(path[j-1].pos, path[j].pos, path[i-1].pos, path[i].pos) {
+				return true
+			}
+		}
+	}
+	first, last := path[0].pos, path[n-1].pos
+	if first == last {
+		return false
+	}
+	for i := 2; i < n-1; i++ {
+		if cuts(path[i-1].pos, path[i].pos, last, first) {
+			return true
+		}
+	}
+	return false
+}
+
+// windsMoreThanOnce returns whether a subpath that always
+// turns the same way goes around more than once, like a
+// pentagram. The lines of a convex shape go down and up only
+// once
+func windsMoreThanOnce(path []pathPoint) bool {
+	if len(path) < 5 {
+		return false
+	}
+	changes := 0
+	down, started := false, false
+	prev := path[len(path)-1].pos
+	for _, pt := range path {
+		dy := pt.pos[1] - prev[1]
+		prev = pt.pos
+		if dy == 0 {
+			continue
+		}
+		if started && (dy > 0) != down {
+			changes++
+		}
+		down, started = dy > 0, true
+	}
+	return changes > 2
+}
+
+func isFinite(v backendbase.Vec) bool {
+	return !math.IsNaN(v[0]) && !math.IsNaN(v[1]) && !math.IsInf(v[0], 0) && !math.IsInf(v[1], 0)
+}
+
+// windingTriangles appends triangles that cover the area of
+// the subpaths that is inside according to the rule
+func windingTriangles(subPaths [][]pathPoint, mat backendbase.Mat, rule pathRule, target []backendbase.Vec) []backendbase.Vec {
+	var edges []windingEdge
+	var ys []float64
+	var pts []backendbase.Vec
+	for _, sp := range subPaths {
+		// points that are not finite are left out, and the
+		// subpaths are closed by starting at their last point
+		pts = pts[:0]
+		for _, pt := range sp {
+			if pos := pt.pos.MulMat(mat); isFinite(pos) {
+				pts = append(pts, pos)
+			}
+		}
+		if len(pts) == 0 {
+			continue
+		}
+		prev := pts[len(pts)-1]
+		for _, pos := range pts {
+			a, b := prev, pos
+			prev = pos
+			ys = append(ys, pos[1])
+			if a[1] == b[1] {
+				continue
+			}
+			if a[1] < b[1] {
+				edges = append(edges, windingEdge{top: a, bottom: b, dir: 1})
+			} else {
+				edges = append(edges, windingEdge{top: b, bottom: a, dir: -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return target
+	}
+
+	sort.Float64s(ys)
+	sort.Slice(edges, func(i, j int) bool { return edges[i].top[1] < edges[j].top[1] })
+
+	var active []*windingEdge
+	var spans []windingSpan
+	next := 0
+	y0 := ys[0]
+	for _, y1 := range ys[1:] {
+		for y0 < y1 {
+			// update the edges that span the slab
+			for next < len(edges) && edges[next].top[1] <= y0 {
+				active = append(active, &edges[next])
+				next++
+			}
+			n := 0
+			for _, e := range active {
+				if e.bottom[1] > y0 {
+					active[n] = e
+					n++
+				}
+			}
+			active = active[:n]
+
+			spans = spans[:0]
+			for _, e := range active {
+				spans = append(spans, windingSpan{e: e, xTop: e.x(y0), xBot: e.x(y1)})
+			}
+			// edges that start at the same point, or that just
+			// crossed, are sorted by where they go
+			sort.Slice(spans, func(i, j int) bool {
+				a, b := spans[i], spans[j]
+				if math.Abs(a.xTop-b.xTop) > windingTolerance*(math.Abs(a.xTop)+1) {
+					return a.xTop < b.xTop
+				}
+				return a.xBot < b.xBot
+			})
+
+			// end the slab at the first crossing of two edges
+			yEnd := y1
+			for i := 1; i < len(spans); i++ {
+				a, b := spans[i-1], spans[i]
+				if a.xBot <= b.xBot {
+					continue
+				}
+				_, r, _ := lineIntersection(a.e.top, a.e.bottom, b.e.top, b.e.bottom)
+				y := a.e.top[1] + r*(a.e.bottom[1]-a.e.top[1])
+				if y > y0 && y < yEnd {
+					yEnd = y
+				}
+			}
+			if yEnd < y1 {
+				for i := range spans {
+					spans[i].xBot = spans[i].e.x(yEnd)
+				}
+			}
+
+			winding := 0
+			for i, s := range spans {
+				inside := winding != 0
+				if rule == EvenOdd {
+					inside = winding%2 != 0
+				}
+				winding += s.e.dir
+				if !inside || i == 0 {
+					continue
+				}
+				l := spans[i-1]
+				lt, lb := backendbase.Vec{l.xTop, y0}, backendbase.Vec{l.xBot, yEnd}
+				rt, rb := backendbase.Vec{s.xTop, y0}, backendbase.Vec{s.xBot, yEnd}
+				if lt[0] < rt[0] {
+					target = append(target, lt, rt, rb)
+				}
+				if lb[0] < rb[0] {
+					target = append(target, lt, rb, lb)
+				}
+			}
+
+			y0 = yEnd
+		}
+	}
+	return target
+}
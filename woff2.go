@@ -0,0 +1,471 @@
+package canvas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+)
+
+// woff2Tags are the tags that WOFF2 table entries can refer
+// to by index
+var woff2Tags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post",
+	"cvt ", "fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT",
+	"EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea",
+	"vmtx", "BASE", "GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH",
+	"CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar",
+	"bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar",
+	"gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop",
+	"trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+var (
+	tagGlyf = sfntTag("glyf")
+	tagLoca = sfntTag("loca")
+	tagHmtx = sfntTag("hmtx")
+	tagHhea = sfntTag("hhea")
+	tagMaxp = sfntTag("maxp")
+	tagTTCF = sfntTag("ttcf")
+)
+
+type woff2Table struct {
+	tag         uint32
+	transformed bool
+	origLength  uint32
+	data        []byte
+}
+
+// woff2Font decompresses the font with the given index from
+// a WOFF2 file and reverses the transforms of the glyf, loca
+// and hmtx tables
+func woff2Font(data []byte, index int) ([]byte, error) {
+	r := fontReader{data: data, pos: 4}
+	flavor := r.u32()
+	r.pos = 12
+	numTables := int(r.u16())
+	r.pos = 20
+	compressedLength := int(r.u32())
+	r.pos = 48
+
+	tables := make([]woff2Table, numTables)
+	lengths := make([]int, numTables)
+	for i := range tables {
+		t := &tables[i]
+		flags := r.u8()
+		if flags&0x3f == 0x3f {
+			t.tag = r.u32()
+		} else {
+			t.tag = sfntTag(woff2Tags[flags&0x3f])
+		}
+		// for glyf and loca, transform version 0 is the
+		// transform and version 3 is none
+		version := flags >> 6
+		if t.tag == tagGlyf || t.tag == tagLoca {
+			t.transformed = version == 0
+		} else {
+			t.transformed = version != 0
+		}
+		t.origLength = r.base128()
+		lengths[i] = int(t.origLength)
+		if t.transformed {
+			lengths[i] = int(r.base128())
+		}
+	}
+
+	face := make([]int, numTables)
+	for i := range face {
+		face[i] = i
+	}
+	if flavor == tagTTCF {
+		r.u32() // version
+		numFonts := r.u255()
+		if !r.err && (index < 0 || index >= numFonts) {
+			return nil, errFontIndex
+		}
+		faceFlavor := flavor
+		for i := 0; i < numFonts && !r.err; i++ {
+			n := r.u255()
+			fontFlavor := r.u32()
+			if i == index {
+				face, faceFlavor = face[:0], fontFlavor
+			}
+			for j := 0; j < n; j++ {
+				idx := r.u255()
+				if idx >= numTables {
+					return nil, errFontFormat
+				}
+				if i == index {
+					face = append(face, idx)
+				}
+			}
+		}
+		flavor = faceFlavor
+	} else if index != 0 {
+		return nil, errFontIndex
+	}
+
+	compressed := r.bytes(compressedLength)
+	if r.err {
+		return nil, errFontFormat
+	}
+	stream, err := ioutil.ReadAll(brotli.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, err
+	}
+	sr := fontReader{data: stream}
+	for i := range tables {
+		tables[i].data = sr.bytes(lengths[i])
+	}
+	if sr.err {
+		return nil, errFontFormat
+	}
+
+	var glyf, loca, hmtx *woff2Table
+	var out []sfntTable
+	for _, i := range face {
+		t := &tables[i]
+		switch {
+		case t.tag == tagGlyf && t.transformed:
+			glyf = t
+		case t.tag == tagLoca && t.transformed:
+			loca = t
+		case t.tag == tagHmtx && t.transformed:
+			hmtx = t
+		default:
+			out = append(out, sfntTable{tag: t.tag, data: t.data})
+		}
+	}
+	if (glyf == nil) != (loca == nil) {
+		return nil, errFontFormat
+	}
+
+	var xMins []int16
+	if glyf != nil {
+		var glyfData, locaData []byte
+		glyfData, locaData, xMins, err = woff2Glyf(glyf.data)
+		if err != nil {
+			return nil, err
+		}
+		if uint32(len(locaData)) != loca.origLength {
+			return nil, errFontFormat
+		}
+		out = append(out, sfntTable{tag: tagGlyf, data: glyfData}, sfntTable{tag: tagLoca, data: locaData})
+	}
+	if hmtx != nil {
+		var hhea, maxp []byte
+		for _, t := range out {
+			switch t.tag {
+			case tagHhea:
+				hhea = t.data
+			case tagMaxp:
+				maxp = t.data
+			}
+		}
+		if len(hhea) < 36 || len(maxp) < 6 {
+			return nil, errFontFormat
+		}
+		numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+		numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+		hmtxData, err := woff2Hmtx(hmtx.data, numGlyphs, numHMetrics, xMins)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sfntTable{tag: tagHmtx, data: hmtxData})
+	}
+
+	return buildSFNT(flavor, out), nil
+}
+
+// woff2Point is a point of a simple glyph in the transformed
+// glyf table
+type woff2Point struct {
+	x, y int
+	on   bool
+}
+
+// woff2Glyf rebuilds the glyf and loca tables from the
+// transformed glyf table. It also returns the minimum x of
+// each glyph for the hmtx transform
+func woff2Glyf(data []byte) ([]byte, []byte, []int16, error) {
+	r := fontReader{data: data, pos: 2}
+	options := r.u16()
+	numGlyphs := int(r.u16())
+	indexFormat := r.u16()
+	var streams [7]fontReader
+	var sizes [7]int
+	for i := range sizes {
+		sizes[i] = int(r.u32())
+	}
+	for i := range streams {
+		streams[i].data = r.bytes(sizes[i])
+	}
+	nContours, nPoints, flags, glyphs, composites, bboxes, instructions :=
+		&streams[0], &streams[1], &streams[2], &streams[3], &streams[4], &streams[5], &streams[6]
+	bboxBitmap := bboxes.bytes(4 * ((numGlyphs + 31) / 32))
+	var overlap []byte
+	if options&1 != 0 {
+		overlap = r.bytes((numGlyphs + 7) / 8)
+	}
+	if r.err || bboxes.err {
+		return nil, nil, nil, errFontFormat
+	}
+
+	var glyf []byte
+	offsets := make([]int, numGlyphs+1)
+	xMins := make([]int16, numGlyphs)
+	for i := 0; i < numGlyphs; i++ {
+		offsets[i] = len(glyf)
+		n := int16(nContours.u16())
+		hasBBox := bboxBitmap[i>>3]&(0x80>>uint(i&7)) != 0
+
+		switch {
+		case n == 0:
+			if hasBBox {
+				return nil, nil, nil, errFontFormat
+			}
+		case n == -1:
+			if !hasBBox {
+				return nil, nil, nil, errFontFormat
+			}
+			bbox := bboxes.bytes(8)
+			start := composites.pos
+			hasInstructions := false
+			for !composites.err {
+				flags := composites.u16()
+				size := 4 // glyph index and byte arguments
+				if flags&0x0001 != 0 {
+					size += 2 // word arguments
+				}
+				if flags&0x0008 != 0 {
+					size += 2 // scale
+				} else if flags&0x0040 != 0 {
+					size += 4 // x and y scale
+				} else if flags&0x0080 != 0 {
+					size += 8 // two by two
+				}
+				composites.bytes(size)
+				if flags&0x0100 != 0 {
+					hasInstructions = true
+				}
+				if flags&0x0020 == 0 {
+					break
+				}
+			}
+			if composites.err {
+				return nil, nil, nil, errFontFormat
+			}
+			glyf = append(glyf, 0xff, 0xff)
+			glyf = append(glyf, bbox...)
+			glyf = append(glyf, composites.data[start:composites.pos]...)
+			if hasInstructions {
+				length := glyphs.u255()
+				glyf = append(glyf, byte(length>>8), byte(length))
+				glyf = append(glyf, instructions.bytes(length)...)
+			}
+			xMins[i] = int16(binary.BigEndian.Uint16(bbox))
+		case n > 0:
+			ends := make([]int, n)
+			total := 0
+			for j := range ends {
+				total += nPoints.u255()
+				ends[j] = total - 1
+			}
+			if nPoints.err || total > len(flags.data)-flags.pos {
+				return nil, nil, nil, errFontFormat
+			}
+			points := make([]woff2Point, total)
+			x, y := 0, 0
+			for j := range points {
+				flag := flags.u8()
+				dx, dy := woff2Triplet(flag&0x7f, glyphs)
+				x += dx
+				y += dy
+				points[j] = woff2Point{x: x, y: y, on: flag&0x80 == 0}
+			}
+			length := glyphs.u255()
+			instrs := instructions.bytes(length)
+
+			var bbox [4]int16
+			if hasBBox {
+				for j := range bbox {
+					bbox[j] = int16(bboxes.u16())
+				}
+			} else if len(points) > 0 {
+				bbox = [4]int16{int16(x), int16(y), int16(x), int16(y)}
+				for _, p := range points {
+					bbox[0] = minInt16(bbox[0], int16(p.x))
+					bbox[1] = minInt16(bbox[1], int16(p.y))
+					bbox[2] = maxInt16(bbox[2], int16(p.x))
+					bbox[3] = maxInt16(bbox[3], int16(p.y))
+				}
+			}
+			overlaps := overlap != nil && overlap[i>>3]&(0x80>>uint(i&7)) != 0
+			glyf = appendSimpleGlyph(glyf, bbox, ends, instrs, points, overlaps)
+			xMins[i] = bbox[0]
+		default:
+			return nil, nil, nil, errFontFormat
+		}
+
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+		for j := range streams {
+			if streams[j].err {
+				return nil, nil, nil, errFontFormat
+			}
+		}
+	}
+	offsets[numGlyphs] = len(glyf)
+
+	var loca []byte
+	if indexFormat == 0 {
+		loca = make([]byte, 2*len(offsets))
+		for i, off := range offsets {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(off/2))
+		}
+	} else {
+		loca = make([]byte, 4*len(offsets))
+		for i, off := range offsets {
+			binary.BigEndian.PutUint32(loca[4*i:], uint32(off))
+		}
+	}
+	return glyf, loca, xMins, nil
+}
+
+// woff2Triplet reads the coordinate delta of a point, which
+// is encoded in the lower 7 bits of its flag and 1 to 4
+// bytes of the glyph stream
+func woff2Triplet(flag byte, r *fontReader) (int, int) {
+	withSign := func(flag byte, v int) int {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	b := int(flag)
+	switch {
+	case b < 10:
+		return 0, withSign(flag, (b&14)<<7+int(r.u8()))
+	case b < 20:
+		return withSign(flag, ((b-10)&14)<<7+int(r.u8())), 0
+	case b < 84:
+		b0, b1 := b-20, int(r.u8())
+		return withSign(flag, 1+(b0&0x30)+b1>>4), withSign(flag>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+	case b < 120:
+		b0 := b - 84
+		data := r.bytes(2)
+		if data == nil {
+			return 0, 0
+		}
+		return withSign(flag, 1+(b0/12)<<8+int(data[0])), withSign(flag>>1, 1+((b0%12)>>2)<<8+int(data[1]))
+	case b < 124:
+		data := r.bytes(3)
+		if data == nil {
+			return 0, 0
+		}
+		return withSign(flag, int(data[0])<<4+int(data[1])>>4), withSign(flag>>1, int(data[1]&0x0f)<<8+int(data[2]))
+	}
+	data := r.bytes(4)
+	if data == nil {
+		return 0, 0
+	}
+	return withSign(flag, int(data[0])<<8+int(data[1])), withSign(flag>>1, int(data[2])<<8+int(data[3]))
+}
+
+// appendSimpleGlyph appends a simple glyph in the format of
+// the glyf table
+func appendSimpleGlyph(glyf []byte, bbox [4]int16, ends []int, instrs []byte, points []woff2Point, overlaps bool) []byte {
+	glyf = append(glyf, byte(len(ends)>>8), byte(len(ends)))
+	for _, v := range bbox {
+		glyf = append(glyf, byte(v>>8), byte(v))
+	}
+	for _, end := range ends {
+		glyf = append(glyf, byte(end>>8), byte(end))
+	}
+	glyf = append(glyf, byte(len(instrs)>>8), byte(len(instrs)))
+	glyf = append(glyf, instrs...)
+
+	var xs, ys []byte
+	x, y := 0, 0
+	for i, p := range points {
+		var flag byte
+		if p.on {
+			flag |= 0x01
+		}
+		if i == 0 && overlaps {
+			flag |= 0x40
+		}
+		flag, xs = appendGlyphCoord(flag, xs, p.x-x, 0x02, 0x10)
+		flag, ys = appendGlyphCoord(flag, ys, p.y-y, 0x04, 0x20)
+		glyf = append(glyf, flag)
+		x, y = p.x, p.y
+	}
+	glyf = append(glyf, xs...)
+	return append(glyf, ys...)
+}
+
+// appendGlyphCoord appends a coordinate delta of a simple
+// glyph as a byte if it is short, or leaves it out if it is
+// zero, and sets the flags accordingly
+func appendGlyphCoord(flag byte, coords []byte, d int, short, same byte) (byte, []byte) {
+	switch {
+	case d == 0:
+		return flag | same, coords
+	case d > 0 && d < 256:
+		return flag | short | same, append(coords, byte(d))
+	case d < 0 && d > -256:
+		return flag | short, append(coords, byte(-d))
+	}
+	return flag, append(coords, byte(d>>8), byte(d))
+}
+
+// woff2Hmtx rebuilds the hmtx table from the transformed one,
+// where the left side bearings can be left out if they are
+// equal to the minimum x of the glyphs
+func woff2Hmtx(data []byte, numGlyphs, numHMetrics int, xMins []int16) ([]byte, error) {
+	if numHMetrics < 1 || numHMetrics > numGlyphs || len(xMins) < numGlyphs {
+		return nil, errFontFormat
+	}
+	r := fontReader{data: data}
+	flags := r.u8()
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = r.u16()
+	}
+	lsbs := make([]int16, numGlyphs)
+	for i := range lsbs {
+		if (i < numHMetrics && flags&1 == 0) || (i >= numHMetrics && flags&2 == 0) {
+			lsbs[i] = int16(r.u16())
+		} else {
+			lsbs[i] = xMins[i]
+		}
+	}
+	if r.err {
+		return nil, errFontFormat
+	}
+
+	out := make([]byte, 0, 2*numHMetrics+2*numGlyphs)
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			out = append(out, byte(advances[i]>>8), byte(advances[i]))
+		}
+		out = append(out, byte(lsb>>8), byte(lsb))
+	}
+	return out, nil
+}
+
+func minInt16(a, b int16) int16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt16(a, b int16) int16 {
+	if a > b {
+		return a
+	}
+	return b
+}